@@ -4,8 +4,8 @@ import (
 	"strconv"
 
 	"github.com/ego-component/egorm"
-	"github.com/google/uuid"
 	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
 	"github.com/spf13/cast"
 
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
@@ -17,6 +17,8 @@ import (
 	"github.com/clickvisual/clickvisual/api/pkg/constx"
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+	"github.com/clickvisual/clickvisual/api/pkg/utils"
 )
 
 func Create(c *core.Context) {
@@ -25,18 +27,13 @@ func Create(c *core.Context) {
 		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
 		return
 	}
-	var tid int
-	for _, f := range req.Filters {
-		if f.SetOperatorTyp == 0 {
-			if tid != 0 {
-				c.JSONE(1, "invalid parameter: only one default table allowed", nil)
-				return
-			}
-			tid = f.Tid
-		}
+	if err := validateMessageTemplates(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
 	}
-	if tid == 0 {
-		c.JSONE(1, "invalid parameter: tid should above zero", nil)
+	tid, err := filtersTid(req.Filters)
+	if err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
 		return
 	}
 	tableInfo, err := db.TableInfo(invoker.Db, tid)
@@ -52,38 +49,13 @@ func Create(c *core.Context) {
 		c.JSONE(1, err.Error(), nil)
 		return
 	}
-	if len(req.Filters) > 0 {
-		req.Mode = req.Filters[0].Mode
-	}
-	tx := invoker.Db.Begin()
-	obj := &db.Alarm{
-		Tid:        tid,
-		Uuid:       uuid.NewString(),
-		Name:       req.Name,
-		Desc:       req.Desc,
-		Interval:   req.Interval,
-		Unit:       req.Unit,
-		Tags:       req.Tags,
-		NoDataOp:   req.NoDataOp,
-		ChannelIds: db.Ints(req.ChannelIds),
-		Uid:        c.Uid(),
-		Mode:       req.Mode,
-		Level:      req.Level,
-	}
-	if err = db.AlarmCreate(tx, obj); err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm create failed 01: "+err.Error(), nil)
+	if !service.InstanceCapability(tableInfo.Database.Iid).Alarm {
+		c.JSONE(1, constx.ErrDatasourceNotSupported.Error(), nil)
 		return
 	}
-	err = service.Alarm.CreateOrUpdate(tx, obj, req)
+	obj, err := service.Alarm.Create(c.Uid(), tid, "", req)
 	if err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm create failed 02: "+err.Error(), nil)
-		return
-	}
-	if err = tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm create failed 03: "+err.Error(), nil)
+		c.JSONE(1, err.Error(), nil)
 		return
 	}
 	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsCreate, map[string]interface{}{"obj": obj})
@@ -105,6 +77,10 @@ func Update(c *core.Context) {
 		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
 		return
 	}
+	if err = validateMessageTemplates(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
 
 	instanceInfo, tableInfo, alarmInfo, errAlarmInfo := db.GetAlarmTableInstanceInfo(id)
 	if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
@@ -119,6 +95,11 @@ func Update(c *core.Context) {
 		c.JSONE(1, err.Error(), nil)
 		return
 	}
+	// the alarms of the datasources without alarm support can only be closed
+	if req.Status != db.AlarmStatusClose && !service.InstanceCapability(tableInfo.Database.Iid).Alarm {
+		c.JSONE(1, constx.ErrDatasourceNotSupported.Error(), nil)
+		return
+	}
 
 	switch req.Status {
 	case db.AlarmStatusOpen:
@@ -133,9 +114,11 @@ func Update(c *core.Context) {
 			c.JSONE(core.CodeErr, errInstanceManager.Error(), nil)
 			return
 		}
-		if err = op.AlertViewDrop(alarmInfo.ViewTableName, tableInfo.Database.Cluster); err != nil {
-			c.JSONE(1, "alarm update failed when delete metrics view: "+err.Error(), nil)
-			return
+		if alarmInfo.ViewTableName != "" {
+			if err = op.AlertViewDrop(alarmInfo.ViewTableName, tableInfo.Database.Cluster); err != nil {
+				c.JSONE(1, "alarm update failed when delete metrics view: "+err.Error(), nil)
+				return
+			}
 		}
 		if err = service.Alarm.PrometheusRuleDelete(&instanceInfo, &alarmInfo); err != nil {
 			c.JSONE(1, "alarm update failed 03: prometheus rule delete failed:"+err.Error(), nil)
@@ -164,6 +147,7 @@ func List(c *core.Context) {
 	tid, _ := strconv.Atoi(c.Query("tid"))
 	did, _ := strconv.Atoi(c.Query("did"))
 	status, _ := strconv.Atoi(c.Query("status"))
+	templateId, _ := strconv.Atoi(c.Query("templateId"))
 	query := egorm.Conds{}
 	if name != "" {
 		query["name"] = egorm.Cond{
@@ -174,6 +158,9 @@ func List(c *core.Context) {
 	if status != 0 {
 		query["status"] = status
 	}
+	if templateId != 0 {
+		query["template_id"] = templateId
+	}
 	var (
 		total int64
 		list  []*db.Alarm
@@ -316,43 +303,10 @@ func Delete(c *core.Context) {
 		c.JSONE(1, err.Error(), nil)
 		return
 	}
-	tx := invoker.Db.Begin()
-	if err = db.AlarmDelete(tx, id); err != nil {
+	if err = service.Alarm.Delete(instanceInfo, tableInfo, alarmInfo); err != nil {
 		c.JSONE(1, "alarm failed to delete 02: "+err.Error(), nil)
 		return
 	}
-	// filter
-	if err = db.AlarmFilterDeleteBatch(tx, id); err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm failed to delete 03: "+err.Error(), nil)
-		return
-	}
-	// condition
-	if err = db.AlarmConditionDeleteBatch(tx, id); err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm failed to delete 04: "+err.Error(), nil)
-		return
-	}
-	if err = service.Alarm.PrometheusRuleDelete(&instanceInfo, &alarmInfo); err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm failed to delete 05: "+err.Error(), nil)
-		return
-	}
-	op, err := service.InstanceManager.Load(tableInfo.Database.Iid)
-	if err != nil {
-		c.JSONE(core.CodeErr, err.Error(), nil)
-		return
-	}
-	if err = op.AlertViewDrop(alarmInfo.ViewTableName, tableInfo.Database.Cluster); err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm failed to delete 06: "+err.Error(), nil)
-		return
-	}
-	if err = tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm failed to delete 07"+err.Error(), nil)
-		return
-	}
 	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsDelete, map[string]interface{}{"alarmInfo": alarmInfo})
 	c.JSONOK()
 }
@@ -409,3 +363,171 @@ func HistoryInfo(c *core.Context) {
 	c.JSONE(core.CodeOK, "succ", res)
 	return
 }
+
+// HistoryTimeline returns the notifications and the incidents of an alarm
+func HistoryTimeline(c *core.Context) {
+	var req view.ReqAlarmHistoryStats
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if req.AlarmId == 0 {
+		c.JSONE(1, "invalid parameter: alarmId is required", nil)
+		return
+	}
+	if ids := permittedAlarmIds(c.Uid()); ids != nil && !utils.IntSliceContains(ids, req.AlarmId) {
+		c.JSONE(1, "permission denied", nil)
+		return
+	}
+	service.HistoryStatsRange(&req)
+	res, err := service.AlarmTimeline(req.AlarmId, req.StartTime, req.EndTime)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// HistoryMTTR returns the mean time to resolve of the alarms
+func HistoryMTTR(c *core.Context) {
+	var req view.ReqAlarmHistoryStats
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	ids, ok := statsAlarmIds(c.Uid(), req.AlarmId)
+	if !ok {
+		c.JSONOK([]view.RespAlarmMTTR{})
+		return
+	}
+	service.HistoryStatsRange(&req)
+	res, err := service.AlarmMTTR(ids, req.StartTime, req.EndTime)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// HistoryNoisiest returns the alarms with the most firing notifications
+func HistoryNoisiest(c *core.Context) {
+	var req view.ReqAlarmHistoryStats
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	ids, ok := statsAlarmIds(c.Uid(), req.AlarmId)
+	if !ok {
+		c.JSONOK([]view.RespAlarmNoisiest{})
+		return
+	}
+	service.HistoryStatsRange(&req)
+	res, err := service.AlarmNoisiest(ids, req.StartTime, req.EndTime, req.Limit)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// statsAlarmIds returns the alarms of the stats, nil means all, ok is false when there is nothing to read
+func statsAlarmIds(uid, alarmId int) (ids []int, ok bool) {
+	ids = permittedAlarmIds(uid)
+	if alarmId != 0 {
+		if ids != nil && !utils.IntSliceContains(ids, alarmId) {
+			return nil, false
+		}
+		return []int{alarmId}, true
+	}
+	if ids != nil && len(ids) == 0 {
+		return nil, false
+	}
+	return ids, true
+}
+
+// permittedAlarmIds returns the ids of the alarms the user can read, nil for root users
+func permittedAlarmIds(uid int) []int {
+	if permission.Manager.IsRootUser(uid) == nil {
+		return nil
+	}
+	ids := make([]int, 0)
+	ts := service.ReadAllPermissionTable(uid, pmsplugin.Alarm)
+	if len(ts) == 0 {
+		return ids
+	}
+	conds := egorm.Conds{}
+	conds["tid"] = egorm.Cond{Op: "in", Val: ts}
+	alarms, _ := db.AlarmList(conds)
+	for _, a := range alarms {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+// Backtest evaluates a draft alarm over the history, nothing is created.
+// It requires the edit permission as Create does, since the sql modes run the sql of the request.
+func Backtest(c *core.Context) {
+	var req view.ReqAlarmBacktest
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tid, err := filtersTid(req.Filters)
+	if err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Alarm,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(tableInfo.ID),
+	}); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if !service.InstanceCapability(tableInfo.Database.Iid).Alarm {
+		c.JSONE(1, constx.ErrDatasourceNotSupported.Error(), nil)
+		return
+	}
+	res, err := service.AlarmBacktest(tid, req)
+	if err != nil {
+		c.JSONE(1, "backtest failed: "+err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// filtersTid returns the table of the default filter
+func filtersTid(filters []view.ReqAlarmFilterCreate) (tid int, err error) {
+	for _, f := range filters {
+		if f.SetOperatorTyp == 0 {
+			if tid != 0 {
+				return 0, errors.New("only one default table allowed")
+			}
+			tid = f.Tid
+		}
+	}
+	if tid == 0 {
+		return 0, errors.New("tid should above zero")
+	}
+	return tid, nil
+}
+
+func validateMessageTemplates(req view.ReqAlarmCreate) error {
+	if err := push.ValidateMessageTemplate(req.FiringTemplate); err != nil {
+		return errors.Wrap(err, "firing template")
+	}
+	if err := push.ValidateMessageTemplate(req.ResolvedTemplate); err != nil {
+		return errors.Wrap(err, "resolved template")
+	}
+	return nil
+}
@@ -34,9 +34,15 @@ func ChannelSendTest(c *core.Context) {
 		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
 		return
 	}
-	if err := service.SendTestToChannel(&req); err != nil {
-		c.JSONE(1, "send test error: "+err.Error(), nil)
+	if err := channelEditPermission(c.Uid()); err != nil {
+		c.JSONE(1, err.Error(), nil)
 		return
 	}
-	c.JSONOK()
+	unmaskChannelKey(req.ID, &req)
+	payload, err := service.SendTestToChannel(&req)
+	if err != nil {
+		c.JSONE(1, "send test error: "+err.Error(), view.RespChannelSendTest{Payload: payload})
+		return
+	}
+	c.JSONOK(view.RespChannelSendTest{Payload: payload})
 }
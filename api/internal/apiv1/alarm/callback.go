@@ -0,0 +1,143 @@
+package alarm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/slack-go/slack"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+// Acknowledge claims the firing alert of the alarm, it is escalated no more
+func Acknowledge(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	if err := checkAlarmScopePermission(c.Uid(), id, 0, 0); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if err := service.AlarmAcknowledge(id, c.Uid(), ""); err != nil {
+		c.JSONE(1, "acknowledge failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsAck, map[string]interface{}{"alarmId": id})
+	c.JSONOK()
+}
+
+// Resolve closes the firing alert of the alarm by hand
+func Resolve(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	if err := checkAlarmScopePermission(c.Uid(), id, 0, 0); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if err := service.AlarmResolve(id, c.Uid(), ""); err != nil {
+		c.JSONE(1, "resolve failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsResolve, map[string]interface{}{"alarmId": id})
+	c.JSONOK()
+}
+
+type feishuCallback struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+	OpenId    string `json:"open_id"`
+	Action    struct {
+		Value push.CallbackValue `json:"value"`
+	} `json:"action"`
+}
+
+// FeishuCallback handles the buttons of the feishu message cards,
+// the request url of the card is verified with alarm.callback.feishuToken when it is set
+func FeishuCallback(c *core.Context) {
+	var req feishuCallback
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Context.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	if token := econf.GetString("alarm.callback.feishuToken"); token != "" && req.Token != token {
+		c.Context.JSON(http.StatusUnauthorized, map[string]interface{}{"error": "invalid token"})
+		return
+	}
+	if req.Type == "url_verification" {
+		c.Context.JSON(http.StatusOK, map[string]interface{}{"challenge": req.Challenge})
+		return
+	}
+	if err := service.AlarmCallback(req.Action.Value, "feishu:"+req.OpenId); err != nil {
+		invoker.Logger.Warn("alarm", elog.String("step", "feishuCallback"), elog.Any("value", req.Action.Value), elog.String("error", err.Error()))
+	}
+	c.Context.JSON(http.StatusOK, map[string]interface{}{})
+}
+
+// SlackCallback handles the buttons of the slack messages,
+// the requests are verified with alarm.callback.slackSigningSecret when it is set
+func SlackCallback(c *core.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if secret := econf.GetString("alarm.callback.slackSigningSecret"); secret != "" {
+		sv, errVerifier := slack.NewSecretsVerifier(c.Request.Header, secret)
+		if errVerifier != nil {
+			c.String(http.StatusUnauthorized, errVerifier.Error())
+			return
+		}
+		_, _ = sv.Write(body)
+		if err = sv.Ensure(); err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	var callback slack.InteractionCallback
+	if err = json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Status(http.StatusOK)
+	if len(callback.ActionCallback.BlockActions) == 0 {
+		return
+	}
+	var value push.CallbackValue
+	if err = json.Unmarshal([]byte(callback.ActionCallback.BlockActions[0].Value), &value); err != nil {
+		return
+	}
+	text := "<@" + callback.User.ID + "> resolved the alert"
+	if value.Action == push.CallbackAcknowledge {
+		text = "<@" + callback.User.ID + "> acknowledged the alert"
+	}
+	if err = service.AlarmCallback(value, "slack:"+callback.User.ID); err != nil {
+		text = "Failed to " + value.Action + " the alert: " + err.Error()
+	}
+	if callback.ResponseURL == "" {
+		return
+	}
+	if err = slack.PostWebhook(callback.ResponseURL, &slack.WebhookMessage{Text: text, ResponseType: "in_channel"}); err != nil {
+		invoker.Logger.Warn("alarm", elog.String("step", "slackCallback"), elog.String("error", err.Error()))
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"errors"
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
 	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
 	"github.com/clickvisual/clickvisual/api/pkg/component/core"
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
 	"github.com/clickvisual/clickvisual/api/pkg/push"
 	"github.com/ego-component/egorm"
 	"github.com/spf13/cast"
+	"strconv"
 	"strings"
 )
 
@@ -33,6 +37,7 @@ func ChannelCreate(c *core.Context) {
 		c.JSONE(1, "create failed: "+err.Error(), nil)
 		return
 	}
+	req.Key = maskChannelKey(req.Typ, req.Key)
 	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsChannelsCreate, map[string]interface{}{"req": req})
 	c.JSONOK()
 }
@@ -40,12 +45,29 @@ func ChannelCreate(c *core.Context) {
 // JudgmentType judgment channel key legality
 // temporary support slack feishu
 func JudgmentType(req db.AlarmChannel) (err error) {
+	if !push.IsLocaleSupported(req.Locale) {
+		return errors.New("unsupported locale: " + req.Locale)
+	}
+	if req.RateLimit < 0 || req.RateWindow < 0 {
+		return errors.New("rateLimit and rateWindow should not be negative")
+	}
 	switch req.Typ {
 	//TODO finish all channels support
 	case push.ChannelDingDing:
 	case push.ChannelWeChat:
 	case push.ChannelTelegram:
 	case push.ChannelEmail:
+		if _, err = push.ParseEmailConfig(req.Key); err != nil {
+			return
+		}
+	case push.ChannelWebhook:
+		if _, err = push.ParseWebhookConfig(req.Key); err != nil {
+			return
+		}
+	case push.ChannelTeams, push.ChannelDiscord, push.ChannelMattermost:
+		if err = push.ValidateWebhookURL(req.Key); err != nil {
+			return
+		}
 	case push.ChannelFeiShu:
 		if !strings.Contains(req.Key, FEISHUURL) {
 			err = errors.New("invalid FeiShu webhook url")
@@ -71,6 +93,7 @@ func ChannelUpdate(c *core.Context) {
 		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
 		return
 	}
+	unmaskChannelKey(id, &req)
 	if err := JudgmentType(req); err != nil {
 		c.JSONE(1, err.Error(), nil)
 		return
@@ -79,11 +102,15 @@ func ChannelUpdate(c *core.Context) {
 	ups["name"] = req.Name
 	ups["typ"] = req.Typ
 	ups["key"] = req.Key
+	ups["locale"] = req.Locale
+	ups["rate_limit"] = req.RateLimit
+	ups["rate_window"] = req.RateWindow
 	ups["uid"] = c.Uid()
 	if err := db.AlarmChannelUpdate(invoker.Db, id, ups); err != nil {
 		c.JSONE(1, "update failed: "+err.Error(), nil)
 		return
 	}
+	req.Key = maskChannelKey(req.Typ, req.Key)
 	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsChannelsUpdate, map[string]interface{}{"req": req})
 	c.JSONOK()
 }
@@ -94,6 +121,9 @@ func ChannelList(c *core.Context) {
 		c.JSONE(core.CodeErr, err.Error(), nil)
 		return
 	}
+	for _, ch := range res {
+		ch.Key = maskChannelKey(ch.Typ, ch.Key)
+	}
 	c.JSONE(core.CodeOK, "succ", res)
 	return
 }
@@ -105,6 +135,7 @@ func ChannelDelete(c *core.Context) {
 		return
 	}
 	alarmInfo, _ := db.AlarmChannelInfo(invoker.Db, id)
+	alarmInfo.Key = maskChannelKey(alarmInfo.Typ, alarmInfo.Key)
 	if err := db.AlarmChannelDelete(invoker.Db, id); err != nil {
 		c.JSONE(1, "failed to delete: "+err.Error(), nil)
 		return
@@ -124,6 +155,51 @@ func ChannelInfo(c *core.Context) {
 		c.JSONE(core.CodeErr, err.Error(), nil)
 		return
 	}
+	res.Key = maskChannelKey(res.Typ, res.Key)
 	c.JSONE(core.CodeOK, "succ", res)
 	return
 }
+
+// channelEditPermission allows the root users and the users who edit the alarms of an instance,
+// the channels are shared by all the alarms, and their tests send messages to any url.
+func channelEditPermission(uid int) error {
+	if permission.Manager.IsRootUser(uid) == nil {
+		return nil
+	}
+	instances, err := db.InstanceList(egorm.Conds{})
+	if err != nil {
+		return err
+	}
+	for _, ins := range instances {
+		if permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      uid,
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(ins.ID),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{pmsplugin.ActEdit},
+		}) == nil {
+			return nil
+		}
+	}
+	return errors.New("permission denied: alarm edit permission is required to test channels")
+}
+
+// maskChannelKey hides the secrets in the key of the channel before it leaves the server
+func maskChannelKey(typ int, key string) string {
+	if typ == push.ChannelEmail {
+		return push.MaskEmailKey(key)
+	}
+	return key
+}
+
+// unmaskChannelKey keeps the stored secrets of the channel when the request sends the masks back
+func unmaskChannelKey(id int, req *db.AlarmChannel) {
+	if id == 0 || req.Typ != push.ChannelEmail {
+		return
+	}
+	stored, err := db.AlarmChannelInfo(invoker.Db, id)
+	if err != nil {
+		return
+	}
+	req.Key = push.UnmaskEmailKey(req.Key, stored.Key)
+}
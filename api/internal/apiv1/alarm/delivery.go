@@ -0,0 +1,86 @@
+package alarm
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/utils"
+)
+
+// DeliveryList returns the deliveries of the outbox, status 2 lists the dead letters
+func DeliveryList(c *core.Context) {
+	var req view.ReqAlarmDeliveryList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	ids, ok := statsAlarmIds(c.Uid(), req.AlarmId)
+	if !ok {
+		c.JSONPage([]*db.AlarmDelivery{}, core.Pagination{Current: req.Current, PageSize: req.PageSize})
+		return
+	}
+	conds := egorm.Conds{}
+	if ids != nil {
+		conds["alarm_id"] = egorm.Cond{Op: "in", Val: ids}
+	}
+	if req.HistoryId != 0 {
+		conds["history_id"] = req.HistoryId
+	}
+	if req.Status != nil {
+		conds["status"] = *req.Status
+	}
+	total, list := db.AlarmDeliveryPage(conds, &req.ReqPage)
+	c.JSONPage(list, core.Pagination{
+		Current:  req.Current,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func DeliveryInfo(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	res, err := db.AlarmDeliveryInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if ids := permittedAlarmIds(c.Uid()); ids != nil && !utils.IntSliceContains(ids, res.AlarmId) {
+		c.JSONE(1, "permission denied", nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// DeliveryReplay sends a failed or dead letter delivery again
+func DeliveryReplay(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	delivery, err := db.AlarmDeliveryInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "delivery not found: "+err.Error(), nil)
+		return
+	}
+	if err = checkAlarmScopePermission(c.Uid(), delivery.AlarmId, 0, 0); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if err = service.DeliveryReplay(&delivery); err != nil {
+		c.JSONE(1, "replay failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsDeliveryReplay, map[string]interface{}{"id": id, "alarmId": delivery.AlarmId, "channelId": delivery.ChannelId})
+	c.JSONOK()
+}
@@ -0,0 +1,136 @@
+package alarm
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ego-component/egorm"
+	"sigs.k8s.io/yaml"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/utils"
+)
+
+// Export returns the yaml document of the readable alarms, optionally of an instance, a database or a table
+func Export(c *core.Context) {
+	iid, _ := strconv.Atoi(c.Query("iid"))
+	did, _ := strconv.Atoi(c.Query("did"))
+	tid, _ := strconv.Atoi(c.Query("tid"))
+	tids, err := exportTids(iid, did, tid)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if permission.Manager.IsRootUser(c.Uid()) != nil {
+		permitted := service.ReadAllPermissionTable(c.Uid(), pmsplugin.Alarm)
+		if tids == nil {
+			tids = permitted
+		} else {
+			readable := make([]int, 0, len(tids))
+			for _, t := range tids {
+				if utils.IntSliceContains(permitted, t) {
+					readable = append(readable, t)
+				}
+			}
+			tids = readable
+		}
+	}
+	alarms := make([]*db.Alarm, 0)
+	if tids == nil || len(tids) > 0 {
+		conds := egorm.Conds{}
+		if tids != nil {
+			conds["tid"] = egorm.Cond{Op: "in", Val: tids}
+		}
+		if alarms, err = db.AlarmList(conds); err != nil {
+			c.JSONE(1, err.Error(), nil)
+			return
+		}
+	}
+	doc, err := service.AlarmExport(alarms)
+	if err != nil {
+		c.JSONE(1, "alarm export failed: "+err.Error(), nil)
+		return
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		c.JSONE(1, "alarm export failed: "+err.Error(), nil)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="alarms.yaml"`)
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", out)
+}
+
+// exportTids returns the tables of the scope, nil for all the tables
+func exportTids(iid, did, tid int) ([]int, error) {
+	if tid != 0 {
+		return []int{tid}, nil
+	}
+	conds := egorm.Conds{}
+	if did != 0 {
+		conds["did"] = did
+	} else if iid != 0 {
+		databases, err := db.DatabaseList(invoker.Db, egorm.Conds{"iid": iid})
+		if err != nil {
+			return nil, err
+		}
+		dids := make([]int, 0, len(databases))
+		for _, d := range databases {
+			dids = append(dids, d.ID)
+		}
+		if len(dids) == 0 {
+			return []int{}, nil
+		}
+		conds["did"] = egorm.Cond{Op: "in", Val: dids}
+	} else {
+		return nil, nil
+	}
+	tables, err := db.TableList(invoker.Db, conds)
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(tables))
+	for _, t := range tables {
+		tids = append(tids, t.ID)
+	}
+	return tids, nil
+}
+
+// Import applies the yaml document of the alarms, nothing is changed for dry runs
+func Import(c *core.Context) {
+	var req view.ReqAlarmImport
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	res, err := service.AlarmImport(c.Uid(), req, alarmTableCheck(c))
+	if err != nil {
+		c.JSONE(1, "alarm import failed: "+err.Error(), res)
+		return
+	}
+	if !req.DryRun {
+		event.Event.AlarmCMDB(c.User(), db.OpnAlarmsImport, map[string]interface{}{"items": res.Items, "prune": req.Prune})
+	}
+	c.JSONOK(res)
+}
+
+// alarmTableCheck checks the permission of the user on the alarms of the table
+func alarmTableCheck(c *core.Context) func(table db.BaseTable, act string) error {
+	return func(table db.BaseTable, act string) error {
+		return permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      c.Uid(),
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(table.Database.Iid),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{act},
+			DomainType:  pmsplugin.PrefixTable,
+			DomainId:    strconv.Itoa(table.ID),
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package alarm
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func EscalationCreate(c *core.Context) {
+	var req view.ReqAlarmEscalationCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if err := service.EscalationValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	obj := &db.AlarmEscalation{
+		Name:  req.Name,
+		Desc:  req.Desc,
+		Steps: req.Steps,
+		Uid:   c.Uid(),
+	}
+	if err := db.AlarmEscalationCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsEscalationsCreate, map[string]interface{}{"obj": obj})
+	c.JSONOK(obj)
+}
+
+func EscalationUpdate(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmEscalationCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if err := service.EscalationValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if _, err := db.AlarmEscalationInfo(invoker.Db, id); err != nil {
+		c.JSONE(1, "escalation policy not found: "+err.Error(), nil)
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["name"] = req.Name
+	ups["desc"] = req.Desc
+	ups["steps"] = db.EscalationSteps(req.Steps)
+	if err := db.AlarmEscalationUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsEscalationsUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+func EscalationList(c *core.Context) {
+	var req view.ReqAlarmEscalationList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	conds := egorm.Conds{}
+	if req.Name != "" {
+		conds["name"] = egorm.Cond{Op: "like", Val: req.Name}
+	}
+	total, list := db.AlarmEscalationPage(conds, &req.ReqPage)
+	c.JSONPage(list, core.Pagination{
+		Current:  req.Current,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func EscalationInfo(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	res, err := db.AlarmEscalationInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// EscalationDelete deletes the policy that no alarm uses
+func EscalationDelete(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	escalation, err := db.AlarmEscalationInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "escalation policy not found: "+err.Error(), nil)
+		return
+	}
+	alarms, err := db.AlarmList(egorm.Conds{"escalation_id": id})
+	if err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	if len(alarms) > 0 {
+		c.JSONE(1, "failed to delete: the escalation policy is used by alarm "+alarms[0].Name, nil)
+		return
+	}
+	if err = db.AlarmEscalationDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsEscalationsDelete, map[string]interface{}{"escalation": escalation})
+	c.JSONOK()
+}
@@ -0,0 +1,173 @@
+package alarm
+
+import (
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func OncallCreate(c *core.Context) {
+	var req view.ReqAlarmOncallCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if req.HandoffAt == 0 {
+		req.HandoffAt = time.Now().Unix()
+	}
+	if err := service.OncallValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	obj := &db.AlarmOncall{
+		Name:      req.Name,
+		Desc:      req.Desc,
+		Uids:      req.Uids,
+		Rotation:  req.Rotation,
+		HandoffAt: req.HandoffAt,
+		Overrides: req.Overrides,
+		Uid:       c.Uid(),
+	}
+	if err := db.AlarmOncallCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsOncallsCreate, map[string]interface{}{"obj": obj})
+	c.JSONOK(obj)
+}
+
+func OncallUpdate(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmOncallCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	oncall, err := db.AlarmOncallInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "on-call schedule not found: "+err.Error(), nil)
+		return
+	}
+	if req.HandoffAt == 0 {
+		req.HandoffAt = oncall.HandoffAt
+	}
+	if err = service.OncallValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["name"] = req.Name
+	ups["desc"] = req.Desc
+	ups["uids"] = db.Ints(req.Uids)
+	ups["rotation"] = req.Rotation
+	ups["handoff_at"] = req.HandoffAt
+	ups["overrides"] = db.OncallOverrides(req.Overrides)
+	if err = db.AlarmOncallUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsOncallsUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+func OncallList(c *core.Context) {
+	var req view.ReqAlarmOncallList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	conds := egorm.Conds{}
+	if req.Name != "" {
+		conds["name"] = egorm.Cond{Op: "like", Val: req.Name}
+	}
+	total, list := db.AlarmOncallPage(conds, &req.ReqPage)
+	c.JSONPage(list, core.Pagination{
+		Current:  req.Current,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func OncallInfo(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	res, err := db.AlarmOncallInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// OncallCurrent returns the user on call of the schedule, now or at the time
+func OncallCurrent(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	oncall, err := db.AlarmOncallInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	at := time.Now()
+	if ts := cast.ToInt64(c.Query("time")); ts != 0 {
+		at = time.Unix(ts, 0)
+	}
+	res := view.RespAlarmOncallCurrent{Uid: oncall.OncallAt(at)}
+	if res.Uid != 0 {
+		user, errUser := db.UserInfo(res.Uid)
+		if errUser != nil {
+			c.JSONE(1, errUser.Error(), nil)
+			return
+		}
+		user.Password = "*"
+		res.User = &user
+	}
+	c.JSONOK(res)
+}
+
+// OncallDelete deletes the schedule that no alarm uses
+func OncallDelete(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	oncall, err := db.AlarmOncallInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "on-call schedule not found: "+err.Error(), nil)
+		return
+	}
+	alarms, err := db.AlarmList(egorm.Conds{"oncall_id": id})
+	if err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	if len(alarms) > 0 {
+		c.JSONE(1, "failed to delete: the on-call schedule is used by alarm "+alarms[0].Name, nil)
+		return
+	}
+	if err = db.AlarmOncallDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsOncallsDelete, map[string]interface{}{"oncall": oncall})
+	c.JSONOK()
+}
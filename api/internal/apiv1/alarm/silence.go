@@ -0,0 +1,231 @@
+package alarm
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func SilenceCreate(c *core.Context) {
+	var req view.ReqAlarmSilenceCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if req.StartsAt == 0 {
+		req.StartsAt = time.Now().Unix()
+	}
+	if err := service.SilenceValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if err := checkAlarmScopePermission(c.Uid(), req.AlarmId, req.Tid, req.Iid); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	obj := &db.AlarmSilence{
+		AlarmId:  req.AlarmId,
+		Tid:      req.Tid,
+		Iid:      req.Iid,
+		Tags:     req.Tags,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+		Cron:     req.Cron,
+		Duration: req.Duration,
+		Comment:  req.Comment,
+		Uid:      c.Uid(),
+	}
+	if err := db.AlarmSilenceCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsSilencesCreate, map[string]interface{}{"obj": obj})
+	c.JSONOK(obj)
+}
+
+func SilenceUpdate(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmSilenceCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	silence, err := db.AlarmSilenceInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "silence not found: "+err.Error(), nil)
+		return
+	}
+	if req.StartsAt == 0 {
+		req.StartsAt = silence.StartsAt
+	}
+	if err = service.SilenceValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	// permissions of both the current and the new matchers are required
+	if err = checkAlarmScopePermission(c.Uid(), silence.AlarmId, silence.Tid, silence.Iid); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if err = checkAlarmScopePermission(c.Uid(), req.AlarmId, req.Tid, req.Iid); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["alarm_id"] = req.AlarmId
+	ups["tid"] = req.Tid
+	ups["iid"] = req.Iid
+	ups["tags"] = db.String2String(req.Tags)
+	ups["starts_at"] = req.StartsAt
+	ups["ends_at"] = req.EndsAt
+	ups["cron"] = req.Cron
+	ups["duration"] = req.Duration
+	ups["comment"] = req.Comment
+	if err = db.AlarmSilenceUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsSilencesUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+// SilenceExpire ends the silence now
+func SilenceExpire(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	silence, err := db.AlarmSilenceInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "silence not found: "+err.Error(), nil)
+		return
+	}
+	if err = checkAlarmScopePermission(c.Uid(), silence.AlarmId, silence.Tid, silence.Iid); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	ups := map[string]interface{}{"ends_at": time.Now().Unix()}
+	if silence.StartsAt > time.Now().Unix() {
+		ups["starts_at"] = time.Now().Unix()
+	}
+	if err = db.AlarmSilenceUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "expire failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsSilencesUpdate, map[string]interface{}{"id": id, "expire": true})
+	c.JSONOK()
+}
+
+func SilenceList(c *core.Context) {
+	var req view.ReqAlarmSilenceList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	conds := egorm.Conds{}
+	if req.AlarmId != 0 {
+		conds["alarm_id"] = req.AlarmId
+	}
+	switch req.Expired {
+	case 0:
+		conds["ends_at"] = egorm.Cond{Op: ">", Val: time.Now().Unix()}
+	case 1:
+		conds["ends_at"] = egorm.Cond{Op: "<=", Val: time.Now().Unix()}
+	}
+	total, list := db.AlarmSilencePage(conds, &req.ReqPage)
+	c.JSONPage(list, core.Pagination{
+		Current:  req.Current,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func SilenceInfo(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	res, err := db.AlarmSilenceInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+func SilenceDelete(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	silence, err := db.AlarmSilenceInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "silence not found: "+err.Error(), nil)
+		return
+	}
+	if err = checkAlarmScopePermission(c.Uid(), silence.AlarmId, silence.Tid, silence.Iid); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if err = db.AlarmSilenceDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsSilencesDelete, map[string]interface{}{"silence": silence})
+	c.JSONOK()
+}
+
+// checkAlarmScopePermission checks the alarm edit permission of the scope of an alarm, a table or an instance,
+// the scope of all the instances is allowed for root users only
+func checkAlarmScopePermission(uid, alarmId, tid, iid int) error {
+	if alarmId != 0 {
+		alarmInfo, err := db.AlarmInfo(invoker.Db, alarmId)
+		if err != nil {
+			return err
+		}
+		tid = alarmInfo.Tid
+	}
+	if tid != 0 {
+		tableInfo, err := db.TableInfo(invoker.Db, tid)
+		if err != nil {
+			return err
+		}
+		return permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      uid,
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{pmsplugin.ActEdit},
+			DomainType:  pmsplugin.PrefixTable,
+			DomainId:    strconv.Itoa(tableInfo.ID),
+		})
+	}
+	if iid != 0 {
+		return permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      uid,
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(iid),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{pmsplugin.ActEdit},
+		})
+	}
+	return permission.Manager.IsRootUser(uid)
+}
@@ -0,0 +1,159 @@
+package alarm
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TemplateCreate(c *core.Context) {
+	var req view.ReqAlarmTemplateCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if err := service.AlarmTemplateValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	obj := &db.AlarmTemplate{
+		Name:      req.Name,
+		Desc:      req.Desc,
+		Content:   req.Content,
+		Variables: req.Variables,
+		Uid:       c.Uid(),
+	}
+	if err := db.AlarmTemplateCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsTemplatesCreate, map[string]interface{}{"obj": obj})
+	c.JSONOK(obj)
+}
+
+// TemplateUpdate updates the template and the alarms derived from it, nothing is changed for dry runs
+func TemplateUpdate(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmTemplateUpdate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if err := service.AlarmTemplateValidate(req.ReqAlarmTemplateCreate); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tpl, err := db.AlarmTemplateInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "alarm template not found: "+err.Error(), nil)
+		return
+	}
+	res, err := service.AlarmTemplateUpdate(c.Uid(), tpl, req, alarmTableCheck(c))
+	if err != nil {
+		c.JSONE(1, "update failed: "+err.Error(), res)
+		return
+	}
+	if !req.DryRun {
+		event.Event.AlarmCMDB(c.User(), db.OpnAlarmsTemplatesUpdate, map[string]interface{}{"req": req, "items": res.Items})
+	}
+	c.JSONOK(res)
+}
+
+func TemplateList(c *core.Context) {
+	var req view.ReqAlarmTemplateList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	conds := egorm.Conds{}
+	if req.Name != "" {
+		conds["name"] = egorm.Cond{Op: "like", Val: req.Name}
+	}
+	total, list := db.AlarmTemplatePage(conds, &req.ReqPage)
+	c.JSONPage(list, core.Pagination{
+		Current:  req.Current,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func TemplateInfo(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	res, err := db.AlarmTemplateInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// TemplateApply creates or updates the alarms of the template on the selected tables, nothing is changed for dry runs
+func TemplateApply(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmTemplateApply
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tpl, err := db.AlarmTemplateInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "alarm template not found: "+err.Error(), nil)
+		return
+	}
+	res, err := service.AlarmTemplateApply(c.Uid(), &tpl, req, alarmTableCheck(c))
+	if err != nil {
+		c.JSONE(1, "apply failed: "+err.Error(), res)
+		return
+	}
+	if !req.DryRun {
+		event.Event.AlarmCMDB(c.User(), db.OpnAlarmsTemplatesApply, map[string]interface{}{"template": tpl.Name, "req": req, "items": res.Items})
+	}
+	c.JSONOK(res)
+}
+
+// TemplateDelete deletes the template that no alarm is derived from
+func TemplateDelete(c *core.Context) {
+	id := cast.ToInt(c.Param("id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	tpl, err := db.AlarmTemplateInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "alarm template not found: "+err.Error(), nil)
+		return
+	}
+	alarms, err := db.AlarmList(egorm.Conds{"template_id": id})
+	if err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	if len(alarms) > 0 {
+		c.JSONE(1, "failed to delete: alarm "+alarms[0].Name+" is derived from the template", nil)
+		return
+	}
+	if err = db.AlarmTemplateDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "failed to delete: "+err.Error(), nil)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db.OpnAlarmsTemplatesDelete, map[string]interface{}{"template": tpl})
+	c.JSONOK()
+}
@@ -0,0 +1,87 @@
+package base
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/constx"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+const ingestDefaultMaxBodyBytes = 32 << 20
+
+// TableIngest writes NDJSON log lines into the data table, the body can be gzip compressed.
+func TableIngest(c *core.Context) {
+	tid := cast.ToInt(c.Param("id"))
+	if tid == 0 {
+		c.JSONE(core.CodeErr, "invalid parameter", nil)
+		return
+	}
+	token, ok := service.Ingest.Token(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if !ok || !token.AllowTable(tid) {
+		c.JSON(http.StatusUnauthorized, core.Res{Code: core.CodeErr, Msg: "token error"})
+		return
+	}
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil || tableInfo.ID == 0 {
+		c.JSONE(core.CodeErr, "table not found", nil)
+		return
+	}
+	schema, err := service.Ingest.Schema(tableInfo)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), nil)
+		return
+	}
+	maxBodyBytes := econf.GetInt64("ingest.maxBodyBytes")
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = ingestDefaultMaxBodyBytes
+	}
+	var body io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+	if c.GetHeader("Content-Encoding") == "gzip" {
+		gr, errGzip := gzip.NewReader(body)
+		if errGzip != nil {
+			c.JSONE(core.CodeErr, "invalid gzip body: "+errGzip.Error(), nil)
+			return
+		}
+		defer func() { _ = gr.Close() }()
+		body = io.LimitReader(gr, maxBodyBytes)
+	}
+	rows, err := service.Ingest.Parse(schema, body)
+	if err != nil {
+		c.JSONE(core.CodeErr, "invalid body: "+err.Error(), nil)
+		return
+	}
+	if err = service.Ingest.Allow(token, len(rows)); err != nil {
+		c.JSON(ingestStatus(err), core.Res{Code: core.CodeErr, Msg: err.Error()})
+		return
+	}
+	if err = service.Ingest.Push(schema, rows); err != nil {
+		if status := ingestStatus(err); status != http.StatusInternalServerError {
+			c.JSON(status, core.Res{Code: core.CodeErr, Msg: err.Error()})
+			return
+		}
+		c.JSONE(core.CodeErr, err.Error(), nil)
+		return
+	}
+	c.JSONOK(map[string]int{"accepted": len(rows)})
+}
+
+// ingestStatus is the http status of the ingestion errors, the batch larger than the burst can not be retried as is
+func ingestStatus(err error) int {
+	switch {
+	case errors.Is(err, constx.ErrIngestBatchTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, constx.ErrIngestRateLimited), errors.Is(err, constx.ErrIngestBufferFull):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
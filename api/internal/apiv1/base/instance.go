@@ -56,6 +56,10 @@ func InstanceUpdate(c *core.Context) {
 		c.JSONE(1, err.Error(), nil)
 		return
 	}
+	if err := service.RuleStoreValidate(req); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
 	req.PrometheusTarget = strings.TrimSpace(req.PrometheusTarget)
 	if req.PrometheusTarget != "" {
 		if err := service.Alarm.PrometheusReload(req.PrometheusTarget); err != nil {
@@ -111,6 +115,7 @@ func InstanceUpdate(c *core.Context) {
 		ups["desc"] = req.Desc
 	}
 	ups["clusters"] = req.Clusters
+	ups["rule_labels"] = req.RuleLabels
 	ups["prometheus_target"] = req.PrometheusTarget
 	if err = db.InstanceUpdate(invoker.Db, id, ups); err != nil {
 		c.JSONE(1, "update failed: "+err.Error(), nil)
@@ -121,12 +126,12 @@ func InstanceUpdate(c *core.Context) {
 }
 
 func InstanceList(c *core.Context) {
-	res := make([]*db.BaseInstance, 0)
+	res := make([]view.RespInstance, 0)
 	tmp, err := db.InstanceList(egorm.Conds{})
 	for _, row := range tmp {
 		if service.InstanceViewIsPermission(c.Uid(), row.ID) {
 			row.Dsn = "*"
-			res = append(res, row)
+			res = append(res, view.RespInstance{BaseInstance: row, Capability: service.DatasourceCapability(row.Datasource)})
 		}
 	}
 	if err != nil {
@@ -137,6 +142,11 @@ func InstanceList(c *core.Context) {
 	return
 }
 
+// DatasourceList returns the supported datasource types and their capabilities
+func DatasourceList(c *core.Context) {
+	c.JSONOK(service.Datasources())
+}
+
 func InstanceInfo(c *core.Context) {
 	id := cast.ToInt(c.Param("id"))
 	if id == 0 {
@@ -152,7 +162,7 @@ func InstanceInfo(c *core.Context) {
 		c.JSONE(core.CodeErr, err.Error(), nil)
 		return
 	}
-	c.JSONE(core.CodeOK, "succ", res)
+	c.JSONE(core.CodeOK, "succ", view.RespInstance{BaseInstance: &res, Capability: service.DatasourceCapability(res.Datasource)})
 	return
 }
 
@@ -196,9 +206,19 @@ func InstanceTest(c *core.Context) {
 		c.JSONE(1, err.Error(), nil)
 		return
 	}
-	if _, err := service.ClickHouseLink(req.Dsn); err != nil {
+	if req.Datasource == "" {
+		req.Datasource = db.DatasourceClickHouse
+	}
+	backend, ok := service.LoadBackend(req.Datasource)
+	if !ok {
+		c.JSONE(1, "unsupported datasource: "+req.Datasource, nil)
+		return
+	}
+	conn, err := backend.Link(req.Dsn)
+	if err != nil {
 		c.JSONE(1, "connection failure: "+err.Error(), nil)
 		return
 	}
+	_ = conn.Close()
 	c.JSONOK()
 }
@@ -0,0 +1,347 @@
+package es
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const (
+	defaultSize      = 10
+	maxResultWindow  = 10000 // from + size of a search, the default index.max_result_window of es
+	defaultTermsSize = 10
+	maxTermsSize     = 1000
+	defaultWindow    = 24 * time.Hour // searched when there is no range on the time field
+)
+
+var shards = view.ESShards{Total: 1, Successful: 1}
+
+// esIndex is the table behind an es api request, the table id is used as the index name
+type esIndex struct {
+	info    db.BaseTable
+	op      inquiry.Operator
+	indexes []*db.BaseIndex
+	fields  map[string]string // field name -> column
+}
+
+// Search is the es /<index>/_search api
+func Search(c *core.Context) {
+	start := time.Now()
+	idx, ok := loadIndex(c)
+	if !ok {
+		return
+	}
+	req, err := bindSearch(c)
+	if err != nil {
+		esError(c, http.StatusBadRequest, "parsing_exception", err)
+		return
+	}
+	param, err := idx.param(req.Query)
+	if err != nil {
+		esError(c, http.StatusBadRequest, "parsing_exception", err)
+		return
+	}
+	size := defaultSize
+	if req.Size != nil {
+		size = *req.Size
+	}
+	if v := c.Query("size"); v != "" {
+		size = cast.ToInt(v)
+	}
+	if v := c.Query("from"); v != "" {
+		req.From = cast.ToInt(v)
+	}
+	if size < 0 || req.From < 0 {
+		esError(c, http.StatusBadRequest, "illegal_argument_exception", errors.New("[from] and [size] parameters cannot be negative"))
+		return
+	}
+	if size > maxResultWindow {
+		size = maxResultWindow
+	}
+	if req.From+size > maxResultWindow {
+		esError(c, http.StatusBadRequest, "illegal_argument_exception", errors.Errorf("Result window is too large, from + size must be less than or equal to: [%d] but was [%d]", maxResultWindow, req.From+size))
+		return
+	}
+	count, err := idx.op.Count(param)
+	if err != nil {
+		esError(c, http.StatusInternalServerError, "search_phase_execution_exception", err)
+		return
+	}
+	res := view.RespESSearch{
+		Shards: shards,
+		Hits: view.ESHits{
+			Total: view.ESTotal{Value: count, Relation: "eq"},
+			Hits:  make([]view.ESHit, 0),
+		},
+	}
+	if size > 0 {
+		logs, errGet := idx.search(param, req.From, size)
+		if errGet != nil {
+			esError(c, http.StatusInternalServerError, "search_phase_execution_exception", errGet)
+			return
+		}
+		for i, log := range logs {
+			res.Hits.Hits = append(res.Hits.Hits, view.ESHit{
+				Index:  c.Param("index"),
+				ID:     strconv.Itoa(req.From + i),
+				Source: log,
+			})
+		}
+	}
+	aggs := req.Aggs
+	if len(aggs) == 0 {
+		aggs = req.Aggregations
+	}
+	if len(aggs) > 0 {
+		res.Aggregations = make(map[string]view.ESAggResult, len(aggs))
+	}
+	for name, agg := range aggs {
+		if agg.Terms == nil {
+			esError(c, http.StatusBadRequest, "parsing_exception", errors.Errorf("[%s] only terms aggregations are supported", name))
+			return
+		}
+		col, ok := idx.fields[agg.Terms.Field]
+		if !ok {
+			esError(c, http.StatusBadRequest, "parsing_exception", errors.Errorf("unknown field: %s", agg.Terms.Field))
+			return
+		}
+		res.Aggregations[name] = termsAggregation(idx.op, param, col, agg.Terms.Size, count)
+	}
+	res.Took = time.Since(start).Milliseconds()
+	event.Event.InquiryCMDB(c.User(), db.OpnTablesLogsQuery, map[string]interface{}{"param": req})
+	c.Context.JSON(http.StatusOK, res)
+}
+
+// Count is the es /<index>/_count api
+func Count(c *core.Context) {
+	idx, ok := loadIndex(c)
+	if !ok {
+		return
+	}
+	req, err := bindSearch(c)
+	if err != nil {
+		esError(c, http.StatusBadRequest, "parsing_exception", err)
+		return
+	}
+	param, err := idx.param(req.Query)
+	if err != nil {
+		esError(c, http.StatusBadRequest, "parsing_exception", err)
+		return
+	}
+	count, err := idx.op.Count(param)
+	if err != nil {
+		esError(c, http.StatusInternalServerError, "search_phase_execution_exception", err)
+		return
+	}
+	c.Context.JSON(http.StatusOK, view.RespESCount{Count: count, Shards: shards})
+}
+
+// Mapping is the es /<index>/_mapping api, analysis fields are exposed as properties
+func Mapping(c *core.Context) {
+	idx, ok := loadIndex(c)
+	if !ok {
+		return
+	}
+	properties := map[string]interface{}{
+		idx.info.GetTimeField(): map[string]interface{}{"type": "date"},
+		idx.rawLogField():       map[string]interface{}{"type": "text"},
+	}
+	for _, index := range idx.indexes {
+		typ := "keyword"
+		switch index.Typ {
+		case 1:
+			typ = "long"
+		case 2:
+			typ = "double"
+		}
+		if index.RootName == "" {
+			properties[index.Field] = map[string]interface{}{"type": typ}
+			continue
+		}
+		root, ok := properties[index.RootName].(map[string]interface{})
+		if !ok {
+			root = map[string]interface{}{"properties": map[string]interface{}{}}
+			properties[index.RootName] = root
+		}
+		root["properties"].(map[string]interface{})[index.Field] = map[string]interface{}{"type": typ}
+	}
+	c.Context.JSON(http.StatusOK, map[string]interface{}{
+		c.Param("index"): map[string]interface{}{
+			"mappings": map[string]interface{}{"properties": properties},
+		},
+	})
+}
+
+func loadIndex(c *core.Context) (*esIndex, bool) {
+	tid := cast.ToInt(c.Param("index"))
+	if tid == 0 {
+		esError(c, http.StatusNotFound, "index_not_found_exception", errors.Errorf("no such index [%s]", c.Param("index")))
+		return nil, false
+	}
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil || tableInfo.ID == 0 || tableInfo.Database == nil {
+		esError(c, http.StatusNotFound, "index_not_found_exception", errors.Errorf("no such index [%s]", c.Param("index")))
+		return nil, false
+	}
+	if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActView},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(tableInfo.ID),
+	}); err != nil {
+		esError(c, http.StatusForbidden, "security_exception", err)
+		return nil, false
+	}
+	op, err := service.InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		esError(c, http.StatusInternalServerError, "exception", err)
+		return nil, false
+	}
+	indexes, err := db.IndexList(egorm.Conds{"tid": tableInfo.ID})
+	if err != nil {
+		esError(c, http.StatusInternalServerError, "exception", err)
+		return nil, false
+	}
+	res := &esIndex{info: tableInfo, op: op, indexes: indexes, fields: make(map[string]string)}
+	res.fields[tableInfo.GetTimeField()] = tableInfo.GetTimeField()
+	res.fields[res.rawLogField()] = res.rawLogField()
+	for _, index := range indexes {
+		res.fields[index.GetFieldName()] = inquiry.IndexColumn(index)
+	}
+	return res, true
+}
+
+func (e *esIndex) rawLogField() string {
+	if e.info.CreateType == inquiry.TableCreateTypeExist && e.info.RawLogField != "" {
+		return e.info.RawLogField
+	}
+	return "_raw_log_"
+}
+
+// param translates the query, the last day is searched when there is no range on the time field
+func (e *esIndex) param(query map[string]interface{}) (view.ReqQuery, error) {
+	now := time.Now()
+	q, err := inquiry.ParseESQuery(query, e.fields, e.info.GetTimeField(), now)
+	if err != nil {
+		return view.ReqQuery{}, err
+	}
+	if q.ET == 0 {
+		q.ET = now.Unix() + 1
+	}
+	if q.ST == 0 {
+		window := defaultWindow
+		if hours := econf.GetInt64("app.queryLimitHours"); hours > 0 && time.Duration(hours)*time.Hour < window {
+			window = time.Duration(hours) * time.Hour
+		}
+		q.ST = q.ET - int64(window/time.Second)
+	}
+	return e.op.Prepare(view.ReqQuery{
+		Tid:           e.info.ID,
+		Database:      e.info.Database.Name,
+		Table:         e.info.Name,
+		TimeField:     e.info.GetTimeField(),
+		TimeFieldType: e.info.TimeFieldType,
+		Query:         q.Where,
+		ST:            q.ST,
+		ET:            q.ET,
+	}, false)
+}
+
+// search returns size logs from the offset from, the pages of GET are used when from is a multiple of size,
+// otherwise the first from+size logs are read and the ones before from are skipped, both are bounded by maxResultWindow
+func (e *esIndex) search(param view.ReqQuery, from, size int) ([]map[string]interface{}, error) {
+	if from%size == 0 {
+		param.PageSize = uint32(size)
+		param.Page = uint32(from/size + 1)
+	} else {
+		param.PageSize = uint32(from + size)
+		param.Page = 1
+	}
+	res, err := e.op.GET(param, e.info.ID)
+	if err != nil {
+		return nil, err
+	}
+	if from%size == 0 {
+		return res.Logs, nil
+	}
+	if len(res.Logs) <= from {
+		return nil, nil
+	}
+	return res.Logs[from:], nil
+}
+
+// termsAggregation groups with the same query as the field statistics, size buckets are returned, 10 by default
+func termsAggregation(op inquiry.Operator, param view.ReqQuery, col string, size int, total uint64) view.ESAggResult {
+	if size <= 0 {
+		size = defaultTermsSize
+	}
+	if size > maxTermsSize {
+		size = maxTermsSize
+	}
+	param.Field = col
+	param.GroupByLimit = uint32(size)
+	res := view.ESAggResult{Buckets: make([]view.ESBucket, 0)}
+	var sum uint64
+	for key, count := range op.GroupBy(param) {
+		res.Buckets = append(res.Buckets, view.ESBucket{Key: key, DocCount: count})
+		sum += count
+	}
+	sort.Slice(res.Buckets, func(i, j int) bool {
+		if res.Buckets[i].DocCount == res.Buckets[j].DocCount {
+			return res.Buckets[i].Key < res.Buckets[j].Key
+		}
+		return res.Buckets[i].DocCount > res.Buckets[j].DocCount
+	})
+	if total > sum {
+		res.SumOtherDocCount = total - sum
+	}
+	return res
+}
+
+func bindSearch(c *core.Context) (view.ReqESSearch, error) {
+	var req view.ReqESSearch
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return req, err
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return req, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	err = decoder.Decode(&req)
+	return req, err
+}
+
+func esError(c *core.Context, status int, typ string, err error) {
+	c.Context.JSON(status, view.RespESError{
+		Error: view.ESError{
+			RootCause: []view.ESErrorCause{{Type: typ, Reason: err.Error()}},
+			Type:      typ,
+			Reason:    err.Error(),
+		},
+		Status: status,
+	})
+}
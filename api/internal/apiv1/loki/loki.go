@@ -0,0 +1,529 @@
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 5000
+	maxPoints    = 11000
+	seriesLimit  = 1000
+	maxBuckets   = 100000
+)
+
+// lokiTable is the table behind a loki api request
+type lokiTable struct {
+	info   db.BaseTable
+	op     inquiry.Operator
+	labels map[string]*db.BaseIndex // loki label name -> analysis field
+}
+
+// QueryRange is the loki /loki/api/v1/query_range api
+func QueryRange(c *core.Context) {
+	var req view.ReqLokiQueryRange
+	if err := c.Bind(&req); err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	t, ok := loadTable(c)
+	if !ok {
+		return
+	}
+	q, err := inquiry.ParseLogQL(req.Query)
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	start, end, err := parseRange(req.Start, req.End)
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	var res view.RespLokiQuery
+	if q.IsMetric() {
+		step, errStep := parseStep(req.Step, start, end)
+		if errStep != nil {
+			lokiError(c, http.StatusBadRequest, errStep)
+			return
+		}
+		res, err = t.matrix(q, start, end, step)
+	} else {
+		res, err = t.streams(q, start, end, req.Limit, req.Direction)
+	}
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	event.Event.InquiryCMDB(c.User(), db.OpnTablesLogsQuery, map[string]interface{}{"param": req})
+	c.Context.JSON(http.StatusOK, view.RespLoki{Status: "success", Data: res})
+}
+
+// Labels is the loki /loki/api/v1/labels api, string analysis fields are exposed as labels
+func Labels(c *core.Context) {
+	t, ok := loadTable(c)
+	if !ok {
+		return
+	}
+	res := make([]string, 0, len(t.labels))
+	for name := range t.labels {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	c.Context.JSON(http.StatusOK, view.RespLoki{Status: "success", Data: res})
+}
+
+// LabelValues is the loki /loki/api/v1/label/<name>/values api
+func LabelValues(c *core.Context) {
+	var req view.ReqLokiLabels
+	if err := c.Bind(&req); err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	t, ok := loadTable(c)
+	if !ok {
+		return
+	}
+	idx, ok := t.labels[c.Param("name")]
+	if !ok {
+		c.Context.JSON(http.StatusOK, view.RespLoki{Status: "success", Data: []string{}})
+		return
+	}
+	start, end, err := parseRange(req.Start, req.End)
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	param, err := t.param("", start.Unix(), end.Unix()+1)
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	param.Field = inquiry.IndexColumn(idx)
+	res := make([]string, 0)
+	for val := range t.op.GroupBy(param) {
+		if val != "" {
+			res = append(res, val)
+		}
+	}
+	sort.Strings(res)
+	c.Context.JSON(http.StatusOK, view.RespLoki{Status: "success", Data: res})
+}
+
+// Series is the loki /loki/api/v1/series api
+func Series(c *core.Context) {
+	var req view.ReqLokiSeries
+	if err := c.Bind(&req); err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	t, ok := loadTable(c)
+	if !ok {
+		return
+	}
+	start, end, err := parseRange(req.Start, req.End)
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	uniq := make(map[string]map[string]string)
+	for _, match := range req.Match {
+		q, errParse := inquiry.ParseLogQL(match)
+		if errParse != nil {
+			lokiError(c, http.StatusBadRequest, errParse)
+			return
+		}
+		logs, errLogs := t.logs(q, start, end, seriesLimit)
+		if errLogs != nil {
+			lokiError(c, http.StatusBadRequest, errLogs)
+			return
+		}
+		for _, log := range logs {
+			labels := t.streamLabels(log)
+			uniq[labelsKey(labels)] = labels
+		}
+	}
+	keys := make([]string, 0, len(uniq))
+	for k := range uniq {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]map[string]string, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, uniq[k])
+	}
+	c.Context.JSON(http.StatusOK, view.RespLoki{Status: "success", Data: res})
+}
+
+func loadTable(c *core.Context) (*lokiTable, bool) {
+	tid := cast.ToInt(c.Param("id"))
+	if tid == 0 {
+		lokiError(c, http.StatusBadRequest, errors.New("invalid table id"))
+		return nil, false
+	}
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil || tableInfo.ID == 0 || tableInfo.Database == nil {
+		lokiError(c, http.StatusNotFound, errors.New("table not found"))
+		return nil, false
+	}
+	if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActView},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(tableInfo.ID),
+	}); err != nil {
+		lokiError(c, http.StatusForbidden, err)
+		return nil, false
+	}
+	op, err := service.InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		lokiError(c, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	indexes, err := db.IndexList(egorm.Conds{"tid": tableInfo.ID})
+	if err != nil {
+		lokiError(c, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	labels := make(map[string]*db.BaseIndex)
+	for _, idx := range indexes {
+		// numeric fields would split every line into its own stream
+		if idx.Typ == 0 {
+			labels[inquiry.LogQLLabel(idx)] = idx
+		}
+	}
+	return &lokiTable{info: tableInfo, op: op, labels: labels}, true
+}
+
+func (t *lokiTable) rawLogField() string {
+	if t.info.CreateType == inquiry.TableCreateTypeExist && t.info.RawLogField != "" {
+		return t.info.RawLogField
+	}
+	return "_raw_log_"
+}
+
+func (t *lokiTable) param(query string, st, et int64) (view.ReqQuery, error) {
+	return t.op.Prepare(view.ReqQuery{
+		Tid:           t.info.ID,
+		Database:      t.info.Database.Name,
+		Table:         t.info.Name,
+		TimeField:     t.info.GetTimeField(),
+		TimeFieldType: t.info.TimeFieldType,
+		Query:         query,
+		ST:            st,
+		ET:            et,
+	}, false)
+}
+
+func (t *lokiTable) logs(q *inquiry.LogQL, start, end time.Time, limit int) ([]map[string]interface{}, error) {
+	where, err := q.Where(t.labels, t.rawLogField())
+	if err != nil {
+		return nil, err
+	}
+	param, err := t.param(where, start.Unix(), end.Unix()+1)
+	if err != nil {
+		return nil, err
+	}
+	param.PageSize = uint32(limit)
+	res, err := t.op.GET(param, t.info.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res.Logs, nil
+}
+
+func (t *lokiTable) streams(q *inquiry.LogQL, start, end time.Time, limit int, direction string) (res view.RespLokiQuery, err error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	logs, err := t.logs(q, start, end, limit)
+	if err != nil {
+		return
+	}
+	streams := make(map[string]*view.LokiStream)
+	type entry struct {
+		ts   int64
+		line string
+	}
+	entries := make(map[string][]entry)
+	for _, log := range logs {
+		labels := t.streamLabels(log)
+		key := labelsKey(labels)
+		if _, ok := streams[key]; !ok {
+			streams[key] = &view.LokiStream{Stream: labels}
+		}
+		entries[key] = append(entries[key], entry{ts: logTimestamp(log), line: t.logLine(log)})
+	}
+	keys := make([]string, 0, len(streams))
+	for k := range streams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]view.LokiStream, 0, len(keys))
+	for _, k := range keys {
+		list := entries[k]
+		sort.SliceStable(list, func(i, j int) bool {
+			if direction == "forward" {
+				return list[i].ts < list[j].ts
+			}
+			return list[i].ts > list[j].ts
+		})
+		s := streams[k]
+		for _, e := range list {
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(e.ts, 10), e.line})
+		}
+		result = append(result, *s)
+	}
+	return view.RespLokiQuery{ResultType: "streams", Result: result, Stats: map[string]interface{}{}}, nil
+}
+
+// matrix evaluates count_over_time and rate at every step with a single count query,
+// the logs are counted in buckets that divide both the step and the range, and every step sums the buckets of its range.
+func (t *lokiTable) matrix(q *inquiry.LogQL, start, end time.Time, step time.Duration) (res view.RespLokiQuery, err error) {
+	if len(q.By) > 1 {
+		return res, errors.New("sum by supports one label")
+	}
+	var byIdx *db.BaseIndex
+	if len(q.By) == 1 {
+		idx, ok := t.labels[q.By[0]]
+		if !ok {
+			return res, errors.Errorf("unknown label: %s", q.By[0])
+		}
+		byIdx = idx
+	}
+	where, err := q.Where(t.labels, t.rawLogField())
+	if err != nil {
+		return
+	}
+	stepSeconds := int64(step.Seconds())
+	rangeSeconds := int64(q.Range.Seconds())
+	if rangeSeconds <= 0 {
+		rangeSeconds = 1
+	}
+	bucket := gcd(stepSeconds, rangeSeconds)
+	steps := (end.Unix()-start.Unix())/stepSeconds + 1
+	// the step at start+i*step counts the logs in [start+i*step-range, start+i*step)
+	param, err := t.param(where, start.Unix()-rangeSeconds, start.Unix()+(steps-1)*stepSeconds)
+	if err != nil {
+		return
+	}
+	if (param.ET-param.ST)/bucket > maxBuckets {
+		return res, errors.New("too many points to count, use a range that is a multiple of the step")
+	}
+	var field string
+	if byIdx != nil {
+		field = inquiry.IndexColumn(byIdx)
+	}
+	resp, err := t.op.Complete(inquiry.LogQLMatrixSQL(param, param.Query, field, bucket))
+	if err != nil {
+		return
+	}
+	counts := make(map[string]map[int64]uint64)
+	for _, row := range resp.Logs {
+		key := cast.ToString(row["f"])
+		if _, ok := counts[key]; !ok {
+			if len(counts) >= seriesLimit {
+				return res, errors.Errorf("maximum of series (%d) reached for a single query", seriesLimit)
+			}
+			counts[key] = make(map[int64]uint64)
+		}
+		counts[key][cast.ToInt64(row["k"])] += cast.ToUint64(row["c"])
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]view.LokiSeries, 0, len(keys))
+	for _, k := range keys {
+		s := view.LokiSeries{Metric: t.metricLabels(q, byIdx, k), Values: make([][2]interface{}, 0, steps)}
+		for i := int64(0); i < steps; i++ {
+			var count uint64
+			for b := i * stepSeconds / bucket; b < (i*stepSeconds+rangeSeconds)/bucket; b++ {
+				count += counts[k][b]
+			}
+			val := float64(count)
+			if q.Func == inquiry.LogQLRate {
+				val = val / float64(rangeSeconds)
+			}
+			s.Values = append(s.Values, [2]interface{}{start.Unix() + i*stepSeconds, strconv.FormatFloat(val, 'f', -1, 64)})
+		}
+		result = append(result, s)
+	}
+	return view.RespLokiQuery{ResultType: "matrix", Result: result, Stats: map[string]interface{}{}}, nil
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func (t *lokiTable) metricLabels(q *inquiry.LogQL, byIdx *db.BaseIndex, key string) map[string]string {
+	res := make(map[string]string)
+	if byIdx != nil {
+		res[inquiry.LogQLLabel(byIdx)] = key
+		return res
+	}
+	if q.Sum {
+		return res
+	}
+	for _, m := range q.Matchers {
+		if m.Op == "=" {
+			res[m.Name] = m.Value
+		}
+	}
+	return res
+}
+
+func (t *lokiTable) streamLabels(log map[string]interface{}) map[string]string {
+	res := make(map[string]string)
+	for name, idx := range t.labels {
+		var val string
+		switch v := log[idx.GetFieldName()].(type) {
+		case string:
+			val = v
+		case *string:
+			if v != nil {
+				val = *v
+			}
+		}
+		if val != "" {
+			res[name] = val
+		}
+	}
+	return res
+}
+
+func (t *lokiTable) logLine(log map[string]interface{}) string {
+	if line, ok := log[t.rawLogField()].(string); ok {
+		return line
+	}
+	line, _ := json.Marshal(log)
+	return string(line)
+}
+
+func logTimestamp(log map[string]interface{}) int64 {
+	for _, field := range []string{db.TimeFieldNanoseconds, db.TimeFieldSecond} {
+		switch v := log[field].(type) {
+		case time.Time:
+			return v.UnixNano()
+		case *time.Time:
+			if v != nil {
+				return v.UnixNano()
+			}
+		case int64:
+			// unix seconds or milliseconds
+			if v > 1e11 {
+				return v * int64(time.Millisecond)
+			}
+			return v * int64(time.Second)
+		case float64:
+			return int64(v * 1e9)
+		}
+	}
+	return 0
+}
+
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// parseRange parses start and end, the default range is the last hour
+func parseRange(startStr, endStr string) (start, end time.Time, err error) {
+	end = time.Now()
+	if endStr != "" {
+		if end, err = parseTime(endStr); err != nil {
+			return
+		}
+	}
+	start = end.Add(-time.Hour)
+	if startStr != "" {
+		if start, err = parseTime(startStr); err != nil {
+			return
+		}
+	}
+	if end.Before(start) {
+		err = errors.New("end timestamp must not be before start time")
+	}
+	return
+}
+
+// parseTime accepts unix nanoseconds, unix seconds with fraction or RFC3339
+func parseTime(s string) (time.Time, error) {
+	if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ns < 1e12 {
+			return time.Unix(ns, 0), nil
+		}
+		return time.Unix(0, ns), nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return t, errors.Errorf("invalid timestamp %q", s)
+	}
+	return t, nil
+}
+
+// parseStep accepts durations or float seconds, the default resolution follows loki
+func parseStep(s string, start, end time.Time) (time.Duration, error) {
+	var step time.Duration
+	if s == "" {
+		step = time.Duration(math.Max(math.Floor(end.Sub(start).Seconds()/250), 1)) * time.Second
+	} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+		step = time.Duration(f * float64(time.Second))
+	} else if step, err = inquiry.ParseLogQLDuration(s); err != nil {
+		return 0, err
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+	if end.Sub(start)/step > maxPoints {
+		return 0, errors.New("exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)")
+	}
+	return step.Truncate(time.Second), nil
+}
+
+func lokiError(c *core.Context, code int, err error) {
+	c.String(code, err.Error())
+}
@@ -0,0 +1,284 @@
+package loki
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/spf13/cast"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/pkg/constx"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const defaultMaxBodyBytes = 32 << 20
+
+var errBodyTooLarge = errors.New("decoded body is larger than ingest.maxBodyBytes")
+
+// Push is the loki /loki/api/v1/push api, it accepts snappy compressed protobuf and json bodies,
+// and shares the ingestion tokens, rate limits and buffers with the NDJSON ingestion api.
+func Push(c *core.Context) {
+	tid := cast.ToInt(c.Param("id"))
+	if tid == 0 {
+		lokiError(c, http.StatusBadRequest, errors.New("invalid table id"))
+		return
+	}
+	token, ok := service.Ingest.Token(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if !ok || !token.AllowTable(tid) {
+		lokiError(c, http.StatusUnauthorized, errors.New("token error"))
+		return
+	}
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil || tableInfo.ID == 0 {
+		lokiError(c, http.StatusNotFound, errors.New("table not found"))
+		return
+	}
+	schema, err := service.Ingest.Schema(tableInfo)
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	maxBodyBytes := econf.GetInt64("ingest.maxBodyBytes")
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	var body io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+	if c.GetHeader("Content-Encoding") == "gzip" {
+		gr, errGzip := gzip.NewReader(body)
+		if errGzip != nil {
+			lokiError(c, http.StatusBadRequest, errGzip)
+			return
+		}
+		defer func() { _ = gr.Close() }()
+		body = &limitedReader{r: gr, n: maxBodyBytes}
+	}
+	var streams []view.LokiStream
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		var req view.ReqLokiPush
+		err = json.NewDecoder(body).Decode(&req)
+		streams = req.Streams
+	} else {
+		streams, err = decodePushProto(body, maxBodyBytes)
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errBodyTooLarge) || errors.As(err, &maxBytesErr) {
+		lokiError(c, http.StatusRequestEntityTooLarge, errBodyTooLarge)
+		return
+	}
+	if err != nil {
+		lokiError(c, http.StatusBadRequest, err)
+		return
+	}
+	rows := make([][]interface{}, 0)
+	for _, s := range streams {
+		for _, v := range s.Values {
+			ns, errTs := strconv.ParseInt(v[0], 10, 64)
+			if errTs != nil {
+				lokiError(c, http.StatusBadRequest, errors.New("invalid timestamp "+v[0]))
+				return
+			}
+			rows = append(rows, schema.StreamRow(s.Stream, time.Unix(0, ns), v[1]))
+		}
+	}
+	if err = service.Ingest.Allow(token, len(rows)); err != nil {
+		if errors.Is(err, constx.ErrIngestBatchTooLarge) {
+			lokiError(c, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		lokiError(c, http.StatusTooManyRequests, err)
+		return
+	}
+	if err = service.Ingest.Push(schema, rows); err != nil {
+		if errors.Is(err, constx.ErrIngestBufferFull) {
+			lokiError(c, http.StatusTooManyRequests, err)
+			return
+		}
+		lokiError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// limitedReader reads at most n bytes from r and fails with errBodyTooLarge after them,
+// unlike io.LimitReader it does not end a decompressed body that is too large as if it were complete
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		n, err := l.r.Read(make([]byte, 1))
+		if n > 0 {
+			return 0, errBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// decodePushProto decodes the snappy compressed logproto.PushRequest sent by promtail:
+//
+//	PushRequest { repeated Stream streams = 1; }
+//	Stream { string labels = 1; repeated Entry entries = 2; }
+//	Entry { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+//
+// The decoded length is checked against maxBytes before decoding, so that a small body can not expand without a limit.
+func decodePushProto(r io.Reader, maxBytes int64) ([]view.LokiStream, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if int64(n) > maxBytes {
+		return nil, errBodyTooLarge
+	}
+	buf, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]view.LokiStream, 0)
+	err = protoFields(buf, func(num protowire.Number, val []byte) error {
+		if num != 1 {
+			return nil
+		}
+		stream, errStream := decodeProtoStream(val)
+		if errStream != nil {
+			return errStream
+		}
+		res = append(res, stream)
+		return nil
+	})
+	return res, err
+}
+
+func decodeProtoStream(buf []byte) (res view.LokiStream, err error) {
+	err = protoFields(buf, func(num protowire.Number, val []byte) error {
+		switch num {
+		case 1:
+			labels, errLabels := parseLabels(string(val))
+			if errLabels != nil {
+				return errLabels
+			}
+			res.Stream = labels
+		case 2:
+			var (
+				ts   time.Time
+				line string
+			)
+			errEntry := protoFields(val, func(num protowire.Number, val []byte) error {
+				switch num {
+				case 1:
+					var sec, nsec int64
+					errTs := protoVarints(val, func(num protowire.Number, v uint64) {
+						switch num {
+						case 1:
+							sec = int64(v)
+						case 2:
+							nsec = int64(v)
+						}
+					})
+					ts = time.Unix(sec, nsec)
+					return errTs
+				case 2:
+					line = string(val)
+				}
+				return nil
+			})
+			if errEntry != nil {
+				return errEntry
+			}
+			res.Values = append(res.Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), line})
+		}
+		return nil
+	})
+	return
+}
+
+// protoFields walks the length-delimited fields of a message, other wire types are skipped
+func protoFields(buf []byte, fn func(protowire.Number, []byte) error) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			buf = buf[n:]
+			continue
+		}
+		val, n := protowire.ConsumeBytes(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		if err := fn(num, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoVarints walks the varint fields of a message, other wire types are skipped
+func protoVarints(buf []byte, fn func(protowire.Number, uint64)) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		if typ != protowire.VarintType {
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			buf = buf[n:]
+			continue
+		}
+		v, n := protowire.ConsumeVarint(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		fn(num, v)
+	}
+	return nil
+}
+
+// parseLabels parses the prometheus label string of a stream, e.g. {job="app", env="prod"}
+func parseLabels(s string) (map[string]string, error) {
+	q, err := inquiry.ParseLogQL(s)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string, len(q.Matchers))
+	for _, m := range q.Matchers {
+		res[m.Name] = m.Value
+	}
+	return res, nil
+}
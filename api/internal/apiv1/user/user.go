@@ -2,6 +2,7 @@ package user
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/ego-component/egorm"
 	"github.com/gotomicro/ego/core/econf"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
 	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
 	"github.com/clickvisual/clickvisual/api/pkg/component/core"
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
 )
@@ -186,3 +188,38 @@ func UpdatePassword(c *core.Context) {
 	c.JSONOK("")
 	return
 }
+
+type contact struct {
+	Phone    string `json:"phone" form:"phone"`
+	FeishuId string `json:"feishuId" form:"feishuId"`
+	SlackId  string `json:"slackId" form:"slackId"`
+}
+
+// UpdateContact updates the contacts that alert messages mention the user on call with, by the user or a root user
+func UpdateContact(c *core.Context) {
+	uid := cast.ToInt(c.Param("uid"))
+	if uid == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var param contact
+	if err := c.Bind(&param); err != nil {
+		c.JSONE(1, err.Error(), nil)
+		return
+	}
+	if uid != c.Uid() {
+		if err := permission.Manager.IsRootUser(c.Uid()); err != nil {
+			c.JSONE(1, err.Error(), nil)
+			return
+		}
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["phone"] = strings.TrimSpace(param.Phone)
+	ups["feishu_id"] = strings.TrimSpace(param.FeishuId)
+	ups["slack_id"] = strings.TrimSpace(param.SlackId)
+	if err := db.UserUpdate(invoker.Db, uid, ups); err != nil {
+		c.JSONE(1, "contact update error", err.Error())
+		return
+	}
+	c.JSONOK("")
+}
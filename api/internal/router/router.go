@@ -15,9 +15,11 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/bigdata"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/bigdata/mining"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/configure"
+	"github.com/clickvisual/clickvisual/api/internal/apiv1/es"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/event"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/initialize"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/kube"
+	"github.com/clickvisual/clickvisual/api/internal/apiv1/loki"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/permission"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/setting"
 	"github.com/clickvisual/clickvisual/api/internal/apiv1/template"
@@ -68,13 +70,15 @@ func GetRouter() *egin.Component {
 	{
 		// webhook
 		v1Open.POST("/prometheus/alerts", core.Handle(alarm.Webhook))
-		// alarms send test
-		v1Open.POST("/alarms-channels/send-test", core.Handle(alarm.ChannelSendTest))
+		v1Open.POST("/alarms-callback/feishu", core.Handle(alarm.FeishuCallback))
+		v1Open.POST("/alarms-callback/slack", core.Handle(alarm.SlackCallback))
 		// mock
 		v1Open.POST("/template/:id", core.Handle(template.Gen))
 		v1Open.POST("/install", core.Handle(initialize.Install))
 		v1Open.GET("/install", core.Handle(initialize.IsInstall))
 		v1Open.POST("/open/bigdata/nodes/:id/run", core.Handle(bigdata.NodeRunOpenAPI))
+		v1Open.POST("/open/tables/:id/ingest", core.Handle(base.TableIngest))
+		v1Open.POST("/open/tables/:id/loki/api/v1/push", core.Handle(loki.Push))
 	}
 	admin := r.Group(apiPrefix + "/admin")
 	{
@@ -91,6 +95,7 @@ func GetRouter() *egin.Component {
 		v1.GET("/migration", core.Handle(initialize.Migration))
 		v1.GET("/menus/list", core.Handle(permission.MenuList))
 		v1.PATCH("/users/:uid/password", core.Handle(user.UpdatePassword))
+		v1.PATCH("/users/:uid/contact", core.Handle(user.UpdateContact))
 		// Cluster configuration
 		v1.POST("/sys/clusters", core.Handle(setting.ClusterCreate))
 		v1.GET("/sys/clusters/:id", core.Handle(setting.ClusterInfo))
@@ -116,6 +121,7 @@ func GetRouter() *egin.Component {
 		v1.POST("/clusters/:clusterId/configmaps", core.Handle(kube.ConfigMapCreate))
 		v1.GET("/clusters/:clusterId/namespace/:namespace/configmaps/:name", core.Handle(kube.ConfigMapInfo))
 		// Instance
+		v1.GET("/sys/datasources", core.Handle(base.DatasourceList))
 		v1.GET("/sys/instances", core.Handle(base.InstanceList))
 		v1.POST("/sys/instances", core.Handle(base.InstanceCreate))
 		v1.GET("/sys/instances/:id", core.Handle(base.InstanceInfo))
@@ -136,6 +142,19 @@ func GetRouter() *egin.Component {
 		v1.GET("/tables/:id/logs", core.Handle(base.TableLogs))
 		v1.DELETE("/tables/:id", core.Handle(base.TableDelete))
 		v1.GET("/tables/:id/charts", core.Handle(base.TableCharts))
+		// loki compatible api, use /api/v1/tables/:id as the url of the grafana loki datasource
+		v1.GET("/tables/:id/loki/api/v1/query_range", core.Handle(loki.QueryRange))
+		v1.POST("/tables/:id/loki/api/v1/query_range", core.Handle(loki.QueryRange))
+		v1.GET("/tables/:id/loki/api/v1/labels", core.Handle(loki.Labels))
+		v1.GET("/tables/:id/loki/api/v1/label/:name/values", core.Handle(loki.LabelValues))
+		v1.GET("/tables/:id/loki/api/v1/series", core.Handle(loki.Series))
+		v1.POST("/tables/:id/loki/api/v1/series", core.Handle(loki.Series))
+		// elasticsearch compatible api, use /api/v1/es as the url and the table id as the index name
+		v1.GET("/es/:index/_search", core.Handle(es.Search))
+		v1.POST("/es/:index/_search", core.Handle(es.Search))
+		v1.GET("/es/:index/_count", core.Handle(es.Count))
+		v1.POST("/es/:index/_count", core.Handle(es.Count))
+		v1.GET("/es/:index/_mapping", core.Handle(es.Mapping))
 		v1.GET("/databases/:did/tables", core.Handle(base.TableList))
 		v1.POST("/databases/:did/tables", core.Handle(base.TableCreate))
 		v1.GET("/instances/:iid/complete", core.Handle(base.QueryComplete))
@@ -160,13 +179,48 @@ func GetRouter() *egin.Component {
 		v1.GET("/alarms/:id", core.Handle(alarm.Info))
 		v1.PATCH("/alarms/:id", core.Handle(alarm.Update))
 		v1.DELETE("/alarms/:id", core.Handle(alarm.Delete))
+		v1.POST("/alarms/:id/ack", core.Handle(alarm.Acknowledge))
+		v1.POST("/alarms/:id/resolve", core.Handle(alarm.Resolve))
+		v1.POST("/alarms-backtest", core.Handle(alarm.Backtest))
+		v1.GET("/alarms-export", core.Handle(alarm.Export))
+		v1.POST("/alarms-import", core.Handle(alarm.Import))
 		v1.GET("/alarms-channels", core.Handle(alarm.ChannelList))
 		v1.GET("/alarms-histories", core.Handle(alarm.HistoryList))
 		v1.POST("/alarms-channels", core.Handle(alarm.ChannelCreate))
+		v1.POST("/alarms-channels/send-test", core.Handle(alarm.ChannelSendTest))
 		v1.GET("/alarms-channels/:id", core.Handle(alarm.ChannelInfo))
+		v1.GET("/alarms-histories/timeline", core.Handle(alarm.HistoryTimeline))
+		v1.GET("/alarms-histories/mttr", core.Handle(alarm.HistoryMTTR))
+		v1.GET("/alarms-histories/noisiest", core.Handle(alarm.HistoryNoisiest))
 		v1.GET("/alarms-histories/:id", core.Handle(alarm.HistoryInfo))
 		v1.PATCH("/alarms-channels/:id", core.Handle(alarm.ChannelUpdate))
 		v1.DELETE("/alarms-channels/:id", core.Handle(alarm.ChannelDelete))
+		v1.GET("/alarms-silences", core.Handle(alarm.SilenceList))
+		v1.POST("/alarms-silences", core.Handle(alarm.SilenceCreate))
+		v1.GET("/alarms-silences/:id", core.Handle(alarm.SilenceInfo))
+		v1.PATCH("/alarms-silences/:id", core.Handle(alarm.SilenceUpdate))
+		v1.POST("/alarms-silences/:id/expire", core.Handle(alarm.SilenceExpire))
+		v1.DELETE("/alarms-silences/:id", core.Handle(alarm.SilenceDelete))
+		v1.GET("/alarms-escalations", core.Handle(alarm.EscalationList))
+		v1.POST("/alarms-escalations", core.Handle(alarm.EscalationCreate))
+		v1.GET("/alarms-escalations/:id", core.Handle(alarm.EscalationInfo))
+		v1.PATCH("/alarms-escalations/:id", core.Handle(alarm.EscalationUpdate))
+		v1.DELETE("/alarms-escalations/:id", core.Handle(alarm.EscalationDelete))
+		v1.GET("/alarms-oncalls", core.Handle(alarm.OncallList))
+		v1.POST("/alarms-oncalls", core.Handle(alarm.OncallCreate))
+		v1.GET("/alarms-oncalls/:id", core.Handle(alarm.OncallInfo))
+		v1.GET("/alarms-oncalls/:id/current", core.Handle(alarm.OncallCurrent))
+		v1.PATCH("/alarms-oncalls/:id", core.Handle(alarm.OncallUpdate))
+		v1.DELETE("/alarms-oncalls/:id", core.Handle(alarm.OncallDelete))
+		v1.GET("/alarms-templates", core.Handle(alarm.TemplateList))
+		v1.POST("/alarms-templates", core.Handle(alarm.TemplateCreate))
+		v1.GET("/alarms-templates/:id", core.Handle(alarm.TemplateInfo))
+		v1.PATCH("/alarms-templates/:id", core.Handle(alarm.TemplateUpdate))
+		v1.POST("/alarms-templates/:id/apply", core.Handle(alarm.TemplateApply))
+		v1.DELETE("/alarms-templates/:id", core.Handle(alarm.TemplateDelete))
+		v1.GET("/alarms-deliveries", core.Handle(alarm.DeliveryList))
+		v1.GET("/alarms-deliveries/:id", core.Handle(alarm.DeliveryInfo))
+		v1.POST("/alarms-deliveries/:id/replay", core.Handle(alarm.DeliveryReplay))
 		// OpEvent Operation event interface
 		v1.GET("/events", core.Handle(event.ListPage))
 		v1.GET("/event/enums", core.Handle(event.GetAllEnums))
@@ -4,13 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ego-component/egorm"
+	"github.com/google/uuid"
 	"github.com/gotomicro/ego/core/elog"
 	"gorm.io/gorm"
 
@@ -34,30 +35,18 @@ const prometheusRuleTemplate = `groups:
       severity: warning
     annotations:
       summary: "告警 {{ $labels.name }}"
-      description: "{{ $labels.desc }}  (当前值: {{ $value }})"`
-
-const (
-	reloadTimes    = 30
-	reloadInterval = time.Second * 10
-)
+      description: "{{ $labels.desc }}  (当前值: {{ $value }})"
+      value: "{{ $value }}"`
 
 type alarm struct {
-	reloadChan chan int64
+	reloader *reloader
 }
 
 // NewAlarm ...
 func NewAlarm() *alarm {
-	a := &alarm{
-		reloadChan: make(chan int64, reloadTimes),
-	}
-	go func() {
-		for r := range a.reloadChan {
-			invoker.Logger.Info("AllPrometheusReload", elog.Int("times", len(a.reloadChan)), elog.Int64("r", r), elog.Int64("now", time.Now().Unix()))
-			AllPrometheusReload()
-			time.Sleep(reloadInterval)
-		}
-	}()
-	return a
+	return &alarm{
+		reloader: newReloader(),
+	}
 }
 
 func (i *alarm) FilterCreate(tx *gorm.DB, alertID int, filters []view.ReqAlarmFilterCreate) (res []*db.AlarmFilter, err error) {
@@ -89,17 +78,10 @@ func (i *alarm) ConditionCreate(tx *gorm.DB, obj *db.Alarm, conditions []view.Re
 		return conditions[i].SetOperatorTyp < conditions[j].SetOperatorTyp
 	})
 	for _, condition := range conditions {
-		var innerCond string
-		switch condition.Cond {
-		case 0:
-			innerCond = fmt.Sprintf("%s>%d", expVal, condition.Val1)
-		case 1:
-			innerCond = fmt.Sprintf("%s<%d", expVal, condition.Val1)
-		case 2:
-			innerCond = fmt.Sprintf("(%s<%d or %s>%d)", expVal, condition.Val1, expVal, condition.Val2)
-		case 3:
-			innerCond = fmt.Sprintf("(%s>=%d and %s<=%d)", expVal, condition.Val1, expVal, condition.Val2)
+		if err = conditionValidate(condition); err != nil {
+			return
 		}
+		innerCond := conditionExp(expVal, obj, condition)
 		switch condition.SetOperatorTyp {
 		case 0:
 			exp = innerCond
@@ -135,6 +117,68 @@ func (i *alarm) ConditionCreate(tx *gorm.DB, obj *db.Alarm, conditions []view.Re
 	return
 }
 
+// conditionSelectors are the prometheus functions of the aggregation selectors, 0 last 1 min 2 max 3 sum 4 count 5 avg,
+// the latest sample of the default selector is the raw metric, as the alarms created before the selectors are evaluated.
+var conditionSelectors = map[int]string{
+	0: "",
+	1: "min_over_time",
+	2: "max_over_time",
+	3: "sum_over_time",
+	4: "count_over_time",
+	5: "avg_over_time",
+}
+
+// conditionValidate checks the aggregation selector and the operator of the condition
+func conditionValidate(condition view.ReqAlarmConditionCreate) error {
+	if _, ok := conditionSelectors[condition.SetOperatorExp]; !ok {
+		return fmt.Errorf("unknown aggregation selector %d", condition.SetOperatorExp)
+	}
+	if condition.Cond < 0 || condition.Cond > 7 {
+		return fmt.Errorf("unknown condition %d", condition.Cond)
+	}
+	if (condition.Cond == 2 || condition.Cond == 3) && condition.Val1 > condition.Val2 {
+		return errors.New("the minimum of the range should not be above the maximum")
+	}
+	return nil
+}
+
+// conditionExp builds the expression of the condition over the samples of the last interval,
+// rates are the sum of the samples per second, and changes are the percentages to the previous interval.
+func conditionExp(expVal string, obj *db.Alarm, condition view.ReqAlarmConditionCreate) string {
+	var (
+		interval = obj.AlertInterval()
+		selector = conditionSelectors[condition.SetOperatorExp]
+		cur      = fmt.Sprintf("%s(%s[%s])", selector, expVal, interval)
+		prev     = fmt.Sprintf("%s(%s[%s] offset %s)", selector, expVal, interval, interval)
+		val1     = strconv.FormatFloat(condition.Val1, 'f', -1, 64)
+		val2     = strconv.FormatFloat(condition.Val2, 'f', -1, 64)
+	)
+	if selector == "" {
+		cur, prev = expVal, fmt.Sprintf("%s offset %s", expVal, interval)
+	}
+	switch condition.Cond {
+	case 0:
+		return fmt.Sprintf("%s>%s", cur, val1)
+	case 1:
+		return fmt.Sprintf("%s<%s", cur, val1)
+	case 2:
+		return fmt.Sprintf("(%s<%s or %s>%s)", cur, val1, cur, val2)
+	case 3:
+		return fmt.Sprintf("(%s>=%s and %s<=%s)", cur, val1, cur, val2)
+	}
+	if condition.Cond == 4 || condition.Cond == 5 {
+		rate := fmt.Sprintf("sum_over_time(%s[%s])/%d", expVal, interval, int64(obj.AlertDuration()/time.Second))
+		if condition.Cond == 4 {
+			return fmt.Sprintf("%s>%s", rate, val1)
+		}
+		return fmt.Sprintf("%s<%s", rate, val1)
+	}
+	if condition.Cond == 6 {
+		return fmt.Sprintf("(%s-%s)/%s*100>%s", cur, prev, prev, val1)
+	}
+	return fmt.Sprintf("(%s-%s)/%s*100>%s", prev, cur, prev, val1)
+}
+
 const (
 	NoDataOpDefault = 0
 	NoDataOpOK      = 1
@@ -155,12 +199,9 @@ func noDataOp(exp, expVal string, op int) string {
 }
 
 func (i *alarm) PrometheusReload(prometheusTarget string) (err error) {
-	resp, err := http.Post(strings.TrimSuffix(prometheusTarget, "/")+"/-/reload", "text/html;charset=utf-8", nil)
-	if err != nil {
+	if err = prometheusReload(prometheusTarget); err != nil {
 		invoker.Logger.Error("reload", elog.Any("reload", prometheusTarget+"/-/reload"), elog.Any("err", err.Error()))
-		return
 	}
-	defer func() { _ = resp.Body.Close() }()
 	return
 }
 
@@ -169,7 +210,11 @@ func (i *alarm) PrometheusRuleGen(obj *db.Alarm, exp string) (rule string, err e
 	return
 }
 
+// PrometheusRuleCreateOrUpdate validates and writes the rule, prometheus is reloaded by the reloader of its target
 func (i *alarm) PrometheusRuleCreateOrUpdate(instance db.BaseInstance, obj *db.Alarm, rule string) (err error) {
+	if err = ValidatePrometheusRule(rule); err != nil {
+		return
+	}
 	switch instance.RuleStoreType {
 	case db.RuleStoreTypeFile:
 		content := []byte(rule)
@@ -191,10 +236,19 @@ func (i *alarm) PrometheusRuleCreateOrUpdate(instance db.BaseInstance, obj *db.A
 		if err != nil {
 			return
 		}
+	case db.RuleStoreTypeOperator:
+		// prometheus-operator watches the resource and reloads prometheus by itself
+		client, errCluster := kube.ClusterManager.GetClusterManager(instance.ClusterId)
+		if errCluster != nil {
+			return errCluster
+		}
+		if err = resource.PrometheusRuleCreateOrUpdate(client, instance.Namespace, obj.PrometheusRuleName(), instance.RuleLabels, rule); err != nil {
+			return
+		}
 	default:
 		return constx.ErrAlarmRuleStoreIsClosed
 	}
-	i.AddPrometheusReloadChan()
+	i.reloader.Reload(instance.PrometheusTarget, obj, instance.RuleStoreType != db.RuleStoreTypeOperator)
 	return nil
 }
 
@@ -217,14 +271,19 @@ func (i *alarm) PrometheusRuleDelete(instance *db.BaseInstance, obj *db.Alarm) (
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			return
 		}
+	case db.RuleStoreTypeOperator:
+		return resource.PrometheusRuleDelete(instance.ClusterId, instance.Namespace, obj.PrometheusRuleName())
 	default:
 		return nil
 	}
-	i.AddPrometheusReloadChan()
+	i.reloader.Reload(instance.PrometheusTarget, nil, true)
 	return nil
 }
 
 func (i *alarm) CreateOrUpdate(tx *gorm.DB, alarmObj *db.Alarm, req view.ReqAlarmCreate) (err error) {
+	if err = groupByValidate(alarmObj); err != nil {
+		return
+	}
 	filtersDB, err := i.FilterCreate(tx, alarmObj.ID, req.Filters)
 	if err != nil {
 		invoker.Logger.Error("alarm", elog.String("step", "alarm create failed 02"), elog.String("err", err.Error()))
@@ -247,6 +306,25 @@ func (i *alarm) CreateOrUpdate(tx *gorm.DB, alarmObj *db.Alarm, req view.ReqAlar
 		invoker.Logger.Error("alarm", elog.String("step", "you need to configure alarms related to the instance first:"), elog.String("err", err.Error()))
 		return
 	}
+	if err = anomalyValidate(alarmObj, instance.RuleStoreType); err != nil {
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	if instance.RuleStoreType == db.RuleStoreTypeNative {
+		// evaluated by the native evaluator, the view and the prometheus rule are useless
+		if err = i.dropView(tableInfo, alarmObj); err != nil {
+			invoker.Logger.Error("alarm", elog.String("step", "alarm create failed 05"), elog.String("err", err.Error()))
+			return
+		}
+		ups["view"] = ""
+		ups["alert_rule"] = ""
+		ups["view_table_name"] = ""
+		ups["rule_store_type"] = instance.RuleStoreType
+		ups["status"] = db.AlarmStatusOpen
+		ups["sync_status"] = db.AlarmSyncStatusUnknown
+		ups["sync_msg"] = ""
+		return db.AlarmUpdate(tx, alarmObj.ID, ups)
+	}
 	op, err := InstanceManager.Load(tableInfo.Database.Iid)
 	if err != nil {
 		invoker.Logger.Error("alarm", elog.String("step", "alarm create failed 04"), elog.String("err", err.Error()))
@@ -283,20 +361,71 @@ func (i *alarm) CreateOrUpdate(tx *gorm.DB, alarmObj *db.Alarm, req view.ReqAlar
 		invoker.Logger.Error("alarm", elog.String("step", "alarm create failed 09"), elog.String("err", err.Error()))
 		return
 	}
-	ups := make(map[string]interface{}, 0)
 	ups["view"] = viewSQL
 	ups["alert_rule"] = rule
 	ups["view_table_name"] = viewTableName
 	ups["rule_store_type"] = instance.RuleStoreType
 	ups["status"] = db.AlarmStatusOpen
+	ups["sync_status"] = ruleSyncStatus(instance)
+	ups["sync_msg"] = ""
 	return db.AlarmUpdate(tx, alarmObj.ID, ups)
 }
 
+// groupByValidate checks the group-by fields are analysis fields of the table, and their labels are not taken
+func groupByValidate(alarmObj *db.Alarm) error {
+	if len(alarmObj.GroupBy) == 0 {
+		return nil
+	}
+	if alarmObj.Mode != db.AlarmModeDefault {
+		return errors.New("group by is only supported in the default mode")
+	}
+	conds := egorm.Conds{}
+	conds["tid"] = alarmObj.Tid
+	indexes, err := db.IndexList(conds)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]struct{}, len(indexes))
+	for _, idx := range indexes {
+		fields[idx.GetFieldName()] = struct{}{}
+	}
+	labels := map[string]struct{}{"uuid": {}, "alertname": {}, "severity": {}}
+	for k := range alarmObj.Tags {
+		labels[k] = struct{}{}
+	}
+	for _, field := range alarmObj.GroupBy {
+		if _, ok := fields[field]; !ok {
+			return fmt.Errorf("group by field %s is not an analysis field of the table", field)
+		}
+		label := db.GroupLabel(field)
+		if _, ok := labels[label]; ok {
+			return fmt.Errorf("label %s of group by field %s is taken", label, field)
+		}
+		labels[label] = struct{}{}
+	}
+	return nil
+}
+
+// dropView drops the metrics view of the alarm if there is one
+func (i *alarm) dropView(tableInfo db.BaseTable, alarmObj *db.Alarm) error {
+	if alarmObj.ViewTableName == "" {
+		return nil
+	}
+	op, err := InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		return err
+	}
+	return op.AlertViewDrop(alarmObj.ViewTableName, tableInfo.Database.Cluster)
+}
+
 func (i *alarm) OpenOperator(id int) (err error) {
 	instanceInfo, tableInfo, alarmInfo, err := db.GetAlarmTableInstanceInfo(id)
 	if err != nil {
 		return
 	}
+	if alarmInfo.RuleStoreType == db.RuleStoreTypeNative {
+		return db.AlarmUpdate(invoker.Db, id, map[string]interface{}{"status": db.AlarmStatusOpen})
+	}
 	op, errInstanceManager := InstanceManager.Load(instanceInfo.ID)
 	if errInstanceManager != nil {
 		return
@@ -308,7 +437,83 @@ func (i *alarm) OpenOperator(id int) (err error) {
 		invoker.Logger.Error("alarm", elog.String("step", "prometheus rule delete failed"), elog.String("err", err.Error()))
 		return
 	}
-	if err = db.AlarmUpdate(invoker.Db, id, map[string]interface{}{"status": db.AlarmStatusOpen}); err != nil {
+	if err = db.AlarmUpdate(invoker.Db, id, map[string]interface{}{"status": db.AlarmStatusOpen, "sync_status": ruleSyncStatus(instanceInfo), "sync_msg": ""}); err != nil {
+		return
+	}
+	return
+}
+
+// Create creates the alarm of the table, a new uuid is generated when alarmUuid is empty
+func (i *alarm) Create(uid, tid int, alarmUuid string, req view.ReqAlarmCreate) (obj *db.Alarm, err error) {
+	if alarmUuid == "" {
+		alarmUuid = uuid.NewString()
+	}
+	if len(req.Filters) > 0 {
+		req.Mode = req.Filters[0].Mode
+	}
+	obj = &db.Alarm{
+		Tid:              tid,
+		Uuid:             alarmUuid,
+		Name:             req.Name,
+		Desc:             req.Desc,
+		Interval:         req.Interval,
+		Unit:             req.Unit,
+		Tags:             req.Tags,
+		NoDataOp:         req.NoDataOp,
+		ChannelIds:       db.Ints(req.ChannelIds),
+		Uid:              uid,
+		Mode:             req.Mode,
+		Level:            req.Level,
+		ForDuration:      req.ForDuration,
+		Freshness:        req.Freshness,
+		FiringTemplate:   req.FiringTemplate,
+		ResolvedTemplate: req.ResolvedTemplate,
+		GroupBy:          req.GroupBy,
+		EscalationId:     req.EscalationId,
+		OncallId:         req.OncallId,
+		Anomaly:          req.Anomaly,
+	}
+	tx := invoker.Db.Begin()
+	if err = db.AlarmCreate(tx, obj); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("alarm create failed 01: %w", err)
+	}
+	if err = i.CreateOrUpdate(tx, obj, req); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("alarm create failed 02: %w", err)
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("alarm create failed 03: %w", err)
+	}
+	return obj, nil
+}
+
+// Delete deletes the alarm with its filters, conditions, prometheus rule and metrics view
+func (i *alarm) Delete(instanceInfo db.BaseInstance, tableInfo db.BaseTable, alarmInfo db.Alarm) (err error) {
+	tx := invoker.Db.Begin()
+	if err = db.AlarmDelete(tx, alarmInfo.ID); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = db.AlarmFilterDeleteBatch(tx, alarmInfo.ID); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = db.AlarmConditionDeleteBatch(tx, alarmInfo.ID); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = i.PrometheusRuleDelete(&instanceInfo, &alarmInfo); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = i.dropView(tableInfo, &alarmInfo); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
 		return
 	}
 	return
@@ -331,6 +536,14 @@ func (i *alarm) Update(uid, alarmId int, req view.ReqAlarmCreate) (err error) {
 	ups["no_data_op"] = req.NoDataOp
 	ups["mode"] = req.Mode
 	ups["level"] = req.Level
+	ups["for_duration"] = req.ForDuration
+	ups["freshness"] = req.Freshness
+	ups["firing_template"] = req.FiringTemplate
+	ups["resolved_template"] = req.ResolvedTemplate
+	ups["group_by"] = db.Strings(req.GroupBy)
+	ups["escalation_id"] = req.EscalationId
+	ups["oncall_id"] = req.OncallId
+	ups["anomaly"] = req.Anomaly
 	ups["channel_ids"] = db.Ints(req.ChannelIds)
 	if len(req.Filters) > 0 {
 		ups["tid"] = req.Filters[0].Tid
@@ -365,37 +578,6 @@ func (i *alarm) Update(uid, alarmId int, req view.ReqAlarmCreate) (err error) {
 	return
 }
 
-func (i *alarm) AddPrometheusReloadChan() {
-	// 10 times
-	for k := 0; k < reloadTimes; k++ {
-		if len(i.reloadChan) < reloadTimes {
-			invoker.Logger.Debug("AllPrometheusReload", elog.String("step", "AddPrometheusReloadChan"), elog.Any("k", k))
-			i.reloadChan <- time.Now().Unix()
-		}
-	}
-}
-
-func AllPrometheusReload() {
-	instances, err := db.InstanceList(egorm.Conds{})
-	if err != nil {
-		invoker.Logger.Error("AllPrometheusReload", elog.String("step", "InstanceList"), elog.String("error", err.Error()))
-		return
-	}
-	pm := make(map[string]interface{})
-	for _, ins := range instances {
-		if ins.PrometheusTarget != "" {
-			pm[ins.PrometheusTarget] = struct{}{}
-		}
-	}
-	for target := range pm {
-		errReload := Alarm.PrometheusReload(target)
-		if errReload != nil {
-			invoker.Logger.Error("AllPrometheusReload", elog.String("step", "PrometheusReload"), elog.String("error", errReload.Error()))
-		}
-	}
-	return
-}
-
 func AlarmAttachInfo(respList []*db.Alarm) []view.RespAlarmList {
 	res := make([]view.RespAlarmList, 0)
 	for _, a := range respList {
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ego-component/egorm"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+const historyStatsDefaultRange = time.Hour * 24 * 7
+
+// HistoryStatsRange fills the default time range of history stats
+func HistoryStatsRange(req *view.ReqAlarmHistoryStats) {
+	if req.EndTime == 0 {
+		req.EndTime = time.Now().Unix()
+	}
+	if req.StartTime == 0 {
+		req.StartTime = req.EndTime - int64(historyStatsDefaultRange/time.Second)
+	}
+}
+
+func historyConds(alarmIds []int, start, end int64) egorm.Conds {
+	conds := egorm.Conds{}
+	if alarmIds != nil {
+		conds["alarm_id"] = egorm.Cond{Op: "in", Val: alarmIds}
+	}
+	conds["ctime"] = egorm.Cond{Op: "between", Val: []interface{}{start, end}}
+	return conds
+}
+
+// AlarmTimeline returns the notifications of the alarm in time order and the incidents they make up
+func AlarmTimeline(alarmId int, start, end int64) (res view.RespAlarmTimeline, err error) {
+	histories, err := db.AlarmHistoryList(historyConds([]int{alarmId}, start, end))
+	if err != nil {
+		return
+	}
+	sort.SliceStable(histories, func(i, j int) bool {
+		return histories[i].ID < histories[j].ID
+	})
+	return view.RespAlarmTimeline{
+		Events:    histories,
+		Incidents: alarmIncidents(histories),
+	}, nil
+}
+
+// AlarmMTTR returns the mean time to resolve of each alarm, alarmIds nil means all the alarms
+func AlarmMTTR(alarmIds []int, start, end int64) (res []view.RespAlarmMTTR, err error) {
+	histories, err := db.AlarmHistoryList(historyConds(alarmIds, start, end))
+	if err != nil {
+		return
+	}
+	sort.SliceStable(histories, func(i, j int) bool {
+		return histories[i].ID < histories[j].ID
+	})
+	byAlarm := make(map[int][]*db.AlarmHistory)
+	for _, h := range histories {
+		byAlarm[h.AlarmId] = append(byAlarm[h.AlarmId], h)
+	}
+	names := alarmNames(byAlarm)
+	res = make([]view.RespAlarmMTTR, 0, len(byAlarm))
+	for alarmId, hs := range byAlarm {
+		item := view.RespAlarmMTTR{AlarmId: alarmId, AlarmName: names[alarmId]}
+		var total int64
+		for _, incident := range alarmIncidents(hs) {
+			item.Incidents++
+			if incident.EndsAt != 0 {
+				item.Resolved++
+				total += incident.Duration
+			}
+		}
+		if item.Resolved > 0 {
+			item.MTTR = total / int64(item.Resolved)
+		}
+		res = append(res, item)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].MTTR != res[j].MTTR {
+			return res[i].MTTR > res[j].MTTR
+		}
+		return res[i].AlarmId < res[j].AlarmId
+	})
+	return
+}
+
+// AlarmNoisiest returns the alarms with the most firing notifications
+func AlarmNoisiest(alarmIds []int, start, end int64, limit int) (res []view.RespAlarmNoisiest, err error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	conds := historyConds(alarmIds, start, end)
+	conds["status"] = push.StatusFiring
+	counts, err := db.AlarmHistoryCountByAlarm(conds, limit)
+	if err != nil {
+		return
+	}
+	ids := make(map[int][]*db.AlarmHistory, len(counts))
+	for _, c := range counts {
+		ids[c.AlarmId] = nil
+	}
+	names := alarmNames(ids)
+	res = make([]view.RespAlarmNoisiest, 0, len(counts))
+	for _, c := range counts {
+		res = append(res, view.RespAlarmNoisiest{AlarmId: c.AlarmId, AlarmName: names[c.AlarmId], Notifications: c.Count})
+	}
+	return
+}
+
+func alarmNames(byAlarm map[int][]*db.AlarmHistory) map[int]string {
+	names := make(map[int]string, len(byAlarm))
+	if len(byAlarm) == 0 {
+		return names
+	}
+	ids := make([]int, 0, len(byAlarm))
+	for id := range byAlarm {
+		ids = append(ids, id)
+	}
+	conds := egorm.Conds{}
+	conds["id"] = egorm.Cond{Op: "in", Val: ids}
+	alarms, _ := db.AlarmList(conds)
+	for _, a := range alarms {
+		names[a.ID] = a.Name
+	}
+	return names
+}
+
+// alarmIncidents pairs the firing and resolved notifications of an alarm in time order,
+// repeated firing notifications belong to the open incident.
+func alarmIncidents(histories []*db.AlarmHistory) []view.AlarmIncident {
+	res := make([]view.AlarmIncident, 0)
+	var open *view.AlarmIncident
+	for _, h := range histories {
+		switch h.Status {
+		case push.StatusFiring:
+			if open != nil {
+				open.Notifications++
+				continue
+			}
+			startsAt := h.StartsAt
+			if startsAt == 0 {
+				startsAt = h.Ctime
+			}
+			open = &view.AlarmIncident{StartsAt: startsAt, Notifications: 1, Value: h.Value}
+		case push.StatusResolved:
+			endsAt := h.EndsAt
+			if endsAt == 0 {
+				endsAt = h.Ctime
+			}
+			if open == nil {
+				// fired before the time range
+				if h.StartsAt == 0 {
+					continue
+				}
+				open = &view.AlarmIncident{StartsAt: h.StartsAt}
+			}
+			open.Notifications++
+			open.EndsAt = endsAt
+			if open.EndsAt > open.StartsAt {
+				open.Duration = open.EndsAt - open.StartsAt
+			}
+			res = append(res, *open)
+			open = nil
+		}
+	}
+	if open != nil {
+		res = append(res, *open)
+	}
+	return res
+}
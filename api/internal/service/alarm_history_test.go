@@ -0,0 +1,117 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+func Test_alarmIncidents(t *testing.T) {
+	firing := func(ctime, startsAt int64, value string) *db.AlarmHistory {
+		return &db.AlarmHistory{BaseModel: db.BaseModel{Ctime: ctime}, Status: push.StatusFiring, StartsAt: startsAt, Value: value}
+	}
+	resolved := func(ctime, startsAt, endsAt int64) *db.AlarmHistory {
+		return &db.AlarmHistory{BaseModel: db.BaseModel{Ctime: ctime}, Status: push.StatusResolved, StartsAt: startsAt, EndsAt: endsAt}
+	}
+	tests := []struct {
+		name      string
+		histories []*db.AlarmHistory
+		want      []view.AlarmIncident
+	}{
+		{
+			name:      "resolved",
+			histories: []*db.AlarmHistory{firing(110, 100, "5"), resolved(400, 100, 390)},
+			want:      []view.AlarmIncident{{StartsAt: 100, EndsAt: 390, Duration: 290, Notifications: 2, Value: "5"}},
+		},
+		{
+			name:      "repeated firing",
+			histories: []*db.AlarmHistory{firing(110, 100, "5"), firing(210, 100, "6"), resolved(400, 100, 0)},
+			want:      []view.AlarmIncident{{StartsAt: 100, EndsAt: 400, Duration: 300, Notifications: 3, Value: "5"}},
+		},
+		{
+			name:      "still firing",
+			histories: []*db.AlarmHistory{firing(110, 100, "5"), resolved(400, 100, 390), firing(500, 0, "7")},
+			want: []view.AlarmIncident{
+				{StartsAt: 100, EndsAt: 390, Duration: 290, Notifications: 2, Value: "5"},
+				{StartsAt: 500, Notifications: 1, Value: "7"},
+			},
+		},
+		{
+			name:      "fired before the range",
+			histories: []*db.AlarmHistory{resolved(400, 0, 390), resolved(600, 500, 590)},
+			want:      []view.AlarmIncident{{StartsAt: 500, EndsAt: 590, Duration: 90, Notifications: 1}},
+		},
+		{
+			name:      "legacy",
+			histories: []*db.AlarmHistory{{BaseModel: db.BaseModel{Ctime: 100}}},
+			want:      []view.AlarmIncident{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alarmIncidents(tt.histories); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alarmIncidents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_historyFromNotification(t *testing.T) {
+	startsAt := time.Unix(1654300000, 0)
+	endsAt := startsAt.Add(5 * time.Minute)
+	tests := []struct {
+		name         string
+		notification view.Notification
+		want         db.AlarmHistory
+	}{
+		{
+			name: "firing",
+			notification: view.Notification{
+				Status:            push.StatusFiring,
+				CommonLabels:      map[string]string{"alarmId": "1"},
+				CommonAnnotations: map[string]string{"value": "12"},
+				Alerts: []view.Alert{
+					{StartsAt: startsAt.Add(time.Minute), EndsAt: endsAt},
+					{StartsAt: startsAt, EndsAt: endsAt},
+				},
+			},
+			want: db.AlarmHistory{
+				AlarmId:     1,
+				Status:      push.StatusFiring,
+				StartsAt:    startsAt.Unix(),
+				Labels:      db.String2String{"alarmId": "1"},
+				Annotations: db.String2String{"value": "12"},
+				Value:       "12",
+			},
+		},
+		{
+			name: "resolved without the value annotation",
+			notification: view.Notification{
+				Status: push.StatusResolved,
+				Alerts: []view.Alert{{
+					StartsAt:    startsAt,
+					EndsAt:      endsAt,
+					Annotations: map[string]string{"description": "too many errors  (当前值: 3.5)"},
+				}},
+			},
+			want: db.AlarmHistory{
+				AlarmId:     1,
+				Status:      push.StatusResolved,
+				StartsAt:    startsAt.Unix(),
+				EndsAt:      endsAt.Unix(),
+				Annotations: db.String2String{"description": "too many errors  (当前值: 3.5)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := historyFromNotification(1, tt.notification); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("historyFromNotification() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,665 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+const (
+	AlarmImportCreate    = "create"
+	AlarmImportUpdate    = "update"
+	AlarmImportDelete    = "delete"
+	AlarmImportUnchanged = "unchanged"
+)
+
+// alarmRefs resolves the tables and the channels of the alarm documents, by ids when exporting and by names when importing
+type alarmRefs struct {
+	tables     map[int]db.BaseTable
+	refs       map[int]view.AlarmTableRef
+	tids       map[view.AlarmTableRef]int
+	channels   map[int]string
+	channelIds map[string]int // 0 for the names shared by several channels
+
+	escalations   map[int]string
+	escalationIds map[string]int // 0 for the names shared by several policies
+	oncalls       map[int]string
+	oncallIds     map[string]int // 0 for the names shared by several schedules
+}
+
+func newAlarmRefs() (*alarmRefs, error) {
+	channels, err := db.AlarmChannelList(egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	escalations, err := db.AlarmEscalationList(egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	oncalls, err := db.AlarmOncallList(egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	r := &alarmRefs{
+		tables:        make(map[int]db.BaseTable),
+		refs:          make(map[int]view.AlarmTableRef),
+		tids:          make(map[view.AlarmTableRef]int),
+		channels:      make(map[int]string),
+		channelIds:    make(map[string]int),
+		escalations:   make(map[int]string),
+		escalationIds: make(map[string]int),
+		oncalls:       make(map[int]string),
+		oncallIds:     make(map[string]int),
+	}
+	for _, ch := range channels {
+		r.addChannel(ch.ID, ch.Name)
+	}
+	for _, e := range escalations {
+		r.addEscalation(e.ID, e.Name)
+	}
+	for _, o := range oncalls {
+		r.addOncall(o.ID, o.Name)
+	}
+	return r, nil
+}
+
+func (r *alarmRefs) addChannel(id int, name string) {
+	r.channels[id] = name
+	if _, ok := r.channelIds[name]; ok {
+		r.channelIds[name] = 0
+		return
+	}
+	r.channelIds[name] = id
+}
+
+func (r *alarmRefs) addEscalation(id int, name string) {
+	r.escalations[id] = name
+	if _, ok := r.escalationIds[name]; ok {
+		r.escalationIds[name] = 0
+		return
+	}
+	r.escalationIds[name] = id
+}
+
+func (r *alarmRefs) addOncall(id int, name string) {
+	r.oncalls[id] = name
+	if _, ok := r.oncallIds[name]; ok {
+		r.oncallIds[name] = 0
+		return
+	}
+	r.oncallIds[name] = id
+}
+
+func (r *alarmRefs) addTable(table db.BaseTable, ref view.AlarmTableRef) {
+	r.tables[table.ID] = table
+	r.refs[table.ID] = ref
+	r.tids[ref] = table.ID
+}
+
+func (r *alarmRefs) table(tid int) (db.BaseTable, view.AlarmTableRef, error) {
+	if table, ok := r.tables[tid]; ok {
+		return table, r.refs[tid], nil
+	}
+	table, err := db.TableInfo(invoker.Db, tid)
+	if err != nil {
+		return table, view.AlarmTableRef{}, err
+	}
+	if table.ID == 0 || table.Database == nil {
+		return table, view.AlarmTableRef{}, errors.Errorf("table %d not found", tid)
+	}
+	instance, err := db.InstanceInfo(invoker.Db, table.Database.Iid)
+	if err != nil {
+		return table, view.AlarmTableRef{}, err
+	}
+	ref := view.AlarmTableRef{Instance: instance.Name, Database: table.Database.Name, Table: table.Name}
+	r.addTable(table, ref)
+	return table, ref, nil
+}
+
+func (r *alarmRefs) tid(ref view.AlarmTableRef) (int, error) {
+	if tid, ok := r.tids[ref]; ok {
+		return tid, nil
+	}
+	instance, err := db.InstanceInfoX(invoker.Db, map[string]interface{}{"name": ref.Instance})
+	if err != nil {
+		return 0, err
+	}
+	if instance.ID == 0 {
+		return 0, errors.Errorf("instance %s not found", ref.Instance)
+	}
+	database, err := db.DatabaseInfoX(invoker.Db, map[string]interface{}{"iid": instance.ID, "name": ref.Database})
+	if err != nil {
+		return 0, err
+	}
+	if database.ID == 0 {
+		return 0, errors.Errorf("database %s.%s not found", ref.Instance, ref.Database)
+	}
+	table, err := db.TableInfoX(invoker.Db, map[string]interface{}{"did": database.ID, "name": ref.Table})
+	if err != nil {
+		return 0, err
+	}
+	if table.ID == 0 {
+		return 0, errors.Errorf("table %s.%s.%s not found", ref.Instance, ref.Database, ref.Table)
+	}
+	if _, _, err = r.table(table.ID); err != nil {
+		return 0, err
+	}
+	return table.ID, nil
+}
+
+// alarmSpec returns the spec of the stored alarm
+func (r *alarmRefs) alarmSpec(obj *db.Alarm) (view.AlarmSpec, error) {
+	filters, err := db.AlarmFilterList(egorm.Conds{"alarm_id": obj.ID})
+	if err != nil {
+		return view.AlarmSpec{}, err
+	}
+	conditions, err := db.AlarmConditionList(egorm.Conds{"alarm_id": obj.ID})
+	if err != nil {
+		return view.AlarmSpec{}, err
+	}
+	return r.spec(obj, filters, conditions)
+}
+
+func (r *alarmRefs) spec(obj *db.Alarm, filters []*db.AlarmFilter, conditions []*db.AlarmCondition) (spec view.AlarmSpec, err error) {
+	_, ref, err := r.table(obj.Tid)
+	if err != nil {
+		return
+	}
+	spec = view.AlarmSpec{
+		Uuid:             obj.Uuid,
+		Name:             obj.Name,
+		Desc:             obj.Desc,
+		Table:            ref,
+		Interval:         obj.Interval,
+		Unit:             obj.Unit,
+		Mode:             obj.Mode,
+		Level:            obj.Level,
+		NoDataOp:         obj.NoDataOp,
+		ForDuration:      obj.ForDuration,
+		Freshness:        obj.Freshness,
+		Tags:             obj.Tags,
+		GroupBy:          obj.GroupBy,
+		FiringTemplate:   obj.FiringTemplate,
+		ResolvedTemplate: obj.ResolvedTemplate,
+		Escalation:       r.escalations[obj.EscalationId],
+		Oncall:           r.oncalls[obj.OncallId],
+		Channels:         make([]string, 0, len(obj.ChannelIds)),
+		Filters:          make([]view.AlarmFilterSpec, 0, len(filters)),
+		Conditions:       make([]view.AlarmConditionSpec, 0, len(conditions)),
+	}
+	for _, id := range obj.ChannelIds {
+		// the deleted channels are not notified either
+		if name, ok := r.channels[id]; ok {
+			spec.Channels = append(spec.Channels, name)
+		}
+	}
+	for _, f := range filters {
+		filter := view.AlarmFilterSpec{When: f.When, Typ: f.SetOperatorTyp, Exp: f.SetOperatorExp}
+		if f.Tid != obj.Tid {
+			_, filterRef, errTable := r.table(f.Tid)
+			if errTable != nil {
+				return spec, errTable
+			}
+			filter.Table = &filterRef
+		}
+		spec.Filters = append(spec.Filters, filter)
+	}
+	for _, c := range conditions {
+		spec.Conditions = append(spec.Conditions, view.AlarmConditionSpec{
+			Typ:  c.SetOperatorTyp,
+			Exp:  c.SetOperatorExp,
+			Cond: c.Cond,
+			Val1: c.Val1,
+			Val2: c.Val2,
+		})
+	}
+	if obj.Mode == db.AlarmModeAnomaly {
+		anomaly := obj.Anomaly
+		spec.Anomaly = &anomaly
+	}
+	normalizeAlarmSpec(&spec)
+	return spec, nil
+}
+
+// request returns the table and the create request of the spec
+func (r *alarmRefs) request(spec view.AlarmSpec) (tid int, req view.ReqAlarmCreate, err error) {
+	if tid, err = r.tid(spec.Table); err != nil {
+		return
+	}
+	req = view.ReqAlarmCreate{
+		Name:             spec.Name,
+		Desc:             spec.Desc,
+		Interval:         spec.Interval,
+		Unit:             spec.Unit,
+		NoDataOp:         spec.NoDataOp,
+		Tags:             spec.Tags,
+		Mode:             spec.Mode,
+		Level:            spec.Level,
+		ForDuration:      spec.ForDuration,
+		Freshness:        spec.Freshness,
+		FiringTemplate:   spec.FiringTemplate,
+		ResolvedTemplate: spec.ResolvedTemplate,
+		GroupBy:          spec.GroupBy,
+	}
+	if spec.Anomaly != nil {
+		req.Anomaly = *spec.Anomaly
+	}
+	for _, name := range spec.Channels {
+		id, ok := r.channelIds[name]
+		if !ok {
+			return tid, req, errors.Errorf("channel %s not found", name)
+		}
+		if id == 0 {
+			return tid, req, errors.Errorf("channel name %s is shared by several channels", name)
+		}
+		req.ChannelIds = append(req.ChannelIds, id)
+	}
+	if spec.Escalation != "" {
+		id, ok := r.escalationIds[spec.Escalation]
+		if !ok {
+			return tid, req, errors.Errorf("escalation policy %s not found", spec.Escalation)
+		}
+		if id == 0 {
+			return tid, req, errors.Errorf("escalation policy name %s is shared by several policies", spec.Escalation)
+		}
+		req.EscalationId = id
+	}
+	if spec.Oncall != "" {
+		id, ok := r.oncallIds[spec.Oncall]
+		if !ok {
+			return tid, req, errors.Errorf("on-call schedule %s not found", spec.Oncall)
+		}
+		if id == 0 {
+			return tid, req, errors.Errorf("on-call schedule name %s is shared by several schedules", spec.Oncall)
+		}
+		req.OncallId = id
+	}
+	for _, f := range spec.Filters {
+		filter := view.ReqAlarmFilterCreate{Tid: tid, When: f.When, SetOperatorTyp: f.Typ, SetOperatorExp: f.Exp, Mode: spec.Mode}
+		if f.Table != nil {
+			if f.Typ == 0 {
+				return tid, req, errors.New("the default filter should be of the table of the alarm")
+			}
+			if filter.Tid, err = r.tid(*f.Table); err != nil {
+				return
+			}
+		}
+		req.Filters = append(req.Filters, filter)
+	}
+	for _, c := range spec.Conditions {
+		condition := view.ReqAlarmConditionCreate{SetOperatorTyp: c.Typ, SetOperatorExp: c.Exp, Cond: c.Cond, Val1: c.Val1, Val2: c.Val2}
+		if err = conditionValidate(condition); err != nil {
+			return
+		}
+		req.Conditions = append(req.Conditions, condition)
+	}
+	if err = push.ValidateMessageTemplate(req.FiringTemplate); err != nil {
+		return tid, req, errors.Wrap(err, "firing template")
+	}
+	if err = push.ValidateMessageTemplate(req.ResolvedTemplate); err != nil {
+		return tid, req, errors.Wrap(err, "resolved template")
+	}
+	return
+}
+
+// normalizeAlarmSpec makes the equal alarms have the same spec
+func normalizeAlarmSpec(spec *view.AlarmSpec) {
+	sort.Strings(spec.Channels)
+	for i := range spec.Filters {
+		if spec.Filters[i].When == "" {
+			spec.Filters[i].When = "1=1"
+		}
+		if spec.Filters[i].Table != nil && *spec.Filters[i].Table == spec.Table {
+			spec.Filters[i].Table = nil
+		}
+	}
+	// the conditions are stored in the order of their operators
+	sort.SliceStable(spec.Conditions, func(i, j int) bool {
+		return spec.Conditions[i].Typ < spec.Conditions[j].Typ
+	})
+	if len(spec.Tags) == 0 {
+		spec.Tags = nil
+	}
+	if len(spec.GroupBy) == 0 {
+		spec.GroupBy = nil
+	}
+	if spec.Conditions == nil {
+		spec.Conditions = make([]view.AlarmConditionSpec, 0)
+	}
+	if spec.Mode != db.AlarmModeAnomaly {
+		spec.Anomaly = nil
+	}
+}
+
+// AlarmExport returns the document of the alarms sorted by their names
+func AlarmExport(alarms []*db.Alarm) (doc view.AlarmDocument, err error) {
+	refs, err := newAlarmRefs()
+	if err != nil {
+		return
+	}
+	doc.Alarms = make([]view.AlarmSpec, 0, len(alarms))
+	for _, a := range alarms {
+		spec, errSpec := refs.alarmSpec(a)
+		if errSpec != nil {
+			return doc, errors.Wrapf(errSpec, "alarm %s", a.Name)
+		}
+		doc.Alarms = append(doc.Alarms, spec)
+	}
+	sortAlarmSpecs(doc.Alarms)
+	return doc, nil
+}
+
+func sortAlarmSpecs(specs []view.AlarmSpec) {
+	sort.SliceStable(specs, func(i, j int) bool {
+		if specs[i].Name != specs[j].Name {
+			return specs[i].Name < specs[j].Name
+		}
+		return specs[i].Uuid < specs[j].Uuid
+	})
+}
+
+// ParseAlarmDocument parses the yaml document strictly and checks the alarms are identified uniquely
+func ParseAlarmDocument(content []byte) (doc view.AlarmDocument, err error) {
+	if err = yaml.UnmarshalStrict(content, &doc); err != nil {
+		return doc, errors.Wrap(err, "invalid document")
+	}
+	names := make(map[string]struct{}, len(doc.Alarms))
+	uuids := make(map[string]struct{}, len(doc.Alarms))
+	for i := range doc.Alarms {
+		spec := &doc.Alarms[i]
+		if spec.Name == "" {
+			return doc, errors.Errorf("alarm %d: name is required", i)
+		}
+		if _, ok := names[spec.Name]; ok {
+			return doc, errors.Errorf("alarm %s: duplicate name", spec.Name)
+		}
+		names[spec.Name] = struct{}{}
+		if spec.Uuid != "" {
+			if _, ok := uuids[spec.Uuid]; ok {
+				return doc, errors.Errorf("alarm %s: duplicate uuid %s", spec.Name, spec.Uuid)
+			}
+			uuids[spec.Uuid] = struct{}{}
+		}
+		if err = alarmSpecValidate(spec); err != nil {
+			return doc, errors.Wrapf(err, "alarm %s", spec.Name)
+		}
+	}
+	return doc, nil
+}
+
+// alarmSpecValidate checks the required fields of the spec and normalizes it
+func alarmSpecValidate(spec *view.AlarmSpec) error {
+	if spec.Table.Instance == "" || spec.Table.Database == "" || spec.Table.Table == "" {
+		return errors.New("instance, database and table are required")
+	}
+	if spec.Interval <= 0 || len(spec.Channels) == 0 || len(spec.Filters) == 0 {
+		return errors.New("interval, channels and filters are required")
+	}
+	// anomaly alarms compare with their baselines instead of the conditions
+	if len(spec.Conditions) == 0 && spec.Mode != db.AlarmModeAnomaly {
+		return errors.New("conditions are required")
+	}
+	normalizeAlarmSpec(spec)
+	return nil
+}
+
+// alarmSpecDiff returns the changed fields of the spec, cur is nil for the created alarms
+func alarmSpecDiff(cur *view.AlarmSpec, next view.AlarmSpec) ([]view.AlarmFieldChange, error) {
+	oldFields := make(map[string]json.RawMessage)
+	if cur != nil {
+		if err := specFields(*cur, oldFields); err != nil {
+			return nil, err
+		}
+	}
+	newFields := make(map[string]json.RawMessage)
+	if err := specFields(next, newFields); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(newFields))
+	for k := range newFields {
+		keys = append(keys, k)
+	}
+	for k := range oldFields {
+		if _, ok := newFields[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	changes := make([]view.AlarmFieldChange, 0)
+	for _, k := range keys {
+		if string(oldFields[k]) != string(newFields[k]) {
+			changes = append(changes, view.AlarmFieldChange{Field: k, Old: string(oldFields[k]), New: string(newFields[k])})
+		}
+	}
+	return changes, nil
+}
+
+func specFields(spec view.AlarmSpec, fields map[string]json.RawMessage) error {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &fields)
+}
+
+// alarmImportStep is a change of the import
+type alarmImportStep struct {
+	item    view.AlarmImportItem
+	tid     int
+	req     view.ReqAlarmCreate
+	current *db.Alarm // nil for the created alarms
+
+	templateId   int // the alarm is derived from the template
+	templateVars db.String2String
+}
+
+// AlarmImport applies the document, alarms are matched by their uuids then their names, and only the changed ones are updated.
+// The alarms of the tables in the document that are not in the document are deleted when pruning.
+// check is called with the tables of all the changes before any of them is applied.
+func AlarmImport(uid int, req view.ReqAlarmImport, check func(table db.BaseTable, act string) error) (res view.RespAlarmImport, err error) {
+	doc, err := ParseAlarmDocument([]byte(req.Content))
+	if err != nil {
+		return
+	}
+	refs, err := newAlarmRefs()
+	if err != nil {
+		return
+	}
+	var (
+		steps   = make([]alarmImportStep, 0, len(doc.Alarms))
+		matched = make(map[int]struct{})
+		tids    = make(map[int]struct{})
+	)
+	for _, spec := range doc.Alarms {
+		step, errPlan := alarmImportPlan(refs, spec, matched, check)
+		if errPlan != nil {
+			return res, errors.Wrapf(errPlan, "alarm %s", spec.Name)
+		}
+		tids[step.tid] = struct{}{}
+		steps = append(steps, step)
+	}
+	if req.Prune && len(tids) > 0 {
+		pruned, errPrune := alarmImportPrune(refs, tids, matched, check)
+		if errPrune != nil {
+			return res, errPrune
+		}
+		steps = append(steps, pruned...)
+	}
+	return alarmImportSteps(uid, req.DryRun, steps)
+}
+
+// alarmImportSteps applies the planned steps in order, nothing is changed for dry runs
+func alarmImportSteps(uid int, dryRun bool, steps []alarmImportStep) (res view.RespAlarmImport, err error) {
+	res.DryRun = dryRun
+	res.Items = make([]view.AlarmImportItem, 0, len(steps))
+	for _, step := range steps {
+		res.Items = append(res.Items, step.item)
+	}
+	if dryRun {
+		return res, nil
+	}
+	for _, step := range steps {
+		if err = alarmImportApply(uid, step); err != nil {
+			return res, errors.Wrapf(err, "%s alarm %s", step.item.Action, step.item.Name)
+		}
+	}
+	return res, nil
+}
+
+func alarmImportPlan(refs *alarmRefs, spec view.AlarmSpec, matched map[int]struct{}, check func(table db.BaseTable, act string) error) (step alarmImportStep, err error) {
+	current, err := alarmImportMatch(spec)
+	if err != nil {
+		return
+	}
+	return alarmPlan(refs, spec, current, matched, check)
+}
+
+// alarmPlan returns the change of the spec against the current alarm, nil for the created ones
+func alarmPlan(refs *alarmRefs, spec view.AlarmSpec, current *db.Alarm, matched map[int]struct{}, check func(table db.BaseTable, act string) error) (step alarmImportStep, err error) {
+	step.current = current
+	if step.tid, step.req, err = refs.request(spec); err != nil {
+		return
+	}
+	table, _, err := refs.table(step.tid)
+	if err != nil {
+		return
+	}
+	if err = check(table, pmsplugin.ActEdit); err != nil {
+		return
+	}
+	if !InstanceCapability(table.Database.Iid).Alarm {
+		return step, errors.New("the datasource of the table does not support alarms")
+	}
+	step.item = view.AlarmImportItem{Name: spec.Name, Uuid: spec.Uuid, Action: AlarmImportCreate}
+	if step.current == nil {
+		step.item.Changes, err = alarmSpecDiff(nil, spec)
+		return
+	}
+	if _, ok := matched[step.current.ID]; ok {
+		return step, errors.Errorf("alarm %s is matched twice", step.current.Name)
+	}
+	matched[step.current.ID] = struct{}{}
+	if step.current.Tid != step.tid {
+		currentTable, _, errTable := refs.table(step.current.Tid)
+		if errTable != nil {
+			return step, errTable
+		}
+		if err = check(currentTable, pmsplugin.ActEdit); err != nil {
+			return
+		}
+	}
+	cur, err := refs.alarmSpec(step.current)
+	if err != nil {
+		return
+	}
+	spec.Uuid = step.current.Uuid
+	step.item.Uuid = step.current.Uuid
+	if step.item.Changes, err = alarmSpecDiff(&cur, spec); err != nil {
+		return
+	}
+	step.item.Action = AlarmImportUpdate
+	if len(step.item.Changes) == 0 {
+		step.item.Action = AlarmImportUnchanged
+	}
+	return step, nil
+}
+
+// alarmImportMatch returns the stored alarm of the spec by its uuid, then by its name
+func alarmImportMatch(spec view.AlarmSpec) (*db.Alarm, error) {
+	if spec.Uuid != "" {
+		obj, err := db.AlarmInfoX(invoker.Db, map[string]interface{}{"uuid": spec.Uuid})
+		if err != nil {
+			return nil, err
+		}
+		if obj.ID != 0 {
+			return &obj, nil
+		}
+	}
+	alarms, err := db.AlarmList(egorm.Conds{"name": spec.Name})
+	if err != nil {
+		return nil, err
+	}
+	switch len(alarms) {
+	case 0:
+		return nil, nil
+	case 1:
+		return alarms[0], nil
+	}
+	return nil, errors.Errorf("%d alarms are named %s, the uuid is required", len(alarms), spec.Name)
+}
+
+func alarmImportPrune(refs *alarmRefs, tids, matched map[int]struct{}, check func(table db.BaseTable, act string) error) ([]alarmImportStep, error) {
+	ids := make([]int, 0, len(tids))
+	for tid := range tids {
+		ids = append(ids, tid)
+	}
+	alarms, err := db.AlarmList(egorm.Conds{"tid": egorm.Cond{Op: "in", Val: ids}})
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]alarmImportStep, 0)
+	for _, a := range alarms {
+		if _, ok := matched[a.ID]; ok {
+			continue
+		}
+		table, _, errTable := refs.table(a.Tid)
+		if errTable != nil {
+			return nil, errTable
+		}
+		if err = check(table, pmsplugin.ActDelete); err != nil {
+			return nil, errors.Wrapf(err, "alarm %s", a.Name)
+		}
+		steps = append(steps, alarmImportStep{
+			item:    view.AlarmImportItem{Name: a.Name, Uuid: a.Uuid, Action: AlarmImportDelete},
+			tid:     a.Tid,
+			current: a,
+		})
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].item.Name < steps[j].item.Name
+	})
+	return steps, nil
+}
+
+func alarmImportApply(uid int, step alarmImportStep) error {
+	var alarmId int
+	switch step.item.Action {
+	case AlarmImportCreate:
+		obj, err := Alarm.Create(uid, step.tid, step.item.Uuid, step.req)
+		if err != nil {
+			return err
+		}
+		alarmId = obj.ID
+	case AlarmImportUpdate:
+		// tags are labels of the rule, they are set before the rule is generated again
+		if err := db.AlarmUpdate(invoker.Db, step.current.ID, map[string]interface{}{"tag": db.String2String(step.req.Tags)}); err != nil {
+			return err
+		}
+		if err := Alarm.Update(uid, step.current.ID, step.req); err != nil {
+			return err
+		}
+		alarmId = step.current.ID
+	case AlarmImportUnchanged:
+		alarmId = step.current.ID
+	case AlarmImportDelete:
+		instanceInfo, tableInfo, alarmInfo, err := db.GetAlarmTableInstanceInfo(step.current.ID)
+		if err != nil {
+			return err
+		}
+		return Alarm.Delete(instanceInfo, tableInfo, alarmInfo)
+	}
+	if step.templateId == 0 {
+		return nil
+	}
+	// the variables of unchanged alarms may change as well
+	return db.AlarmUpdate(invoker.Db, alarmId, map[string]interface{}{"template_id": step.templateId, "template_vars": step.templateVars})
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func testAlarmRefs() *alarmRefs {
+	r := &alarmRefs{
+		tables:        make(map[int]db.BaseTable),
+		refs:          make(map[int]view.AlarmTableRef),
+		tids:          make(map[view.AlarmTableRef]int),
+		channels:      make(map[int]string),
+		channelIds:    make(map[string]int),
+		escalations:   make(map[int]string),
+		escalationIds: make(map[string]int),
+		oncalls:       make(map[int]string),
+		oncallIds:     make(map[string]int),
+	}
+	r.addTable(db.BaseTable{BaseModel: db.BaseModel{ID: 1}, Name: "app"}, view.AlarmTableRef{Instance: "ck", Database: "logs", Table: "app"})
+	r.addTable(db.BaseTable{BaseModel: db.BaseModel{ID: 2}, Name: "nginx"}, view.AlarmTableRef{Instance: "ck", Database: "logs", Table: "nginx"})
+	r.addChannel(10, "ops")
+	r.addChannel(11, "dev")
+	r.addChannel(12, "shared")
+	r.addChannel(13, "shared")
+	r.addEscalation(3, "oncall")
+	r.addOncall(5, "sre")
+	return r
+}
+
+func testAlarmObj() (*db.Alarm, []*db.AlarmFilter, []*db.AlarmCondition) {
+	obj := &db.Alarm{
+		BaseModel:    db.BaseModel{ID: 7},
+		Tid:          1,
+		Uuid:         "2c5e",
+		Name:         "errors",
+		Interval:     1,
+		Tags:         db.String2String{"team": "infra"},
+		ChannelIds:   db.Ints{11, 10, 99},
+		EscalationId: 3,
+		OncallId:     5,
+	}
+	filters := []*db.AlarmFilter{
+		{Tid: 1, When: "level='error'"},
+		{Tid: 2, When: "status>499", SetOperatorTyp: 1, SetOperatorExp: "app.trace_id=nginx.trace_id"},
+	}
+	conditions := []*db.AlarmCondition{
+		{SetOperatorTyp: 1, SetOperatorExp: 4, Cond: 0, Val1: 10},
+		{SetOperatorTyp: 0, SetOperatorExp: 0, Cond: 2, Val1: 0.5, Val2: 2.5},
+	}
+	return obj, filters, conditions
+}
+
+func Test_alarmRefs_spec_roundTrip(t *testing.T) {
+	r := testAlarmRefs()
+	spec, err := r.spec(testAlarmObj())
+	if err != nil {
+		t.Fatalf("spec() error = %v", err)
+	}
+	if want := []string{"dev", "ops"}; !reflect.DeepEqual(spec.Channels, want) {
+		t.Errorf("channels = %v, want %v", spec.Channels, want)
+	}
+	if spec.Filters[0].Table != nil || spec.Filters[1].Table == nil || spec.Filters[1].Table.Table != "nginx" {
+		t.Errorf("filters = %+v", spec.Filters)
+	}
+	if spec.Conditions[0].Typ != 0 {
+		t.Errorf("conditions = %+v, the WHEN condition should be the first", spec.Conditions)
+	}
+	out, err := yaml.Marshal(view.AlarmDocument{Alarms: []view.AlarmSpec{spec}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseAlarmDocument(out)
+	if err != nil {
+		t.Fatalf("ParseAlarmDocument() error = %v\n%s", err, out)
+	}
+	changes, err := alarmSpecDiff(&spec, doc.Alarms[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("round trip changes = %+v", changes)
+	}
+	again, _ := yaml.Marshal(doc)
+	if string(again) != string(out) {
+		t.Errorf("export is not stable:\n%s\n%s", out, again)
+	}
+}
+
+func Test_alarmRefs_request(t *testing.T) {
+	r := testAlarmRefs()
+	spec, _ := r.spec(testAlarmObj())
+	spec.Mode = db.AlarmModeWithInSQL
+	tid, req, err := r.request(spec)
+	if err != nil {
+		t.Fatalf("request() error = %v", err)
+	}
+	if tid != 1 || !reflect.DeepEqual(req.ChannelIds, []int{11, 10}) || req.EscalationId != 3 || req.OncallId != 5 {
+		t.Errorf("request() tid = %d, channels = %v, escalation = %d", tid, req.ChannelIds, req.EscalationId)
+	}
+	if req.Filters[0].Tid != 1 || req.Filters[1].Tid != 2 || req.Filters[1].Mode != db.AlarmModeWithInSQL {
+		t.Errorf("request() filters = %+v", req.Filters)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(spec *view.AlarmSpec)
+	}{
+		{name: "unknown channel", modify: func(spec *view.AlarmSpec) { spec.Channels = []string{"qa"} }},
+		{name: "unknown escalation", modify: func(spec *view.AlarmSpec) { spec.Escalation = "qa" }},
+		{name: "unknown on-call schedule", modify: func(spec *view.AlarmSpec) { spec.Oncall = "qa" }},
+		{name: "ambiguous channel", modify: func(spec *view.AlarmSpec) { spec.Channels = []string{"shared"} }},
+		{name: "default filter of another table", modify: func(spec *view.AlarmSpec) { spec.Filters[0].Table = spec.Filters[1].Table }},
+		{name: "invalid condition", modify: func(spec *view.AlarmSpec) { spec.Conditions[0].Cond = 9 }},
+		{name: "invalid template", modify: func(spec *view.AlarmSpec) { spec.FiringTemplate = "{{ .Alarm" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, _ := r.spec(testAlarmObj())
+			tt.modify(&spec)
+			if _, _, err := r.request(spec); err == nil {
+				t.Error("request() expected an error")
+			}
+		})
+	}
+}
+
+func TestParseAlarmDocument(t *testing.T) {
+	const alarm = `
+  table: {instance: ck, database: logs, table: app}
+  interval: 1
+  channels: [ops]
+  filters: [{when: "1=1"}]
+  conditions: [{typ: 0, exp: 0, cond: 0, val1: 1}]`
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: "alarms:\n- name: a" + alarm + "\n- name: b" + alarm},
+		{name: "duplicate name", content: "alarms:\n- name: a" + alarm + "\n- name: a" + alarm, wantErr: true},
+		{name: "duplicate uuid", content: "alarms:\n- name: a\n  uuid: u" + alarm + "\n- name: b\n  uuid: u" + alarm, wantErr: true},
+		{name: "unknown field", content: "alarms:\n- name: a\n  threshold: 1" + alarm, wantErr: true},
+		{name: "missing table", content: "alarms:\n- name: a\n  interval: 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAlarmDocument([]byte(tt.content)); (err != nil) != tt.wantErr {
+				t.Errorf("ParseAlarmDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_alarmSpecDiff(t *testing.T) {
+	cur, _ := testAlarmRefs().spec(testAlarmObj())
+	next := cur
+	next.Interval = 5
+	next.Tags = nil
+	changes, err := alarmSpecDiff(&cur, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []view.AlarmFieldChange{
+		{Field: "interval", Old: "1", New: "5"},
+		{Field: "tags", Old: `{"team":"infra"}`, New: ""},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("alarmSpecDiff() = %+v, want %+v", changes, want)
+	}
+	created, _ := alarmSpecDiff(nil, next)
+	for _, c := range created {
+		if c.Old != "" {
+			t.Errorf("alarmSpecDiff() of created alarm = %+v", c)
+		}
+	}
+}
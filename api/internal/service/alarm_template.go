@@ -0,0 +1,242 @@
+package service
+
+import (
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// alarmTemplateVar is the placeholder of the variables, ${name}.
+// The message templates of the alarm use {{ }} already.
+var alarmTemplateVar = regexp.MustCompile(`\$\{(\w*)}`)
+
+// alarmTemplateTarget is a table the template is applied to
+type alarmTemplateTarget struct {
+	tid     int
+	current *db.Alarm // the alarm derived from the template, nil for the new ones
+	vars    map[string]string
+}
+
+// AlarmTemplateValidate checks the placeholders of the template
+func AlarmTemplateValidate(req view.ReqAlarmTemplateCreate) error {
+	for _, m := range alarmTemplateVar.FindAllStringSubmatch(req.Content, -1) {
+		if m[1] == "" {
+			return errors.New("empty variable name")
+		}
+	}
+	return nil
+}
+
+// renderAlarmTemplate replaces the variables of the template and parses the alarm spec.
+// The instance, database and table variables are the names of the table.
+func renderAlarmTemplate(content string, vars map[string]string, ref view.AlarmTableRef) (spec view.AlarmSpec, err error) {
+	values := make(map[string]string, len(vars)+3)
+	for k, v := range vars {
+		values[k] = v
+	}
+	values["instance"], values["database"], values["table"] = ref.Instance, ref.Database, ref.Table
+	missing := make([]string, 0)
+	out := alarmTemplateVar.ReplaceAllStringFunc(content, func(s string) string {
+		name := alarmTemplateVar.FindStringSubmatch(s)[1]
+		v, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return spec, errors.Errorf("variables %v are not set", missing)
+	}
+	if err = yaml.UnmarshalStrict([]byte(out), &spec); err != nil {
+		return spec, errors.Wrap(err, "invalid template")
+	}
+	if spec.Name == "" {
+		return spec, errors.New("name is required")
+	}
+	// the derived alarms are identified by the template and the table
+	spec.Uuid = ""
+	spec.Table = ref
+	return spec, alarmSpecValidate(&spec)
+}
+
+// alarmTemplateMatch matches the name with the glob pattern, empty patterns match all the names
+func alarmTemplateMatch(pattern, name string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	ok, err := path.Match(pattern, name)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid pattern %s", pattern)
+	}
+	return ok, nil
+}
+
+// alarmTemplateTables returns the tables of the request in the order of their ids
+func alarmTemplateTables(req view.ReqAlarmTemplateApply) ([]int, error) {
+	if len(req.Tids) > 0 {
+		tids := append([]int{}, req.Tids...)
+		sort.Ints(tids)
+		return tids, nil
+	}
+	if req.Database == "" && req.Table == "" {
+		return nil, errors.New("tables or patterns of the database and table names are required")
+	}
+	conds := egorm.Conds{}
+	if req.Iid != 0 {
+		conds["iid"] = req.Iid
+	}
+	databases, err := db.DatabaseList(invoker.Db, conds)
+	if err != nil {
+		return nil, err
+	}
+	dids := make([]int, 0, len(databases))
+	for _, d := range databases {
+		ok, errMatch := alarmTemplateMatch(req.Database, d.Name)
+		if errMatch != nil {
+			return nil, errMatch
+		}
+		if ok {
+			dids = append(dids, d.ID)
+		}
+	}
+	tids := make([]int, 0)
+	if len(dids) == 0 {
+		return tids, nil
+	}
+	tables, err := db.TableList(invoker.Db, egorm.Conds{"did": egorm.Cond{Op: "in", Val: dids}})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		ok, errMatch := alarmTemplateMatch(req.Table, t.Name)
+		if errMatch != nil {
+			return nil, errMatch
+		}
+		if ok {
+			tids = append(tids, t.ID)
+		}
+	}
+	return tids, nil
+}
+
+// derivedAlarms returns the alarms of the template by their tables
+func derivedAlarms(templateId int) (map[int]*db.Alarm, error) {
+	alarms, err := db.AlarmList(egorm.Conds{"template_id": templateId})
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int]*db.Alarm, len(alarms))
+	for _, a := range alarms {
+		res[a.Tid] = a
+	}
+	return res, nil
+}
+
+// alarmTemplatePlan renders the template for every target, nothing is changed until all of them are planned
+func alarmTemplatePlan(tpl *db.AlarmTemplate, targets []alarmTemplateTarget, check func(table db.BaseTable, act string) error) ([]alarmImportStep, error) {
+	refs, err := newAlarmRefs()
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]alarmImportStep, 0, len(targets))
+	matched := make(map[int]struct{})
+	for _, target := range targets {
+		_, ref, errTable := refs.table(target.tid)
+		if errTable != nil {
+			return nil, errTable
+		}
+		vars := make(map[string]string, len(tpl.Variables)+len(target.vars))
+		for k, v := range tpl.Variables {
+			vars[k] = v
+		}
+		for k, v := range target.vars {
+			vars[k] = v
+		}
+		spec, errRender := renderAlarmTemplate(tpl.Content, vars, ref)
+		if errRender != nil {
+			return nil, errors.Wrapf(errRender, "table %s.%s.%s", ref.Instance, ref.Database, ref.Table)
+		}
+		step, errPlan := alarmPlan(refs, spec, target.current, matched, check)
+		if errPlan != nil {
+			return nil, errors.Wrapf(errPlan, "alarm %s", spec.Name)
+		}
+		step.templateId = tpl.ID
+		step.templateVars = target.vars
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// AlarmTemplateApply creates or updates the alarms of the template on the tables.
+// The values of the variables of a table are kept for the later changes of the template.
+func AlarmTemplateApply(uid int, tpl *db.AlarmTemplate, req view.ReqAlarmTemplateApply, check func(table db.BaseTable, act string) error) (res view.RespAlarmImport, err error) {
+	tids, err := alarmTemplateTables(req)
+	if err != nil {
+		return
+	}
+	if len(tids) == 0 {
+		return res, errors.New("no table matches")
+	}
+	derived, err := derivedAlarms(tpl.ID)
+	if err != nil {
+		return
+	}
+	targets := make([]alarmTemplateTarget, 0, len(tids))
+	for _, tid := range tids {
+		target := alarmTemplateTarget{tid: tid, current: derived[tid], vars: make(map[string]string)}
+		if target.current != nil {
+			for k, v := range target.current.TemplateVars {
+				target.vars[k] = v
+			}
+		}
+		for k, v := range req.Vars {
+			target.vars[k] = v
+		}
+		targets = append(targets, target)
+	}
+	steps, err := alarmTemplatePlan(tpl, targets, check)
+	if err != nil {
+		return
+	}
+	return alarmImportSteps(uid, req.DryRun, steps)
+}
+
+// AlarmTemplateUpdate updates the template and propagates the change to all the alarms derived from it.
+// The template is not updated when any of the alarms fails to be planned.
+func AlarmTemplateUpdate(uid int, tpl db.AlarmTemplate, req view.ReqAlarmTemplateUpdate, check func(table db.BaseTable, act string) error) (res view.RespAlarmImport, err error) {
+	tpl.Name, tpl.Desc, tpl.Content, tpl.Variables = req.Name, req.Desc, req.Content, req.Variables
+	derived, err := derivedAlarms(tpl.ID)
+	if err != nil {
+		return
+	}
+	targets := make([]alarmTemplateTarget, 0, len(derived))
+	for tid, a := range derived {
+		targets = append(targets, alarmTemplateTarget{tid: tid, current: a, vars: a.TemplateVars})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].tid < targets[j].tid
+	})
+	steps, err := alarmTemplatePlan(&tpl, targets, check)
+	if err != nil {
+		return
+	}
+	if !req.DryRun {
+		ups := make(map[string]interface{}, 0)
+		ups["name"] = tpl.Name
+		ups["desc"] = tpl.Desc
+		ups["content"] = tpl.Content
+		ups["variables"] = tpl.Variables
+		if err = db.AlarmTemplateUpdate(invoker.Db, tpl.ID, ups); err != nil {
+			return
+		}
+	}
+	return alarmImportSteps(uid, req.DryRun, steps)
+}
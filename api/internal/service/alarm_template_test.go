@@ -0,0 +1,82 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const testAlarmTemplate = `
+name: ${table} 5xx
+interval: 1
+channels: [ops]
+firingTemplate: "{{ .Alarm.Name }} on ${database}.${table}"
+filters:
+- when: status >= ${status}
+conditions:
+- exp: 4
+  val1: ${threshold}
+`
+
+func Test_renderAlarmTemplate(t *testing.T) {
+	ref := view.AlarmTableRef{Instance: "ck", Database: "logs", Table: "nginx"}
+	spec, err := renderAlarmTemplate(testAlarmTemplate, map[string]string{"status": "500", "threshold": "20"}, ref)
+	if err != nil {
+		t.Fatalf("renderAlarmTemplate() error = %v", err)
+	}
+	if spec.Name != "nginx 5xx" || spec.Table != ref || spec.Filters[0].When != "status >= 500" || spec.Conditions[0].Val1 != 20 {
+		t.Errorf("renderAlarmTemplate() = %+v", spec)
+	}
+	// the message templates are kept
+	if spec.FiringTemplate != "{{ .Alarm.Name }} on logs.nginx" {
+		t.Errorf("renderAlarmTemplate() firing template = %q", spec.FiringTemplate)
+	}
+	tid, req, err := testAlarmRefs().request(spec)
+	if err != nil {
+		t.Fatalf("request() error = %v", err)
+	}
+	if tid != 2 || len(req.ChannelIds) != 1 || req.ChannelIds[0] != 10 || req.Conditions[0].Val1 != 20 {
+		t.Errorf("request() = %d, %+v", tid, req)
+	}
+
+	if _, err = renderAlarmTemplate(testAlarmTemplate, map[string]string{"status": "500"}, ref); err == nil || !strings.Contains(err.Error(), "threshold") {
+		t.Errorf("renderAlarmTemplate() error = %v, want the missing variable", err)
+	}
+	if _, err = renderAlarmTemplate("name: x\ninterval: 1\nchannels: [ops]\nfilters: [{when: 1=1}]", nil, ref); err == nil {
+		t.Error("renderAlarmTemplate() should require the conditions")
+	}
+	if _, err = renderAlarmTemplate("name: x\nunknown: 1", nil, ref); err == nil {
+		t.Error("renderAlarmTemplate() should reject the unknown fields")
+	}
+}
+
+func Test_alarmTemplateMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+		wantErr bool
+	}{
+		{pattern: "", name: "app", want: true},
+		{pattern: "app_*", name: "app_logs", want: true},
+		{pattern: "app_*", name: "nginx", want: false},
+		{pattern: "ngin?", name: "nginx", want: true},
+		{pattern: "[", name: "nginx", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := alarmTemplateMatch(tt.pattern, tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("alarmTemplateMatch(%q, %q) = %v, %v, want %v", tt.pattern, tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestAlarmTemplateValidate(t *testing.T) {
+	if err := AlarmTemplateValidate(view.ReqAlarmTemplateCreate{Content: testAlarmTemplate}); err != nil {
+		t.Errorf("AlarmTemplateValidate() error = %v", err)
+	}
+	if err := AlarmTemplateValidate(view.ReqAlarmTemplateCreate{Content: "name: ${}"}); err == nil {
+		t.Error("AlarmTemplateValidate() should reject the empty variable names")
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func Test_conditionExp(t *testing.T) {
+	obj := &db.Alarm{Interval: 5, Unit: 0}
+	metric := `m{uuid="a"}`
+	tests := []struct {
+		name      string
+		condition view.ReqAlarmConditionCreate
+		want      string
+	}{
+		{name: "avg above", condition: view.ReqAlarmConditionCreate{SetOperatorExp: 5, Cond: 0, Val1: 0.5}, want: `avg_over_time(m{uuid="a"}[5m])>0.5`},
+		{name: "last change", condition: view.ReqAlarmConditionCreate{Cond: 6, Val1: 50}, want: `(m{uuid="a"}-m{uuid="a"} offset 5m)/m{uuid="a"} offset 5m*100>50`},
+		{name: "max outside", condition: view.ReqAlarmConditionCreate{SetOperatorExp: 2, Cond: 2, Val1: 1, Val2: 1.25},
+			want: `(max_over_time(m{uuid="a"}[5m])<1 or max_over_time(m{uuid="a"}[5m])>1.25)`},
+		{name: "rate below", condition: view.ReqAlarmConditionCreate{Cond: 5, Val1: 0.1}, want: `sum_over_time(m{uuid="a"}[5m])/300<0.1`},
+		{name: "increase", condition: view.ReqAlarmConditionCreate{SetOperatorExp: 3, Cond: 6, Val1: 50},
+			want: `(sum_over_time(m{uuid="a"}[5m])-sum_over_time(m{uuid="a"}[5m] offset 5m))/sum_over_time(m{uuid="a"}[5m] offset 5m)*100>50`},
+		{name: "decrease", condition: view.ReqAlarmConditionCreate{SetOperatorExp: 4, Cond: 7, Val1: 20},
+			want: `(count_over_time(m{uuid="a"}[5m] offset 5m)-count_over_time(m{uuid="a"}[5m]))/count_over_time(m{uuid="a"}[5m] offset 5m)*100>20`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditionExp(metric, obj, tt.condition); got != tt.want {
+				t.Errorf("conditionExp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// the alarms created before the selectors keep the raw metric of their expressions when they are saved again
+func Test_conditionExp_preExisting(t *testing.T) {
+	obj := &db.Alarm{Interval: 1, Unit: 1}
+	metric := `m{uuid="a"}`
+	row := db.AlarmCondition{AlarmId: 1, SetOperatorTyp: 0, SetOperatorExp: 0, Cond: 2, Val1: 10, Val2: 100}
+	condition := view.ReqAlarmConditionCreate{SetOperatorTyp: row.SetOperatorTyp, SetOperatorExp: row.SetOperatorExp, Cond: row.Cond, Val1: row.Val1, Val2: row.Val2}
+	if err := conditionValidate(condition); err != nil {
+		t.Fatalf("conditionValidate() error = %v", err)
+	}
+	if got, want := conditionExp(metric, obj, condition), `(m{uuid="a"}<10 or m{uuid="a"}>100)`; got != want {
+		t.Errorf("conditionExp() = %v, want %v", got, want)
+	}
+}
+
+func Test_conditionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition view.ReqAlarmConditionCreate
+		wantErr   bool
+	}{
+		{name: "ok", condition: view.ReqAlarmConditionCreate{SetOperatorExp: 4, Cond: 7, Val1: 1}},
+		{name: "unknown selector", condition: view.ReqAlarmConditionCreate{SetOperatorExp: 6, Val1: 1}, wantErr: true},
+		{name: "unknown condition", condition: view.ReqAlarmConditionCreate{Cond: 8, Val1: 1}, wantErr: true},
+		{name: "reversed range", condition: view.ReqAlarmConditionCreate{Cond: 3, Val1: 2, Val2: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conditionValidate(tt.condition); (err != nil) != tt.wantErr {
+				t.Errorf("conditionValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/ego-component/egorm"
@@ -19,19 +20,56 @@ func Send(alarmUUID string, notification view.Notification) (err error) {
 		return err
 	}
 	// create history
-	alarmHistory := db.AlarmHistory{AlarmId: alarmObj.ID}
+	alarmHistory := historyFromNotification(alarmObj.ID, notification)
+	// the firing notifications of the stale tables would be misleading, the data source stale one has been sent instead,
+	// they are only recorded in the history with the stale status. The status of the alarm is kept up to date by the freshness ticker.
+	stale := alarmObj.Status == db.AlarmStatusStale
+	if stale && notification.Status == push.StatusFiring {
+		alarmHistory.Status = db.AlarmHistoryStatusStale
+		return db.AlarmHistoryCreate(invoker.Db, &alarmHistory)
+	}
 	if err = db.AlarmHistoryCreate(invoker.Db, &alarmHistory); err != nil {
 		return err
 	}
-	err = db.AlarmStatusUpdate(alarmObj.ID, notification.Status)
-	if err != nil {
-		return err
+	// the resolved notifications are sent, the alarm stays stale until the logs come back
+	if !stale {
+		if err = db.AlarmStatusUpdate(alarmObj.ID, notification.Status); err != nil {
+			return err
+		}
 	}
 	// one of the logs
 	ins, table, _, err := db.GetAlarmTableInstanceInfo(alarmObj.ID)
 	if err != nil {
 		return err
 	}
+	// the silenced notifications are only recorded in the history
+	silence, err := MatchedSilence(&alarmObj, table.ID, ins.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if silence != nil {
+		return db.AlarmHistoryUpdate(invoker.Db, alarmHistory.ID, map[string]interface{}{"silence_id": silence.ID})
+	}
+	// and the firing notifications inhibited by the critical alarms on the same table
+	if notification.Status == push.StatusFiring {
+		source, errInhibit := Noise.InhibitingAlarm(&alarmObj)
+		if errInhibit != nil {
+			return errInhibit
+		}
+		if source != nil {
+			return db.AlarmHistoryUpdate(invoker.Db, alarmHistory.ID, map[string]interface{}{"inhibit_id": source.ID})
+		}
+	}
+	// so are the repeated notifications of the acknowledged alerts
+	if notification.Status == push.StatusFiring {
+		inc, ok, errIncident := openIncident(alarmObj.ID)
+		if errIncident != nil {
+			return errIncident
+		}
+		if ok && inc.acked != nil {
+			return nil
+		}
+	}
 	var oneTheLogs string
 	op, err := InstanceManager.Load(ins.ID)
 	if err != nil {
@@ -65,33 +103,87 @@ func Send(alarmUUID string, notification view.Notification) (err error) {
 			oneTheLogs = val.(string)
 		}
 	}
-	for _, channelId := range alarmObj.ChannelIds {
-		channelInfo, errAlarmChannelInfo := db.AlarmChannelInfo(invoker.Db, channelId)
-		if errAlarmChannelInfo != nil {
-			return errAlarmChannelInfo
-		}
-		channelInstance, errChannelType := push.Instance(channelInfo.Typ)
-		if errChannelType != nil {
-			return errChannelType
+	return Noise.enqueue(&alarmObj, alarmHistory.ID, notification, oneTheLogs)
+}
+
+// enqueueDeliveries persists a delivery of the notification for every channel, the outbox sends each of them on its own
+func enqueueDeliveries(alarmId, historyId int, channelIds []int, notification view.Notification, oneTheLogs string) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	results := make(db.ChannelResults, 0, len(channelIds))
+	tx := invoker.Db.Begin()
+	for _, channelId := range channelIds {
+		delivery := &db.AlarmDelivery{
+			AlarmId:      alarmId,
+			HistoryId:    historyId,
+			ChannelId:    channelId,
+			Notification: string(payload),
+			Log:          oneTheLogs,
+			Status:       db.DeliveryStatusPending,
+			NextAt:       time.Now().Unix(),
 		}
-		errSend := channelInstance.Send(notification, &alarmObj, &channelInfo, oneTheLogs)
-		if errSend != nil {
-			return errSend
+		if err = db.AlarmDeliveryCreate(tx, delivery); err != nil {
+			tx.Rollback()
+			return err
 		}
+		results = append(results, db.ChannelResult{ChannelId: channelId, DeliveryId: delivery.ID, Status: db.DeliveryStatusPending})
+	}
+	if err = db.AlarmHistoryUpdate(tx, historyId, map[string]interface{}{"log": oneTheLogs, "channel_results": results}); err != nil {
+		tx.Rollback()
+		return err
 	}
-	if err = db.AlarmHistoryUpdate(invoker.Db, alarmHistory.ID, map[string]interface{}{"is_pushed": 1}); err != nil {
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
 		return err
 	}
+	Outbox.Wake()
 	return nil
 }
 
-func SendTestToChannel(c *db.AlarmChannel) (err error) {
+// historyFromNotification records the status, the time range, the labels and the value of the notification
+func historyFromNotification(alarmId int, notification view.Notification) db.AlarmHistory {
+	h := db.AlarmHistory{
+		AlarmId:     alarmId,
+		Status:      notification.Status,
+		Labels:      notification.CommonLabels,
+		Annotations: notification.CommonAnnotations,
+	}
+	for _, alert := range notification.Alerts {
+		if !alert.StartsAt.IsZero() && (h.StartsAt == 0 || alert.StartsAt.Unix() < h.StartsAt) {
+			h.StartsAt = alert.StartsAt.Unix()
+		}
+		if notification.Status == push.StatusResolved && alert.EndsAt.Unix() > h.EndsAt {
+			h.EndsAt = alert.EndsAt.Unix()
+		}
+		if len(h.Labels) == 0 {
+			h.Labels = alert.Labels
+		}
+		if len(h.Annotations) == 0 {
+			h.Annotations = alert.Annotations
+		}
+	}
+	h.Value = h.Annotations["value"]
+	if len(h.Value) > 64 {
+		h.Value = h.Value[:64]
+	}
+	return h
+}
+
+// SendTestToChannel sends a test message, the payload is returned for the channels with user-defined templates
+func SendTestToChannel(c *db.AlarmChannel) (payload string, err error) {
 	ci, err := push.Instance(c.Typ)
 	if err != nil {
 		return
 	}
 	n := view.Notification{}
 	a := &db.Alarm{Name: c.Name, Desc: "Test the availability of the alarm channel"}
+	if p, ok := ci.(push.Previewer); ok {
+		if payload, err = p.Preview(n, a, c, ""); err != nil {
+			return
+		}
+	}
 	err = ci.Send(n, a, c, "")
 	return
 }
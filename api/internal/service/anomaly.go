@@ -0,0 +1,128 @@
+package service
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// anomalyResult explains the evaluation of an anomaly alarm, the actual value against the band around the expected one
+type anomalyResult struct {
+	actual   float64
+	expected float64 // mean of the baseline
+	lower    float64
+	upper    float64
+	skipped  bool // below the minimum volume
+	breached bool
+}
+
+// anomalyEvaluate compares the actual value with the baseline, the values of the same window of the previous periods
+func anomalyEvaluate(cfg db.AlarmAnomaly, actual float64, baseline []float64) anomalyResult {
+	res := anomalyResult{actual: actual}
+	if len(baseline) == 0 {
+		res.skipped = true
+		return res
+	}
+	var sum float64
+	for _, v := range baseline {
+		sum += v
+	}
+	res.expected = sum / float64(len(baseline))
+	var width float64
+	switch cfg.Band {
+	case db.AnomalyBandPercent:
+		width = math.Abs(res.expected) * cfg.Threshold / 100
+	default:
+		// the sample standard deviation
+		var squares float64
+		for _, v := range baseline {
+			squares += (v - res.expected) * (v - res.expected)
+		}
+		if len(baseline) > 1 {
+			width = cfg.Threshold * math.Sqrt(squares/float64(len(baseline)-1))
+		}
+	}
+	res.lower, res.upper = res.expected-width, res.expected+width
+	if actual < cfg.MinVolume && res.expected < cfg.MinVolume {
+		res.skipped = true
+		return res
+	}
+	switch cfg.Direction {
+	case db.AnomalyDirectionAbove:
+		res.breached = actual > res.upper
+	case db.AnomalyDirectionBelow:
+		res.breached = actual < res.lower
+	default:
+		res.breached = actual > res.upper || actual < res.lower
+	}
+	return res
+}
+
+func formatAnomalyValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// anomalyValidate checks the anomaly mode of the alarm, which is evaluated by the native evaluator only
+func anomalyValidate(alarmObj *db.Alarm, ruleStoreType int) error {
+	if alarmObj.Mode != db.AlarmModeAnomaly {
+		return nil
+	}
+	if ruleStoreType != db.RuleStoreTypeNative {
+		return errors.New("the anomaly mode is only supported by the native rule store")
+	}
+	cfg := alarmObj.Anomaly
+	if cfg.Season != db.AnomalySeasonDay && cfg.Season != db.AnomalySeasonWeek {
+		return errors.Errorf("invalid anomaly season %d", cfg.Season)
+	}
+	if cfg.Band != db.AnomalyBandStddev && cfg.Band != db.AnomalyBandPercent {
+		return errors.Errorf("invalid anomaly band %d", cfg.Band)
+	}
+	if cfg.Direction < db.AnomalyDirectionBoth || cfg.Direction > db.AnomalyDirectionBelow {
+		return errors.Errorf("invalid anomaly direction %d", cfg.Direction)
+	}
+	if cfg.Threshold <= 0 {
+		return errors.New("anomaly threshold should above zero")
+	}
+	if cfg.Periods < 0 || cfg.Periods > 30 {
+		return errors.New("anomaly periods should not be above 30, 0 for the default")
+	}
+	if cfg.MinVolume < 0 {
+		return errors.New("anomaly minimum volume should not be negative")
+	}
+	period, _ := cfg.SeasonPeriods()
+	if alarmObj.AlertDuration() > period {
+		return errors.New("the interval of the anomaly mode should not be longer than its season")
+	}
+	return nil
+}
+
+// anomalySeries counts the matched logs of the last interval and of its baseline windows
+func anomalySeries(alarmObj *db.Alarm, op inquiry.Operator, tableInfo db.BaseTable, where string, now time.Time) ([]alertSeries, error) {
+	interval := alarmObj.AlertDuration()
+	period, periods := alarmObj.Anomaly.SeasonPeriods()
+	res, err := op.Complete(inquiry.AlertBaselineSQL(view.ReqQuery{
+		Database:      tableInfo.Database.Name,
+		Table:         tableInfo.Name,
+		TimeField:     tableInfo.GetTimeField(),
+		TimeFieldType: tableInfo.TimeFieldType,
+		ET:            now.Unix(),
+	}, where, int64(interval/time.Second), int64(period/time.Second), periods))
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]float64, periods+1)
+	for _, row := range res.Logs {
+		if k := cast.ToInt(row["k"]); k >= 0 && k <= periods {
+			counts[k] = cast.ToFloat64(row["c"])
+		}
+	}
+	result := anomalyEvaluate(alarmObj.Anomaly, counts[0], counts[1:])
+	return []alertSeries{{value: scalarValue(counts[0], interval), ok: true, anomaly: &result}}, nil
+}
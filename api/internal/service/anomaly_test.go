@@ -0,0 +1,93 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+func Test_anomalyEvaluate(t *testing.T) {
+	baseline := []float64{90, 100, 110, 100, 100}
+	tests := []struct {
+		name         string
+		cfg          db.AlarmAnomaly
+		actual       float64
+		baseline     []float64
+		wantBreached bool
+		wantSkipped  bool
+	}{
+		{name: "within 3 sigma", cfg: db.AlarmAnomaly{Threshold: 3}, actual: 120, baseline: baseline},
+		{name: "above 3 sigma", cfg: db.AlarmAnomaly{Threshold: 3}, actual: 125, baseline: baseline, wantBreached: true},
+		{name: "below 3 sigma", cfg: db.AlarmAnomaly{Threshold: 3}, actual: 70, baseline: baseline, wantBreached: true},
+		{name: "below but watching above", cfg: db.AlarmAnomaly{Threshold: 3, Direction: db.AnomalyDirectionAbove}, actual: 70, baseline: baseline},
+		{name: "above but watching below", cfg: db.AlarmAnomaly{Threshold: 3, Direction: db.AnomalyDirectionBelow}, actual: 125, baseline: baseline},
+		{name: "within percent band", cfg: db.AlarmAnomaly{Band: db.AnomalyBandPercent, Threshold: 50}, actual: 140, baseline: baseline},
+		{name: "out of percent band", cfg: db.AlarmAnomaly{Band: db.AnomalyBandPercent, Threshold: 50}, actual: 160, baseline: baseline, wantBreached: true},
+		{name: "minimum volume", cfg: db.AlarmAnomaly{Threshold: 3, MinVolume: 200}, actual: 125, baseline: baseline, wantSkipped: true},
+		{name: "minimum volume reached by the actual value", cfg: db.AlarmAnomaly{Threshold: 3, MinVolume: 200}, actual: 300, baseline: baseline, wantBreached: true},
+		{name: "flat baseline", cfg: db.AlarmAnomaly{Threshold: 3}, actual: 1, baseline: []float64{0, 0, 0}, wantBreached: true},
+		{name: "no baseline", cfg: db.AlarmAnomaly{Threshold: 3}, actual: 1, wantSkipped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := anomalyEvaluate(tt.cfg, tt.actual, tt.baseline)
+			if got.breached != tt.wantBreached || got.skipped != tt.wantSkipped {
+				t.Errorf("anomalyEvaluate() = %+v, want breached %v, skipped %v", got, tt.wantBreached, tt.wantSkipped)
+			}
+		})
+	}
+	got := anomalyEvaluate(db.AlarmAnomaly{Threshold: 3}, 125, baseline)
+	// the sample standard deviation of the baseline is sqrt(50)
+	if got.expected != 100 || formatAnomalyValue(got.lower) != "78.79" || formatAnomalyValue(got.upper) != "121.21" {
+		t.Errorf("anomalyEvaluate() band = %+v", got)
+	}
+}
+
+func Test_anomalyValidate(t *testing.T) {
+	valid := db.Alarm{Mode: db.AlarmModeAnomaly, Interval: 5, Anomaly: db.AlarmAnomaly{Season: db.AnomalySeasonWeek, Threshold: 3}}
+	if err := anomalyValidate(&valid, db.RuleStoreTypeNative); err != nil {
+		t.Errorf("anomalyValidate() error = %v", err)
+	}
+	if err := anomalyValidate(&valid, db.RuleStoreTypeK8s); err == nil {
+		t.Error("anomalyValidate() should fail with the prometheus rule stores")
+	}
+	tests := []struct {
+		name   string
+		modify func(a *db.Alarm)
+	}{
+		{name: "threshold", modify: func(a *db.Alarm) { a.Anomaly.Threshold = 0 }},
+		{name: "season", modify: func(a *db.Alarm) { a.Anomaly.Season = 3 }},
+		{name: "direction", modify: func(a *db.Alarm) { a.Anomaly.Direction = 3 }},
+		{name: "periods", modify: func(a *db.Alarm) { a.Anomaly.Periods = 100 }},
+		{name: "interval longer than the season", modify: func(a *db.Alarm) { a.Anomaly.Season, a.Interval, a.Unit = db.AnomalySeasonDay, 2, 3 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			tt.modify(&a)
+			if err := anomalyValidate(&a, db.RuleStoreTypeNative); err == nil {
+				t.Error("anomalyValidate() expected an error")
+			}
+		})
+	}
+}
+
+func Test_nativeNotification_anomaly(t *testing.T) {
+	alarmObj := &db.Alarm{Uuid: "a-b", Desc: "requests", Mode: db.AlarmModeAnomaly}
+	result := anomalyEvaluate(db.AlarmAnomaly{Band: db.AnomalyBandPercent, Threshold: 50}, 160, []float64{100, 100})
+	s := &alertState{}
+	res := s.advance(alarmObj, nil, []alertSeries{{value: scalarValue(160, time.Minute), ok: true, anomaly: &result}}, time.Unix(1700000000, 0))
+	if len(res) != 1 || res[0].status != NotificationFiring {
+		t.Fatalf("advance() = %+v", res)
+	}
+	n := nativeNotification(alarmObj, res[0], time.Unix(1700000000, 0))
+	want := "requests  (当前值: 160)"
+	if n.CommonAnnotations["description"] != want {
+		t.Errorf("nativeNotification() description = %q, want %q", n.CommonAnnotations["description"], want)
+	}
+	if n.CommonAnnotations["expected"] != "100" || !strings.HasPrefix(n.CommonAnnotations["upper"], "150") {
+		t.Errorf("nativeNotification() annotations = %v", n.CommonAnnotations)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// Backend is the query backend of a datasource type
+type Backend struct {
+	Link       func(dsn string) (*sql.DB, error) // open and test the connection
+	New        func(*sql.DB, *db.BaseInstance) inquiry.Operator
+	Capability view.Capability
+}
+
+var backends = make(map[string]*Backend)
+
+func init() {
+	RegisterBackend(db.DatasourceClickHouse, &Backend{
+		Link: ClickHouseLink,
+		New: func(conn *sql.DB, ins *db.BaseInstance) inquiry.Operator {
+			return inquiry.NewClickHouse(conn, ins)
+		},
+		Capability: view.Capability{Alarm: true, DDL: true},
+	})
+	RegisterBackend(db.DatasourceMySQL, &Backend{
+		Link: MySQLLink,
+		New: func(conn *sql.DB, ins *db.BaseInstance) inquiry.Operator {
+			return inquiry.NewMySQL(conn, ins)
+		},
+	})
+	RegisterBackend(db.DatasourceStarRocks, &Backend{
+		Link: MySQLLink,
+		New: func(conn *sql.DB, ins *db.BaseInstance) inquiry.Operator {
+			return inquiry.NewStarRocks(conn, ins)
+		},
+	})
+	RegisterBackend(db.DatasourceDoris, &Backend{
+		Link: MySQLLink,
+		New: func(conn *sql.DB, ins *db.BaseInstance) inquiry.Operator {
+			return inquiry.NewDoris(conn, ins)
+		},
+	})
+}
+
+// RegisterBackend registers the backend of the datasource type, the existing one is replaced
+func RegisterBackend(datasource string, b *Backend) {
+	backends[datasource] = b
+}
+
+func LoadBackend(datasource string) (*Backend, bool) {
+	b, ok := backends[datasource]
+	return b, ok
+}
+
+// DatasourceCapability returns the features supported by the datasource type, nothing is supported for unknown types
+func DatasourceCapability(datasource string) view.Capability {
+	if b, ok := backends[datasource]; ok {
+		return b.Capability
+	}
+	return view.Capability{}
+}
+
+// InstanceCapability returns the features supported by the instance
+func InstanceCapability(iid int) view.Capability {
+	instance, err := db.InstanceInfo(invoker.Db, iid)
+	if err != nil {
+		return view.Capability{}
+	}
+	return DatasourceCapability(instance.Datasource)
+}
+
+func Datasources() []view.RespDatasource {
+	res := make([]view.RespDatasource, 0, len(backends))
+	for datasource, b := range backends {
+		res = append(res, view.RespDatasource{Datasource: datasource, Capability: b.Capability})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Datasource < res[j].Datasource
+	})
+	return res
+}
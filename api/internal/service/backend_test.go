@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TestDatasourceCapability(t *testing.T) {
+	tests := []struct {
+		datasource string
+		want       view.Capability
+	}{
+		{datasource: db.DatasourceClickHouse, want: view.Capability{Alarm: true, DDL: true}},
+		{datasource: db.DatasourceMySQL, want: view.Capability{}},
+		{datasource: db.DatasourceStarRocks, want: view.Capability{}},
+		{datasource: db.DatasourceDoris, want: view.Capability{}},
+		{datasource: "unknown", want: view.Capability{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.datasource, func(t *testing.T) {
+			if _, ok := LoadBackend(tt.datasource); ok != (tt.datasource != "unknown") {
+				t.Errorf("LoadBackend() ok = %v", ok)
+			}
+			if got := DatasourceCapability(tt.datasource); got != tt.want {
+				t.Errorf("DatasourceCapability() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := Datasources(); len(got) != 4 || got[0].Datasource != db.DatasourceClickHouse {
+		t.Errorf("Datasources() = %v", got)
+	}
+}
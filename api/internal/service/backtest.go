@@ -0,0 +1,193 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const (
+	backtestMaxPoints  = 2000
+	backtestMaxQueries = 200 // the sql of the aggregation modes is evaluated once per point
+)
+
+// AlarmBacktest evaluates the draft alarm of the table over the history without creating the view or the rule
+func AlarmBacktest(tid int, req view.ReqAlarmBacktest) (res view.RespAlarmBacktest, err error) {
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil {
+		return
+	}
+	alarmObj := &db.Alarm{
+		Tid:         tid,
+		Interval:    req.Interval,
+		Unit:        req.Unit,
+		NoDataOp:    req.NoDataOp,
+		Mode:        req.Mode,
+		ForDuration: req.ForDuration,
+	}
+	interval := int64(alarmObj.AlertDuration() / time.Second)
+	if interval <= 0 {
+		return res, errors.New("interval should above zero")
+	}
+	if req.EndTime == 0 {
+		req.EndTime = time.Now().Unix()
+	}
+	st, et := req.StartTime/interval*interval, (req.EndTime+interval-1)/interval*interval
+	if st >= et {
+		return res, errors.New("endTime should be after startTime")
+	}
+	points := int((et - st) / interval)
+	aggregation := alarmObj.Mode == db.AlarmModeWithInSQL || alarmObj.Mode == db.AlarmModeAggregation
+	if points > backtestMaxPoints || (aggregation && points > backtestMaxQueries) {
+		return res, errors.Errorf("too many points, the range covers %d intervals", points)
+	}
+	if len(req.GroupBy) > 0 {
+		return res, errors.New("backtest of group-by alarms is not supported")
+	}
+	if alarmObj.Mode == db.AlarmModeAnomaly {
+		return res, errors.New("backtest of anomaly alarms is not supported")
+	}
+	conditions, err := backtestConditions(req.Conditions)
+	if err != nil {
+		return
+	}
+	filters := make([]*db.AlarmFilter, 0, len(req.Filters))
+	for _, f := range req.Filters {
+		filter := &db.AlarmFilter{Tid: f.Tid, When: f.When, SetOperatorTyp: f.SetOperatorTyp, SetOperatorExp: f.SetOperatorExp, Mode: f.Mode}
+		if filter.When == "" {
+			filter.When = "1=1"
+		}
+		filters = append(filters, filter)
+	}
+	op, err := InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		return
+	}
+	param := view.ReqQuery{
+		Tid:           tableInfo.ID,
+		Database:      tableInfo.Database.Name,
+		Table:         tableInfo.Name,
+		TimeField:     tableInfo.GetTimeField(),
+		TimeFieldType: tableInfo.TimeFieldType,
+		ST:            st,
+		ET:            et,
+	}
+	where := db.WhereConditionFromFilter(alarmObj, filters)
+	values := make(map[int64]alertValue, points)
+	if aggregation {
+		for ts := st; ts < et; ts += interval {
+			param.ST, param.ET = ts, ts+interval
+			sql, errSQL := inquiry.AlertBacktestValueSQL(param, where)
+			if errSQL != nil {
+				return res, errSQL
+			}
+			resp, errComplete := op.Complete(sql)
+			if errComplete != nil {
+				return res, errComplete
+			}
+			if val, ok := completeValue(resp); ok {
+				values[ts] = scalarValue(val, alarmObj.AlertDuration())
+			}
+		}
+	} else {
+		resp, errComplete := op.Complete(inquiry.AlertBacktestSQL(param, where, interval))
+		if errComplete != nil {
+			return res, errComplete
+		}
+		for _, row := range resp.Logs {
+			if val, ok := samplesValue(row, alarmObj.AlertDuration()); ok {
+				values[cast.ToInt64(row["ts"])] = val
+			}
+		}
+	}
+	return backtest(alarmObj, conditions, st, et, interval, values), nil
+}
+
+// backtest replays the values of [st, et) through the conditions and the alert states of the native evaluator,
+// every point is evaluated at the end of its interval.
+func backtest(alarmObj *db.Alarm, conditions []*db.AlarmCondition, st, et, interval int64, values map[int64]alertValue) view.RespAlarmBacktest {
+	res := view.RespAlarmBacktest{
+		Interval:  interval,
+		Series:    make([]view.AlarmBacktestPoint, 0, (et-st)/interval),
+		Crossings: make([]view.AlarmBacktestPoint, 0),
+		Windows:   make([]view.AlarmBacktestWindow, 0),
+	}
+	var (
+		state       alertState
+		last        bool
+		prev        *alertValue
+		forDuration = time.Duration(alarmObj.ForDuration) * time.Second
+	)
+	for ts := st; ts < et; ts += interval {
+		val, ok := values[ts]
+		point := view.AlarmBacktestPoint{Ts: ts, Val: displayValue(conditions, val), NoData: !ok}
+		if ok {
+			point.Breached = conditionsMatch(conditions, val, prev)
+			prev = &val
+		} else {
+			point.Breached = alarmObj.NoDataOp == NoDataOpAlert
+			prev = nil
+		}
+		res.Series = append(res.Series, point)
+		if point.Breached != last {
+			res.Crossings = append(res.Crossings, point)
+			last = point.Breached
+		}
+		now := time.Unix(ts+interval, 0)
+		switch state.next(point.Breached, now, forDuration) {
+		case NotificationFiring:
+			res.Windows = append(res.Windows, view.AlarmBacktestWindow{ActiveAt: state.activeAt.Unix(), FiringAt: now.Unix()})
+		case NotificationResolved:
+			res.Windows[len(res.Windows)-1].EndsAt = now.Unix()
+		}
+	}
+	return res
+}
+
+// backtestConditions checks the conditions the same way as ConditionCreate
+func backtestConditions(reqs []view.ReqAlarmConditionCreate) ([]*db.AlarmCondition, error) {
+	if len(reqs) == 0 {
+		return nil, errors.New("conditions are required")
+	}
+	conditions := make([]*db.AlarmCondition, 0, len(reqs))
+	for _, c := range reqs {
+		if err := conditionValidate(c); err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, &db.AlarmCondition{
+			SetOperatorTyp: c.SetOperatorTyp,
+			SetOperatorExp: c.SetOperatorExp,
+			Cond:           c.Cond,
+			Val1:           c.Val1,
+			Val2:           c.Val2,
+		})
+	}
+	sort.SliceStable(conditions, func(i, j int) bool {
+		return conditions[i].SetOperatorTyp < conditions[j].SetOperatorTyp
+	})
+	if conditions[0].SetOperatorTyp != 0 {
+		return nil, errors.New("conditions error")
+	}
+	return conditions, nil
+}
+
+// completeValue returns the val column of the first row
+func completeValue(resp view.RespComplete) (float64, bool) {
+	if len(resp.Logs) == 0 {
+		return 0, false
+	}
+	return rowValue(resp.Logs[0])
+}
+
+// rowValue returns the val column of the row, NULL and NaN are returned as empty strings
+func rowValue(row map[string]interface{}) (float64, bool) {
+	val, err := cast.ToFloat64E(row["val"])
+	return val, err == nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func Test_backtest(t *testing.T) {
+	above := []*db.AlarmCondition{{Cond: 0, Val1: 10}}
+	tests := []struct {
+		name        string
+		alarm       db.Alarm
+		values      map[int64]float64
+		wantWindows []view.AlarmBacktestWindow
+		crossings   int
+	}{
+		{
+			name:        "fire and resolve",
+			values:      map[int64]float64{0: 1, 60: 20, 120: 30, 180: 1},
+			wantWindows: []view.AlarmBacktestWindow{{ActiveAt: 120, FiringAt: 120, EndsAt: 240}},
+			crossings:   2,
+		},
+		{
+			name:        "for duration",
+			alarm:       db.Alarm{ForDuration: 60},
+			values:      map[int64]float64{0: 1, 60: 20, 120: 30, 180: 40},
+			wantWindows: []view.AlarmBacktestWindow{{ActiveAt: 120, FiringAt: 180}},
+			crossings:   1,
+		},
+		{
+			name:        "for duration not reached",
+			alarm:       db.Alarm{ForDuration: 120},
+			values:      map[int64]float64{0: 1, 60: 20, 120: 1, 180: 40},
+			wantWindows: []view.AlarmBacktestWindow{},
+			crossings:   3,
+		},
+		{
+			name:        "no data alert",
+			alarm:       db.Alarm{NoDataOp: NoDataOpAlert},
+			values:      map[int64]float64{0: 1, 60: 1, 180: 1},
+			wantWindows: []view.AlarmBacktestWindow{{ActiveAt: 180, FiringAt: 180, EndsAt: 240}},
+			crossings:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := make(map[int64]alertValue, len(tt.values))
+			for ts, val := range tt.values {
+				values[ts] = scalarValue(val, time.Minute)
+			}
+			got := backtest(&tt.alarm, above, 0, 240, 60, values)
+			if len(got.Series) != 4 {
+				t.Fatalf("backtest() series = %v, want 4 points", got.Series)
+			}
+			if len(got.Crossings) != tt.crossings {
+				t.Errorf("backtest() crossings = %v, want %d", got.Crossings, tt.crossings)
+			}
+			if !reflect.DeepEqual(got.Windows, tt.wantWindows) {
+				t.Errorf("backtest() windows = %v, want %v", got.Windows, tt.wantWindows)
+			}
+		})
+	}
+}
+
+func Test_backtestConditions(t *testing.T) {
+	if _, err := backtestConditions(nil); err == nil {
+		t.Error("backtestConditions() without conditions should fail")
+	}
+	if _, err := backtestConditions([]view.ReqAlarmConditionCreate{{SetOperatorTyp: 1, Val1: 1}}); err == nil {
+		t.Error("backtestConditions() without the when condition should fail")
+	}
+	if _, err := backtestConditions([]view.ReqAlarmConditionCreate{{Cond: 8, Val1: 1}}); err == nil {
+		t.Error("backtestConditions() with an unknown condition should fail")
+	}
+	got, err := backtestConditions([]view.ReqAlarmConditionCreate{{SetOperatorTyp: 2, Val1: 1}, {SetOperatorTyp: 0, Val1: 2}})
+	if err != nil || got[0].Val1 != 2 {
+		t.Errorf("backtestConditions() = %v, %v", got, err)
+	}
+}
+
+func Test_completeValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		logs   []map[string]interface{}
+		want   float64
+		wantOk bool
+	}{
+		{name: "empty", logs: nil},
+		{name: "null", logs: []map[string]interface{}{{"val": ""}}},
+		{name: "float", logs: []map[string]interface{}{{"val": 1.5}}, want: 1.5, wantOk: true},
+		{name: "zero", logs: []map[string]interface{}{{"val": float64(0)}}, want: 0, wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := completeValue(view.RespComplete{Logs: tt.logs})
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("completeValue() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_backtest_change(t *testing.T) {
+	increase := []*db.AlarmCondition{{SetOperatorExp: 3, Cond: 6, Val1: 100}}
+	values := map[int64]alertValue{0: {sum: 10}, 60: {sum: 30}, 180: {sum: 100}}
+	got := backtest(&db.Alarm{}, increase, 0, 240, 60, values)
+	breached := make([]bool, 0, len(got.Series))
+	for _, p := range got.Series {
+		breached = append(breached, p.Breached)
+	}
+	// the point after the gap has no previous value
+	if want := []bool{false, true, false, false}; !reflect.DeepEqual(breached, want) {
+		t.Errorf("backtest() breached = %v, want %v", breached, want)
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+var (
+	errNotFiring           = errors.New("the alarm is not firing")
+	errAlreadyAcknowledged = errors.New("the alert has been acknowledged")
+	errIncidentClosed      = errors.New("the incident of the message has been closed")
+)
+
+// escalator notifies the steps of the escalation policies of the firing alarms that are not acknowledged in time.
+// The steps are claimed on the history that opens the incident, so the replicas never notify a step twice.
+type escalator struct{}
+
+func NewEscalator() *escalator {
+	e := &escalator{}
+	tick := econf.GetDuration("alarm.escalation.tick")
+	if tick <= 0 {
+		tick = time.Second * 30
+	}
+	xgo.Go(func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for range ticker.C {
+			e.run(time.Now())
+		}
+	})
+	return e
+}
+
+func (e *escalator) run(now time.Time) {
+	conds := egorm.Conds{}
+	conds["status"] = db.AlarmStatusFiring
+	conds["escalation_id"] = egorm.Cond{Op: ">", Val: 0}
+	alarms, err := db.AlarmList(conds)
+	if err != nil {
+		invoker.Logger.Error("escalator", elog.String("step", "AlarmList"), elog.String("error", err.Error()))
+		return
+	}
+	for _, a := range alarms {
+		if err = e.escalate(a, now); err != nil {
+			invoker.Logger.Error("escalator", elog.Int("alarmId", a.ID), elog.String("error", err.Error()))
+		}
+	}
+}
+
+func (e *escalator) escalate(alarmObj *db.Alarm, now time.Time) error {
+	policy, err := db.AlarmEscalationInfo(invoker.Db, alarmObj.EscalationId)
+	if err != nil {
+		return errors.Wrap(err, "escalation policy")
+	}
+	inc, ok, err := openIncident(alarmObj.ID)
+	if err != nil || !ok {
+		return err
+	}
+	step, due := escalationDue(policy.Steps, inc, now)
+	if !due {
+		return nil
+	}
+	claimed, err := db.AlarmHistoryEscalationClaim(invoker.Db, inc.opened.ID, step)
+	if err != nil || !claimed {
+		return err
+	}
+	history := db.AlarmHistory{
+		AlarmId:     alarmObj.ID,
+		Status:      db.AlarmHistoryStatusEscalated,
+		StartsAt:    incidentStartsAt(inc.opened),
+		Labels:      inc.opened.Labels,
+		Annotations: inc.opened.Annotations,
+		Value:       inc.opened.Value,
+		Step:        step + 1,
+	}
+	if err = db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return err
+	}
+	return enqueueDeliveries(alarmObj.ID, history.ID, policy.Steps[step].ChannelIds, escalationNotification(alarmObj, inc.opened, step, now), inc.opened.Log)
+}
+
+// incident is the firing alert of an alarm since its last resolved notification
+type incident struct {
+	opened *db.AlarmHistory // the firing history that opens the incident
+	acked  *db.AlarmHistory
+}
+
+// openIncident returns the incident of the alarm that has not been resolved
+func openIncident(alarmId int) (inc incident, ok bool, err error) {
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarmId
+	conds["status"] = push.StatusResolved
+	resolved, err := db.AlarmHistoryLast(invoker.Db, conds)
+	if err != nil {
+		return
+	}
+	conds = egorm.Conds{}
+	conds["alarm_id"] = alarmId
+	conds["id"] = egorm.Cond{Op: ">", Val: resolved.ID}
+	histories, err := db.AlarmHistoryList(conds)
+	if err != nil {
+		return
+	}
+	inc, ok = incidentOf(histories)
+	return inc, ok, nil
+}
+
+// incidentOf returns the open incident of the histories of an alarm, the histories are in any order
+func incidentOf(histories []*db.AlarmHistory) (inc incident, ok bool) {
+	var last *db.AlarmHistory
+	for _, h := range histories {
+		if h.Status == push.StatusResolved && (last == nil || h.ID > last.ID) {
+			last = h
+		}
+	}
+	for _, h := range histories {
+		if last != nil && h.ID < last.ID {
+			continue
+		}
+		switch h.Status {
+		case push.StatusFiring:
+			if inc.opened == nil || h.ID < inc.opened.ID {
+				inc.opened = h
+			}
+		case db.AlarmHistoryStatusAcknowledged:
+			inc.acked = h
+		}
+	}
+	return inc, inc.opened != nil
+}
+
+func incidentStartsAt(h *db.AlarmHistory) int64 {
+	if h.StartsAt != 0 {
+		return h.StartsAt
+	}
+	return h.Ctime
+}
+
+// escalationDue returns the step to notify, the silenced and the acknowledged incidents are never escalated
+func escalationDue(steps db.EscalationSteps, inc incident, now time.Time) (step int, due bool) {
+	if inc.opened == nil || inc.acked != nil || inc.opened.SilenceId != 0 {
+		return 0, false
+	}
+	step = inc.opened.Step
+	if step >= len(steps) {
+		return step, false
+	}
+	return step, now.Unix() >= incidentStartsAt(inc.opened)+int64(steps[step].After)*60
+}
+
+// escalationNotification is the firing notification of the incident, the description tells the step
+func escalationNotification(alarmObj *db.Alarm, opened *db.AlarmHistory, step int, now time.Time) view.Notification {
+	startsAt := time.Unix(incidentStartsAt(opened), 0)
+	annotations := make(map[string]string, len(opened.Annotations)+1)
+	for k, v := range opened.Annotations {
+		annotations[k] = v
+	}
+	annotations["description"] = fmt.Sprintf("Escalation step %d: not acknowledged for %s. %s",
+		step+1, now.Sub(startsAt).Truncate(time.Minute), opened.Annotations["description"])
+	return view.Notification{
+		Status:            push.StatusFiring,
+		GroupKey:          alarmObj.Uuid,
+		CommonLabels:      opened.Labels,
+		CommonAnnotations: annotations,
+		Alerts: []view.Alert{{
+			Labels:      opened.Labels,
+			Annotations: annotations,
+			StartsAt:    startsAt,
+		}},
+	}
+}
+
+// EscalationValidate checks the steps are notified in order
+func EscalationValidate(req view.ReqAlarmEscalationCreate) error {
+	if len(req.Steps) == 0 {
+		return errors.New("steps are required")
+	}
+	for i, s := range req.Steps {
+		if s.After < 0 {
+			return errors.Errorf("step %d: after should not be negative", i+1)
+		}
+		if i > 0 && s.After < req.Steps[i-1].After {
+			return errors.Errorf("step %d: after should not be before the previous step", i+1)
+		}
+		if len(s.ChannelIds) == 0 {
+			return errors.Errorf("step %d: channels are required", i+1)
+		}
+	}
+	return nil
+}
+
+// AlarmAcknowledge claims the firing alert of the alarm, it is escalated no more and its repeated notifications are not pushed.
+// uid is the user of the api, operator is the user of the interactive message.
+func AlarmAcknowledge(alarmId, uid int, operator string) error {
+	inc, ok, err := openIncident(alarmId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNotFiring
+	}
+	return acknowledgeIncident(alarmId, inc, uid, operator)
+}
+
+func acknowledgeIncident(alarmId int, inc incident, uid int, operator string) error {
+	if inc.acked != nil {
+		return errAlreadyAcknowledged
+	}
+	history := db.AlarmHistory{
+		AlarmId:  alarmId,
+		Status:   db.AlarmHistoryStatusAcknowledged,
+		StartsAt: incidentStartsAt(inc.opened),
+		Labels:   inc.opened.Labels,
+		Value:    inc.opened.Value,
+		Uid:      uid,
+		Operator: operator,
+		IsPushed: 1,
+	}
+	return db.AlarmHistoryCreate(invoker.Db, &history)
+}
+
+// AlarmResolve closes the firing alert of the alarm by hand, the next firing notification opens a new incident
+func AlarmResolve(alarmId, uid int, operator string) error {
+	inc, ok, err := openIncident(alarmId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNotFiring
+	}
+	return resolveIncident(alarmId, inc, uid, operator)
+}
+
+func resolveIncident(alarmId int, inc incident, uid int, operator string) error {
+	history := db.AlarmHistory{
+		AlarmId:  alarmId,
+		Status:   push.StatusResolved,
+		StartsAt: incidentStartsAt(inc.opened),
+		EndsAt:   time.Now().Unix(),
+		Labels:   inc.opened.Labels,
+		Value:    inc.opened.Value,
+		Uid:      uid,
+		Operator: operator,
+		IsPushed: 1,
+	}
+	if err := db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return err
+	}
+	return db.AlarmStatusUpdate(alarmId, push.StatusResolved)
+}
+
+// AlarmCallback applies the action of a button clicked in an interactive message,
+// the button acts only on the incident open when the message was sent
+func AlarmCallback(value push.CallbackValue, operator string) error {
+	if !value.Verify() {
+		return errors.New("invalid or expired signature")
+	}
+	inc, ok, err := openIncident(value.AlarmId)
+	if err != nil {
+		return err
+	}
+	if !ok || inc.opened.ID != value.HistoryId {
+		return errIncidentClosed
+	}
+	switch value.Action {
+	case push.CallbackAcknowledge:
+		return acknowledgeIncident(value.AlarmId, inc, 0, operator)
+	case push.CallbackResolve:
+		return resolveIncident(value.AlarmId, inc, 0, operator)
+	}
+	return errors.Errorf("unknown action %s", value.Action)
+}
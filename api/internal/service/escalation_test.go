@@ -0,0 +1,130 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+func testHistory(id int, status string, startsAt int64) *db.AlarmHistory {
+	return &db.AlarmHistory{BaseModel: db.BaseModel{ID: id}, Status: status, StartsAt: startsAt}
+}
+
+func Test_incidentOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		histories  []*db.AlarmHistory
+		wantOk     bool
+		wantOpened int
+		wantAcked  bool
+	}{
+		{name: "none"},
+		{name: "resolved", histories: []*db.AlarmHistory{testHistory(1, push.StatusFiring, 100), testHistory(2, push.StatusResolved, 100)}},
+		{
+			name:       "repeated firing",
+			histories:  []*db.AlarmHistory{testHistory(4, push.StatusFiring, 100), testHistory(3, push.StatusFiring, 100)},
+			wantOk:     true,
+			wantOpened: 3,
+		},
+		{
+			name: "acknowledged after resolved",
+			histories: []*db.AlarmHistory{
+				testHistory(1, push.StatusFiring, 100),
+				testHistory(2, db.AlarmHistoryStatusAcknowledged, 100),
+				testHistory(3, push.StatusResolved, 100),
+				testHistory(4, push.StatusFiring, 200),
+				testHistory(5, db.AlarmHistoryStatusAcknowledged, 200),
+			},
+			wantOk:     true,
+			wantOpened: 4,
+			wantAcked:  true,
+		},
+		{
+			name: "acknowledged before resolved",
+			histories: []*db.AlarmHistory{
+				testHistory(2, db.AlarmHistoryStatusAcknowledged, 100),
+				testHistory(3, push.StatusResolved, 100),
+				testHistory(4, push.StatusFiring, 200),
+			},
+			wantOk:     true,
+			wantOpened: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inc, ok := incidentOf(tt.histories)
+			if ok != tt.wantOk {
+				t.Fatalf("incidentOf() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if inc.opened.ID != tt.wantOpened || (inc.acked != nil) != tt.wantAcked {
+				t.Errorf("incidentOf() opened = %d, acked = %v", inc.opened.ID, inc.acked)
+			}
+		})
+	}
+}
+
+func Test_escalationDue(t *testing.T) {
+	steps := db.EscalationSteps{{After: 5, ChannelIds: []int{1}}, {After: 15, ChannelIds: []int{2}}}
+	start := time.Unix(1000, 0)
+	incidentAt := func(step int) incident {
+		h := testHistory(1, push.StatusFiring, start.Unix())
+		h.Step = step
+		return incident{opened: h}
+	}
+	tests := []struct {
+		name     string
+		inc      incident
+		now      time.Time
+		wantStep int
+		wantDue  bool
+	}{
+		{name: "not yet", inc: incidentAt(0), now: start.Add(4 * time.Minute)},
+		{name: "first step", inc: incidentAt(0), now: start.Add(5 * time.Minute), wantDue: true},
+		{name: "second step not yet", inc: incidentAt(1), now: start.Add(10 * time.Minute), wantStep: 1},
+		{name: "second step", inc: incidentAt(1), now: start.Add(20 * time.Minute), wantStep: 1, wantDue: true},
+		{name: "all notified", inc: incidentAt(2), now: start.Add(time.Hour), wantStep: 2},
+		{name: "acknowledged", inc: incident{opened: incidentAt(0).opened, acked: testHistory(2, db.AlarmHistoryStatusAcknowledged, 1000)}, now: start.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, due := escalationDue(steps, tt.inc, tt.now)
+			if due != tt.wantDue || (due && step != tt.wantStep) {
+				t.Errorf("escalationDue() = %d, %v, want %d, %v", step, due, tt.wantStep, tt.wantDue)
+			}
+		})
+	}
+
+	silenced := incidentAt(0)
+	silenced.opened.SilenceId = 3
+	if _, due := escalationDue(steps, silenced, start.Add(time.Hour)); due {
+		t.Error("escalationDue() of silenced incident should not be due")
+	}
+}
+
+func TestEscalationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []db.EscalationStep
+		wantErr bool
+	}{
+		{name: "valid", steps: []db.EscalationStep{{After: 0, ChannelIds: []int{1}}, {After: 10, ChannelIds: []int{2}}}},
+		{name: "empty", wantErr: true},
+		{name: "negative", steps: []db.EscalationStep{{After: -1, ChannelIds: []int{1}}}, wantErr: true},
+		{name: "out of order", steps: []db.EscalationStep{{After: 10, ChannelIds: []int{1}}, {After: 5, ChannelIds: []int{2}}}, wantErr: true},
+		{name: "no channel", steps: []db.EscalationStep{{After: 5}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := EscalationValidate(view.ReqAlarmEscalationCreate{Name: "oncall", Steps: tt.steps})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EscalationValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+const (
+	alertStateInactive = iota
+	alertStatePending
+	alertStateFiring
+)
+
+const (
+	NotificationFiring   = "firing"
+	NotificationResolved = "resolved"
+)
+
+// alertState is the state of an alarm evaluated by the native evaluator
+type alertState struct {
+	state    int
+	activeAt time.Time // the time when the conditions started to hold
+	nextAt   time.Time // the time of the next evaluation
+	running  bool
+	value    *alertValue            // the value of the last evaluation, nil when there was no data
+	labels   map[string]string      // group labels of the series
+	series   map[string]*alertState // states of the groups of group-by alarms, keyed by their labels
+}
+
+// alertSeries is a value of an alarm, group-by alarms have a series per group
+type alertSeries struct {
+	key     string            // group labels joined, empty for the alarms without group-by fields
+	labels  map[string]string // group labels
+	value   alertValue
+	ok      bool           // false when there is no data
+	anomaly *anomalyResult // evaluation of the anomaly mode, which replaces the conditions
+}
+
+// alertValue is the value of a series over an interval under every aggregation selector of the conditions
+type alertValue struct {
+	last, min, max, sum, count, avg float64
+	rate                            float64 // sum per second
+}
+
+// alertTransition is a notification to send
+type alertTransition struct {
+	series   alertSeries
+	status   string
+	activeAt time.Time
+	value    float64 // the value shown in the notification
+	anomaly  *anomalyResult
+}
+
+// selected returns the value under the aggregation selector, 0 last 1 min 2 max 3 sum 4 count 5 avg
+func (v alertValue) selected(exp int) float64 {
+	switch exp {
+	case 1:
+		return v.min
+	case 2:
+		return v.max
+	case 3:
+		return v.sum
+	case 4:
+		return v.count
+	case 5:
+		return v.avg
+	}
+	return v.last
+}
+
+// samplesValue reads the selector columns of AlertSeriesSQL and AlertBacktestSQL, ok is false when there are no samples
+func samplesValue(row map[string]interface{}, interval time.Duration) (alertValue, bool) {
+	count := cast.ToFloat64(row["val_count"])
+	if count == 0 {
+		return alertValue{}, false
+	}
+	v := alertValue{
+		last:  cast.ToFloat64(row["val_last"]),
+		avg:   cast.ToFloat64(row["val_avg"]),
+		min:   cast.ToFloat64(row["val_min"]),
+		max:   cast.ToFloat64(row["val_max"]),
+		sum:   cast.ToFloat64(row["val_sum"]),
+		count: count,
+	}
+	v.rate = v.sum / interval.Seconds()
+	return v, true
+}
+
+// scalarValue is the value of the sql of the aggregation modes, which is a single sample under every selector
+func scalarValue(val float64, interval time.Duration) alertValue {
+	return alertValue{last: val, avg: val, min: val, max: val, sum: val, count: 1, rate: val / interval.Seconds()}
+}
+
+// next moves the state forward with the result of an evaluation,
+// it returns the notification status to send, empty when nothing changes.
+func (s *alertState) next(breached bool, now time.Time, forDuration time.Duration) string {
+	if !breached {
+		state := s.state
+		s.state = alertStateInactive
+		if state == alertStateFiring {
+			return NotificationResolved
+		}
+		return ""
+	}
+	switch s.state {
+	case alertStateInactive:
+		s.activeAt = now
+		s.state = alertStatePending
+		if forDuration > 0 {
+			return ""
+		}
+	case alertStateFiring:
+		return ""
+	}
+	if now.Sub(s.activeAt) < forDuration {
+		return ""
+	}
+	s.state = alertStateFiring
+	return NotificationFiring
+}
+
+// evaluator evaluates the alarms of the instances with RuleStoreTypeNative, without prometheus and alertmanager.
+// Every alarm is evaluated at its interval over the data of the last interval.
+type evaluator struct {
+	mu     sync.Mutex
+	states map[int]*alertState
+}
+
+func NewEvaluator() *evaluator {
+	e := &evaluator{
+		states: make(map[int]*alertState),
+	}
+	// the replicas with the evaluator enabled evaluate every alarm, enable it in a single replica to avoid duplicated notifications
+	if !econf.GetBool("alarm.evaluator.enable") {
+		invoker.Logger.Info("evaluator", elog.String("step", "disabled"), elog.String("tip", "set alarm.evaluator.enable to evaluate the alarms of the native rule store"))
+		return e
+	}
+	tick := econf.GetDuration("alarm.evaluator.tick")
+	if tick <= 0 {
+		tick = time.Second * 10
+	}
+	xgo.Go(func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for range ticker.C {
+			e.schedule(time.Now())
+		}
+	})
+	return e
+}
+
+// schedule starts the evaluations that are due
+func (e *evaluator) schedule(now time.Time) {
+	conds := egorm.Conds{}
+	conds["rule_store_type"] = db.RuleStoreTypeNative
+	conds["status"] = egorm.Cond{Op: "in", Val: []int{db.AlarmStatusOpen, db.AlarmStatusFiring}}
+	alarms, err := db.AlarmList(conds)
+	if err != nil {
+		invoker.Logger.Error("evaluator", elog.String("step", "AlarmList"), elog.String("error", err.Error()))
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	active := make(map[int]struct{}, len(alarms))
+	for _, a := range alarms {
+		active[a.ID] = struct{}{}
+		s, ok := e.states[a.ID]
+		if !ok {
+			s = &alertState{}
+			if a.Status == db.AlarmStatusFiring && len(a.GroupBy) == 0 {
+				// keep firing across restarts, so that the resolved notification is still sent
+				s.state = alertStateFiring
+				s.activeAt = time.Unix(a.Utime, 0)
+			}
+			e.states[a.ID] = s
+		}
+		if s.running || now.Before(s.nextAt) {
+			continue
+		}
+		interval := a.AlertDuration()
+		if interval <= 0 {
+			continue
+		}
+		s.running = true
+		s.nextAt = now.Add(interval)
+		alarmObj := a
+		xgo.Go(func() {
+			e.evaluate(alarmObj, now)
+		})
+	}
+	// closed or deleted alarms
+	for id := range e.states {
+		if _, ok := active[id]; !ok {
+			delete(e.states, id)
+		}
+	}
+}
+
+// evaluate checks the alarm, the alarms of the stale tables are not scheduled until the freshness ticker sees the logs back
+func (e *evaluator) evaluate(alarmObj *db.Alarm, now time.Time) {
+	var conditions []*db.AlarmCondition
+	series, err := alarmSeries(alarmObj, now)
+	if err == nil {
+		conditions, err = alarmConditions(alarmObj)
+	}
+	e.mu.Lock()
+	s := e.states[alarmObj.ID]
+	if s == nil {
+		e.mu.Unlock()
+		return
+	}
+	s.running = false
+	if err != nil {
+		e.mu.Unlock()
+		invoker.Logger.Error("evaluator", elog.Int("alarmId", alarmObj.ID), elog.String("step", "alarmSeries"), elog.String("error", err.Error()))
+		return
+	}
+	transitions := s.advance(alarmObj, conditions, series, now)
+	e.mu.Unlock()
+	invoker.Logger.Debug("evaluator", elog.Int("alarmId", alarmObj.ID), elog.Any("series", len(series)), elog.Any("transitions", len(transitions)))
+	for _, t := range transitions {
+		if err = Send(alarmObj.Uuid, nativeNotification(alarmObj, t, now)); err != nil {
+			invoker.Logger.Error("evaluator", elog.Int("alarmId", alarmObj.ID), elog.String("step", "Send"), elog.String("error", err.Error()))
+		}
+	}
+}
+
+// advance evaluates the series of the alarm and returns the notifications to send.
+// The groups missing from the series no longer hold the conditions, and they are dropped once inactive.
+func (s *alertState) advance(alarmObj *db.Alarm, conditions []*db.AlarmCondition, series []alertSeries, now time.Time) []alertTransition {
+	var (
+		res         = make([]alertTransition, 0)
+		seen        = make(map[string]struct{}, len(series))
+		forDuration = time.Duration(alarmObj.ForDuration) * time.Second
+	)
+	step := func(sr alertSeries, absent bool) {
+		st := s
+		if sr.key != "" {
+			if s.series == nil {
+				s.series = make(map[string]*alertState)
+			}
+			if st = s.series[sr.key]; st == nil {
+				st = &alertState{labels: sr.labels}
+				s.series[sr.key] = st
+			}
+		}
+		var (
+			breached bool
+			value    *alertValue
+		)
+		switch {
+		case absent:
+		case sr.anomaly != nil:
+			breached = sr.anomaly.breached
+			value = &sr.value
+		case sr.ok:
+			breached = conditionsMatch(conditions, sr.value, st.value)
+			value = &sr.value
+		default:
+			breached = alarmObj.NoDataOp == NoDataOpAlert
+		}
+		st.value = value
+		if status := st.next(breached, now, forDuration); status != "" {
+			t := alertTransition{series: sr, status: status, activeAt: st.activeAt, value: displayValue(conditions, sr.value), anomaly: sr.anomaly}
+			if sr.anomaly != nil {
+				t.value = sr.anomaly.actual
+			}
+			res = append(res, t)
+		}
+	}
+	for _, sr := range series {
+		seen[sr.key] = struct{}{}
+		step(sr, false)
+	}
+	if _, ok := seen[""]; !ok {
+		// group-by alarms with data
+		step(alertSeries{}, true)
+	}
+	keys := make([]string, 0, len(s.series))
+	for key := range s.series {
+		if _, ok := seen[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		step(alertSeries{key: key, labels: s.series[key].labels}, true)
+		if s.series[key].state == alertStateInactive {
+			delete(s.series, key)
+		}
+	}
+	return res
+}
+
+// alarmSource returns the operator, the table and the filter sql of the alarm
+func alarmSource(alarmObj *db.Alarm) (op inquiry.Operator, tableInfo db.BaseTable, where string, err error) {
+	tableInfo, err = db.TableInfo(invoker.Db, alarmObj.Tid)
+	if err != nil {
+		return
+	}
+	op, err = InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		return
+	}
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarmObj.ID
+	filters, err := db.AlarmFilterList(conds)
+	if err != nil {
+		return
+	}
+	return op, tableInfo, db.WhereConditionFromFilter(alarmObj, filters), nil
+}
+
+// alarmSeries queries the series of the alarm over the last interval,
+// a group-by alarm without data has a single series without group labels.
+// The samples are the numbers of the matched logs by timestamp, or the result of the sql in aggregation modes.
+func alarmSeries(alarmObj *db.Alarm, now time.Time) ([]alertSeries, error) {
+	op, tableInfo, where, err := alarmSource(alarmObj)
+	if err != nil {
+		return nil, err
+	}
+	interval := alarmObj.AlertDuration()
+	if alarmObj.Mode == db.AlarmModeAnomaly {
+		return anomalySeries(alarmObj, op, tableInfo, where, now)
+	}
+	if alarmObj.Mode == db.AlarmModeWithInSQL || alarmObj.Mode == db.AlarmModeAggregation {
+		res, errComplete := op.Complete(inquiry.AlertValueSQL(where))
+		if errComplete != nil {
+			return nil, errComplete
+		}
+		val, ok := completeValue(res)
+		return []alertSeries{{value: scalarValue(val, interval), ok: ok}}, nil
+	}
+	res, err := op.Complete(inquiry.AlertSeriesSQL(view.ReqQuery{
+		Database:      tableInfo.Database.Name,
+		Table:         tableInfo.Name,
+		TimeField:     tableInfo.GetTimeField(),
+		TimeFieldType: tableInfo.TimeFieldType,
+		ST:            now.Add(-interval).Unix(),
+		ET:            now.Unix(),
+	}, where, alarmObj.GroupBy))
+	if err != nil {
+		return nil, err
+	}
+	series := make([]alertSeries, 0, len(res.Logs))
+	for _, row := range res.Logs {
+		sr := alertSeries{labels: make(map[string]string, len(alarmObj.GroupBy))}
+		for i, field := range alarmObj.GroupBy {
+			sr.labels[db.GroupLabel(field)] = cast.ToString(row[fmt.Sprintf("g%d", i)])
+		}
+		sr.key = strings.Join(alarmObj.GroupLabels(sr.labels), ",")
+		sr.value, sr.ok = samplesValue(row, interval)
+		series = append(series, sr)
+	}
+	if len(series) == 0 {
+		series = append(series, alertSeries{})
+	}
+	return series, nil
+}
+
+func alarmConditions(alarmObj *db.Alarm) ([]*db.AlarmCondition, error) {
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarmObj.ID
+	return db.AlarmConditionList(conds)
+}
+
+// conditionsMatch evaluates the conditions the same way as the expression built by ConditionCreate,
+// prev is the value of the previous interval, the changes do not hold without it.
+func conditionsMatch(conditions []*db.AlarmCondition, cur alertValue, prev *alertValue) bool {
+	sort.SliceStable(conditions, func(i, j int) bool {
+		return conditions[i].SetOperatorTyp < conditions[j].SetOperatorTyp
+	})
+	var res bool
+	for _, condition := range conditions {
+		var (
+			match bool
+			val   = cur.selected(condition.SetOperatorExp)
+		)
+		switch condition.Cond {
+		case 0:
+			match = val > condition.Val1
+		case 1:
+			match = val < condition.Val1
+		case 2:
+			match = val < condition.Val1 || val > condition.Val2
+		case 3:
+			match = val >= condition.Val1 && val <= condition.Val2
+		case 4:
+			match = cur.rate > condition.Val1
+		case 5:
+			match = cur.rate < condition.Val1
+		case 6, 7:
+			if prev == nil {
+				break
+			}
+			// the same Inf and NaN as prometheus when the previous value is zero
+			last := prev.selected(condition.SetOperatorExp)
+			change := (val - last) / last * 100
+			if condition.Cond == 7 {
+				change = (last - val) / last * 100
+			}
+			match = change > condition.Val1
+		}
+		switch condition.SetOperatorTyp {
+		case 0:
+			res = match
+		case 1:
+			res = res && match
+		case 2:
+			res = res || match
+		}
+	}
+	return res
+}
+
+// displayValue is the value of the WHEN condition
+func displayValue(conditions []*db.AlarmCondition, v alertValue) float64 {
+	for _, condition := range conditions {
+		if condition.SetOperatorTyp != 0 {
+			continue
+		}
+		if condition.Cond == 4 || condition.Cond == 5 {
+			return v.rate
+		}
+		return v.selected(condition.SetOperatorExp)
+	}
+	return v.sum
+}
+
+// nativeNotification builds the notification of a series in the format of alertmanager webhooks
+func nativeNotification(alarmObj *db.Alarm, t alertTransition, now time.Time) view.Notification {
+	series := t.series
+	labels := map[string]string{
+		"alertname": alarmObj.AlertUniqueName(),
+		"severity":  alarmObj.Severity(),
+	}
+	for k, v := range alarmObj.Tags {
+		labels[k] = v
+	}
+	for k, v := range series.labels {
+		labels[k] = v
+	}
+	labels["uuid"] = alarmObj.Uuid
+	desc := alarmObj.Desc
+	if series.key != "" {
+		desc = fmt.Sprintf("%s [%s]", desc, series.key)
+	}
+	value := strconv.FormatFloat(t.value, 'f', -1, 64)
+	summary, description := push.RenderAnnotations(push.AnnotationData{Name: alarmObj.Name, Desc: desc, Value: value})
+	annotations := map[string]string{
+		"summary":     summary,
+		"description": description,
+		"value":       value,
+	}
+	if t.anomaly != nil {
+		annotations["expected"] = formatAnomalyValue(t.anomaly.expected)
+		annotations["lower"] = formatAnomalyValue(t.anomaly.lower)
+		annotations["upper"] = formatAnomalyValue(t.anomaly.upper)
+	}
+	alert := view.Alert{
+		Labels:      labels,
+		Annotations: annotations,
+		StartsAt:    t.activeAt,
+	}
+	if t.status == NotificationResolved {
+		alert.EndsAt = now
+	}
+	groupKey := alarmObj.AlertUniqueName()
+	if series.key != "" {
+		groupKey = fmt.Sprintf("%s{%s}", groupKey, series.key)
+	}
+	return view.Notification{
+		Version:           "4",
+		GroupKey:          groupKey,
+		Status:            t.status,
+		Receiver:          "clickvisual",
+		GroupLabels:       map[string]string{"alertname": labels["alertname"]},
+		CommonLabels:      labels,
+		CommonAnnotations: annotations,
+		Alerts:            []view.Alert{alert},
+	}
+}
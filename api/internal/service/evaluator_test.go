@@ -0,0 +1,159 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+func Test_conditionsMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []*db.AlarmCondition
+		val        float64
+		want       bool
+	}{
+		{name: "gt", conditions: []*db.AlarmCondition{{Cond: 0, Val1: 10}}, val: 11, want: true},
+		{name: "gt equal", conditions: []*db.AlarmCondition{{Cond: 0, Val1: 10}}, val: 10, want: false},
+		{name: "lt", conditions: []*db.AlarmCondition{{Cond: 1, Val1: 10}}, val: 9, want: true},
+		{name: "outside", conditions: []*db.AlarmCondition{{Cond: 2, Val1: 10, Val2: 20}}, val: 21, want: true},
+		{name: "not outside", conditions: []*db.AlarmCondition{{Cond: 2, Val1: 10, Val2: 20}}, val: 15, want: false},
+		{name: "between", conditions: []*db.AlarmCondition{{Cond: 3, Val1: 10, Val2: 20}}, val: 20, want: true},
+		{name: "and", conditions: []*db.AlarmCondition{
+			{SetOperatorTyp: 1, Cond: 1, Val1: 20},
+			{SetOperatorTyp: 0, Cond: 0, Val1: 10},
+		}, val: 25, want: false},
+		{name: "or", conditions: []*db.AlarmCondition{
+			{SetOperatorTyp: 2, Cond: 1, Val1: 5},
+			{SetOperatorTyp: 0, Cond: 0, Val1: 10},
+		}, val: 3, want: true},
+		{name: "float", conditions: []*db.AlarmCondition{{Cond: 0, Val1: 0.5}}, val: 0.75, want: true},
+		{name: "empty", conditions: nil, val: 3, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditionsMatch(tt.conditions, scalarValue(tt.val, time.Minute), nil); got != tt.want {
+				t.Errorf("conditionsMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_conditionsMatch_selectors(t *testing.T) {
+	cur := alertValue{last: 3, avg: 2, min: 1, max: 8, sum: 120, count: 60, rate: 2}
+	tests := []struct {
+		name      string
+		condition db.AlarmCondition
+		prev      *alertValue
+		want      bool
+	}{
+		{name: "last", condition: db.AlarmCondition{SetOperatorExp: 0, Cond: 0, Val1: 2.5}, want: true},
+		{name: "avg", condition: db.AlarmCondition{SetOperatorExp: 5, Cond: 0, Val1: 2.5}, want: false},
+		{name: "min", condition: db.AlarmCondition{SetOperatorExp: 1, Cond: 0, Val1: 1.5}, want: false},
+		{name: "max", condition: db.AlarmCondition{SetOperatorExp: 2, Cond: 3, Val1: 5, Val2: 10}, want: true},
+		{name: "sum", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 1, Val1: 100}, want: false},
+		{name: "count", condition: db.AlarmCondition{SetOperatorExp: 4, Cond: 0, Val1: 59}, want: true},
+		{name: "rate above", condition: db.AlarmCondition{Cond: 4, Val1: 1.99}, want: true},
+		{name: "rate below", condition: db.AlarmCondition{Cond: 5, Val1: 1.99}, want: false},
+		{name: "increase", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 6, Val1: 50}, prev: &alertValue{sum: 60}, want: true},
+		{name: "increase not enough", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 6, Val1: 50}, prev: &alertValue{sum: 100}, want: false},
+		{name: "increase from zero", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 6, Val1: 50}, prev: &alertValue{}, want: true},
+		{name: "increase without previous", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 6, Val1: 50}, want: false},
+		{name: "decrease", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 7, Val1: 20}, prev: &alertValue{sum: 200}, want: true},
+		{name: "decrease on increase", condition: db.AlarmCondition{SetOperatorExp: 3, Cond: 7, Val1: 20}, prev: &alertValue{sum: 60}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditionsMatch([]*db.AlarmCondition{&tt.condition}, cur, tt.prev); got != tt.want {
+				t.Errorf("conditionsMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_samplesValue(t *testing.T) {
+	row := map[string]interface{}{"val_last": float64(3), "val_avg": 2.5, "val_min": float64(1), "val_max": float64(4), "val_sum": float64(30), "val_count": float64(12)}
+	got, ok := samplesValue(row, time.Minute)
+	want := alertValue{last: 3, avg: 2.5, min: 1, max: 4, sum: 30, count: 12, rate: 0.5}
+	if !ok || got != want {
+		t.Errorf("samplesValue() = %v, %v, want %v", got, ok, want)
+	}
+	if _, ok = samplesValue(map[string]interface{}{"val_avg": "", "val_sum": float64(0), "val_count": float64(0)}, time.Minute); ok {
+		t.Error("samplesValue() without samples should not be ok")
+	}
+}
+
+func Test_alertState_next(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name        string
+		forDuration time.Duration
+		breached    []bool
+		want        []string
+	}{
+		{name: "fire at once", breached: []bool{true, true, false, false}, want: []string{NotificationFiring, "", NotificationResolved, ""}},
+		{name: "pending", forDuration: time.Minute, breached: []bool{true, true, true, false}, want: []string{"", "", NotificationFiring, NotificationResolved}},
+		{name: "pending cancelled", forDuration: time.Minute, breached: []bool{true, false, true, true}, want: []string{"", "", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &alertState{}
+			for i, breached := range tt.breached {
+				// one evaluation every 30 seconds
+				if got := s.next(breached, now.Add(time.Duration(i)*30*time.Second), tt.forDuration); got != tt.want[i] {
+					t.Errorf("next() #%d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_alertState_advance(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	alarmObj := &db.Alarm{GroupBy: db.Strings{"service"}}
+	conditions := []*db.AlarmCondition{{Cond: 0, Val1: 50}}
+	group := func(service string, val float64) alertSeries {
+		return alertSeries{key: "service=" + service, labels: map[string]string{"service": service}, value: scalarValue(val, time.Minute), ok: true}
+	}
+	transitions := func(res []alertTransition) []string {
+		out := make([]string, 0, len(res))
+		for _, t := range res {
+			out = append(out, t.status+" "+t.series.key)
+		}
+		return out
+	}
+	s := &alertState{}
+	steps := []struct {
+		series []alertSeries
+		want   []string
+	}{
+		{series: []alertSeries{group("api", 60), group("web", 10)}, want: []string{"firing service=api"}},
+		{series: []alertSeries{group("api", 70), group("web", 80)}, want: []string{"firing service=web"}},
+		// api is gone, web recovers
+		{series: []alertSeries{group("web", 10)}, want: []string{"resolved service=web", "resolved service=api"}},
+		{series: []alertSeries{{}}, want: []string{}},
+	}
+	for i, step := range steps {
+		got := transitions(s.advance(alarmObj, conditions, step.series, now.Add(time.Duration(i)*time.Minute)))
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("advance() #%d = %v, want %v", i, got, step.want)
+		}
+	}
+	if len(s.series) != 0 {
+		t.Errorf("inactive series should be dropped, got %v", s.series)
+	}
+}
+
+func Test_nativeNotification(t *testing.T) {
+	alarmObj := &db.Alarm{Uuid: "a-b", Desc: "errors", GroupBy: db.Strings{"service"}, Level: db.AlarmLevelFatal}
+	series := alertSeries{key: "service=api", labels: map[string]string{"service": "api"}}
+	n := nativeNotification(alarmObj, alertTransition{series: series, status: NotificationFiring, activeAt: time.Unix(1700000000, 0), value: 60}, time.Unix(1700000060, 0))
+	if n.CommonLabels["service"] != "api" || n.CommonLabels["severity"] != "critical" || n.GroupKey != "a_b{service=api}" {
+		t.Errorf("nativeNotification() labels = %v, group key = %s", n.CommonLabels, n.GroupKey)
+	}
+	if want := "errors [service=api]  (当前值: 60)"; n.CommonAnnotations["description"] != want {
+		t.Errorf("nativeNotification() description = %q, want %q", n.CommonAnnotations["description"], want)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+// freshness pauses the alarms whose tables stop receiving logs, so that a broken ingestion
+// does not fire the no data alarms nor silence the threshold ones without a word.
+// A stale alarm sends a single data source stale notification, and opens again once the logs come back.
+// The status of the alarm caches the result of the ticker, the evaluator and the webhook read it instead of querying the tables.
+type freshness struct{}
+
+func NewFreshness() *freshness {
+	f := &freshness{}
+	tick := econf.GetDuration("alarm.freshness.tick")
+	if tick <= 0 {
+		tick = time.Minute
+	}
+	xgo.Go(func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for range ticker.C {
+			f.run(time.Now())
+		}
+	})
+	return f
+}
+
+// run checks the tables of the alarms with the freshness check, every table is queried once
+func (f *freshness) run(now time.Time) {
+	conds := egorm.Conds{}
+	conds["freshness"] = egorm.Cond{Op: ">", Val: 0}
+	conds["status"] = egorm.Cond{Op: "in", Val: []int{db.AlarmStatusOpen, db.AlarmStatusFiring, db.AlarmStatusStale}}
+	alarms, err := db.AlarmList(conds)
+	if err != nil {
+		invoker.Logger.Error("freshness", elog.String("step", "AlarmList"), elog.String("error", err.Error()))
+		return
+	}
+	windows := make(map[int]int)
+	for _, a := range alarms {
+		if a.Freshness > windows[a.Tid] {
+			windows[a.Tid] = a.Freshness
+		}
+	}
+	latests := make(map[int]int64, len(windows))
+	for tid, window := range windows {
+		latest, errLatest := tableLatest(tid, window, now)
+		if errLatest != nil {
+			invoker.Logger.Error("freshness", elog.Int("tid", tid), elog.String("error", errLatest.Error()))
+			continue
+		}
+		latests[tid] = latest
+	}
+	for _, a := range alarms {
+		latest, ok := latests[a.Tid]
+		if !ok {
+			continue
+		}
+		if _, err = f.apply(a, latest, now); err != nil {
+			invoker.Logger.Error("freshness", elog.Int("alarmId", a.ID), elog.String("error", err.Error()))
+		}
+	}
+}
+
+// apply moves the alarm to the status of the freshness of its table, the replica that moves it in notifies the channels
+func (f *freshness) apply(alarmObj *db.Alarm, latest int64, now time.Time) (bool, error) {
+	stale := tableStale(latest, alarmObj.Freshness, now)
+	switch {
+	case stale && alarmObj.Status != db.AlarmStatusStale:
+		ok, err := db.AlarmStatusSwap(invoker.Db, alarmObj.ID, []int{db.AlarmStatusOpen, db.AlarmStatusFiring}, db.AlarmStatusStale)
+		if err != nil || !ok {
+			return stale, err
+		}
+		alarmObj.Status = db.AlarmStatusStale
+		return stale, notifyStale(alarmObj, latest)
+	case !stale && alarmObj.Status == db.AlarmStatusStale:
+		ok, err := db.AlarmStatusSwap(invoker.Db, alarmObj.ID, []int{db.AlarmStatusStale}, db.AlarmStatusOpen)
+		if err != nil || !ok {
+			return stale, err
+		}
+		alarmObj.Status = db.AlarmStatusOpen
+		invoker.Logger.Info("freshness", elog.Int("alarmId", alarmObj.ID), elog.String("step", "recovered"))
+	}
+	return stale, nil
+}
+
+// tableStale reports whether the latest log is older than the freshness minutes, latest is 0 when there is none in the window
+func tableStale(latest int64, freshness int, now time.Time) bool {
+	return latest <= now.Add(-time.Duration(freshness)*time.Minute).Unix()
+}
+
+// tableLatest returns the unix seconds of the latest log of the table within the last window minutes, 0 when there is none
+func tableLatest(tid, window int, now time.Time) (int64, error) {
+	tableInfo, err := db.TableInfo(invoker.Db, tid)
+	if err != nil {
+		return 0, errors.Wrap(err, "table")
+	}
+	op, err := InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		return 0, err
+	}
+	res, err := op.Complete(inquiry.TableFreshnessSQL(view.ReqQuery{
+		Database:      tableInfo.Database.Name,
+		Table:         tableInfo.Name,
+		TimeField:     tableInfo.GetTimeField(),
+		TimeFieldType: tableInfo.TimeFieldType,
+		ST:            now.Add(-time.Duration(window) * time.Minute).Unix(),
+		ET:            now.Add(time.Minute).Unix(),
+	}))
+	if err != nil {
+		return 0, err
+	}
+	if len(res.Logs) == 0 {
+		return 0, nil
+	}
+	return cast.ToInt64(res.Logs[0]["latest"]), nil
+}
+
+// notifyStale records the stale history of the alarm and notifies its channels
+func notifyStale(alarmObj *db.Alarm, latest int64) error {
+	notification := staleNotification(alarmObj, latest)
+	history := historyFromNotification(alarmObj.ID, notification)
+	if err := db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return err
+	}
+	return enqueueDeliveries(alarmObj.ID, history.ID, alarmObj.ChannelIds, notification, "")
+}
+
+// staleNotification is the notification of the stale table, the alert starts at the latest log when there is one
+func staleNotification(alarmObj *db.Alarm, latest int64) view.Notification {
+	labels := map[string]string{"alertname": alarmObj.AlertUniqueName(), "uuid": alarmObj.Uuid}
+	annotations := map[string]string{"freshness": strconv.Itoa(alarmObj.Freshness)}
+	alert := view.Alert{Labels: labels, Annotations: annotations}
+	if latest > 0 {
+		alert.StartsAt = time.Unix(latest, 0)
+	}
+	return view.Notification{
+		GroupKey:          alarmObj.Uuid,
+		Status:            push.StatusStale,
+		CommonLabels:      labels,
+		CommonAnnotations: annotations,
+		Alerts:            []view.Alert{alert},
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+func Test_tableStale(t *testing.T) {
+	now := time.Unix(10000, 0)
+	tests := []struct {
+		name   string
+		latest int64
+		want   bool
+	}{
+		{name: "no logs in the window", latest: 0, want: true},
+		{name: "older than the freshness", latest: 10000 - 600, want: true},
+		{name: "within the freshness", latest: 10000 - 599},
+		{name: "ahead of the clock", latest: 10030},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tableStale(tt.latest, 10, now); got != tt.want {
+				t.Errorf("tableStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_staleNotification(t *testing.T) {
+	alarmObj := &db.Alarm{Uuid: "a-b", Name: "nginx 5xx", Freshness: 15}
+	n := staleNotification(alarmObj, 0)
+	if n.Status != push.StatusStale || n.CommonAnnotations["freshness"] != "15" || !n.Alerts[0].StartsAt.IsZero() {
+		t.Errorf("staleNotification() = %+v", n)
+	}
+	n = staleNotification(alarmObj, 9000)
+	if n.Alerts[0].StartsAt.Unix() != 9000 {
+		t.Errorf("staleNotification() startsAt = %v", n.Alerts[0].StartsAt)
+	}
+	if h := historyFromNotification(1, n); h.Status != db.AlarmHistoryStatusStale || h.StartsAt != 9000 {
+		t.Errorf("historyFromNotification() = %+v", h)
+	}
+}
@@ -0,0 +1,532 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/constx"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/utils"
+)
+
+const (
+	ingestDefaultFlushSize     = 1000
+	ingestDefaultFlushInterval = time.Second
+	ingestDefaultBufferSize    = 100000
+	ingestDefaultRetries       = 3
+	ingestDefaultRetryInterval = time.Second
+)
+
+// ingestDefaultMapping is the source mapping of tables created by clickvisual without custom fields
+var ingestDefaultMapping = []view.MappingStructItem{
+	{Key: "_source_", Value: "String"},
+	{Key: "_cluster_", Value: "String"},
+	{Key: "_log_agent_", Value: "String"},
+	{Key: "_namespace_", Value: "String"},
+	{Key: "_node_name_", Value: "String"},
+	{Key: "_node_ip_", Value: "String"},
+	{Key: "_container_name_", Value: "String"},
+	{Key: "_pod_name_", Value: "String"},
+}
+
+var ingestTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// IngestToken is an access token of the ingestion api
+type IngestToken struct {
+	Name   string  `json:"name"`
+	Token  string  `json:"token"`
+	Rate   float64 `json:"rate"`   // rows per second, 0 means unlimited
+	Burst  int     `json:"burst"`  // max rows accepted at once, default equals rate
+	Tables []int   `json:"tables"` // table ids allowed to write, empty means all tables
+}
+
+// AllowTable reports whether the token is allowed to write into the table
+func (t *IngestToken) AllowTable(tid int) bool {
+	if len(t.Tables) == 0 {
+		return true
+	}
+	for _, id := range t.Tables {
+		if id == tid {
+			return true
+		}
+	}
+	return false
+}
+
+// ingestSchema describes how a log line is mapped onto the columns of the data table
+type ingestSchema struct {
+	tid      int
+	iid      int
+	database string
+	table    string
+	storage  view.ReqStorageCreate
+	indexes  []*db.BaseIndex
+	columns  []string
+}
+
+type ingestBuffer struct {
+	schema *ingestSchema
+	rows   [][]interface{}
+}
+
+type ingest struct {
+	flushSize     int
+	flushInterval time.Duration
+	bufferSize    int64
+	retries       int
+	retryInterval time.Duration
+	pending       int64 // rows accepted but not yet written
+
+	tokens   map[string]*IngestToken
+	limiters map[string]*rate.Limiter
+
+	mu      sync.Mutex
+	buffers map[int]*ingestBuffer
+}
+
+func NewIngest() *ingest {
+	i := &ingest{
+		flushSize:     econf.GetInt("ingest.flushSize"),
+		flushInterval: econf.GetDuration("ingest.flushInterval"),
+		bufferSize:    econf.GetInt64("ingest.bufferSize"),
+		retries:       econf.GetInt("ingest.retries"),
+		retryInterval: econf.GetDuration("ingest.retryInterval"),
+		tokens:        make(map[string]*IngestToken),
+		limiters:      make(map[string]*rate.Limiter),
+		buffers:       make(map[int]*ingestBuffer),
+	}
+	if i.flushSize <= 0 {
+		i.flushSize = ingestDefaultFlushSize
+	}
+	if i.flushInterval <= 0 {
+		i.flushInterval = ingestDefaultFlushInterval
+	}
+	if i.bufferSize <= 0 {
+		i.bufferSize = ingestDefaultBufferSize
+	}
+	if i.retries <= 0 {
+		i.retries = ingestDefaultRetries
+	}
+	if i.retryInterval <= 0 {
+		i.retryInterval = ingestDefaultRetryInterval
+	}
+	tokens := make([]*IngestToken, 0)
+	_ = econf.UnmarshalKey("ingest.tokens", &tokens)
+	for _, t := range tokens {
+		if t.Token == "" {
+			continue
+		}
+		i.tokens[t.Token] = t
+		if t.Rate > 0 {
+			burst := t.Burst
+			if burst <= 0 {
+				burst = int(math.Ceil(t.Rate))
+			}
+			i.limiters[t.Token] = rate.NewLimiter(rate.Limit(t.Rate), burst)
+		}
+	}
+	xgo.Go(func() {
+		ticker := time.NewTicker(i.flushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			i.flushAll()
+		}
+	})
+	return i
+}
+
+// Token returns the configured token, false if it does not exist
+func (i *ingest) Token(token string) (*IngestToken, bool) {
+	t, ok := i.tokens[token]
+	return t, ok
+}
+
+// Allow reports whether the token may write n rows now.
+// A batch larger than the burst of the token can never be accepted, it gets ErrIngestBatchTooLarge instead of the rate limit error.
+func (i *ingest) Allow(t *IngestToken, n int) error {
+	limiter, ok := i.limiters[t.Token]
+	if !ok {
+		return nil
+	}
+	if n > limiter.Burst() {
+		return constx.ErrIngestBatchTooLarge
+	}
+	if !limiter.AllowN(time.Now(), n) {
+		return constx.ErrIngestRateLimited
+	}
+	return nil
+}
+
+// Schema loads the column mapping of the data table
+func (i *ingest) Schema(tableInfo db.BaseTable) (*ingestSchema, error) {
+	var storage view.ReqStorageCreate
+	switch tableInfo.CreateType {
+	case inquiry.TableCreateTypeAnyJSON:
+		storage = view.ReqStorageCreateUnmarshal(tableInfo.AnyJSON)
+	case inquiry.TableCreateTypeCV:
+		storage = view.ReqStorageCreate{Typ: tableInfo.Typ}
+	default:
+		return nil, constx.ErrIngestTableNotSupported
+	}
+	if storage.TimeField == "" {
+		storage.TimeField = "_time_"
+	}
+	if len(storage.SourceMapping.Data) == 0 {
+		storage.SourceMapping.Data = ingestDefaultMapping
+	}
+	indexes, err := db.IndexList(egorm.Conds{"tid": tableInfo.ID})
+	if err != nil {
+		return nil, errors.Wrap(err, "index list")
+	}
+	return newIngestSchema(tableInfo, storage, indexes), nil
+}
+
+func newIngestSchema(tableInfo db.BaseTable, storage view.ReqStorageCreate, indexes []*db.BaseIndex) *ingestSchema {
+	s := &ingestSchema{
+		tid:     tableInfo.ID,
+		table:   tableInfo.Name,
+		storage: storage,
+		indexes: indexes,
+	}
+	if tableInfo.Database != nil {
+		s.iid = tableInfo.Database.Iid
+		s.database = tableInfo.Database.Name
+	}
+	for _, item := range s.mapping() {
+		s.columns = append(s.columns, item.Key)
+	}
+	s.columns = append(s.columns, db.TimeFieldSecond, db.TimeFieldNanoseconds, "_raw_log_")
+	for _, idx := range indexes {
+		s.columns = append(s.columns, idx.GetFieldName())
+		if hashFieldName, ok := idx.GetHashFieldName(); ok {
+			s.columns = append(s.columns, hashFieldName)
+		}
+	}
+	return s
+}
+
+func (s *ingestSchema) mapping() []view.MappingStructItem {
+	res := make([]view.MappingStructItem, 0, len(s.storage.SourceMapping.Data))
+	for _, item := range s.storage.SourceMapping.Data {
+		if item.Key == s.storage.TimeField || item.Key == s.storage.RawLogField {
+			continue
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func (s *ingestSchema) key() string {
+	return strings.Join(s.columns, ",")
+}
+
+// Row converts one json log line into a data table row
+func (s *ingestSchema) Row(line []byte) ([]interface{}, error) {
+	obj := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	rawLog, ok := obj[s.storage.GetRawLogField()]
+	if !ok {
+		rawLog = string(line)
+	}
+	return s.row(obj, ingestTime(s.storage.Typ, obj[s.storage.TimeField]), ingestValue("String", rawLog).(string), nil), nil
+}
+
+// StreamRow converts one entry of a labeled log stream into a data table row.
+// Labels fill the mapping columns, and the analysis fields missing from the log line.
+func (s *ingestSchema) StreamRow(labels map[string]string, ts time.Time, line string) []interface{} {
+	obj := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		obj[k] = v
+	}
+	return s.row(obj, ts, line, obj)
+}
+
+func (s *ingestSchema) row(obj map[string]interface{}, ts time.Time, rawLog string, fallback map[string]interface{}) []interface{} {
+	row := make([]interface{}, 0, len(s.columns))
+	for _, item := range s.mapping() {
+		row = append(row, ingestValue(item.Value, obj[item.Key]))
+	}
+	row = append(row, time.Unix(ts.Unix(), 0), ts, rawLog)
+	if len(s.indexes) == 0 {
+		return row
+	}
+	fields := make(map[string]interface{})
+	decoder := json.NewDecoder(strings.NewReader(rawLog))
+	decoder.UseNumber()
+	_ = decoder.Decode(&fields)
+	for k, v := range fallback {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	for _, idx := range s.indexes {
+		row = append(row, ingestIndexValue(idx, fields))
+		if _, ok := idx.GetHashFieldName(); ok {
+			row = append(row, ingestHashValue(idx, fields))
+		}
+	}
+	return row
+}
+
+// Parse converts an NDJSON body into data table rows, empty lines are skipped
+func (i *ingest) Parse(schema *ingestSchema, r io.Reader) ([][]interface{}, error) {
+	rows := make([][]interface{}, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row, err := schema.Row(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", lineNo)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Push appends rows to the buffer of the table, rows are rejected when the buffer is full
+func (i *ingest) Push(schema *ingestSchema, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if atomic.AddInt64(&i.pending, int64(len(rows))) > i.bufferSize {
+		atomic.AddInt64(&i.pending, -int64(len(rows)))
+		return constx.ErrIngestBufferFull
+	}
+	i.mu.Lock()
+	buf, ok := i.buffers[schema.tid]
+	if !ok {
+		buf = &ingestBuffer{schema: schema}
+		i.buffers[schema.tid] = buf
+	}
+	if buf.schema.key() != schema.key() {
+		// the table structure has changed, write the rows of the previous structure first
+		prev := buf.rows
+		prevSchema := buf.schema
+		buf.schema, buf.rows = schema, nil
+		xgo.Go(func() {
+			i.write(prevSchema, prev)
+		})
+	}
+	buf.rows = append(buf.rows, rows...)
+	full := len(buf.rows) >= i.flushSize
+	i.mu.Unlock()
+	if full {
+		xgo.Go(func() {
+			i.flush(schema.tid)
+		})
+	}
+	return nil
+}
+
+func (i *ingest) flushAll() {
+	i.mu.Lock()
+	tids := make([]int, 0, len(i.buffers))
+	for tid, buf := range i.buffers {
+		if len(buf.rows) > 0 {
+			tids = append(tids, tid)
+		}
+	}
+	i.mu.Unlock()
+	for _, tid := range tids {
+		i.flush(tid)
+	}
+}
+
+func (i *ingest) flush(tid int) {
+	i.mu.Lock()
+	buf, ok := i.buffers[tid]
+	if !ok || len(buf.rows) == 0 {
+		i.mu.Unlock()
+		return
+	}
+	schema, rows := buf.schema, buf.rows
+	buf.rows = nil
+	i.mu.Unlock()
+	i.write(schema, rows)
+}
+
+// write inserts the acknowledged rows, a failed insert is retried with a growing interval.
+// The rows stay pending while retrying, so a broken database fills the buffer and new requests get 429.
+func (i *ingest) write(schema *ingestSchema, rows [][]interface{}) {
+	if len(rows) == 0 {
+		return
+	}
+	defer atomic.AddInt64(&i.pending, -int64(len(rows)))
+	var err error
+	for attempt := 0; attempt <= i.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * i.retryInterval)
+		}
+		if err = i.insert(schema, rows); err == nil {
+			return
+		}
+		invoker.Logger.Warn("ingest", elog.String("step", "insert"), elog.Int("tid", schema.tid), elog.Int("attempt", attempt), elog.String("error", err.Error()))
+	}
+	invoker.Logger.Error("ingest", elog.String("step", "drop"), elog.Int("tid", schema.tid), elog.Int("rows", len(rows)), elog.String("error", err.Error()))
+}
+
+func (i *ingest) insert(schema *ingestSchema, rows [][]interface{}) error {
+	op, err := InstanceManager.Load(schema.iid)
+	if err != nil {
+		return err
+	}
+	return op.Insert(schema.database, schema.table, schema.columns, rows)
+}
+
+func ingestValue(typ string, v interface{}) interface{} {
+	switch typ {
+	case "Float64":
+		switch val := v.(type) {
+		case json.Number:
+			f, _ := val.Float64()
+			return f
+		case string:
+			f, _ := strconv.ParseFloat(val, 64)
+			return f
+		case bool:
+			if val {
+				return float64(1)
+			}
+		}
+		return float64(0)
+	case "Bool":
+		switch val := v.(type) {
+		case bool:
+			return val
+		case string:
+			b, _ := strconv.ParseBool(val)
+			return b
+		case json.Number:
+			f, _ := val.Float64()
+			return f != 0
+		}
+		return false
+	}
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	res, _ := json.Marshal(v)
+	return string(res)
+}
+
+// ingestTime parses the time field, the current time is used when it is missing or invalid
+func ingestTime(typ int, v interface{}) time.Time {
+	switch val := v.(type) {
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return ingestFloatTime(f)
+		}
+	case string:
+		if typ == inquiry.TimeTypeFloat {
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				return ingestFloatTime(f)
+			}
+		}
+		for _, layout := range ingestTimeLayouts {
+			if t, err := time.ParseInLocation(layout, val, time.Local); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Now()
+}
+
+func ingestFloatTime(f float64) time.Time {
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// ingestHashValue computes the hash column of the analysis field, keeping the semantics of genJsonExtractSQL:
+// the field is read as JSONExtractString, which is empty for a missing or non string value
+func ingestHashValue(idx *db.BaseIndex, fields map[string]interface{}) uint64 {
+	if idx.RootName != "" {
+		root, _ := fields[idx.RootName].(map[string]interface{})
+		fields = root
+	}
+	s, _ := fields[idx.Field].(string)
+	if idx.HashTyp == db.HashTypeURL {
+		return utils.URLHash(s)
+	}
+	return utils.SipHash64(s)
+}
+
+// ingestIndexValue extracts the analysis field from the raw log, keeping the semantics of genJsonExtractSQL
+func ingestIndexValue(idx *db.BaseIndex, fields map[string]interface{}) interface{} {
+	if idx.RootName != "" {
+		root, _ := fields[idx.RootName].(map[string]interface{})
+		fields = root
+	}
+	v, ok := fields[idx.Field]
+	switch idx.Typ {
+	case 0:
+		s := ""
+		if ok {
+			s = ingestValue("String", v).(string)
+		}
+		return &s
+	case 1:
+		if !ok {
+			return nil
+		}
+		s := strings.ReplaceAll(ingestValue("String", v).(string), `"`, "")
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return &n
+	case 2:
+		if !ok {
+			return nil
+		}
+		s := strings.ReplaceAll(ingestValue("String", v).(string), `"`, "")
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil
+		}
+		return &f
+	}
+	return nil
+}
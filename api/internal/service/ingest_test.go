@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry"
+	"github.com/clickvisual/clickvisual/api/pkg/constx"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func strPtr(s string) *string       { return &s }
+func int64Ptr(n int64) *int64       { return &n }
+func float64Ptr(f float64) *float64 { return &f }
+
+func Test_ingestSchemaRow(t *testing.T) {
+	ts := time.Unix(1662000000, 500000000)
+	tests := []struct {
+		name    string
+		storage view.ReqStorageCreate
+		indexes []*db.BaseIndex
+		line    string
+		columns []string
+		want    []interface{}
+	}{
+		{
+			name: "test-1",
+			storage: view.ReqStorageCreate{
+				Typ:         inquiry.TimeTypeFloat,
+				TimeField:   "ts",
+				RawLogField: "log",
+				SourceMapping: view.MappingStruct{Data: []view.MappingStructItem{
+					{Key: "ts", Value: "Float64"},
+					{Key: "app", Value: "String"},
+					{Key: "cost", Value: "Float64"},
+					{Key: "ok", Value: "Bool"},
+					{Key: "log", Value: "String"},
+				}},
+			},
+			indexes: []*db.BaseIndex{
+				{Field: "code", Typ: 1},
+				{Field: "uri", RootName: "req", Typ: 0},
+				{Field: "missing", Typ: 2},
+			},
+			line:    `{"ts":1662000000.5,"app":"svc","cost":"1.5","ok":true,"log":"{\"code\":\"200\",\"req\":{\"uri\":\"/ping\"}}"}`,
+			columns: []string{"app", "cost", "ok", "_time_second_", "_time_nanosecond_", "_raw_log_", "code", "req.uri", "missing"},
+			want:    []interface{}{"svc", 1.5, true, time.Unix(1662000000, 0), ts, `{"code":"200","req":{"uri":"/ping"}}`, int64Ptr(200), strPtr("/ping"), nil},
+		},
+		{
+			name: "test-2",
+			storage: view.ReqStorageCreate{
+				Typ:       inquiry.TimeTypeString,
+				TimeField: "_time_",
+				SourceMapping: view.MappingStruct{Data: []view.MappingStructItem{
+					{Key: "_pod_name_", Value: "String"},
+					{Key: "_namespace_", Value: "String"},
+				}},
+			},
+			indexes: []*db.BaseIndex{{Field: "lat", Typ: 2}},
+			line:    `{"_time_":"2022-09-01T02:40:00.5Z","_pod_name_":{"a":1},"_log_":{"lat":0.25}}`,
+			columns: []string{"_pod_name_", "_namespace_", "_time_second_", "_time_nanosecond_", "_raw_log_", "lat"},
+			want:    []interface{}{`{"a":1}`, "", time.Unix(1662000000, 0), ts, `{"lat":0.25}`, float64Ptr(0.25)},
+		},
+		{
+			name: "test-3",
+			storage: view.ReqStorageCreate{
+				Typ:           inquiry.TimeTypeString,
+				TimeField:     "_time_",
+				SourceMapping: view.MappingStruct{Data: []view.MappingStructItem{{Key: "_pod_name_", Value: "String"}}},
+			},
+			indexes: []*db.BaseIndex{{Field: "trace", HashTyp: db.HashTypeSip}, {Field: "url", HashTyp: db.HashTypeURL}},
+			line:    `{"_time_":"2022-09-01T02:40:00.5Z","_pod_name_":"p","_log_":{"url":1}}`,
+			columns: []string{"_pod_name_", "_time_second_", "_time_nanosecond_", "_raw_log_", "trace", "_inner_siphash_trace_", "url", "_inner_urlhash_url_"},
+			want:    []interface{}{"p", time.Unix(1662000000, 0), ts, `{"url":1}`, strPtr(""), uint64(2202906307356721367), strPtr("1"), uint64(11160318154034397263)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newIngestSchema(db.BaseTable{Name: "t"}, tt.storage, tt.indexes)
+			if !reflect.DeepEqual(s.columns, tt.columns) {
+				t.Errorf("columns = %v, want %v", s.columns, tt.columns)
+			}
+			got, err := s.Row([]byte(tt.line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Row() = %v, want %v", got, tt.want)
+			}
+			for k := range got {
+				g, w := got[k], tt.want[k]
+				if gt, ok := g.(time.Time); ok {
+					if !gt.Equal(w.(time.Time)) {
+						t.Errorf("Row()[%d] = %v, want %v", k, g, w)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(g, w) {
+					t.Errorf("Row()[%d] = %#v, want %#v", k, g, w)
+				}
+			}
+		})
+	}
+}
+
+func Test_ingestAllow(t *testing.T) {
+	token := &IngestToken{Token: "t"}
+	i := &ingest{limiters: map[string]*rate.Limiter{"t": rate.NewLimiter(1, 10)}}
+	if err := i.Allow(token, 11); !errors.Is(err, constx.ErrIngestBatchTooLarge) {
+		t.Errorf("Allow() = %v, want %v", err, constx.ErrIngestBatchTooLarge)
+	}
+	if err := i.Allow(token, 10); err != nil {
+		t.Errorf("Allow() = %v", err)
+	}
+	if err := i.Allow(token, 10); !errors.Is(err, constx.ErrIngestRateLimited) {
+		t.Errorf("Allow() = %v, want %v", err, constx.ErrIngestRateLimited)
+	}
+	if err := i.Allow(&IngestToken{Token: "unlimited"}, 1000); err != nil {
+		t.Errorf("Allow() = %v", err)
+	}
+}
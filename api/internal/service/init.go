@@ -16,6 +16,12 @@ var (
 	InstanceManager *instanceManager
 	Index           *index
 	Alarm           *alarm
+	Ingest          *ingest
+	Evaluator       *evaluator
+	Outbox          *outbox
+	Noise           *noise
+	Escalator       *escalator
+	Freshness       *freshness
 )
 
 func Init() error {
@@ -24,6 +30,12 @@ func Init() error {
 
 	Index = NewIndex()
 	Alarm = NewAlarm()
+	Ingest = NewIngest()
+	Evaluator = NewEvaluator()
+	Noise = NewNoise()
+	Outbox = NewOutbox()
+	Escalator = NewEscalator()
+	Freshness = NewFreshness()
 
 	initGob()
 	configure.InitConfigure()
@@ -48,6 +48,7 @@ type ParamsView struct {
 	SourceTable  string
 	Where        string
 	TimeConvert  string
+	GroupBy      string // extra group by fields of ViewTypePrometheusMetric, one series per group
 }
 
 const PrometheusMetricName = "clickvisual_alert_metrics"
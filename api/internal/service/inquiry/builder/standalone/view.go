@@ -74,7 +74,11 @@ FROM %s
 func (b *ViewBuilder) BuilderWhere() {
 	switch b.QueryAssembly.Params.View.ViewType {
 	case bumo.ViewTypePrometheusMetric:
-		b.QueryAssembly.Result += fmt.Sprintf("WHERE %s GROUP BY %s\n", b.QueryAssembly.Params.View.Where, b.QueryAssembly.Params.TimeField)
+		groupBy := b.QueryAssembly.Params.TimeField
+		if b.QueryAssembly.Params.View.GroupBy != "" {
+			groupBy += ", " + b.QueryAssembly.Params.View.GroupBy
+		}
+		b.QueryAssembly.Result += fmt.Sprintf("WHERE %s GROUP BY %s\n", b.QueryAssembly.Params.View.Where, groupBy)
 	case bumo.ViewTypePrometheusMetricAggregation:
 		b.QueryAssembly.Result += fmt.Sprintf("GROUP BY %s\n", b.QueryAssembly.Params.TimeField)
 	default:
@@ -206,6 +206,11 @@ func (c *ClickHouse) ViewSync(table db.BaseTable, current *db.BaseView, list []*
 }
 
 func (c *ClickHouse) Prepare(res view.ReqQuery, isFilter bool) (view.ReqQuery, error) {
+	return prepare(res, isFilter)
+}
+
+// prepare fills the default values of the query parameters shared by all datasources
+func prepare(res view.ReqQuery, isFilter bool) (view.ReqQuery, error) {
 	if res.Database != "" {
 		res.DatabaseTable = fmt.Sprintf("`%s`.`%s`", res.Database, res.Table)
 	}
@@ -549,6 +554,14 @@ func (c *ClickHouse) AlertViewGen(alarm *db.Alarm, whereCondition string) (strin
 		CommonFields: TagsToString(alarm, true),
 		SourceTable:  sourceTableName,
 		Where:        whereCondition}
+	if len(alarm.GroupBy) > 0 {
+		fields := make([]string, 0, len(alarm.GroupBy))
+		for _, field := range alarm.GroupBy {
+			vp.CommonFields += fmt.Sprintf(",concat('%s=', toString(`%s`))", db.GroupLabel(field), field)
+			fields = append(fields, fmt.Sprintf("`%s`", field))
+		}
+		vp.GroupBy = strings.Join(fields, ", ")
+	}
 
 	if alarm.Mode == db.AlarmModeWithInSQL || alarm.Mode == db.AlarmModeAggregation {
 		vp.ViewType = bumo.ViewTypePrometheusMetricAggregation
@@ -714,6 +727,36 @@ func (c *ClickHouse) GET(param view.ReqQuery, tid int) (res view.RespQuery, err
 	return
 }
 
+// Insert writes rows into the data table in one batch.
+// In cluster mode the distributed table shares the name of the logical table, so no suffix is needed.
+func (c *ClickHouse) Insert(database, table string, columns []string, rows [][]interface{}) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(columns))
+	for _, col := range columns {
+		fields = append(fields, fmt.Sprintf("`%s`", col))
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s)", genName(database, table), strings.Join(fields, ",")))
+	if err != nil {
+		_ = tx.Rollback()
+		return
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, row := range rows {
+		if _, err = stmt.Exec(row...); err != nil {
+			invoker.Logger.Error("Insert", elog.String("database", database), elog.String("table", table), elog.String("error", err.Error()))
+			_ = tx.Rollback()
+			return
+		}
+	}
+	return tx.Commit()
+}
+
 func (c *ClickHouse) TimeFieldEqual(param view.ReqQuery, tid int) string {
 	var res string
 	s := c.logsTimelineSQL(param, tid)
@@ -1056,6 +1099,122 @@ FROM  %s GROUP BY %s ORDER BY %s DESC LIMIT 10
 	return out
 }
 
+// AlertValueSQL returns the query of the current value of an aggregation alarm, the same value as in its metrics view
+func AlertValueSQL(withSQL string) string {
+	return fmt.Sprintf("with(\n%s\n) as limbo\nSELECT toFloat64(limbo.1) as val", adaSelectPart(withSQL))
+}
+
+// alertSelectorFields aggregates the samples c of the timestamps under every aggregation selector of the alarm conditions
+const alertSelectorFields = "argMax(c, t) AS val_last, avg(c) AS val_avg, min(c) AS val_min, max(c) AS val_max, sum(c) AS val_sum, toFloat64(count()) AS val_count"
+
+// AlertBacktestSQL aggregates the samples of every interval of [param.ST, param.ET),
+// the samples are the logs matching the alarm filters counted by timestamp, the same as the materialized view of ViewTypePrometheusMetric.
+func AlertBacktestSQL(param view.ReqQuery, where string, interval int64) string {
+	return fmt.Sprintf("SELECT intDiv(t, %d) * %d AS ts, %s FROM (SELECT %s AS t, toFloat64(count(*)) AS c FROM %s WHERE (%s) AND %s GROUP BY %s) GROUP BY ts ORDER BY ts",
+		interval, interval,
+		alertSelectorFields,
+		genTimeUnix(param),
+		genName(param.Database, param.Table),
+		where,
+		fmt.Sprintf(genTimeCondition(param), param.ST, param.ET),
+		param.TimeField)
+}
+
+// AlertSeriesSQL aggregates the samples in [param.ST, param.ET) of every group,
+// the columns g0, g1, ... are the values of the group-by fields, a single row without them when groupBy is empty.
+func AlertSeriesSQL(param view.ReqQuery, where string, groupBy []string) string {
+	fields := make([]string, 0, len(groupBy))
+	names := make([]string, 0, len(groupBy))
+	for i, field := range groupBy {
+		fields = append(fields, fmt.Sprintf("toString(`%s`) AS g%d, ", field, i))
+		names = append(names, fmt.Sprintf("g%d", i))
+	}
+	var groups, seriesBy string
+	if len(names) > 0 {
+		groups = strings.Join(names, ", ") + ", "
+		seriesBy = " GROUP BY " + strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("SELECT %s%s FROM (SELECT %s%s AS t, toFloat64(count(*)) AS c FROM %s WHERE (%s) AND %s GROUP BY %s%s)%s",
+		groups,
+		alertSelectorFields,
+		strings.Join(fields, ""),
+		param.TimeField,
+		genName(param.Database, param.Table),
+		where,
+		fmt.Sprintf(genTimeCondition(param), param.ST, param.ET),
+		groups,
+		param.TimeField,
+		seriesBy)
+}
+
+// LogQLMatrixSQL counts the logs matching where in [param.ST, param.ET) by buckets of the seconds from param.ST,
+// the column k is the index of the bucket, f the value of the field when it is not empty, c the count; the empty buckets have no rows.
+func LogQLMatrixSQL(param view.ReqQuery, where, field string, bucket int64) string {
+	var fieldPart, groupPart string
+	if field != "" {
+		fieldPart = fmt.Sprintf(", toString(%s) AS f", field)
+		groupPart = ", f"
+	}
+	return fmt.Sprintf("SELECT intDiv(toInt64(%s) - %d, %d) AS k%s, count(*) AS c FROM %s WHERE (%s) AND %s GROUP BY k%s ORDER BY k",
+		genTimeUnix(param), param.ST, bucket,
+		fieldPart,
+		genName(param.Database, param.Table),
+		where,
+		fmt.Sprintf(genTimeCondition(param), param.ST, param.ET),
+		groupPart)
+}
+
+// TableFreshnessSQL returns the unix seconds of the latest log in [param.ST, param.ET) as the column latest, 0 when there is none
+func TableFreshnessSQL(param view.ReqQuery) string {
+	return fmt.Sprintf("SELECT toInt64(max(%s)) AS latest FROM %s WHERE %s",
+		genTimeUnix(param),
+		genName(param.Database, param.Table),
+		fmt.Sprintf(genTimeCondition(param), param.ST, param.ET))
+}
+
+// AlertBaselineSQL counts the logs matching the alarm filters in the interval before param.ET and in the same window of the previous periods,
+// the column k is 0 for the current window and n for the window n periods ago, the windows without logs have no rows.
+func AlertBaselineSQL(param view.ReqQuery, where string, interval, period int64, periods int) string {
+	param.ST = param.ET - int64(periods)*period - interval
+	ago := fmt.Sprintf("(%d - toInt64(%s))", param.ET-1, genTimeUnix(param))
+	return fmt.Sprintf("SELECT intDiv(%s, %d) AS k, toFloat64(count(*)) AS c FROM %s WHERE (%s) AND %s AND modulo(%s, %d) < %d GROUP BY k ORDER BY k",
+		ago, period,
+		genName(param.Database, param.Table),
+		where,
+		fmt.Sprintf(genTimeCondition(param), param.ST, param.ET),
+		ago, period, interval)
+}
+
+var nowFunc = regexp.MustCompile(`(?i)\bnow\(\s*\)`)
+
+// AlertBacktestValueSQL evaluates the sql of the aggregation modes over the logs in [param.ST, param.ET),
+// which are the rows the materialized view of ViewTypePrometheusMetricAggregation reads from an inserted block.
+func AlertBacktestValueSQL(param view.ReqQuery, withSQL string) (string, error) {
+	rows := fmt.Sprintf("(SELECT * FROM %s WHERE %s)", genName(param.Database, param.Table), fmt.Sprintf(genTimeCondition(param), param.ST, param.ET))
+	out := adaSelectPart(withSQL)
+	replaced := strings.ReplaceAll(out, genName(param.Database, param.Table), rows)
+	if replaced == out {
+		replaced = regexp.MustCompile(`\b`+regexp.QuoteMeta(param.Database+"."+param.Table)+`\b`).ReplaceAllLiteralString(out, rows)
+	}
+	if replaced == out {
+		return "", fmt.Errorf("the sql should read from %s.%s", param.Database, param.Table)
+	}
+	// the view runs when the logs are inserted
+	replaced = nowFunc.ReplaceAllLiteralString(replaced, fmt.Sprintf("toDateTime(%d)", param.ET))
+	return fmt.Sprintf("with(\n%s\n) as limbo\nSELECT toFloat64(limbo.1) as val", replaced), nil
+}
+
+// genTimeUnix returns the unix seconds of the time field
+func genTimeUnix(param view.ReqQuery) string {
+	switch param.TimeFieldType {
+	case db.TimeFieldTypeDT, db.TimeFieldTypeDT3:
+		return fmt.Sprintf("toUnixTimestamp(%s)", param.TimeField)
+	case db.TimeFieldTypeTsMs:
+		return fmt.Sprintf("intDiv(%s,1000)", param.TimeField)
+	}
+	return param.TimeField
+}
+
 func adaSelectPart(in string) (out string) {
 	arr := strings.Split(strings.Replace(in, "from", "FROM", 1), "FROM ")
 	if len(arr) <= 1 {
@@ -1132,12 +1291,13 @@ func (c *ClickHouse) countSQL(param view.ReqQuery) (sql string) {
 }
 
 func (c *ClickHouse) groupBySQL(param view.ReqQuery) (sql string) {
-	sql = fmt.Sprintf("SELECT count(*) as count, %s as f FROM %s WHERE "+genTimeCondition(param)+" %s group by %s  order by count desc limit 10",
+	sql = fmt.Sprintf("SELECT count(*) as count, %s as f FROM %s WHERE "+genTimeCondition(param)+" %s group by %s  order by count desc limit %d",
 		param.Field,
 		param.DatabaseTable,
 		param.ST, param.ET,
 		c.queryHashTransform(param),
-		param.Field)
+		param.Field,
+		groupByLimit(param))
 	invoker.Logger.Debug("ClickHouse", elog.Any("step", "groupBySQL"), elog.Any("sql", sql))
 	return
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
 )
 
 func Test_hashTransform(t *testing.T) {
@@ -93,3 +94,109 @@ limit 1`,
 		})
 	}
 }
+
+func TestAlertBacktestSQL(t *testing.T) {
+	param := view.ReqQuery{Database: "logs", Table: "app", TimeField: "_time_second_", ST: 1654300800, ET: 1654304400}
+	want := "SELECT intDiv(t, 60) * 60 AS ts, " + alertSelectorFields + " FROM (SELECT toUnixTimestamp(_time_second_) AS t, toFloat64(count(*)) AS c FROM `logs`.`app` " +
+		"WHERE (level='error') AND _time_second_ >= toDateTime(1654300800) AND _time_second_ < toDateTime(1654304400) GROUP BY _time_second_) GROUP BY ts ORDER BY ts"
+	if got := AlertBacktestSQL(param, "level='error'", 60); got != want {
+		t.Errorf("AlertBacktestSQL() = %v, want %v", got, want)
+	}
+}
+
+func TestLogQLMatrixSQL(t *testing.T) {
+	param := view.ReqQuery{Database: "logs", Table: "app", TimeField: "_time_second_", ST: 1654300800, ET: 1654304400}
+	want := "SELECT intDiv(toInt64(toUnixTimestamp(_time_second_)) - 1654300800, 60) AS k, count(*) AS c FROM `logs`.`app` " +
+		"WHERE (level='error') AND _time_second_ >= toDateTime(1654300800) AND _time_second_ < toDateTime(1654304400) GROUP BY k ORDER BY k"
+	if got := LogQLMatrixSQL(param, "level='error'", "", 60); got != want {
+		t.Errorf("LogQLMatrixSQL() = %v, want %v", got, want)
+	}
+	want = "SELECT intDiv(toInt64(toUnixTimestamp(_time_second_)) - 1654300800, 60) AS k, toString(`service`) AS f, count(*) AS c FROM `logs`.`app` " +
+		"WHERE (level='error') AND _time_second_ >= toDateTime(1654300800) AND _time_second_ < toDateTime(1654304400) GROUP BY k, f ORDER BY k"
+	if got := LogQLMatrixSQL(param, "level='error'", "`service`", 60); got != want {
+		t.Errorf("LogQLMatrixSQL() = %v, want %v", got, want)
+	}
+}
+
+func TestAlertBacktestValueSQL(t *testing.T) {
+	param := view.ReqQuery{Database: "logs", Table: "app", TimeField: "ts", TimeFieldType: db.TimeFieldTypeTs, ST: 1654300800, ET: 1654300860}
+	tests := []struct {
+		name    string
+		withSQL string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "table",
+			withSQL: "SELECT count(1) FROM logs.app WHERE ts > toUnixTimestamp(now()) - 60",
+			want: "with(\nSELECT count(1) ,count(1) FROM (SELECT * FROM `logs`.`app` WHERE ts >= 1654300800 AND ts < 1654300860) " +
+				"WHERE ts > toUnixTimestamp(toDateTime(1654300860)) - 60\n) as limbo\nSELECT toFloat64(limbo.1) as val",
+		},
+		{
+			name:    "quoted table",
+			withSQL: "SELECT count(1), sum(cost) FROM `logs`.`app`",
+			want:    "with(\nSELECT count(1), sum(cost) FROM (SELECT * FROM `logs`.`app` WHERE ts >= 1654300800 AND ts < 1654300860)\n) as limbo\nSELECT toFloat64(limbo.1) as val",
+		},
+		{
+			name:    "other table",
+			withSQL: "SELECT count(1) FROM logs.app_v2",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AlertBacktestValueSQL(param, tt.withSQL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AlertBacktestValueSQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AlertBacktestValueSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertSeriesSQL(t *testing.T) {
+	param := view.ReqQuery{Database: "logs", Table: "app", TimeField: "_time_second_", ST: 1654300800, ET: 1654300860}
+	where := "WHERE (level='error') AND _time_second_ >= toDateTime(1654300800) AND _time_second_ < toDateTime(1654300860)"
+	tests := []struct {
+		name    string
+		groupBy []string
+		want    string
+	}{
+		{
+			name: "series",
+			want: "SELECT " + alertSelectorFields + " FROM (SELECT _time_second_ AS t, toFloat64(count(*)) AS c FROM `logs`.`app` " + where + " GROUP BY _time_second_)",
+		},
+		{
+			name:    "group by",
+			groupBy: []string{"service", "_raw_log_.env"},
+			want: "SELECT g0, g1, " + alertSelectorFields + " FROM (SELECT toString(`service`) AS g0, toString(`_raw_log_.env`) AS g1, _time_second_ AS t, toFloat64(count(*)) AS c FROM `logs`.`app` " +
+				where + " GROUP BY g0, g1, _time_second_) GROUP BY g0, g1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AlertSeriesSQL(param, "level='error'", tt.groupBy); got != tt.want {
+				t.Errorf("AlertSeriesSQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertBaselineSQL(t *testing.T) {
+	param := view.ReqQuery{Database: "logs", Table: "app", TimeField: "ts", TimeFieldType: db.TimeFieldTypeTs, ET: 1654300860}
+	want := "SELECT intDiv((1654300859 - toInt64(ts)), 86400) AS k, toFloat64(count(*)) AS c FROM `logs`.`app` " +
+		"WHERE (level='error') AND ts >= 1654041600 AND ts < 1654300860 AND modulo((1654300859 - toInt64(ts)), 86400) < 60 GROUP BY k ORDER BY k"
+	if got := AlertBaselineSQL(param, "level='error'", 60, 86400, 3); got != want {
+		t.Errorf("AlertBaselineSQL() = %v, want %v", got, want)
+	}
+}
+
+func TestTableFreshnessSQL(t *testing.T) {
+	param := view.ReqQuery{Database: "logs", Table: "app", TimeField: "_time_second_", TimeFieldType: db.TimeFieldTypeDT, ST: 1654300200, ET: 1654300860}
+	want := "SELECT toInt64(max(toUnixTimestamp(_time_second_))) AS latest FROM `logs`.`app` WHERE _time_second_ >= toDateTime(1654300200) AND _time_second_ < toDateTime(1654300860)"
+	if got := TableFreshnessSQL(param); got != want {
+		t.Errorf("TableFreshnessSQL() = %v, want %v", got, want)
+	}
+}
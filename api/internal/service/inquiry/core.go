@@ -12,6 +12,7 @@ import (
 
 type Operator interface {
 	Count(view.ReqQuery) (uint64, error)
+	Insert(string, string, []string, [][]interface{}) error // Batch write rows into the data table
 	DropDatabase(string, string) error
 	AlertViewDrop(string, string) error
 	DatabaseCreate(string, string) error
@@ -42,6 +43,14 @@ const (
 	TimeTypeFloat  = 2
 )
 
+// groupByLimit is the number of the values returned by GroupBy, the top 10 by default
+func groupByLimit(param view.ReqQuery) uint32 {
+	if param.GroupByLimit == 0 {
+		return 10
+	}
+	return param.GroupByLimit
+}
+
 func genName(database, tableName string) string {
 	return fmt.Sprintf("`%s`.`%s`", database, tableName)
 }
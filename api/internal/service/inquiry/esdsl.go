@@ -0,0 +1,369 @@
+package inquiry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ESQuery is the Elasticsearch query DSL translated into the query condition of view.ReqQuery.
+// Supported clauses: match_all, bool (must, filter, should, must_not), term, terms, range and match_phrase.
+type ESQuery struct {
+	Where string
+	ST    int64 // start of the range on the time field, zero when absent
+	ET    int64 // end of the range on the time field, zero when absent
+}
+
+// ParseESQuery translates the query clause, fields maps field names onto columns.
+// Ranges on the time field in the top level must or filter clauses are returned as ST and ET.
+func ParseESQuery(query map[string]interface{}, fields map[string]string, timeField string, now time.Time) (*ESQuery, error) {
+	res := &ESQuery{}
+	p := &esParser{fields: fields}
+	if len(query) == 0 {
+		return res, nil
+	}
+	clauses := []map[string]interface{}{query}
+	if b, ok := query["bool"].(map[string]interface{}); ok && len(query) == 1 {
+		// lift the top level time range out of the bool query
+		clauses = nil
+		rest := make(map[string]interface{}, len(b))
+		for k, v := range b {
+			rest[k] = v
+		}
+		for _, key := range []string{"must", "filter"} {
+			list, err := esClauses(b[key])
+			if err != nil {
+				return nil, err
+			}
+			kept := make([]interface{}, 0, len(list))
+			for _, clause := range list {
+				ok, err := res.timeRange(clause, timeField, now)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					kept = append(kept, clause)
+				}
+			}
+			if len(kept) == 0 {
+				delete(rest, key)
+			} else {
+				rest[key] = kept
+			}
+		}
+		if len(rest) > 0 {
+			clauses = append(clauses, map[string]interface{}{"bool": rest})
+		}
+	} else {
+		ok, err := res.timeRange(query, timeField, now)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			clauses = nil
+		}
+	}
+	conds := make([]string, 0, len(clauses))
+	for _, clause := range clauses {
+		cond, err := p.clause(clause)
+		if err != nil {
+			return nil, err
+		}
+		if cond != "" {
+			conds = append(conds, cond)
+		}
+	}
+	res.Where = strings.Join(conds, " AND ")
+	return res, nil
+}
+
+// timeRange consumes a range clause on the time field
+func (q *ESQuery) timeRange(clause map[string]interface{}, timeField string, now time.Time) (bool, error) {
+	r, ok := clause["range"].(map[string]interface{})
+	if !ok || len(clause) != 1 || len(r) != 1 {
+		return false, nil
+	}
+	opts, ok := r[timeField].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	format, _ := opts["format"].(string)
+	for op, v := range opts {
+		if op == "format" || op == "time_zone" {
+			continue
+		}
+		t, err := esTime(v, format, now)
+		if err != nil {
+			return false, err
+		}
+		switch op {
+		case "gt", "gte", "from":
+			q.ST = t.Unix()
+		case "lt":
+			q.ET = t.Unix()
+		case "lte", "to":
+			q.ET = t.Unix() + 1
+		default:
+			return false, errors.Errorf("unsupported range option %q", op)
+		}
+	}
+	return true, nil
+}
+
+type esParser struct {
+	fields map[string]string
+}
+
+func (p *esParser) column(field string) (string, error) {
+	col, ok := p.fields[field]
+	if !ok {
+		return "", errors.Errorf("unknown field: %s", field)
+	}
+	return col, nil
+}
+
+func (p *esParser) clause(clause map[string]interface{}) (string, error) {
+	if len(clause) != 1 {
+		return "", errors.New("a query clause must contain exactly one query type")
+	}
+	for typ, body := range clause {
+		switch typ {
+		case "match_all":
+			return "", nil
+		case "bool":
+			b, ok := body.(map[string]interface{})
+			if !ok {
+				return "", errors.New("[bool] malformed query")
+			}
+			return p.boolQuery(b)
+		case "term", "terms", "range", "match_phrase":
+			b, ok := body.(map[string]interface{})
+			if !ok || len(b) != 1 {
+				return "", errors.Errorf("[%s] query must contain exactly one field", typ)
+			}
+			for field, v := range b {
+				col, err := p.column(field)
+				if err != nil {
+					return "", err
+				}
+				return p.fieldQuery(typ, col, v)
+			}
+		default:
+			return "", errors.Errorf("unsupported query type [%s]", typ)
+		}
+	}
+	return "", nil
+}
+
+func (p *esParser) boolQuery(b map[string]interface{}) (string, error) {
+	conds := make([]string, 0)
+	joined := func(key, sep string) (string, error) {
+		list, err := esClauses(b[key])
+		if err != nil {
+			return "", err
+		}
+		parts := make([]string, 0, len(list))
+		for _, clause := range list {
+			cond, errClause := p.clause(clause)
+			if errClause != nil {
+				return "", errClause
+			}
+			if cond == "" {
+				cond = "1=1"
+			}
+			parts = append(parts, "("+cond+")")
+		}
+		return strings.Join(parts, sep), nil
+	}
+	for _, key := range []string{"must", "filter"} {
+		cond, err := joined(key, " AND ")
+		if err != nil {
+			return "", err
+		}
+		if cond != "" {
+			conds = append(conds, cond)
+		}
+	}
+	// should only affects scoring when must or filter is present
+	if len(conds) == 0 {
+		cond, err := joined("should", " OR ")
+		if err != nil {
+			return "", err
+		}
+		if cond != "" {
+			conds = append(conds, "("+cond+")")
+		}
+	}
+	cond, err := joined("must_not", " OR ")
+	if err != nil {
+		return "", err
+	}
+	if cond != "" {
+		conds = append(conds, "NOT ("+cond+")")
+	}
+	for key := range b {
+		switch key {
+		case "must", "filter", "should", "must_not", "minimum_should_match", "boost":
+		default:
+			return "", errors.Errorf("[bool] query does not support [%s]", key)
+		}
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+func (p *esParser) fieldQuery(typ, col string, v interface{}) (string, error) {
+	switch typ {
+	case "term":
+		if opts, ok := v.(map[string]interface{}); ok {
+			v = opts["value"]
+		}
+		val, err := esValue(v)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s=%s", col, val), nil
+	case "terms":
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return "", errors.New("[terms] query requires an array of values")
+		}
+		vals := make([]string, 0, len(list))
+		for _, item := range list {
+			val, err := esValue(item)
+			if err != nil {
+				return "", err
+			}
+			vals = append(vals, val)
+		}
+		return fmt.Sprintf("%s IN (%s)", col, strings.Join(vals, ", ")), nil
+	case "match_phrase":
+		if opts, ok := v.(map[string]interface{}); ok {
+			v = opts["query"]
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", errors.New("[match_phrase] query requires a string")
+		}
+		return fmt.Sprintf("%s LIKE %s", col, sqlQuote("%"+sqlLikeEscape(s)+"%")), nil
+	case "range":
+		opts, ok := v.(map[string]interface{})
+		if !ok {
+			return "", errors.New("[range] malformed query")
+		}
+		ops := make([]string, 0, len(opts))
+		for op := range opts {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		conds := make([]string, 0, len(opts))
+		for _, op := range ops {
+			var sign string
+			switch op {
+			case "gt":
+				sign = ">"
+			case "gte", "from":
+				sign = ">="
+			case "lt":
+				sign = "<"
+			case "lte", "to":
+				sign = "<="
+			case "format", "time_zone", "boost":
+				continue
+			default:
+				return "", errors.Errorf("unsupported range option %q", op)
+			}
+			val, err := esValue(opts[op])
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, fmt.Sprintf("%s%s%s", col, sign, val))
+		}
+		return strings.Join(conds, " AND "), nil
+	}
+	return "", errors.Errorf("unsupported query type [%s]", typ)
+}
+
+func esClauses(v interface{}) ([]map[string]interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return []map[string]interface{}{val}, nil
+	case []interface{}:
+		res := make([]map[string]interface{}, 0, len(val))
+		for _, item := range val {
+			clause, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("malformed query clause")
+			}
+			res = append(res, clause)
+		}
+		return res, nil
+	case []map[string]interface{}:
+		return val, nil
+	}
+	return nil, errors.New("malformed query clause")
+}
+
+func esValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return sqlQuote(val), nil
+	case json.Number:
+		return val.String(), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	}
+	return "", errors.Errorf("unsupported value %v", v)
+}
+
+// esTime parses date math like now-15m, epoch milliseconds and RFC3339 dates
+func esTime(v interface{}, format string, now time.Time) (time.Time, error) {
+	var s string
+	switch val := v.(type) {
+	case json.Number:
+		s = val.String()
+	case float64:
+		s = strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		s = val
+	default:
+		return time.Time{}, errors.Errorf("unsupported date %v", v)
+	}
+	if strings.HasPrefix(s, "now") {
+		s = strings.TrimPrefix(s, "now")
+		if i := strings.IndexByte(s, '/'); i >= 0 {
+			s = s[:i] // rounding is ignored
+		}
+		if s == "" {
+			return now, nil
+		}
+		d, err := ParseLogQLDuration(strings.TrimLeft(s, "+-"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		if s[0] == '-' {
+			return now.Add(-d), nil
+		}
+		return now.Add(d), nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		if format == "epoch_second" {
+			return time.Unix(int64(n), 0), nil
+		}
+		return time.UnixMilli(int64(n)), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("failed to parse date %q", s)
+}
@@ -0,0 +1,80 @@
+package inquiry
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseESQuery(t *testing.T) {
+	now := time.Unix(1662000000, 0)
+	fields := map[string]string{
+		"_time_second_": "_time_second_",
+		"_raw_log_":     "_raw_log_",
+		"app":           "app",
+		"code":          "code",
+		"req.uri":       "`req.uri`",
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    ESQuery
+		wantErr bool
+	}{
+		{
+			name:  "test-1",
+			query: `{"match_all":{}}`,
+			want:  ESQuery{},
+		},
+		{
+			name: "test-2",
+			query: `{"bool":{
+				"filter":[{"range":{"_time_second_":{"gte":"now-15m","lte":1662000000000,"format":"epoch_millis"}}},{"term":{"app":"svc"}}],
+				"must_not":{"terms":{"code":[500,502]}}
+			}}`,
+			want: ESQuery{
+				Where: "(app='svc') AND NOT ((code IN (500, 502)))",
+				ST:    1661999100,
+				ET:    1662000001,
+			},
+		},
+		{
+			name:  "test-3",
+			query: `{"bool":{"should":[{"match_phrase":{"_raw_log_":"time out"}},{"range":{"code":{"gte":500,"lt":600}}}]}}`,
+			want:  ESQuery{Where: "((_raw_log_ LIKE '%time out%') OR (code>=500 AND code<600))"},
+		},
+		{
+			name:  "test-4",
+			query: `{"term":{"req.uri":{"value":"/ping"}}}`,
+			want:  ESQuery{Where: "`req.uri`='/ping'"},
+		},
+		{
+			name:    "test-5",
+			query:   `{"term":{"pod":"x"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "test-6",
+			query:   `{"query_string":{"query":"x"}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := make(map[string]interface{})
+			decoder := json.NewDecoder(strings.NewReader(tt.query))
+			decoder.UseNumber()
+			if err := decoder.Decode(&query); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseESQuery(query, fields, "_time_second_", now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseESQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("ParseESQuery() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
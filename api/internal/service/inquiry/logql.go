@@ -0,0 +1,377 @@
+package inquiry
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+const (
+	LogQLCountOverTime = "count_over_time"
+	LogQLRate          = "rate"
+)
+
+var logQLLabelReplacer = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// LogQL is the parsed form of the LogQL subset supported by the loki api:
+// stream selectors, line filters, count_over_time and rate, optionally wrapped in sum by.
+type LogQL struct {
+	Matchers []LogQLMatcher
+	Filters  []LogQLFilter
+	Func     string        // empty for log queries
+	Range    time.Duration // range of the metric function
+	Sum      bool
+	By       []string
+}
+
+type LogQLMatcher struct {
+	Name  string
+	Op    string // = != =~ !~
+	Value string
+}
+
+type LogQLFilter struct {
+	Op    string // |= != |~ !~
+	Value string
+}
+
+// IsMetric reports whether the query returns a matrix instead of log streams
+func (l *LogQL) IsMetric() bool {
+	return l.Func != ""
+}
+
+// LogQLLabel returns the loki label name of the analysis field
+func LogQLLabel(idx *db.BaseIndex) string {
+	return logQLLabelReplacer.ReplaceAllString(idx.GetFieldName(), "_")
+}
+
+// IndexColumn returns the column of the analysis field used in query conditions
+func IndexColumn(idx *db.BaseIndex) string {
+	if idx.RootName == "" {
+		return idx.Field
+	}
+	return fmt.Sprintf("`%s`", idx.GetFieldName())
+}
+
+// Where translates the stream selector and line filters into the query condition of view.ReqQuery,
+// labels maps loki label names onto analysis fields.
+func (l *LogQL) Where(labels map[string]*db.BaseIndex, rawLogField string) (string, error) {
+	if rawLogField == "" {
+		rawLogField = "_raw_log_"
+	}
+	conds := make([]string, 0, len(l.Matchers)+len(l.Filters))
+	for _, m := range l.Matchers {
+		idx, ok := labels[m.Name]
+		if !ok {
+			return "", errors.Errorf("unknown label: %s", m.Name)
+		}
+		col := IndexColumn(idx)
+		switch m.Op {
+		case "=":
+			conds = append(conds, fmt.Sprintf("%s=%s", col, sqlQuote(m.Value)))
+		case "!=":
+			conds = append(conds, fmt.Sprintf("%s!=%s", col, sqlQuote(m.Value)))
+		case "=~":
+			conds = append(conds, fmt.Sprintf("match(%s, %s)", col, sqlQuote("^(?:"+m.Value+")$")))
+		case "!~":
+			conds = append(conds, fmt.Sprintf("NOT match(%s, %s)", col, sqlQuote("^(?:"+m.Value+")$")))
+		}
+	}
+	for _, f := range l.Filters {
+		switch f.Op {
+		case "|=":
+			conds = append(conds, fmt.Sprintf("%s LIKE %s", rawLogField, sqlQuote("%"+sqlLikeEscape(f.Value)+"%")))
+		case "!=":
+			conds = append(conds, fmt.Sprintf("%s NOT LIKE %s", rawLogField, sqlQuote("%"+sqlLikeEscape(f.Value)+"%")))
+		case "|~":
+			conds = append(conds, fmt.Sprintf("match(%s, %s)", rawLogField, sqlQuote(f.Value)))
+		case "!~":
+			conds = append(conds, fmt.Sprintf("NOT match(%s, %s)", rawLogField, sqlQuote(f.Value)))
+		}
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+func sqlQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+func sqlLikeEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// ParseLogQL parses the LogQL subset
+func ParseLogQL(query string) (*LogQL, error) {
+	p := &logQLParser{in: query}
+	res := &LogQL{}
+	if err := p.parseExpr(res); err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.in) {
+		return nil, p.errorf("unexpected %q", p.in[p.pos:])
+	}
+	if len(res.Matchers) == 0 {
+		return nil, errors.New("queries require at least one stream matcher")
+	}
+	return res, nil
+}
+
+type logQLParser struct {
+	in  string
+	pos int
+}
+
+func (p *logQLParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("parse error at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *logQLParser) skipSpaces() {
+	for p.pos < len(p.in) && unicode.IsSpace(rune(p.in[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume skips the token if the input starts with it
+func (p *logQLParser) consume(token string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.in[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *logQLParser) expect(token string) error {
+	if !p.consume(token) {
+		return p.errorf("expected %q", token)
+	}
+	return nil
+}
+
+func (p *logQLParser) ident() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.in) {
+		ch := rune(p.in[p.pos])
+		if ch != '_' && !unicode.IsLetter(ch) && !(p.pos > start && unicode.IsDigit(ch)) {
+			break
+		}
+		p.pos++
+	}
+	return p.in[start:p.pos]
+}
+
+func (p *logQLParser) str() (string, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.in) {
+		return "", p.errorf("expected string")
+	}
+	quote := p.in[p.pos]
+	if quote != '"' && quote != '`' {
+		return "", p.errorf("expected string")
+	}
+	for end := p.pos + 1; end < len(p.in); end++ {
+		if quote == '"' && p.in[end] == '\\' {
+			end++
+			continue
+		}
+		if p.in[end] != quote {
+			continue
+		}
+		raw := p.in[p.pos : end+1]
+		p.pos = end + 1
+		if quote == '`' {
+			return raw[1 : len(raw)-1], nil
+		}
+		res, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", p.errorf("invalid string %s", raw)
+		}
+		return res, nil
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *logQLParser) parseExpr(res *LogQL) error {
+	p.skipSpaces()
+	if strings.HasPrefix(p.in[p.pos:], "{") {
+		return p.parseLogSelector(res)
+	}
+	start := p.pos
+	name := p.ident()
+	switch name {
+	case "sum":
+		res.Sum = true
+		if err := p.parseBy(res); err != nil {
+			return err
+		}
+		if err := p.expect("("); err != nil {
+			return err
+		}
+		fn := p.ident()
+		if err := p.parseRangeAggregation(fn, res); err != nil {
+			return err
+		}
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+		if len(res.By) == 0 {
+			return p.parseBy(res)
+		}
+		return nil
+	case LogQLCountOverTime, LogQLRate:
+		return p.parseRangeAggregation(name, res)
+	}
+	p.pos = start
+	return p.errorf("unsupported expression, expected a stream selector, %s, %s or sum", LogQLCountOverTime, LogQLRate)
+}
+
+func (p *logQLParser) parseBy(res *LogQL) error {
+	start := p.pos
+	if p.ident() != "by" {
+		p.pos = start
+		return nil
+	}
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		name := p.ident()
+		if name == "" {
+			return p.errorf("expected label name")
+		}
+		res.By = append(res.By, name)
+		if p.consume(",") {
+			continue
+		}
+		return p.expect(")")
+	}
+}
+
+func (p *logQLParser) parseRangeAggregation(fn string, res *LogQL) error {
+	if fn != LogQLCountOverTime && fn != LogQLRate {
+		return p.errorf("unsupported function %q", fn)
+	}
+	res.Func = fn
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	if err := p.parseLogSelector(res); err != nil {
+		return err
+	}
+	if err := p.expect("["); err != nil {
+		return err
+	}
+	p.skipSpaces()
+	end := strings.IndexByte(p.in[p.pos:], ']')
+	if end < 0 {
+		return p.errorf("expected \"]\"")
+	}
+	d, err := ParseLogQLDuration(strings.TrimSpace(p.in[p.pos : p.pos+end]))
+	if err != nil {
+		return p.errorf(err.Error())
+	}
+	res.Range = d
+	p.pos += end + 1
+	return p.expect(")")
+}
+
+func (p *logQLParser) parseLogSelector(res *LogQL) error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for !p.consume("}") {
+		name := p.ident()
+		if name == "" {
+			return p.errorf("expected label name")
+		}
+		var op string
+		for _, o := range []string{"=~", "!~", "!=", "="} {
+			if p.consume(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return p.errorf("expected label matcher operator")
+		}
+		val, err := p.str()
+		if err != nil {
+			return err
+		}
+		if op == "=~" || op == "!~" {
+			if _, err = regexp.Compile(val); err != nil {
+				return p.errorf("invalid regexp %q", val)
+			}
+		}
+		res.Matchers = append(res.Matchers, LogQLMatcher{Name: name, Op: op, Value: val})
+		if !p.consume(",") {
+			if err = p.expect("}"); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	for {
+		var op string
+		for _, o := range []string{"|=", "!=", "|~", "!~"} {
+			if p.consume(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil
+		}
+		val, err := p.str()
+		if err != nil {
+			return err
+		}
+		if op == "|~" || op == "!~" {
+			if _, err = regexp.Compile(val); err != nil {
+				return p.errorf("invalid regexp %q", val)
+			}
+		}
+		res.Filters = append(res.Filters, LogQLFilter{Op: op, Value: val})
+	}
+}
+
+// ParseLogQLDuration parses durations like 5m, 1h30m, 1d or 1w
+func ParseLogQLDuration(s string) (time.Duration, error) {
+	var res time.Duration
+	for _, unit := range []struct {
+		suffix string
+		d      time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if i := strings.Index(s, unit.suffix); i > 0 {
+			n, err := strconv.Atoi(s[:i])
+			if err != nil {
+				return 0, errors.Errorf("invalid duration %q", s)
+			}
+			res += time.Duration(n) * unit.d
+			s = s[i+1:]
+		}
+	}
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		res += d
+	}
+	if res <= 0 {
+		return 0, errors.New("duration must be greater than zero")
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+package inquiry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+func TestParseLogQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    *LogQL
+		wantErr bool
+	}{
+		{
+			name:  "test-1",
+			query: `{app="svc", env!~"dev|test"} |= "error" != ` + "`timeout`",
+			want: &LogQL{
+				Matchers: []LogQLMatcher{{Name: "app", Op: "=", Value: "svc"}, {Name: "env", Op: "!~", Value: "dev|test"}},
+				Filters:  []LogQLFilter{{Op: "|=", Value: "error"}, {Op: "!=", Value: "timeout"}},
+			},
+		},
+		{
+			name:  "test-2",
+			query: `sum by (app) (count_over_time({app=~"s.*"} |~ "5\\d\\d" [5m]))`,
+			want: &LogQL{
+				Matchers: []LogQLMatcher{{Name: "app", Op: "=~", Value: "s.*"}},
+				Filters:  []LogQLFilter{{Op: "|~", Value: `5\d\d`}},
+				Func:     LogQLCountOverTime,
+				Range:    5 * time.Minute,
+				Sum:      true,
+				By:       []string{"app"},
+			},
+		},
+		{
+			name:  "test-3",
+			query: `rate({app="svc"}[1d])`,
+			want: &LogQL{
+				Matchers: []LogQLMatcher{{Name: "app", Op: "=", Value: "svc"}},
+				Func:     LogQLRate,
+				Range:    24 * time.Hour,
+			},
+		},
+		{
+			name:    "test-4",
+			query:   `{}`,
+			wantErr: true,
+		},
+		{
+			name:    "test-5",
+			query:   `avg_over_time({app="svc"}[5m])`,
+			wantErr: true,
+		},
+		{
+			name:    "test-6",
+			query:   `{app="svc"} | json`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLogQL(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLogQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLogQL() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogQL_Where(t *testing.T) {
+	labels := map[string]*db.BaseIndex{
+		"app":     {Field: "app"},
+		"req_uri": {Field: "uri", RootName: "req"},
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "test-1",
+			query: `{app="svc",req_uri=~"/api.*"} |= "50%" !~ "it's"`,
+			want:  `app='svc' AND match(` + "`req.uri`" + `, '^(?:/api.*)$') AND _raw_log_ LIKE '%50\\%%' AND NOT match(_raw_log_, 'it\'s')`,
+		},
+		{
+			name:    "test-2",
+			query:   `{pod="x"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ParseLogQL(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := l.Where(labels, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Where() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Where() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package inquiry
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/constx"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// mysqlSystemDatabases are hidden from the self-built table list
+var mysqlSystemDatabases = []string{"information_schema", "mysql", "performance_schema", "sys"}
+
+// mysqlTimeFieldTypes are the column types that can be used as the time field
+var mysqlTimeFieldTypes = []string{"'datetime'", "'datetimev2'", "'timestamp'", "'int'", "'bigint'"}
+
+func genMySQLTimeCondition(param view.ReqQuery) string {
+	switch param.TimeFieldType {
+	case db.TimeFieldTypeDT, db.TimeFieldTypeDT3:
+		return fmt.Sprintf("%s >= FROM_UNIXTIME(%s) AND %s < FROM_UNIXTIME(%s)", param.TimeField, "%d", param.TimeField, "%d")
+	case db.TimeFieldTypeTsMs:
+		return fmt.Sprintf("%s >= %s*1000 AND %s < %s*1000", param.TimeField, "%d", param.TimeField, "%d")
+	}
+	return param.TimeField + " >= %d AND " + param.TimeField + " < %d"
+}
+
+// MySQL is a read only datasource, the tables are registered as self-built tables.
+// It also serves the OLAP databases speaking the mysql protocol, see NewStarRocks and NewDoris.
+type MySQL struct {
+	id              int
+	db              *sql.DB
+	systemDatabases []string
+}
+
+func NewMySQL(db *sql.DB, ins *db.BaseInstance) *MySQL {
+	if ins.ID == 0 {
+		panic("mysql add err, id is 0")
+	}
+	return &MySQL{
+		db:              db,
+		id:              ins.ID,
+		systemDatabases: mysqlSystemDatabases,
+	}
+}
+
+func (m *MySQL) ID() int {
+	return m.id
+}
+
+func (m *MySQL) Prepare(res view.ReqQuery, isFilter bool) (view.ReqQuery, error) {
+	return prepare(res, isFilter)
+}
+
+func (m *MySQL) GET(param view.ReqQuery, tid int) (res view.RespQuery, err error) {
+	// Initialization
+	res.Logs = make([]map[string]interface{}, 0)
+	res.Keys = make([]*db.BaseIndex, 0)
+	res.Terms = make([][]string, 0)
+	if param.AlarmMode != db.AlarmModeDefault {
+		return res, constx.ErrDatasourceNotSupported
+	}
+	q := m.logsSQL(param)
+	res.Logs, err = m.doQuery(q)
+	if err != nil {
+		return
+	}
+	res.Query = q
+	if param.TimeField != db.TimeFieldSecond {
+		for k := range res.Logs {
+			ts := res.Logs[k][param.TimeField]
+			if ms, ok := ts.(int64); ok && param.TimeFieldType == db.TimeFieldTypeTsMs {
+				res.Logs[k][db.TimeFieldSecond] = ms / 1000
+			} else {
+				res.Logs[k][db.TimeFieldSecond] = ts
+			}
+			res.Logs[k][db.TimeFieldNanoseconds] = ts
+		}
+	}
+	res.Limited = param.PageSize
+	// Read the index data
+	conds := egorm.Conds{}
+	conds["tid"] = tid
+	res.Keys, _ = db.IndexList(conds)
+	// keys sort by the first letter
+	sort.Slice(res.Keys, func(i, j int) bool {
+		return res.Keys[i].Field < res.Keys[j].Field
+	})
+	res.HiddenFields = econf.GetStringSlice("app.hiddenFields")
+	res.DefaultFields = econf.GetStringSlice("app.defaultFields")
+	for _, k := range res.Keys {
+		res.DefaultFields = append(res.DefaultFields, k.Field)
+	}
+	return
+}
+
+func (m *MySQL) Count(param view.ReqQuery) (res uint64, err error) {
+	q := m.countSQL(param)
+	sqlCountData, err := m.doQuery(q)
+	if err != nil {
+		invoker.Logger.Error("Count", elog.Any("sql", q), elog.Any("error", err.Error()))
+		return 0, err
+	}
+	if len(sqlCountData) > 0 {
+		if count, ok := sqlCountData[0]["count"].(int64); ok {
+			return uint64(count), nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *MySQL) GroupBy(param view.ReqQuery) (res map[string]uint64) {
+	res = make(map[string]uint64, 0)
+	sqlCountData, err := m.doQuery(m.groupBySQL(param))
+	if err != nil {
+		return
+	}
+	for _, v := range sqlCountData {
+		count, ok := v["count"].(int64)
+		if !ok {
+			continue
+		}
+		var key string
+		switch f := v["f"].(type) {
+		case string:
+			key = f
+		case int64:
+			key = strconv.FormatInt(f, 10)
+		case float64:
+			key = fmt.Sprintf("%f", f)
+		default:
+			invoker.Logger.Info("GroupBy", elog.Any("type", reflect.TypeOf(v["f"])))
+			continue
+		}
+		res[key] = uint64(count)
+	}
+	return
+}
+
+func (m *MySQL) Complete(sql string) (res view.RespComplete, err error) {
+	// Initialization
+	res.Logs = make([]map[string]interface{}, 0)
+	tmp, err := m.doQuery(sql)
+	if err != nil {
+		return
+	}
+	res.Logs = tmp
+	return
+}
+
+func (m *MySQL) Databases() ([]*view.RespDatabaseSelfBuilt, error) {
+	databases := make([]*view.RespDatabaseSelfBuilt, 0)
+	dm := make(map[string][]*view.RespTablesSelfBuilt)
+	systemDatabases := make([]string, 0, len(m.systemDatabases))
+	for _, d := range m.systemDatabases {
+		systemDatabases = append(systemDatabases, sqlQuote(d))
+	}
+	query := fmt.Sprintf("SELECT table_schema AS `database`, table_name AS name FROM information_schema.tables WHERE table_schema NOT IN (%s)",
+		strings.Join(systemDatabases, ","))
+	list, err := m.doQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range list {
+		d, _ := row["database"].(string)
+		t, _ := row["name"].(string)
+		dm[d] = append(dm[d], &view.RespTablesSelfBuilt{
+			Name: t,
+		})
+	}
+	for databaseName, tables := range dm {
+		databases = append(databases, &view.RespDatabaseSelfBuilt{
+			Name:   databaseName,
+			Tables: tables,
+		})
+	}
+	return databases, nil
+}
+
+func (m *MySQL) Columns(database, table string, isTimeField bool) (res []*view.RespColumn, err error) {
+	res = make([]*view.RespColumn, 0)
+	query := fmt.Sprintf("SELECT column_name AS name, data_type AS typ, column_type AS type FROM information_schema.columns WHERE table_schema = %s AND table_name = %s",
+		sqlQuote(database), sqlQuote(table))
+	if isTimeField {
+		query = fmt.Sprintf("%s AND data_type IN (%s)", query, strings.Join(mysqlTimeFieldTypes, ","))
+	}
+	list, err := m.doQuery(query + " ORDER BY ordinal_position")
+	if err != nil {
+		return
+	}
+	for _, row := range list {
+		name, _ := row["name"].(string)
+		typ, _ := row["typ"].(string)
+		typeDesc, _ := row["type"].(string)
+		res = append(res, &view.RespColumn{
+			Name:     name,
+			TypeDesc: typeDesc,
+			Type:     mysqlFieldTypeJudgment(typ),
+		})
+	}
+	return
+}
+
+// mysqlFieldTypeJudgment maps the data type of the column onto the analysis field type, -1 is not supported
+func mysqlFieldTypeJudgment(typ string) int {
+	switch strings.ToLower(typ) {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set", "json", "string":
+		return 0
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "largeint":
+		return 1
+	case "float", "double", "decimal":
+		return 2
+	}
+	return -1
+}
+
+func (m *MySQL) Insert(database, table string, columns []string, rows [][]interface{}) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(columns))
+	for _, col := range columns {
+		fields = append(fields, fmt.Sprintf("`%s`", col))
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", genName(database, table), strings.Join(fields, ","),
+		strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",")))
+	if err != nil {
+		_ = tx.Rollback()
+		return
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, row := range rows {
+		if _, err = stmt.Exec(row...); err != nil {
+			invoker.Logger.Error("Insert", elog.String("database", database), elog.String("table", table), elog.String("error", err.Error()))
+			_ = tx.Rollback()
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// IndexUpdate analysis fields of mysql tables are existing columns, there is nothing to change
+func (m *MySQL) IndexUpdate(db.BaseDatabase, db.BaseTable, map[string]*db.BaseIndex, map[string]*db.BaseIndex, map[string]*db.BaseIndex) error {
+	return nil
+}
+
+func (m *MySQL) SystemTablesInfo(bool) []*view.SystemTable {
+	return make([]*view.SystemTable, 0)
+}
+
+func (m *MySQL) DropDatabase(string, string) error {
+	return constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) AlertViewDrop(string, string) error {
+	return constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) DatabaseCreate(string, string) error {
+	return constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) TableDrop(string, string, string, int) error {
+	return constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) AlertViewCreate(string, string, string) error {
+	return constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) AlertViewGen(*db.Alarm, string) (string, string, error) {
+	return "", "", constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) ViewSync(db.BaseTable, *db.BaseView, []*db.BaseView, bool) (string, string, error) {
+	return "", "", constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) TableCreate(int, db.BaseDatabase, view.ReqTableCreate) (string, string, string, string, error) {
+	return "", "", "", "", constx.ErrDatasourceNotSupported
+}
+
+func (m *MySQL) StorageCreate(int, db.BaseDatabase, view.ReqStorageCreate) (string, string, string, string, error) {
+	return "", "", "", "", constx.ErrDatasourceNotSupported
+}
+
+func mysqlQueryCondition(param view.ReqQuery) string {
+	if param.Query == "" || param.Query == defaultCondition {
+		return ""
+	}
+	return fmt.Sprintf("AND %s", param.Query)
+}
+
+func (m *MySQL) logsSQL(param view.ReqQuery) (sql string) {
+	sql = fmt.Sprintf("SELECT * FROM %s WHERE "+genMySQLTimeCondition(param)+" %s ORDER BY %s DESC LIMIT %d OFFSET %d",
+		param.DatabaseTable,
+		param.ST, param.ET,
+		mysqlQueryCondition(param),
+		param.TimeField,
+		param.PageSize, (param.Page-1)*param.PageSize)
+	invoker.Logger.Debug("MySQL", elog.Any("step", "logsSQL"), elog.Any("sql", sql))
+	return
+}
+
+func (m *MySQL) countSQL(param view.ReqQuery) (sql string) {
+	sql = fmt.Sprintf("SELECT count(*) AS `count` FROM %s WHERE "+genMySQLTimeCondition(param)+" %s",
+		param.DatabaseTable,
+		param.ST, param.ET,
+		mysqlQueryCondition(param))
+	invoker.Logger.Debug("MySQL", elog.Any("step", "countSQL"), elog.Any("sql", sql))
+	return
+}
+
+func (m *MySQL) groupBySQL(param view.ReqQuery) (sql string) {
+	sql = fmt.Sprintf("SELECT count(*) AS `count`, %s AS f FROM %s WHERE "+genMySQLTimeCondition(param)+" %s GROUP BY %s ORDER BY `count` DESC LIMIT %d",
+		param.Field,
+		param.DatabaseTable,
+		param.ST, param.ET,
+		mysqlQueryCondition(param),
+		param.Field,
+		groupByLimit(param))
+	invoker.Logger.Debug("MySQL", elog.Any("step", "groupBySQL"), elog.Any("sql", sql))
+	return
+}
+
+// doQuery the text protocol returns bytes for most columns, they are converted by the column type
+func (m *MySQL) doQuery(sql string) (res []map[string]interface{}, err error) {
+	res = make([]map[string]interface{}, 0)
+	rows, err := m.db.Query(sql)
+	if err != nil {
+		invoker.Logger.Error("MySQL", elog.Any("step", "doQuery"), elog.Any("sql", sql), elog.Any("error", err.Error()))
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	cts, _ := rows.ColumnTypes()
+	values := make([]interface{}, len(cts))
+	for rows.Next() {
+		for idx := range values {
+			values[idx] = new(interface{})
+		}
+		if err = rows.Scan(values...); err != nil {
+			invoker.Logger.Error("MySQL", elog.Any("step", "doQuery"), elog.Any("error", err.Error()))
+			return
+		}
+		line := make(map[string]interface{}, len(cts))
+		for idx, ct := range cts {
+			line[ct.Name()] = mysqlValue(ct.DatabaseTypeName(), *(values[idx].(*interface{})))
+		}
+		res = append(res, line)
+	}
+	if err = rows.Err(); err != nil {
+		invoker.Logger.Error("MySQL", elog.Any("step", "doQuery"), elog.Any("error", err.Error()))
+		return
+	}
+	return
+}
+
+func mysqlValue(typ string, v interface{}) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		if v == nil {
+			return ""
+		}
+		return v
+	}
+	s := string(b)
+	switch strings.TrimPrefix(typ, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case "FLOAT", "DOUBLE", "DECIMAL":
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	}
+	return s
+}
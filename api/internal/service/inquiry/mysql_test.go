@@ -0,0 +1,206 @@
+package inquiry
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gotomicro/ego/core/elog"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func Test_genMySQLTimeCondition(t *testing.T) {
+	tests := []struct {
+		name  string
+		param view.ReqQuery
+		want  string
+	}{
+		{
+			name:  "test-1",
+			param: view.ReqQuery{TimeField: "created_at", TimeFieldType: db.TimeFieldTypeDT, ST: 1, ET: 2},
+			want:  "created_at >= FROM_UNIXTIME(1) AND created_at < FROM_UNIXTIME(2)",
+		},
+		{
+			name:  "test-2",
+			param: view.ReqQuery{TimeField: "ts", TimeFieldType: db.TimeFieldTypeTs, ST: 1, ET: 2},
+			want:  "ts >= 1 AND ts < 2",
+		},
+		{
+			name:  "test-3",
+			param: view.ReqQuery{TimeField: "ts", TimeFieldType: db.TimeFieldTypeTsMs, ST: 1, ET: 2},
+			want:  "ts >= 1*1000 AND ts < 2*1000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprintf(genMySQLTimeCondition(tt.param), tt.param.ST, tt.param.ET); got != tt.want {
+				t.Errorf("genMySQLTimeCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mysqlFieldTypeJudgment(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want int
+	}{
+		{typ: "varchar", want: 0},
+		{typ: "json", want: 0},
+		{typ: "bigint", want: 1},
+		{typ: "DECIMAL", want: 2},
+		{typ: "datetime", want: -1},
+		{typ: "blob", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			if got := mysqlFieldTypeJudgment(tt.typ); got != tt.want {
+				t.Errorf("mysqlFieldTypeJudgment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mysqlValue(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		v    interface{}
+		want interface{}
+	}{
+		{name: "test-1", typ: "UNSIGNED BIGINT", v: []byte("42"), want: int64(42)},
+		{name: "test-2", typ: "DOUBLE", v: []byte("0.5"), want: 0.5},
+		{name: "test-3", typ: "VARCHAR", v: []byte("abc"), want: "abc"},
+		{name: "test-4", typ: "VARCHAR", v: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mysqlValue(tt.typ, tt.v); got != tt.want {
+				t.Errorf("mysqlValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMySQL runs against the mysql of docker-compose, it is skipped when the server is unreachable
+func TestMySQL(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		dsn = "root:root@tcp(127.0.0.1:13306)/"
+	}
+	conn, err := sql.Open("mysql", dsn+"?parseTime=true&loc=Local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	if err = conn.Ping(); err != nil {
+		t.Skipf("mysql is unreachable: %s", err)
+	}
+	if invoker.Logger == nil {
+		invoker.Logger = elog.DefaultLogger
+	}
+	for _, s := range []string{
+		"DROP DATABASE IF EXISTS cv_inquiry_test",
+		"CREATE DATABASE cv_inquiry_test",
+		"CREATE TABLE cv_inquiry_test.audit (id bigint AUTO_INCREMENT PRIMARY KEY, created_at datetime NOT NULL, action varchar(32), cost double)",
+	} {
+		if _, err = conn.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() { _, _ = conn.Exec("DROP DATABASE cv_inquiry_test") }()
+
+	m := NewMySQL(conn, &db.BaseInstance{BaseModel: db.BaseModel{ID: 1}})
+	now := time.Now().Truncate(time.Second)
+	err = m.Insert("cv_inquiry_test", "audit", []string{"created_at", "action", "cost"}, [][]interface{}{
+		{now, "login", 0.5},
+		{now, "login", 1.5},
+		{now, "logout", 2.0},
+		{now.Add(-time.Hour), "login", 1.0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	param, err := m.Prepare(view.ReqQuery{
+		Database:      "cv_inquiry_test",
+		Table:         "audit",
+		TimeField:     "created_at",
+		TimeFieldType: db.TimeFieldTypeDT,
+		ST:            now.Add(-time.Minute).Unix(),
+		ET:            now.Unix() + 1,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := m.Count(param)
+	if err != nil || count != 3 {
+		t.Errorf("Count() = %v, %v, want 3", count, err)
+	}
+	param.Field = "action"
+	if got := m.GroupBy(param); got["login"] != 2 || got["logout"] != 1 {
+		t.Errorf("GroupBy() = %v", got)
+	}
+	param.Query = "cost>1"
+	if count, err = m.Count(param); err != nil || count != 2 {
+		t.Errorf("Count() with query = %v, %v, want 2", count, err)
+	}
+
+	columns, err := m.Columns("cv_inquiry_test", "audit", false)
+	if err != nil || len(columns) != 4 {
+		t.Fatalf("Columns() = %v, %v", columns, err)
+	}
+	for i, want := range []int{1, -1, 0, 2} {
+		if columns[i].Type != want {
+			t.Errorf("Columns() %s type = %d, want %d", columns[i].Name, columns[i].Type, want)
+		}
+	}
+	if columns, err = m.Columns("cv_inquiry_test", "audit", true); err != nil || len(columns) != 2 {
+		t.Errorf("Columns() time fields = %v, %v", columns, err)
+	}
+
+	databases, err := m.Databases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, d := range databases {
+		if d.Name == "mysql" {
+			t.Errorf("Databases() contains the system database")
+		}
+		found = found || d.Name == "cv_inquiry_test"
+	}
+	if !found {
+		t.Errorf("Databases() = %v, missing cv_inquiry_test", databases)
+	}
+
+	res, err := m.Complete("SELECT action, max(cost) AS cost FROM cv_inquiry_test.audit GROUP BY action ORDER BY action")
+	if err != nil || len(res.Logs) != 2 || res.Logs[1]["cost"] != 2.0 {
+		t.Errorf("Complete() = %v, %v", res.Logs, err)
+	}
+	if err = m.TableDrop("cv_inquiry_test", "audit", "", 0); err == nil {
+		t.Errorf("TableDrop() should not be supported")
+	}
+}
+
+func TestMySQL_groupBySQL(t *testing.T) {
+	if invoker.Logger == nil {
+		invoker.Logger = elog.DefaultLogger
+	}
+	param := view.ReqQuery{DatabaseTable: "`logs`.`app`", Field: "level", TimeField: "created_at", TimeFieldType: db.TimeFieldTypeDT, ST: 1, ET: 2, Query: "1='1'"}
+	m := &MySQL{}
+	if got := m.groupBySQL(param); !strings.HasSuffix(got, "LIMIT 10") {
+		t.Errorf("groupBySQL() = %v", got)
+	}
+	param.GroupByLimit = 50
+	if got := m.groupBySQL(param); !strings.HasSuffix(got, "LIMIT 50") {
+		t.Errorf("groupBySQL() = %v", got)
+	}
+}
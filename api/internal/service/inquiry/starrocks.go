@@ -0,0 +1,21 @@
+package inquiry
+
+import (
+	"database/sql"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+// NewStarRocks StarRocks speaks the mysql protocol, its tables are queried in the same way as mysql
+func NewStarRocks(db *sql.DB, ins *db.BaseInstance) *MySQL {
+	m := NewMySQL(db, ins)
+	m.systemDatabases = []string{"information_schema", "_statistics_", "sys"}
+	return m
+}
+
+// NewDoris Doris speaks the mysql protocol, its tables are queried in the same way as mysql
+func NewDoris(db *sql.DB, ins *db.BaseInstance) *MySQL {
+	m := NewMySQL(db, ins)
+	m.systemDatabases = []string{"information_schema", "__internal_schema", "mysql"}
+	return m
+}
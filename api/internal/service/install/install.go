@@ -41,6 +41,11 @@ var models = []interface{}{
 	db.AlarmCondition{},
 	db.AlarmHistory{},
 	db.AlarmChannel{},
+	db.AlarmSilence{},
+	db.AlarmDelivery{},
+	db.AlarmEscalation{},
+	db.AlarmOncall{},
+	db.AlarmTemplate{},
 
 	db.User{},
 	db.Event{},
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ego-component/egorm"
+	"github.com/go-sql-driver/mysql"
 	"github.com/gotomicro/ego/core/elog"
 	"github.com/pkg/errors"
 
@@ -32,18 +33,8 @@ func NewInstanceManager() *instanceManager {
 	}
 	datasourceList, _ := db.InstanceList(egorm.Conds{})
 	for _, ds := range datasourceList {
-		switch ds.Datasource {
-		case db.DatasourceMySQL:
-			// TODO Not supported at this time
-		case db.DatasourceClickHouse:
-			// Test connection, storage
-			chDb, err := ClickHouseLink(ds.Dsn)
-			if err != nil {
-				invoker.Logger.Error("ClickHouse", elog.Any("step", "ClickHouseLink"), elog.Any("error", err.Error()))
-				continue
-			}
-			m.dss.Store(ds.DsKey(), inquiry.NewClickHouse(chDb, ds))
-		}
+		// Test connection, storage
+		_ = m.Add(ds)
 	}
 	return m
 }
@@ -54,39 +45,36 @@ func (i *instanceManager) Delete(key string) {
 }
 
 func (i *instanceManager) Add(obj *db.BaseInstance) error {
-	switch obj.Datasource {
-	case db.DatasourceClickHouse:
-		// Test connection, storage
-		chDb, err := ClickHouseLink(obj.Dsn)
-		if err != nil {
-			invoker.Logger.Error("ClickHouse", elog.Any("step", "ClickHouseLink"), elog.Any("error", err.Error()))
-			return err
-		}
-		i.dss.Store(obj.DsKey(), inquiry.NewClickHouse(chDb, obj))
+	backend, ok := LoadBackend(obj.Datasource)
+	if !ok {
+		return errors.Errorf("unsupported datasource: %s", obj.Datasource)
+	}
+	// Test connection, storage
+	conn, err := backend.Link(obj.Dsn)
+	if err != nil {
+		invoker.Logger.Error("instanceManager", elog.String("datasource", obj.Datasource), elog.Any("step", "link"), elog.Any("error", err.Error()))
+		return err
 	}
+	i.dss.Store(obj.DsKey(), backend.New(conn, obj))
 	return nil
 }
 
 func (i *instanceManager) Load(id int) (inquiry.Operator, error) {
-	instance, err := db.InstanceInfo(invoker.Db, id)
-	if err != nil {
-		invoker.Logger.Error("instanceManager", elog.Any("id", id), elog.Any("error", err.Error()))
-		return nil, err
-	}
 	obj, ok := i.dss.Load(db.InstanceKey(id))
 	if !ok {
+		instance, err := db.InstanceInfo(invoker.Db, id)
+		if err != nil {
+			invoker.Logger.Error("instanceManager", elog.Any("id", id), elog.Any("error", err.Error()))
+			return nil, err
+		}
 		// try again
 		if err = i.Add(&instance); err != nil {
 			return nil, constx.ErrInstanceObj
 		}
 		obj, _ = i.dss.Load(db.InstanceKey(id))
 	}
-	if obj == nil {
-		return nil, constx.ErrInstanceObj
-	}
-	switch instance.Datasource {
-	case db.DatasourceClickHouse:
-		return obj.(*inquiry.ClickHouse), nil
+	if op, ok := obj.(inquiry.Operator); ok {
+		return op, nil
 	}
 	return nil, constx.ErrInstanceObj
 }
@@ -181,6 +169,38 @@ func ClickHouseLink(dsn string) (conn *sql.DB, err error) {
 	return
 }
 
+// MySQLLink time columns are parsed into time.Time in the local time zone, the same as clickhouse
+func MySQLLink(dsn string) (conn *sql.DB, err error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		invoker.Logger.Error("MySQL", elog.Any("step", "parseDSN"), elog.String("error", err.Error()))
+		return
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	conn, err = sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		invoker.Logger.Error("MySQL", elog.Any("step", "sql.error"), elog.String("error", err.Error()))
+		return
+	}
+	conn.SetMaxIdleConns(5)
+	conn.SetMaxOpenConns(10)
+	conn.SetConnMaxLifetime(time.Minute * 3)
+	if err = conn.Ping(); err != nil {
+		invoker.Logger.Error("MySQL", elog.String("step", "notException"), elog.Any("error", err.Error()))
+		return
+	}
+	return
+}
+
+// RuleStoreValidate checks the instance has the kubernetes cluster and namespace of its PrometheusRule resources
+func RuleStoreValidate(req view.ReqCreateInstance) error {
+	if req.RuleStoreType == db.RuleStoreTypeOperator && (req.ClusterId == 0 || req.Namespace == "") {
+		return errors.New("the cluster and the namespace of the PrometheusRule resources are required")
+	}
+	return nil
+}
+
 func InstanceCreate(req view.ReqCreateInstance) (obj db.BaseInstance, err error) {
 	conds := egorm.Conds{}
 	conds["datasource"] = req.Datasource
@@ -199,6 +219,9 @@ func InstanceCreate(req view.ReqCreateInstance) (obj db.BaseInstance, err error)
 		err = errors.New("you need to fill in the cluster information")
 		return
 	}
+	if err = RuleStoreValidate(req); err != nil {
+		return
+	}
 	obj = db.BaseInstance{
 		Datasource:       req.Datasource,
 		Name:             req.Name,
@@ -213,6 +236,7 @@ func InstanceCreate(req view.ReqCreateInstance) (obj db.BaseInstance, err error)
 		ReplicaStatus:    req.ReplicaStatus,
 		Mode:             req.Mode,
 		Clusters:         req.Clusters,
+		RuleLabels:       req.RuleLabels,
 	}
 	invoker.Logger.Debug("instanceCreate", elog.Any("obj", obj))
 	if req.PrometheusTarget != "" {
@@ -354,6 +378,8 @@ func InstanceFilterPms(uid int) (res []view.RespInstanceSimple, err error) {
 			Id:           i.ID,
 			InstanceName: i.Name,
 			Desc:         i.Desc,
+			Datasource:   i.Datasource,
+			Capability:   DatasourceCapability(i.Datasource),
 			Databases:    make([]view.RespDatabaseSimple, 0),
 		}
 	}
@@ -163,3 +163,13 @@ var KindToResourceMap = map[string]ResourceMap{
 		Namespaced: true,
 	},
 }
+
+// PrometheusRuleResource is the custom resource of prometheus-operator, which is handled by the dynamic client
+var PrometheusRuleResource = GroupVersionResourceKind{
+	GroupVersionResource: schema.GroupVersionResource{
+		Group:    "monitoring.coreos.com",
+		Version:  "v1",
+		Resource: "prometheusrules",
+	},
+	Kind: "PrometheusRule",
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/gotomicro/ego/core/elog"
 	"go.uber.org/zap"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
@@ -42,6 +43,7 @@ type ClusterClient struct {
 	Cluster    *db.Cluster
 	Config     *rest.Config
 	KubeClient ResourceHandler
+	Dynamic    dynamic.Interface // custom resources, such as the PrometheusRule of prometheus-operator
 }
 
 func InitClusterManager() {
@@ -114,10 +116,16 @@ func (s *clusterManager) addConn(key string, cluster *db.Cluster) {
 		invoker.Logger.Warn(fmt.Sprintf("build cache controller for cluster (%s) error.", cluster.Name), zap.Error(err))
 		return
 	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		invoker.Logger.Warn(fmt.Sprintf("build cluster (%s)'s dynamic client error.", cluster.Name), zap.Error(err))
+		return
+	}
 	cm := &ClusterClient{
 		Config:     config,
 		Cluster:    cluster,
 		KubeClient: NewResourceHandler(clientSet, cacheFactory),
+		Dynamic:    dynamicClient,
 	}
 	invoker.Logger.Debug("addConn", elog.Any("key", key), elog.Any("cluster", cluster.Name))
 	s.clients.Store(key, cm)
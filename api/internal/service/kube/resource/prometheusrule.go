@@ -0,0 +1,76 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/kube"
+	"github.com/clickvisual/clickvisual/api/internal/service/kube/api"
+)
+
+const labelManagedBy = "app.kubernetes.io/managed-by"
+
+// PrometheusRuleCreateOrUpdate creates the PrometheusRule with the groups of the rule file,
+// or replaces the spec of the existing one, the labels are added for the rule selector of prometheus-operator.
+func PrometheusRuleCreateOrUpdate(client *kube.ClusterClient, namespace, name string, labels map[string]string, rule string) error {
+	spec := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(rule), &spec); err != nil {
+		return errors.Wrap(err, "invalid prometheus rule")
+	}
+	ri := client.Dynamic.Resource(api.PrometheusRuleResource.GroupVersionResource).Namespace(namespace)
+	obj, err := ri.Get(context.Background(), name, metaV1.GetOptions{})
+	if NotFound(err) {
+		obj = &unstructured.Unstructured{}
+		obj.SetAPIVersion(api.PrometheusRuleResource.GroupVersion().String())
+		obj.SetKind(api.PrometheusRuleResource.Kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		obj.SetLabels(prometheusRuleLabels(nil, labels))
+		obj.Object["spec"] = spec
+		if _, err = ri.Create(context.Background(), obj, metaV1.CreateOptions{}); err != nil {
+			invoker.Logger.Error("PrometheusRuleCreateOrUpdate", elog.String("namespace", namespace), elog.String("name", name), elog.String("err", err.Error()))
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Get PrometheusRule failed, in cluster")
+	}
+	obj.SetLabels(prometheusRuleLabels(obj.GetLabels(), labels))
+	obj.Object["spec"] = spec
+	invoker.Logger.Debug("PrometheusRuleCreateOrUpdate", elog.String("namespace", namespace), elog.String("name", name))
+	_, err = ri.Update(context.Background(), obj, metaV1.UpdateOptions{})
+	return err
+}
+
+func PrometheusRuleDelete(clusterId int, namespace, name string) error {
+	client, err := kube.ClusterManager.GetClusterManager(clusterId)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("cluster data acquisition failed: %s, cluster id: %d", err.Error(), clusterId))
+	}
+	err = client.Dynamic.Resource(api.PrometheusRuleResource.GroupVersionResource).Namespace(namespace).Delete(context.Background(), name, metaV1.DeleteOptions{})
+	if err != nil && !NotFound(err) {
+		return errors.Wrap(err, "Delete PrometheusRule failed, in cluster")
+	}
+	return nil
+}
+
+// prometheusRuleLabels merges the labels of the instance into the current ones
+func prometheusRuleLabels(current, labels map[string]string) map[string]string {
+	res := make(map[string]string, len(current)+len(labels)+1)
+	for k, v := range current {
+		res[k] = v
+	}
+	for k, v := range labels {
+		res[k] = v
+	}
+	res[labelManagedBy] = "clickvisual"
+	return res
+}
@@ -0,0 +1,58 @@
+package resource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotomicro/ego/core/elog"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/service/kube"
+	"github.com/clickvisual/clickvisual/api/internal/service/kube/api"
+)
+
+const testRule = `groups:
+- name: default
+  rules:
+  - alert: a_b
+    expr: avg_over_time(m[1m])>1
+    for: 1m`
+
+func TestPrometheusRuleCreateOrUpdate(t *testing.T) {
+	invoker.Logger = elog.DefaultLogger
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{api.PrometheusRuleResource.GroupVersionResource: "PrometheusRuleList"})
+	client := &kube.ClusterClient{Dynamic: dynamicClient}
+	ri := dynamicClient.Resource(api.PrometheusRuleResource.GroupVersionResource).Namespace("monitoring")
+
+	if err := PrometheusRuleCreateOrUpdate(client, "monitoring", "cv-a-b", map[string]string{"release": "prometheus"}, testRule); err != nil {
+		t.Fatalf("PrometheusRuleCreateOrUpdate() create error = %v", err)
+	}
+	obj, err := ri.Get(context.Background(), "cv-a-b", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if obj.GetKind() != "PrometheusRule" || obj.GetLabels()["release"] != "prometheus" || obj.GetLabels()[labelManagedBy] != "clickvisual" {
+		t.Errorf("created object = %v", obj)
+	}
+
+	updated := testRule[:len(testRule)-2] + "5m"
+	if err = PrometheusRuleCreateOrUpdate(client, "monitoring", "cv-a-b", nil, updated); err != nil {
+		t.Fatalf("PrometheusRuleCreateOrUpdate() update error = %v", err)
+	}
+	obj, _ = ri.Get(context.Background(), "cv-a-b", metaV1.GetOptions{})
+	groups, _, _ := unstructured.NestedSlice(obj.Object, "spec", "groups")
+	rules, _, _ := unstructured.NestedSlice(groups[0].(map[string]interface{}), "rules")
+	if rules[0].(map[string]interface{})["for"] != "5m" || obj.GetLabels()["release"] != "prometheus" {
+		t.Errorf("updated object = %v", obj)
+	}
+
+	if err = PrometheusRuleCreateOrUpdate(client, "monitoring", "cv-a-b", nil, "groups: ["); err == nil {
+		t.Error("PrometheusRuleCreateOrUpdate() with an invalid rule should fail")
+	}
+}
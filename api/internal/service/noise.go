@@ -0,0 +1,418 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+// InhibitRule suppresses the firing notifications of the target levels while an alarm of the source levels fires on the same table
+type InhibitRule struct {
+	SourceLevels []int `json:"sourceLevels"`
+	TargetLevels []int `json:"targetLevels"`
+}
+
+func (r InhibitRule) inhibits(source, target *db.Alarm) bool {
+	if source.ID == target.ID || source.Tid != target.Tid || source.Status != db.AlarmStatusFiring {
+		return false
+	}
+	return containsInt(r.SourceLevels, source.Level) && containsInt(r.TargetLevels, target.Level)
+}
+
+// noise reduces the notifications of Send before they reach the outbox:
+// firing notifications are inhibited by the rules, notifications of the same group are merged within the group wait,
+// and the ones over the rate limit of a channel are suppressed and summarized once the window frees up.
+type noise struct {
+	groupWait    time.Duration
+	groupBy      []string
+	rateLimit    int
+	rateWindow   time.Duration
+	inhibitRules []InhibitRule
+}
+
+func NewNoise() *noise {
+	n := &noise{
+		groupWait:  econf.GetDuration("alarm.noise.groupWait"),
+		groupBy:    econf.GetStringSlice("alarm.noise.groupBy"),
+		rateLimit:  econf.GetInt("alarm.noise.rateLimit"),
+		rateWindow: econf.GetDuration("alarm.noise.rateWindow"),
+	}
+	_ = econf.UnmarshalKey("alarm.noise.inhibitRules", &n.inhibitRules)
+	if n.rateWindow <= 0 {
+		n.rateWindow = time.Minute * 10
+	}
+	return n
+}
+
+// InhibitingAlarm returns the firing alarm on the same table that inhibits the alarm, nil when there is none
+func (n *noise) InhibitingAlarm(alarmObj *db.Alarm) (*db.Alarm, error) {
+	if len(n.inhibitRules) == 0 || alarmObj.Tid == 0 {
+		return nil, nil
+	}
+	conds := egorm.Conds{}
+	conds["tid"] = alarmObj.Tid
+	conds["status"] = db.AlarmStatusFiring
+	firing, err := db.AlarmList(conds)
+	if err != nil {
+		return nil, err
+	}
+	return inhibitingAlarm(n.inhibitRules, alarmObj, firing), nil
+}
+
+func inhibitingAlarm(rules []InhibitRule, target *db.Alarm, firing []*db.Alarm) *db.Alarm {
+	for _, source := range firing {
+		for _, rule := range rules {
+			if rule.inhibits(source, target) {
+				return source
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue persists the deliveries of the notification, merged into the waiting ones of the same group when possible
+func (n *noise) enqueue(alarmObj *db.Alarm, historyId int, notification view.Notification, oneTheLogs string) error {
+	if n.groupWait <= 0 && n.rateLimit <= 0 && !channelsRateLimited(alarmObj.ChannelIds) {
+		return enqueueDeliveries(alarmObj.ID, historyId, alarmObj.ChannelIds, notification, oneTheLogs)
+	}
+	now := time.Now()
+	groupKey := notificationGroupKey(alarmObj.ID, notification, n.groupBy)
+	results := make(db.ChannelResults, 0, len(alarmObj.ChannelIds))
+	groupId := 0
+	for _, channelId := range alarmObj.ChannelIds {
+		if n.groupWait > 0 {
+			merged, err := n.merge(groupKey, channelId, notification, now)
+			if err != nil {
+				return err
+			}
+			if merged != nil {
+				groupId = merged.HistoryId
+				results = append(results, db.ChannelResult{ChannelId: channelId, DeliveryId: merged.ID, Status: db.DeliveryStatusPending})
+				continue
+			}
+		}
+		delivery, err := n.create(alarmObj.ID, historyId, channelId, groupKey, notification, oneTheLogs, now)
+		if err != nil {
+			return err
+		}
+		results = append(results, db.ChannelResult{ChannelId: channelId, DeliveryId: delivery.ID, Status: delivery.Status})
+	}
+	ups := map[string]interface{}{"log": oneTheLogs, "channel_results": results}
+	if groupId != 0 {
+		ups["group_id"] = groupId
+	}
+	if err := db.AlarmHistoryUpdate(invoker.Db, historyId, ups); err != nil {
+		return err
+	}
+	Outbox.Wake()
+	return nil
+}
+
+// merge adds the alerts of the notification to the delivery of the group waiting to be sent to the channel,
+// nil is returned when there is no such delivery.
+func (n *noise) merge(groupKey string, channelId int, notification view.Notification, now time.Time) (*db.AlarmDelivery, error) {
+	waiting, err := db.AlarmDeliveryGrouping(invoker.Db, groupKey, channelId, now.Unix())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var grouped view.Notification
+	if err = json.Unmarshal([]byte(waiting.Notification), &grouped); err != nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(mergeNotification(grouped, notification))
+	if err != nil {
+		return nil, err
+	}
+	ok, err := db.AlarmDeliveryMerge(invoker.Db, waiting.ID, string(payload), now.Unix())
+	if err != nil || !ok {
+		// the group wait is over, the notification is sent on its own
+		return nil, err
+	}
+	return &waiting, nil
+}
+
+// create persists a delivery that is sent after the group wait, or suppressed when the channel is over its rate limit
+func (n *noise) create(alarmId, historyId, channelId int, groupKey string, notification view.Notification, oneTheLogs string, now time.Time) (*db.AlarmDelivery, error) {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &db.AlarmDelivery{
+		AlarmId:      alarmId,
+		HistoryId:    historyId,
+		ChannelId:    channelId,
+		Notification: string(payload),
+		Log:          oneTheLogs,
+		Status:       db.DeliveryStatusPending,
+		NextAt:       now.Add(n.groupWait).Unix(),
+		GroupKey:     groupKey,
+	}
+	freeAt, limited, err := n.rateLimited(channelId, now)
+	if err != nil {
+		return nil, err
+	}
+	if limited {
+		delivery.Status = db.DeliveryStatusSuppressed
+		delivery.NextAt = freeAt
+	}
+	if err = db.AlarmDeliveryCreate(invoker.Db, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// rateLimited reports whether the channel has used up its rate limit, freeAt is when the window frees up
+func (n *noise) rateLimited(channelId int, now time.Time) (freeAt int64, limited bool, err error) {
+	limit, window := n.rateLimit, n.rateWindow
+	channelInfo, err := db.AlarmChannelInfo(invoker.Db, channelId)
+	if err != nil {
+		return 0, false, err
+	}
+	if channelInfo.RateLimit > 0 {
+		limit = channelInfo.RateLimit
+	}
+	if channelInfo.RateWindow > 0 {
+		window = time.Duration(channelInfo.RateWindow) * time.Second
+	}
+	if limit <= 0 {
+		return 0, false, nil
+	}
+	conds := egorm.Conds{}
+	conds["channel_id"] = channelId
+	conds["ctime"] = egorm.Cond{Op: ">", Val: now.Add(-window).Unix()}
+	conds["status"] = egorm.Cond{Op: "in", Val: []int{db.DeliveryStatusPending, db.DeliveryStatusSucceeded, db.DeliveryStatusDeadLetter}}
+	sent, err := db.AlarmDeliveryList(conds)
+	if err != nil {
+		return 0, false, err
+	}
+	return rateWindowFreeAt(sent, limit, window, now)
+}
+
+// rateWindowFreeAt returns when the oldest of the deliveries sent in the window leaves it, the deliveries are in the order of ids
+func rateWindowFreeAt(sent []*db.AlarmDelivery, limit int, window time.Duration, now time.Time) (freeAt int64, limited bool, err error) {
+	if len(sent) < limit {
+		return 0, false, nil
+	}
+	freeAt = sent[0].Ctime + int64(window/time.Second)
+	if freeAt <= now.Unix() {
+		freeAt = now.Unix() + 1
+	}
+	return freeAt, true, nil
+}
+
+// summarize sends the latest of the suppressed deliveries of every status of every channel whose window has freed up,
+// with the number of the others of the status in the suppressed annotation.
+func (n *noise) summarize(now time.Time) {
+	conds := egorm.Conds{}
+	conds["status"] = db.DeliveryStatusSuppressed
+	conds["next_at"] = egorm.Cond{Op: "<=", Val: now.Unix()}
+	due, err := db.AlarmDeliveryList(conds)
+	if err != nil {
+		invoker.Logger.Error("noise", elog.String("step", "AlarmDeliveryList"), elog.String("error", err.Error()))
+		return
+	}
+	channels := make(map[int]struct{})
+	for _, d := range due {
+		channels[d.ChannelId] = struct{}{}
+	}
+	for channelId := range channels {
+		if err = n.summarizeChannel(channelId, now); err != nil {
+			invoker.Logger.Error("noise", elog.Int("channelId", channelId), elog.String("error", err.Error()))
+		}
+	}
+}
+
+func (n *noise) summarizeChannel(channelId int, now time.Time) error {
+	conds := egorm.Conds{}
+	conds["channel_id"] = channelId
+	conds["status"] = db.DeliveryStatusSuppressed
+	suppressed, err := db.AlarmDeliveryList(conds)
+	if err != nil || len(suppressed) == 0 {
+		return err
+	}
+	ids := make([]int, 0, len(suppressed))
+	for _, d := range suppressed {
+		ids = append(ids, d.ID)
+	}
+	// summarizing claims the suppressed deliveries, so the replicas never summarize them twice
+	claimed, err := db.AlarmDeliverySummarize(invoker.Db, ids)
+	if err != nil || claimed == 0 {
+		return err
+	}
+	latests, err := latestByStatus(suppressed)
+	if err != nil {
+		return err
+	}
+	for _, latest := range latests {
+		payload, errPayload := json.Marshal(summaryNotification(latest.notification, latest.others))
+		if errPayload != nil {
+			return errPayload
+		}
+		delivery := &db.AlarmDelivery{
+			AlarmId:      latest.delivery.AlarmId,
+			HistoryId:    latest.delivery.HistoryId,
+			ChannelId:    channelId,
+			Notification: string(payload),
+			Log:          latest.delivery.Log,
+			Status:       db.DeliveryStatusPending,
+			NextAt:       now.Unix(),
+		}
+		if err = db.AlarmDeliveryCreate(invoker.Db, delivery); err != nil {
+			return err
+		}
+	}
+	refreshed := make(map[int]bool)
+	for _, d := range suppressed {
+		if refreshed[d.HistoryId] {
+			continue
+		}
+		refreshed[d.HistoryId] = true
+		if err = RefreshHistoryDelivery(d.HistoryId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suppressedLatest is the latest suppressed delivery of a status and the number of the others of the status
+type suppressedLatest struct {
+	delivery     *db.AlarmDelivery
+	notification view.Notification
+	others       int
+}
+
+// latestByStatus returns the latest of the deliveries of every status, ordered by their ids as the deliveries are,
+// so that a resolved notification suppressed after a firing one is neither lost nor sent before it.
+func latestByStatus(deliveries []*db.AlarmDelivery) ([]*suppressedLatest, error) {
+	byStatus := make(map[string]*suppressedLatest)
+	for _, d := range deliveries {
+		var notification view.Notification
+		if err := json.Unmarshal([]byte(d.Notification), &notification); err != nil {
+			return nil, errors.Wrap(err, "invalid notification")
+		}
+		latest, ok := byStatus[notification.Status]
+		if !ok {
+			byStatus[notification.Status] = &suppressedLatest{delivery: d, notification: notification}
+			continue
+		}
+		latest.delivery, latest.notification = d, notification
+		latest.others++
+	}
+	res := make([]*suppressedLatest, 0, len(byStatus))
+	for _, latest := range byStatus {
+		res = append(res, latest)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].delivery.ID < res[j].delivery.ID
+	})
+	return res, nil
+}
+
+// notificationGroupKey is the alarm, the status and the values of the group-by labels of the notification
+func notificationGroupKey(alarmId int, notification view.Notification, groupBy []string) string {
+	key := fmt.Sprintf("%d/%s", alarmId, notification.Status)
+	if len(groupBy) == 0 {
+		return key
+	}
+	labels := notification.CommonLabels
+	if len(labels) == 0 && len(notification.Alerts) > 0 {
+		labels = notification.Alerts[0].Labels
+	}
+	names := append([]string(nil), groupBy...)
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+labels[name])
+	}
+	key += "/" + strings.Join(pairs, ",")
+	if len(key) > 255 {
+		key = key[:255]
+	}
+	return key
+}
+
+// mergeNotification adds the alerts of the notification to the grouped one, an alert of the same labels replaces the old one
+func mergeNotification(grouped, notification view.Notification) view.Notification {
+	for _, alert := range notification.Alerts {
+		replaced := false
+		for i, old := range grouped.Alerts {
+			if sameLabels(old.Labels, alert.Labels) {
+				grouped.Alerts[i] = alert
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			grouped.Alerts = append(grouped.Alerts, alert)
+		}
+	}
+	for name, value := range grouped.CommonLabels {
+		if notification.CommonLabels[name] != value {
+			delete(grouped.CommonLabels, name)
+		}
+	}
+	return grouped
+}
+
+// summaryNotification is the notification with the number of the other suppressed ones
+func summaryNotification(notification view.Notification, others int) view.Notification {
+	if others <= 0 {
+		return notification
+	}
+	annotations := make(map[string]string, len(notification.CommonAnnotations)+1)
+	for k, v := range notification.CommonAnnotations {
+		annotations[k] = v
+	}
+	annotations[push.AnnotationSuppressed] = strconv.Itoa(others)
+	notification.CommonAnnotations = annotations
+	return notification
+}
+
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// channelsRateLimited reports whether any of the channels has a rate limit of its own
+func channelsRateLimited(channelIds []int) bool {
+	for _, channelId := range channelIds {
+		channelInfo, err := db.AlarmChannelInfo(invoker.Db, channelId)
+		if err == nil && channelInfo.RateLimit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
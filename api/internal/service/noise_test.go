@@ -0,0 +1,129 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+func Test_inhibitingAlarm(t *testing.T) {
+	rules := []InhibitRule{{SourceLevels: []int{db.AlarmLevelFatal}, TargetLevels: []int{db.AlarmLevelDefault, db.AlarmLevelKnow}}}
+	alarm := func(id, tid, level, status int) *db.Alarm {
+		a := &db.Alarm{Tid: tid, Level: level, Status: status}
+		a.ID = id
+		return a
+	}
+	warning := alarm(1, 10, db.AlarmLevelKnow, db.AlarmStatusFiring)
+	tests := []struct {
+		name   string
+		target *db.Alarm
+		firing []*db.Alarm
+		want   int
+	}{
+		{name: "critical on the same table", target: warning, firing: []*db.Alarm{alarm(2, 10, db.AlarmLevelFatal, db.AlarmStatusFiring)}, want: 2},
+		{name: "critical on another table", target: warning, firing: []*db.Alarm{alarm(2, 11, db.AlarmLevelFatal, db.AlarmStatusFiring)}},
+		{name: "critical not firing", target: warning, firing: []*db.Alarm{alarm(2, 10, db.AlarmLevelFatal, db.AlarmStatusOpen)}},
+		{name: "warning does not inhibit", target: warning, firing: []*db.Alarm{alarm(2, 10, db.AlarmLevelKnow, db.AlarmStatusFiring)}},
+		{name: "critical is not inhibited", target: alarm(1, 10, db.AlarmLevelFatal, db.AlarmStatusFiring), firing: []*db.Alarm{alarm(2, 10, db.AlarmLevelFatal, db.AlarmStatusFiring)}},
+		{name: "not by itself", target: alarm(2, 10, db.AlarmLevelFatal, db.AlarmStatusFiring), firing: []*db.Alarm{alarm(2, 10, db.AlarmLevelFatal, db.AlarmStatusFiring)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := inhibitingAlarm(rules, tt.target, tt.firing)
+			if (got == nil && tt.want != 0) || (got != nil && got.ID != tt.want) {
+				t.Errorf("inhibitingAlarm() = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_notificationGroupKey(t *testing.T) {
+	n := view.Notification{Status: push.StatusFiring, CommonLabels: map[string]string{"service": "api", "env": "prod"}}
+	if got := notificationGroupKey(1, n, nil); got != "1/firing" {
+		t.Errorf("notificationGroupKey() = %v", got)
+	}
+	if got := notificationGroupKey(1, n, []string{"service", "env"}); got != "1/firing/env=prod,service=api" {
+		t.Errorf("notificationGroupKey() = %v", got)
+	}
+}
+
+func Test_mergeNotification(t *testing.T) {
+	grouped := view.Notification{
+		Status:       push.StatusFiring,
+		CommonLabels: map[string]string{"alertname": "a", "service": "api"},
+		Alerts:       []view.Alert{{Labels: map[string]string{"service": "api"}, Annotations: map[string]string{"value": "1"}}},
+	}
+	n := view.Notification{
+		Status:       push.StatusFiring,
+		CommonLabels: map[string]string{"alertname": "a", "service": "web"},
+		Alerts: []view.Alert{
+			{Labels: map[string]string{"service": "api"}, Annotations: map[string]string{"value": "2"}},
+			{Labels: map[string]string{"service": "web"}},
+		},
+	}
+	got := mergeNotification(grouped, n)
+	if len(got.Alerts) != 2 || got.Alerts[0].Annotations["value"] != "2" {
+		t.Errorf("mergeNotification() alerts = %+v", got.Alerts)
+	}
+	if _, ok := got.CommonLabels["service"]; ok || got.CommonLabels["alertname"] != "a" {
+		t.Errorf("mergeNotification() common labels = %v", got.CommonLabels)
+	}
+}
+
+func Test_rateWindowFreeAt(t *testing.T) {
+	now := time.Unix(10000, 0)
+	sent := func(ctimes ...int64) []*db.AlarmDelivery {
+		res := make([]*db.AlarmDelivery, 0, len(ctimes))
+		for _, ctime := range ctimes {
+			d := &db.AlarmDelivery{}
+			d.Ctime = ctime
+			res = append(res, d)
+		}
+		return res
+	}
+	if _, limited, _ := rateWindowFreeAt(sent(9990), 2, time.Minute, now); limited {
+		t.Errorf("rateWindowFreeAt() limited under the limit")
+	}
+	freeAt, limited, _ := rateWindowFreeAt(sent(9970, 9990), 2, time.Minute, now)
+	if !limited || freeAt != 10030 {
+		t.Errorf("rateWindowFreeAt() = %v, %v", freeAt, limited)
+	}
+}
+
+func Test_summaryNotification(t *testing.T) {
+	n := view.Notification{CommonAnnotations: map[string]string{"description": "d"}}
+	if got := summaryNotification(n, 0); got.CommonAnnotations[push.AnnotationSuppressed] != "" {
+		t.Errorf("summaryNotification() = %v", got.CommonAnnotations)
+	}
+	got := summaryNotification(n, 12)
+	if got.CommonAnnotations[push.AnnotationSuppressed] != "12" || got.CommonAnnotations["description"] != "d" {
+		t.Errorf("summaryNotification() = %v", got.CommonAnnotations)
+	}
+	if _, ok := n.CommonAnnotations[push.AnnotationSuppressed]; ok {
+		t.Errorf("summaryNotification() changed the annotations of the notification")
+	}
+}
+
+func Test_latestByStatus(t *testing.T) {
+	delivery := func(id int, status string) *db.AlarmDelivery {
+		d := &db.AlarmDelivery{Notification: `{"status":"` + status + `"}`}
+		d.ID = id
+		return d
+	}
+	got, err := latestByStatus([]*db.AlarmDelivery{
+		delivery(1, push.StatusResolved),
+		delivery(2, push.StatusFiring),
+		delivery(3, push.StatusFiring),
+		delivery(4, push.StatusResolved),
+		delivery(5, push.StatusFiring),
+	})
+	if err != nil {
+		t.Fatalf("latestByStatus() error = %v", err)
+	}
+	if len(got) != 2 || got[0].delivery.ID != 4 || got[0].others != 1 || got[1].delivery.ID != 5 || got[1].others != 2 {
+		t.Errorf("latestByStatus() = %+v, %+v", got[0], got[len(got)-1])
+	}
+}
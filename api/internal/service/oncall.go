@@ -0,0 +1,32 @@
+package service
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// OncallValidate checks the rotation and the overrides of the schedule
+func OncallValidate(req view.ReqAlarmOncallCreate) error {
+	if len(req.Uids) == 0 {
+		return errors.New("users are required")
+	}
+	for _, uid := range req.Uids {
+		if uid <= 0 {
+			return errors.Errorf("invalid user %d", uid)
+		}
+	}
+	if _, ok := db.OncallRotationPeriod[req.Rotation]; !ok {
+		return errors.Errorf("invalid rotation %d", req.Rotation)
+	}
+	for i, o := range req.Overrides {
+		if o.Uid <= 0 {
+			return errors.Errorf("override %d: invalid user %d", i+1, o.Uid)
+		}
+		if o.EndsAt <= o.StartsAt {
+			return errors.Errorf("override %d: endsAt should be after startsAt", i+1)
+		}
+	}
+	return nil
+}
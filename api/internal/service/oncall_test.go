@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TestAlarmOncall_OncallAt(t *testing.T) {
+	handoff := time.Date(2022, 9, 5, 10, 0, 0, 0, time.UTC)
+	schedule := db.AlarmOncall{
+		Uids:      db.Ints{1, 2, 3},
+		Rotation:  db.OncallRotationDaily,
+		HandoffAt: handoff.Unix(),
+		Overrides: db.OncallOverrides{
+			{Uid: 8, StartsAt: handoff.Add(48 * time.Hour).Unix(), EndsAt: handoff.Add(72 * time.Hour).Unix()},
+			{Uid: 9, StartsAt: handoff.Add(60 * time.Hour).Unix(), EndsAt: handoff.Add(61 * time.Hour).Unix()},
+		},
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{name: "first handoff", at: handoff, want: 1},
+		{name: "before the next handoff", at: handoff.Add(24*time.Hour - time.Second), want: 1},
+		{name: "next handoff", at: handoff.Add(24 * time.Hour), want: 2},
+		{name: "wraps around", at: handoff.Add(3 * 24 * time.Hour), want: 1},
+		{name: "before the first handoff", at: handoff.Add(-time.Second), want: 3},
+		{name: "previous rotations", at: handoff.Add(-3*24*time.Hour - time.Second), want: 3},
+		{name: "override", at: handoff.Add(50 * time.Hour), want: 8},
+		{name: "the last override wins", at: handoff.Add(60 * time.Hour), want: 9},
+		{name: "override ended", at: handoff.Add(72 * time.Hour), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.OncallAt(tt.at); got != tt.want {
+				t.Errorf("OncallAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	weekly := db.AlarmOncall{Uids: db.Ints{1, 2}, Rotation: db.OncallRotationWeekly, HandoffAt: handoff.Unix()}
+	if got := weekly.OncallAt(handoff.Add(6 * 24 * time.Hour)); got != 1 {
+		t.Errorf("weekly OncallAt() = %v, want 1", got)
+	}
+	if got := weekly.OncallAt(handoff.Add(7 * 24 * time.Hour)); got != 2 {
+		t.Errorf("weekly OncallAt() = %v, want 2", got)
+	}
+	if got := (&db.AlarmOncall{}).OncallAt(handoff); got != 0 {
+		t.Errorf("OncallAt() of empty schedule = %v, want 0", got)
+	}
+}
+
+func TestOncallValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     view.ReqAlarmOncallCreate
+		wantErr bool
+	}{
+		{name: "valid", req: view.ReqAlarmOncallCreate{Uids: []int{1, 2}, Rotation: db.OncallRotationWeekly, Overrides: []db.OncallOverride{{Uid: 3, StartsAt: 1, EndsAt: 2}}}},
+		{name: "no user", req: view.ReqAlarmOncallCreate{}, wantErr: true},
+		{name: "invalid rotation", req: view.ReqAlarmOncallCreate{Uids: []int{1}, Rotation: 5}, wantErr: true},
+		{name: "empty override", req: view.ReqAlarmOncallCreate{Uids: []int{1}, Overrides: []db.OncallOverride{{Uid: 3, StartsAt: 2, EndsAt: 2}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := OncallValidate(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("OncallValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/pkg/push"
+)
+
+// errUndeliverable marks the deliveries that would never succeed however many times they are retried
+var errUndeliverable = errors.New("undeliverable")
+
+// outbox sends the deliveries persisted by Send with a pool of workers.
+// Every channel of a notification is delivered on its own, failed deliveries are retried
+// with exponential backoff and move to the dead letter status after maxAttempts.
+type outbox struct {
+	queue chan *db.AlarmDelivery
+	wake  chan struct{}
+
+	workers     int
+	batch       int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	lease       time.Duration
+}
+
+func NewOutbox() *outbox {
+	o := &outbox{
+		wake:        make(chan struct{}, 1),
+		workers:     econf.GetInt("alarm.outbox.workers"),
+		maxAttempts: econf.GetInt("alarm.outbox.maxAttempts"),
+		backoff:     econf.GetDuration("alarm.outbox.backoff"),
+		maxBackoff:  econf.GetDuration("alarm.outbox.maxBackoff"),
+		lease:       econf.GetDuration("alarm.outbox.lease"),
+	}
+	if o.workers <= 0 {
+		o.workers = 4
+	}
+	if o.maxAttempts <= 0 {
+		o.maxAttempts = 8
+	}
+	if o.backoff <= 0 {
+		o.backoff = time.Second * 10
+	}
+	if o.maxBackoff <= 0 {
+		o.maxBackoff = time.Hour
+	}
+	if o.lease <= 0 {
+		o.lease = time.Minute * 5
+	}
+	tick := econf.GetDuration("alarm.outbox.tick")
+	if tick <= 0 {
+		tick = time.Second * 5
+	}
+	o.batch = o.workers * 10
+	o.queue = make(chan *db.AlarmDelivery, o.workers)
+	for i := 0; i < o.workers; i++ {
+		xgo.Go(o.work)
+	}
+	xgo.Go(func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-o.wake:
+			}
+			Noise.summarize(time.Now())
+			o.dispatch(time.Now())
+		}
+	})
+	return o
+}
+
+// Wake dispatches the due deliveries without waiting for the next tick
+func (o *outbox) Wake() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch claims the due deliveries and hands them to the workers,
+// claiming keeps the replicas sharing the database from sending a delivery twice,
+// and stops the noise reduction from merging notifications into a delivery being sent.
+func (o *outbox) dispatch(now time.Time) {
+	due, err := db.AlarmDeliveryDue(now.Unix(), o.batch)
+	if err != nil {
+		invoker.Logger.Error("outbox", elog.String("step", "AlarmDeliveryDue"), elog.String("error", err.Error()))
+		return
+	}
+	for _, d := range due {
+		leaseAt := now.Add(o.lease).Unix()
+		ok, errClaim := db.AlarmDeliveryClaim(invoker.Db, d.ID, d.NextAt, leaseAt, now.Unix())
+		if errClaim != nil || !ok {
+			continue
+		}
+		// reload the claimed delivery, the notifications merged into it before the claim are sent as well
+		claimed, errInfo := db.AlarmDeliveryInfo(invoker.Db, d.ID)
+		if errInfo != nil {
+			continue
+		}
+		o.queue <- &claimed
+	}
+	if len(due) == o.batch {
+		o.Wake()
+	}
+}
+
+func (o *outbox) work() {
+	for d := range o.queue {
+		o.deliver(d, time.Now())
+	}
+}
+
+// deliver sends the delivery and records the result of the attempt
+func (o *outbox) deliver(d *db.AlarmDelivery, now time.Time) {
+	err := sendDelivery(d)
+	attempts := d.Attempts + 1
+	ups := map[string]interface{}{"attempts": attempts, "last_error": ""}
+	switch {
+	case err == nil:
+		ups["status"] = db.DeliveryStatusSucceeded
+	case errors.Is(err, errUndeliverable) || errors.Is(err, gorm.ErrRecordNotFound) || attempts >= o.maxAttempts:
+		// the alarm or the channel is gone, or it has failed too many times
+		ups["status"] = db.DeliveryStatusDeadLetter
+		ups["last_error"] = err.Error()
+	default:
+		ups["next_at"] = now.Add(deliveryBackoff(attempts, o.backoff, o.maxBackoff)).Unix()
+		ups["last_error"] = err.Error()
+	}
+	if err != nil {
+		invoker.Logger.Warn("outbox", elog.Int("deliveryId", d.ID), elog.Int("attempts", attempts), elog.String("error", err.Error()))
+	}
+	if errUpdate := db.AlarmDeliveryUpdate(invoker.Db, d.ID, ups); errUpdate != nil {
+		invoker.Logger.Error("outbox", elog.String("step", "AlarmDeliveryUpdate"), elog.String("error", errUpdate.Error()))
+		return
+	}
+	if errRefresh := RefreshHistoryDelivery(d.HistoryId); errRefresh != nil {
+		invoker.Logger.Error("outbox", elog.String("step", "RefreshHistoryDelivery"), elog.String("error", errRefresh.Error()))
+	}
+}
+
+// deliveryBackoff returns the wait before the next attempt, doubled after every failure
+func deliveryBackoff(attempts int, backoff, maxBackoff time.Duration) time.Duration {
+	wait := backoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	if wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+func sendDelivery(d *db.AlarmDelivery) error {
+	var notification view.Notification
+	if err := json.Unmarshal([]byte(d.Notification), &notification); err != nil {
+		return errors.Wrapf(errUndeliverable, "invalid notification: %s", err)
+	}
+	alarmObj, err := db.AlarmInfo(invoker.Db, d.AlarmId)
+	if err != nil {
+		return errors.Wrap(err, "alarm")
+	}
+	channelInfo, err := db.AlarmChannelInfo(invoker.Db, d.ChannelId)
+	if err != nil {
+		return errors.Wrap(err, "channel")
+	}
+	channelInstance, err := push.Instance(channelInfo.Typ)
+	if err != nil {
+		return errors.Wrapf(errUndeliverable, "channel: %s", err)
+	}
+	if notification.Status == push.StatusFiring && push.CallbackEnabled() {
+		// the buttons of the message act on the incident open when it is sent
+		inc, ok, errInc := openIncident(d.AlarmId)
+		if errInc != nil {
+			return errors.Wrap(errInc, "incident")
+		}
+		if ok {
+			if notification.CommonAnnotations == nil {
+				notification.CommonAnnotations = make(map[string]string)
+			}
+			notification.CommonAnnotations[push.AnnotationIncident] = strconv.Itoa(inc.opened.ID)
+		}
+	}
+	return channelInstance.Send(notification, &alarmObj, &channelInfo, d.Log)
+}
+
+// RefreshHistoryDelivery updates the channel results of the history with its deliveries,
+// the history is pushed when all of them have succeeded or have been summarized.
+// The histories merged into its deliveries within the group wait are refreshed with it.
+func RefreshHistoryDelivery(historyId int) error {
+	history, err := db.AlarmHistoryInfo(invoker.Db, historyId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = refreshHistoryDelivery(&history); err != nil {
+		return err
+	}
+	conds := egorm.Conds{}
+	conds["group_id"] = historyId
+	merged, err := db.AlarmHistoryList(conds)
+	if err != nil {
+		return err
+	}
+	for _, h := range merged {
+		if err = refreshHistoryDelivery(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshHistoryDelivery updates the channel results of the history,
+// the channels whose notifications are merged into the group take the results of the deliveries of the group.
+func refreshHistoryDelivery(history *db.AlarmHistory) error {
+	historyIds := []int{history.ID}
+	if history.GroupId != 0 {
+		historyIds = append(historyIds, history.GroupId)
+	}
+	conds := egorm.Conds{}
+	conds["history_id"] = egorm.Cond{Op: "in", Val: historyIds}
+	deliveries, err := db.AlarmDeliveryList(conds)
+	if err != nil {
+		return err
+	}
+	own := make(map[int]bool)
+	for _, d := range deliveries {
+		if d.HistoryId == history.ID {
+			own[d.ChannelId] = true
+		}
+	}
+	results := make(db.ChannelResults, 0, len(deliveries))
+	pushed := 1
+	for _, d := range deliveries {
+		if d.HistoryId != history.ID && own[d.ChannelId] {
+			continue
+		}
+		result := db.ChannelResult{
+			ChannelId:  d.ChannelId,
+			Ok:         d.Status == db.DeliveryStatusSucceeded || d.Status == db.DeliveryStatusSummarized,
+			Error:      d.LastError,
+			DeliveryId: d.ID,
+			Status:     d.Status,
+			Attempts:   d.Attempts,
+		}
+		if channelInfo, errChannel := db.AlarmChannelInfo(invoker.Db, d.ChannelId); errChannel == nil {
+			result.Name, result.Typ = channelInfo.Name, channelInfo.Typ
+		}
+		if !result.Ok {
+			pushed = 0
+		}
+		results = append(results, result)
+	}
+	return db.AlarmHistoryUpdate(invoker.Db, history.ID, map[string]interface{}{"channel_results": results, "is_pushed": pushed})
+}
+
+// DeliveryReplay sends the delivery again from the first attempt
+func DeliveryReplay(d *db.AlarmDelivery) error {
+	if d.Status == db.DeliveryStatusSucceeded {
+		return errors.New("the delivery has succeeded")
+	}
+	if d.Status == db.DeliveryStatusSummarized {
+		return errors.New("the delivery has been summarized")
+	}
+	ups := map[string]interface{}{
+		"status":   db.DeliveryStatusPending,
+		"attempts": 0,
+		"next_at":  time.Now().Unix(),
+	}
+	if err := db.AlarmDeliveryUpdate(invoker.Db, d.ID, ups); err != nil {
+		return err
+	}
+	if err := RefreshHistoryDelivery(d.HistoryId); err != nil {
+		return err
+	}
+	Outbox.Wake()
+	return nil
+}
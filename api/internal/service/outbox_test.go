@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_deliveryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		backoff  time.Duration
+		want     time.Duration
+	}{
+		{name: "first retry", attempts: 1, backoff: 10 * time.Second, want: 10 * time.Second},
+		{name: "doubled", attempts: 3, backoff: 10 * time.Second, want: 40 * time.Second},
+		{name: "capped", attempts: 20, backoff: 10 * time.Second, want: time.Hour},
+		{name: "backoff above max", attempts: 1, backoff: 2 * time.Hour, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deliveryBackoff(tt.attempts, tt.backoff, time.Hour); got != tt.want {
+				t.Errorf("deliveryBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/rulefmt"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+const syncMsgMaxLen = 255
+
+var prometheusClient = &http.Client{Timeout: time.Second * 10}
+
+// reloader reloads the prometheus of a target once its rules stop changing for the debounce,
+// then checks the rules of the alarms written since the last reload are loaded.
+type reloader struct {
+	mu       sync.Mutex
+	targets  map[string]*reloadTarget
+	debounce time.Duration
+	maxDelay time.Duration
+	checks   int
+
+	reload func(target string) error
+	rules  func(target string) (map[string]prometheusRule, error)
+	done   func(alarmId, status int, msg string)
+}
+
+type reloadTarget struct {
+	timer  *time.Timer
+	first  time.Time
+	reload bool           // false when prometheus-operator reloads prometheus
+	alarms map[int]string // alarm id to the name of its alerting rule
+}
+
+// prometheusRule is an alerting rule returned by /api/v1/rules
+type prometheusRule struct {
+	Name      string `json:"name"`
+	Health    string `json:"health"`
+	LastError string `json:"lastError"`
+}
+
+func newReloader() *reloader {
+	r := &reloader{
+		targets:  make(map[string]*reloadTarget),
+		debounce: econf.GetDuration("alarm.reload.debounce"),
+		maxDelay: econf.GetDuration("alarm.reload.maxDelay"),
+		checks:   econf.GetInt("alarm.reload.checks"),
+		reload:   prometheusReload,
+		rules:    prometheusRules,
+		done:     ruleSyncDone,
+	}
+	if r.debounce <= 0 {
+		r.debounce = time.Second * 5
+	}
+	if r.maxDelay <= 0 {
+		r.maxDelay = time.Minute
+	}
+	if r.checks <= 0 {
+		r.checks = 3
+	}
+	return r
+}
+
+// Reload schedules the reload of the target, the rule of the alarm is checked after it, alarmObj is nil for deleted rules.
+// Prometheus is not reloaded when reload is false, the rules are still checked.
+func (r *reloader) Reload(target string, alarmObj *db.Alarm, reload bool) {
+	if target == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.targets[target]
+	if !ok {
+		t = &reloadTarget{first: time.Now(), alarms: make(map[int]string)}
+		t.timer = time.AfterFunc(r.debounce, func() { r.fire(target) })
+		r.targets[target] = t
+	} else if time.Since(t.first) < r.maxDelay {
+		t.timer.Reset(r.debounce)
+	}
+	t.reload = t.reload || reload
+	if alarmObj != nil {
+		t.alarms[alarmObj.ID] = alarmObj.AlertUniqueName()
+	}
+}
+
+func (r *reloader) fire(target string) {
+	r.mu.Lock()
+	t := r.targets[target]
+	delete(r.targets, target)
+	r.mu.Unlock()
+	if t == nil {
+		return
+	}
+	var (
+		err    error
+		loaded map[string]prometheusRule
+	)
+	if t.reload {
+		err = r.reload(target)
+	}
+	for i := 0; err == nil && len(t.alarms) > 0 && i < r.checks; i++ {
+		if i > 0 {
+			// prometheus-operator and the config reloaders take a while
+			time.Sleep(r.debounce)
+		}
+		if loaded, err = r.rules(target); err == nil && rulesLoaded(t.alarms, loaded) {
+			break
+		}
+	}
+	if err != nil {
+		invoker.Logger.Error("reloader", elog.String("target", target), elog.String("error", err.Error()))
+	}
+	for id, name := range t.alarms {
+		status, msg := ruleSyncResult(name, loaded, err)
+		r.done(id, status, msg)
+	}
+}
+
+func rulesLoaded(alarms map[int]string, loaded map[string]prometheusRule) bool {
+	for _, name := range alarms {
+		if _, ok := loaded[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleSyncResult returns the sync status of the alarm with the loaded rules of prometheus
+func ruleSyncResult(name string, loaded map[string]prometheusRule, err error) (int, string) {
+	if err != nil {
+		return db.AlarmSyncStatusFailed, syncMsg(err.Error())
+	}
+	rule, ok := loaded[name]
+	if !ok {
+		return db.AlarmSyncStatusFailed, "the rule is not loaded by prometheus"
+	}
+	if rule.Health == "err" {
+		return db.AlarmSyncStatusFailed, syncMsg("rule evaluation failed: " + rule.LastError)
+	}
+	return db.AlarmSyncStatusSynced, ""
+}
+
+func syncMsg(msg string) string {
+	if len(msg) > syncMsgMaxLen {
+		return msg[:syncMsgMaxLen]
+	}
+	return msg
+}
+
+func ruleSyncDone(alarmId, status int, msg string) {
+	ups := map[string]interface{}{"sync_status": status, "sync_msg": msg, "sync_at": time.Now().Unix()}
+	if err := db.AlarmUpdate(invoker.Db, alarmId, ups); err != nil {
+		invoker.Logger.Error("reloader", elog.Int("alarmId", alarmId), elog.String("step", "AlarmUpdate"), elog.String("error", err.Error()))
+	}
+}
+
+// ruleSyncStatus is the sync status of the alarms just written to the rule store of the instance
+func ruleSyncStatus(instance db.BaseInstance) int {
+	if instance.PrometheusTarget == "" {
+		return db.AlarmSyncStatusUnknown
+	}
+	return db.AlarmSyncStatusPending
+}
+
+func prometheusReload(target string) error {
+	resp, err := prometheusClient.Post(strings.TrimSuffix(target, "/")+"/-/reload", "text/html;charset=utf-8", nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("reload failed, status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// prometheusRules returns the alerting rules loaded by prometheus, keyed by their names
+func prometheusRules(target string) (map[string]prometheusRule, error) {
+	resp, err := prometheusClient.Get(strings.TrimSuffix(target, "/") + "/api/v1/rules?type=alert")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var res struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Groups []struct {
+				Rules []prometheusRule `json:"rules"`
+			} `json:"groups"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, errors.Wrapf(err, "rules api, status %d", resp.StatusCode)
+	}
+	if res.Status != "success" {
+		return nil, errors.Errorf("rules api: %s", res.Error)
+	}
+	rules := make(map[string]prometheusRule)
+	for _, g := range res.Data.Groups {
+		for _, rule := range g.Rules {
+			rules[rule.Name] = rule
+		}
+	}
+	return rules, nil
+}
+
+// ValidatePrometheusRule checks the rule file the same way as prometheus loads it, including the promql of the expressions
+func ValidatePrometheusRule(rule string) error {
+	_, errs := rulefmt.Parse([]byte(rule))
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("invalid prometheus rule: %s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+func TestValidatePrometheusRule(t *testing.T) {
+	obj := &db.Alarm{Uuid: "a-b", Interval: 1}
+	tests := []struct {
+		name    string
+		rule    string
+		wantErr bool
+	}{
+		{name: "generated", rule: fmt.Sprintf(prometheusRuleTemplate, obj.AlertUniqueName(), `avg_over_time(m{uuid="a-b"}[1m])>0.5`, obj.AlertInterval())},
+		{name: "invalid promql", rule: fmt.Sprintf(prometheusRuleTemplate, obj.AlertUniqueName(), `avg_over_time(m[1m]>`, obj.AlertInterval()), wantErr: true},
+		{name: "unknown field", rule: "groups:\n- name: default\n  rule: []", wantErr: true},
+		{name: "invalid yaml", rule: "groups: [", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePrometheusRule(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePrometheusRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ruleSyncResult(t *testing.T) {
+	loaded := map[string]prometheusRule{
+		"ok":  {Name: "ok", Health: "ok"},
+		"bad": {Name: "bad", Health: "err", LastError: "many-to-many matching not allowed"},
+	}
+	tests := []struct {
+		name       string
+		rule       string
+		err        error
+		wantStatus int
+	}{
+		{name: "synced", rule: "ok", wantStatus: db.AlarmSyncStatusSynced},
+		{name: "missing", rule: "other", wantStatus: db.AlarmSyncStatusFailed},
+		{name: "unhealthy", rule: "bad", wantStatus: db.AlarmSyncStatusFailed},
+		{name: "reload failed", rule: "ok", err: errors.New("connection refused"), wantStatus: db.AlarmSyncStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := ruleSyncResult(tt.rule, loaded, tt.err); got != tt.wantStatus {
+				t.Errorf("ruleSyncResult() = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_reloader_debounce(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reloads int
+		results = make(map[int]int)
+		done    = make(chan struct{}, 2)
+	)
+	r := &reloader{
+		targets:  make(map[string]*reloadTarget),
+		debounce: 20 * time.Millisecond,
+		maxDelay: time.Second,
+		checks:   1,
+		reload: func(target string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			reloads++
+			return nil
+		},
+		rules: func(target string) (map[string]prometheusRule, error) {
+			return map[string]prometheusRule{"a": {Name: "a", Health: "ok"}}, nil
+		},
+		done: func(alarmId, status int, msg string) {
+			mu.Lock()
+			results[alarmId] = status
+			mu.Unlock()
+			done <- struct{}{}
+		},
+	}
+	r.Reload("http://prometheus:9090", &db.Alarm{BaseModel: db.BaseModel{ID: 1}, Uuid: "a"}, true)
+	r.Reload("http://prometheus:9090", &db.Alarm{BaseModel: db.BaseModel{ID: 2}, Uuid: "b"}, true)
+	r.Reload("", &db.Alarm{BaseModel: db.BaseModel{ID: 3}, Uuid: "c"}, true)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("reloader did not finish")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if reloads != 1 {
+		t.Errorf("reloads = %d, want 1", reloads)
+	}
+	want := map[int]int{1: db.AlarmSyncStatusSynced, 2: db.AlarmSyncStatusFailed}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+}
+
+func Test_prometheusRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/rules" || r.URL.Query().Get("type") != "alert" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"groups":[{"name":"default","rules":[{"name":"a_b","health":"ok","type":"alerting"}]}]}}`))
+	}))
+	defer ts.Close()
+	rules, err := prometheusRules(ts.URL + "/")
+	if err != nil {
+		t.Fatalf("prometheusRules() error = %v", err)
+	}
+	if rules["a_b"].Health != "ok" {
+		t.Errorf("prometheusRules() = %v", rules)
+	}
+}
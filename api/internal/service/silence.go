@@ -0,0 +1,64 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// SilenceValidate checks the time window and the schedule of a silence
+func SilenceValidate(req view.ReqAlarmSilenceCreate) error {
+	if req.EndsAt <= req.StartsAt {
+		return errors.New("endsAt should be after startsAt")
+	}
+	if req.EndsAt <= time.Now().Unix() {
+		return errors.New("endsAt should be in the future")
+	}
+	if req.Cron != "" {
+		if _, err := cron.ParseStandard(req.Cron); err != nil {
+			return errors.Wrap(err, "invalid cron")
+		}
+		if req.Duration <= 0 {
+			return errors.New("duration of recurring silences should above zero")
+		}
+	}
+	return nil
+}
+
+// silenceActive reports whether the silence is in effect at the time,
+// a recurring silence is in effect when its schedule fired within the last duration.
+func silenceActive(s *db.AlarmSilence, now time.Time) bool {
+	if now.Unix() < s.StartsAt || now.Unix() >= s.EndsAt {
+		return false
+	}
+	if s.Cron == "" {
+		return true
+	}
+	schedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return false
+	}
+	return !schedule.Next(now.Add(-time.Duration(s.Duration) * time.Second)).After(now)
+}
+
+// MatchedSilence returns the active silence of the alarm, nil when it is not silenced
+func MatchedSilence(alarmObj *db.Alarm, tid, iid int, now time.Time) (*db.AlarmSilence, error) {
+	conds := egorm.Conds{}
+	conds["starts_at"] = egorm.Cond{Op: "<=", Val: now.Unix()}
+	conds["ends_at"] = egorm.Cond{Op: ">", Val: now.Unix()}
+	silences, err := db.AlarmSilenceList(conds)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range silences {
+		if s.Match(alarmObj, tid, iid) && silenceActive(s, now) {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
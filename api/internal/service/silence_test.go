@@ -0,0 +1,79 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func Test_silenceActive(t *testing.T) {
+	// Saturday 2022-06-04 03:00:00 local time
+	now := time.Date(2022, 6, 4, 3, 0, 0, 0, time.Local)
+	start, end := now.Add(-24*time.Hour).Unix(), now.Add(24*time.Hour).Unix()
+	tests := []struct {
+		name    string
+		silence db.AlarmSilence
+		want    bool
+	}{
+		{name: "window", silence: db.AlarmSilence{StartsAt: start, EndsAt: end}, want: true},
+		{name: "expired", silence: db.AlarmSilence{StartsAt: start, EndsAt: now.Unix()}, want: false},
+		{name: "not started", silence: db.AlarmSilence{StartsAt: now.Unix() + 1, EndsAt: end}, want: false},
+		{name: "recurring in window", silence: db.AlarmSilence{StartsAt: start, EndsAt: end, Cron: "0 2 * * 6", Duration: 7200}, want: true},
+		{name: "recurring out of window", silence: db.AlarmSilence{StartsAt: start, EndsAt: end, Cron: "0 2 * * 6", Duration: 3600}, want: false},
+		{name: "recurring other day", silence: db.AlarmSilence{StartsAt: start, EndsAt: end, Cron: "0 2 * * 0", Duration: 7200}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := silenceActive(&tt.silence, now); got != tt.want {
+				t.Errorf("silenceActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlarmSilence_Match(t *testing.T) {
+	alarmObj := &db.Alarm{BaseModel: db.BaseModel{ID: 1}, Tags: db.String2String{"team": "infra", "env": "prod"}}
+	tests := []struct {
+		name    string
+		silence db.AlarmSilence
+		want    bool
+	}{
+		{name: "alarm", silence: db.AlarmSilence{AlarmId: 1}, want: true},
+		{name: "other alarm", silence: db.AlarmSilence{AlarmId: 2}, want: false},
+		{name: "table", silence: db.AlarmSilence{Tid: 10}, want: true},
+		{name: "instance", silence: db.AlarmSilence{Iid: 101}, want: false},
+		{name: "tags", silence: db.AlarmSilence{Tags: db.String2String{"team": "infra"}}, want: true},
+		{name: "tags mismatch", silence: db.AlarmSilence{Tags: db.String2String{"team": "infra", "env": "test"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.Match(alarmObj, 10, 100); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSilenceValidate(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name    string
+		req     view.ReqAlarmSilenceCreate
+		wantErr bool
+	}{
+		{name: "ok", req: view.ReqAlarmSilenceCreate{StartsAt: now, EndsAt: now + 3600}},
+		{name: "ends before starts", req: view.ReqAlarmSilenceCreate{StartsAt: now, EndsAt: now - 1}, wantErr: true},
+		{name: "already ended", req: view.ReqAlarmSilenceCreate{StartsAt: now - 7200, EndsAt: now - 3600}, wantErr: true},
+		{name: "invalid cron", req: view.ReqAlarmSilenceCreate{StartsAt: now, EndsAt: now + 3600, Cron: "every day", Duration: 60}, wantErr: true},
+		{name: "cron without duration", req: view.ReqAlarmSilenceCreate{StartsAt: now, EndsAt: now + 3600, Cron: "0 2 * * *"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SilenceValidate(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("SilenceValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -19,6 +19,11 @@ var (
 	ErrAlarmRuleStoreIsClosed      = &kerror.KError{Code: 10105, Message: "Alarm rule store is closed"}
 	ErrClusterNameEmpty            = &kerror.KError{Code: 10106, Message: "Error: cluster name is empty"}
 	ErrQueryIntervalLimit          = &kerror.KError{Code: 10107, Message: "The current query time exceeds the configured limit"}
+	ErrIngestBufferFull            = &kerror.KError{Code: 10108, Message: "Ingest buffer is full, retry later"}
+	ErrIngestTableNotSupported     = &kerror.KError{Code: 10109, Message: "Ingestion is not supported for self-built tables"}
+	ErrDatasourceNotSupported      = &kerror.KError{Code: 10110, Message: "This operation is not supported by the current datasource"}
+	ErrIngestRateLimited           = &kerror.KError{Code: 10111, Message: "Ingest rate limit exceeded"}
+	ErrIngestBatchTooLarge         = &kerror.KError{Code: 10112, Message: "Ingest batch is larger than the burst of the token"}
 
 	ErrBigdataRTSyncTypeNotSupported         = &kerror.KError{Code: 10201, Message: "This type of synchronization operation is not supported"}
 	ErrBigdataRTSyncOperatorTypeNotSupported = &kerror.KError{Code: 10202, Message: "This type of node operation is not supported "}
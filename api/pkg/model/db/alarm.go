@@ -1,6 +1,8 @@
 package db
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -17,6 +19,23 @@ const (
 	AlarmModeDefault int = iota
 	AlarmModeWithInSQL
 	AlarmModeAggregation
+	AlarmModeAnomaly // the number of the matched logs is compared with its baseline of the previous days or weeks, native rule store only
+)
+
+const (
+	AnomalySeasonDay int = iota
+	AnomalySeasonWeek
+)
+
+const (
+	AnomalyBandStddev  int = iota // Threshold is k of the standard deviations
+	AnomalyBandPercent            // Threshold is the percent of the expected value
+)
+
+const (
+	AnomalyDirectionBoth int = iota
+	AnomalyDirectionAbove
+	AnomalyDirectionBelow
 )
 
 const (
@@ -29,6 +48,22 @@ const (
 	AlarmStatusClose = iota + 1
 	AlarmStatusOpen
 	AlarmStatusFiring
+	AlarmStatusStale // the table stopped receiving logs, the alarm is not evaluated nor notified until it recovers
+)
+
+// statuses of the alarm histories besides the firing and resolved notifications
+const (
+	AlarmHistoryStatusAcknowledged = "acknowledged"
+	AlarmHistoryStatusEscalated    = "escalated"
+	AlarmHistoryStatusStale        = "stale"
+)
+
+// sync status of the prometheus rule of an alarm
+const (
+	AlarmSyncStatusUnknown = iota // not verified, such as the alarms without the prometheus target
+	AlarmSyncStatusPending        // written to the rule store, waiting for the reload
+	AlarmSyncStatusSynced         // loaded by prometheus
+	AlarmSyncStatusFailed
 )
 
 func (m *Alarm) TableName() string {
@@ -56,23 +91,36 @@ type (
 	Alarm struct {
 		BaseModel
 
-		Uid           int           `gorm:"column:uid;type:int(11)" json:"uid"`                                            // uid of alarm operator
-		Tid           int           `gorm:"column:tid;type:int(11)" json:"tid"`                                            // table id
-		Uuid          string        `gorm:"column:uuid;type:varchar(128);NOT NULL" json:"uuid"`                            // foreign key
-		Name          string        `gorm:"column:name;type:varchar(128);NOT NULL" json:"alarmName"`                       // name of an alarm
-		Desc          string        `gorm:"column:desc;type:varchar(255);NOT NULL" json:"desc"`                            // description
-		Interval      int           `gorm:"column:interval;type:int(11)" json:"interval"`                                  // interval second between alarm
-		Unit          int           `gorm:"column:unit;type:int(11)" json:"unit"`                                          // 0 m 1 s 2 h 3 d 4 w 5 y
-		AlertRule     string        `gorm:"column:alert_rule;type:text" json:"alertRule"`                                  // prometheus alert rule
-		View          string        `gorm:"column:view;type:text" json:"view"`                                             // view table ddl
-		ViewTableName string        `gorm:"column:view_table_name;type:varchar(255)" json:"viewTableName"`                 // name of view table
-		Tags          String2String `gorm:"column:tag;type:text" json:"tag"`                                               // tags
-		Status        int           `gorm:"column:status;type:int(11)" json:"status"`                                      // status
-		RuleStoreType int           `gorm:"column:rule_store_type;type:int(11)" db:"rule_store_type" json:"ruleStoreType"` // ruleStoreType
-		ChannelIds    Ints          `gorm:"column:channel_ids;type:varchar(255);NOT NULL" json:"channelIds"`               // channel of an alarm
-		NoDataOp      int           `gorm:"column:no_data_op;type:int(11)" db:"no_data_op" json:"noDataOp"`                // noDataOp 0 nodata 1 ok 2 alert
-		Mode          int           `gorm:"column:mode;type:int(11)" json:"mode"`                                          // 0 m 1 s 2 h 3 d 4 w 5 y
-		Level         int           `gorm:"column:level;type:int(11)" json:"level"`                                        // 0 m 1 s 2 h 3 d 4 w 5 y
+		Uid              int           `gorm:"column:uid;type:int(11)" json:"uid"`                                            // uid of alarm operator
+		Tid              int           `gorm:"column:tid;type:int(11)" json:"tid"`                                            // table id
+		Uuid             string        `gorm:"column:uuid;type:varchar(128);NOT NULL" json:"uuid"`                            // foreign key
+		Name             string        `gorm:"column:name;type:varchar(128);NOT NULL" json:"alarmName"`                       // name of an alarm
+		Desc             string        `gorm:"column:desc;type:varchar(255);NOT NULL" json:"desc"`                            // description
+		Interval         int           `gorm:"column:interval;type:int(11)" json:"interval"`                                  // interval second between alarm
+		Unit             int           `gorm:"column:unit;type:int(11)" json:"unit"`                                          // 0 m 1 s 2 h 3 d 4 w 5 y
+		AlertRule        string        `gorm:"column:alert_rule;type:text" json:"alertRule"`                                  // prometheus alert rule
+		View             string        `gorm:"column:view;type:text" json:"view"`                                             // view table ddl
+		ViewTableName    string        `gorm:"column:view_table_name;type:varchar(255)" json:"viewTableName"`                 // name of view table
+		Tags             String2String `gorm:"column:tag;type:text" json:"tag"`                                               // tags
+		Status           int           `gorm:"column:status;type:int(11)" json:"status"`                                      // status
+		RuleStoreType    int           `gorm:"column:rule_store_type;type:int(11)" db:"rule_store_type" json:"ruleStoreType"` // ruleStoreType
+		ChannelIds       Ints          `gorm:"column:channel_ids;type:varchar(255);NOT NULL" json:"channelIds"`               // channel of an alarm
+		NoDataOp         int           `gorm:"column:no_data_op;type:int(11)" db:"no_data_op" json:"noDataOp"`                // noDataOp 0 nodata 1 ok 2 alert
+		Mode             int           `gorm:"column:mode;type:int(11)" json:"mode"`                                          // 0 m 1 s 2 h 3 d 4 w 5 y
+		Level            int           `gorm:"column:level;type:int(11)" json:"level"`                                        // 0 m 1 s 2 h 3 d 4 w 5 y
+		ForDuration      int           `gorm:"column:for_duration;type:int(11)" json:"forDuration"`                           // seconds pending before firing, native rule store only
+		FiringTemplate   string        `gorm:"column:firing_template;type:text" json:"firingTemplate"`                        // message template of firing alerts, default template of the channel locale when empty
+		ResolvedTemplate string        `gorm:"column:resolved_template;type:text" json:"resolvedTemplate"`                    // message template of resolved alerts
+		GroupBy          Strings       `gorm:"column:group_by;type:text" json:"groupBy"`                                      // analysis fields, one alert series per group of values
+		SyncStatus       int           `gorm:"column:sync_status;type:int(11)" json:"syncStatus"`                             // sync status of the prometheus rule, 0 unknown 1 pending 2 synced 3 failed
+		SyncMsg          string        `gorm:"column:sync_msg;type:varchar(255)" json:"syncMsg"`                              // reason of the failed sync
+		SyncAt           int64         `gorm:"column:sync_at;type:bigint(20)" json:"syncAt"`                                  // time of the last sync check
+		EscalationId     int           `gorm:"column:escalation_id;type:int(11)" json:"escalationId"`                         // escalation policy of the unacknowledged alerts, 0 means none
+		OncallId         int           `gorm:"column:oncall_id;type:int(11)" json:"oncallId"`                                 // on-call schedule whose current user is mentioned, 0 means none
+		Anomaly          AlarmAnomaly  `gorm:"column:anomaly;type:text" json:"anomaly"`                                       // baseline and band of the anomaly mode
+		TemplateId       int           `gorm:"column:template_id;type:int(11);index" json:"templateId"`                       // template the alarm is derived from, 0 means none
+		TemplateVars     String2String `gorm:"column:template_vars;type:text" json:"templateVars"`                            // variables of the template applied to the table
+		Freshness        int           `gorm:"column:freshness;type:int(11)" json:"freshness"`                                // minutes without logs before the table is stale, 0 disables the check
 
 		User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
 	}
@@ -93,37 +141,136 @@ type (
 	AlarmCondition struct {
 		BaseModel
 
-		AlarmId        int `gorm:"column:alarm_id;type:int(11)" json:"alarmId"`              // alarm id
-		SetOperatorTyp int `gorm:"column:set_operator_typ;type:int(11);NOT NULL" json:"typ"` // 0 WHEN 1 AND 2 OR
-		SetOperatorExp int `gorm:"column:set_operator_exp;type:int(11);NOT NULL" json:"exp"` // 0 avg 1 min 2 max 3 sum 4 count
-		Cond           int `gorm:"column:cond;type:int(11)" json:"cond"`                     // 0 above 1 below 2 outside range 3 within range
-		Val1           int `gorm:"column:val_1;type:int(11)" json:"val1"`                    // 基准值/最小值
-		Val2           int `gorm:"column:val_2;type:int(11)" json:"val2"`                    // 最大值
+		AlarmId        int     `gorm:"column:alarm_id;type:int(11)" json:"alarmId"`              // alarm id
+		SetOperatorTyp int     `gorm:"column:set_operator_typ;type:int(11);NOT NULL" json:"typ"` // 0 WHEN 1 AND 2 OR
+		SetOperatorExp int     `gorm:"column:set_operator_exp;type:int(11);NOT NULL" json:"exp"` // 0 last 1 min 2 max 3 sum 4 count 5 avg
+		Cond           int     `gorm:"column:cond;type:int(11)" json:"cond"`                     // 0 above 1 below 2 outside range 3 within range 4 rate above 5 rate below 6 increase above 7 decrease above
+		Val1           float64 `gorm:"column:val_1;type:double" json:"val1"`                     // 基准值/最小值, per second for rates, percent for changes
+		Val2           float64 `gorm:"column:val_2;type:double" json:"val2"`                     // 最大值
 	}
 
 	// AlarmChannel 告警渠道
 	AlarmChannel struct {
 		BaseModel
 
-		Name string `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"` // 告警渠道名称
-		Key  string `gorm:"column:key;type:text" json:"key"`                    // 关键信息
-		Typ  int    `gorm:"column:typ;type:int(11)" json:"typ"`                 // 告警类型：0 dd
-		Uid  int    `gorm:"column:uid;type:int(11)" json:"uid"`                 // 操作人
+		Name   string `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"` // 告警渠道名称
+		Key    string `gorm:"column:key;type:text" json:"key"`                    // 关键信息
+		Locale string `gorm:"column:locale;type:varchar(16)" json:"locale"`       // locale of default message templates, zh-CN or en-US
+		Typ    int    `gorm:"column:typ;type:int(11)" json:"typ"`                 // 告警类型：0 dd
+		Uid    int    `gorm:"column:uid;type:int(11)" json:"uid"`                 // 操作人
+
+		RateLimit  int `gorm:"column:rate_limit;type:int(11)" json:"rateLimit"`   // notifications sent in the rate window, 0 falls back to alarm.noise.rateLimit
+		RateWindow int `gorm:"column:rate_window;type:int(11)" json:"rateWindow"` // seconds, 0 falls back to alarm.noise.rateWindow
 	}
 
 	// AlarmHistory 告警渠道
 	AlarmHistory struct {
 		BaseModel
 
-		AlarmId  int `gorm:"column:alarm_id;type:int(11)" json:"alarmId"`   // alarm id
-		IsPushed int `gorm:"column:is_pushed;type:int(11)" json:"isPushed"` // alarm id
+		AlarmId        int            `gorm:"column:alarm_id;type:int(11)" json:"alarmId"`            // alarm id
+		IsPushed       int            `gorm:"column:is_pushed;type:int(11)" json:"isPushed"`          // alarm id
+		SilenceId      int            `gorm:"column:silence_id;type:int(11)" json:"silenceId"`        // id of the silence that suppressed the notification
+		InhibitId      int            `gorm:"column:inhibit_id;type:int(11)" json:"inhibitId"`        // id of the firing alarm that inhibited the notification
+		GroupId        int            `gorm:"column:group_id;type:int(11)" json:"groupId"`            // id of the history whose deliveries the notification is merged into
+		Status         string         `gorm:"column:status;type:varchar(16)" json:"status"`           // firing or resolved
+		StartsAt       int64          `gorm:"column:starts_at;type:bigint(20)" json:"startsAt"`       // unix seconds when the alert started
+		EndsAt         int64          `gorm:"column:ends_at;type:bigint(20)" json:"endsAt"`           // unix seconds when the alert resolved
+		Labels         String2String  `gorm:"column:labels;type:text" json:"labels"`                  // labels of the alert
+		Annotations    String2String  `gorm:"column:annotations;type:text" json:"annotations"`        // annotations of the alert
+		Value          string         `gorm:"column:value;type:varchar(64)" json:"value"`             // the value that triggered the alert
+		Log            string         `gorm:"column:log;type:text" json:"log"`                        // sample log
+		ChannelResults ChannelResults `gorm:"column:channel_results;type:text" json:"channelResults"` // delivery result of each channel
+		Uid            int            `gorm:"column:uid;type:int(11)" json:"uid"`                     // user who acknowledged or resolved the alert
+		Operator       string         `gorm:"column:operator;type:varchar(128)" json:"operator"`      // user of the interactive message who acknowledged or resolved the alert, such as slack:U012AB3CD
+		Step           int            `gorm:"column:escalation_step;type:int(11)" json:"step"`        // escalation steps notified, counted on the firing history that opens the incident
 	}
 )
 
+// ChannelResult is the delivery result of a notification to a channel
+type ChannelResult struct {
+	ChannelId  int    `json:"channelId"`
+	Name       string `json:"name"`
+	Typ        int    `json:"typ"`
+	Ok         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DeliveryId int    `json:"deliveryId"`
+	Status     int    `json:"status"` // delivery status
+	Attempts   int    `json:"attempts"`
+}
+
+type ChannelResults []ChannelResult
+
+func (t ChannelResults) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *ChannelResults) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		in = []byte("[]")
+	}
+	return json.Unmarshal(in, t)
+}
+
+// AlarmAnomaly fires when the number of the matched logs of the interval leaves the band around its baseline,
+// which is the mean of the same window of the previous days or weeks
+type AlarmAnomaly struct {
+	Season    int     `json:"season"`    // 0 day 1 week
+	Periods   int     `json:"periods"`   // previous days or weeks of the baseline, default 7 days or 4 weeks
+	Band      int     `json:"band"`      // 0 standard deviations 1 percent
+	Threshold float64 `json:"threshold"` // k of the standard deviations, or the percent of the expected value
+	Direction int     `json:"direction"` // 0 both 1 above 2 below
+	MinVolume float64 `json:"minVolume"` // never fires when both the actual and the expected value are below it
+}
+
+func (t AlarmAnomaly) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *AlarmAnomaly) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		in = []byte("{}")
+	}
+	return json.Unmarshal(in, t)
+}
+
+// SeasonPeriods returns the length and the number of the previous periods of the baseline
+func (t AlarmAnomaly) SeasonPeriods() (time.Duration, int) {
+	if t.Season == AnomalySeasonWeek {
+		if t.Periods <= 0 {
+			return 7 * 24 * time.Hour, 4
+		}
+		return 7 * 24 * time.Hour, t.Periods
+	}
+	if t.Periods <= 0 {
+		return 24 * time.Hour, 7
+	}
+	return 24 * time.Hour, t.Periods
+}
+
+// Severity is the severity label of the notifications, in the values used by alertmanager
+func (m *Alarm) Severity() string {
+	switch m.Level {
+	case AlarmLevelKnow:
+		return "info"
+	case AlarmLevelFatal:
+		return "critical"
+	}
+	return "warning"
+}
+
 func (m *Alarm) AlertRuleName() string {
 	return fmt.Sprintf("cv-%s.yaml", m.Uuid)
 }
 
+// PrometheusRuleName is the name of the PrometheusRule resource of the alarm
+func (m *Alarm) PrometheusRuleName() string {
+	return fmt.Sprintf("cv-%s", m.Uuid)
+}
+
 func (m *Alarm) AlertViewName(database, table string) string {
 	return fmt.Sprintf("%s.%s_%s", database, table, m.AlertUniqueName())
 }
@@ -160,10 +307,38 @@ var UnitMap = map[int]UnitItem{
 	},
 }
 
+// GroupLabels returns the values of the group-by fields in the labels of an alert series, in the order of GroupBy
+func (m *Alarm) GroupLabels(labels map[string]string) []string {
+	res := make([]string, 0, len(m.GroupBy))
+	for _, field := range m.GroupBy {
+		label := GroupLabel(field)
+		if v, ok := labels[label]; ok {
+			res = append(res, fmt.Sprintf("%s=%s", label, v))
+		}
+	}
+	return res
+}
+
+// GroupLabel returns the label name of a group-by field, characters not allowed in prometheus label names are replaced with _
+func GroupLabel(field string) string {
+	b := []byte(field)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
 func (m *Alarm) AlertInterval() string {
 	return fmt.Sprintf("%d%s", m.Interval, UnitMap[m.Unit].Alias)
 }
 
+// AlertDuration is the evaluation interval of the alarm, also the time range of its data
+func (m *Alarm) AlertDuration() time.Duration {
+	return time.Duration(m.Interval) * UnitMap[m.Unit].Duration
+}
+
 func WhereConditionFromFilter(alarm *Alarm, filters []*AlarmFilter) (filter string) {
 	if alarm.Mode == AlarmModeAggregation {
 		return getWithSQL(filters)
@@ -221,6 +396,19 @@ func AlarmStatusUpdate(id int, status string) (err error) {
 	return
 }
 
+// AlarmStatusSwap moves the status of the alarm from one of the statuses to another,
+// it reports false when the status has been changed by others.
+func AlarmStatusSwap(db *gorm.DB, id int, from []int, to int) (ok bool, err error) {
+	var sql = "`id`=? AND `status` IN (?)"
+	var binds = []interface{}{id, from}
+	res := db.Model(Alarm{}).Where(sql, binds...).Update("status", to)
+	if err = res.Error; err != nil {
+		invoker.Logger.Error("alarm status swap error", zap.Error(err))
+		return
+	}
+	return res.RowsAffected == 1, nil
+}
+
 func AlarmInfo(db *gorm.DB, id int) (resp Alarm, err error) {
 	var sql = "`id`= ?"
 	var binds = []interface{}{id}
@@ -492,6 +680,29 @@ func AlarmHistoryList(conds egorm.Conds) (resp []*AlarmHistory, err error) {
 	return
 }
 
+// AlarmHistoryLast returns the latest history matching the conds, the zero history when there is none
+func AlarmHistoryLast(db *gorm.DB, conds egorm.Conds) (resp AlarmHistory, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmHistory{}).Where(sql, binds...).Order("id desc").Limit(1).Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm history last error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmHistoryEscalationClaim moves the escalation of the incident opened by the history from step to step+1,
+// it reports false when the step has been notified by others.
+func AlarmHistoryEscalationClaim(db *gorm.DB, id, step int) (ok bool, err error) {
+	var sql = "`id`=? AND `escalation_step`=?"
+	var binds = []interface{}{id, step}
+	res := db.Model(AlarmHistory{}).Where(sql, binds...).Update("escalation_step", step+1)
+	if err = res.Error; err != nil {
+		invoker.Logger.Error("alarm history escalation claim error", zap.Error(err))
+		return
+	}
+	return res.RowsAffected == 1, nil
+}
+
 func AlarmHistoryPage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmHistory) {
 	respList = make([]*AlarmHistory, 0)
 	if reqList.PageSize == 0 {
@@ -507,6 +718,25 @@ func AlarmHistoryPage(conds egorm.Conds, reqList *ReqPage) (total int64, respLis
 	return
 }
 
+type AlarmHistoryCount struct {
+	AlarmId int   `json:"alarmId"`
+	Count   int64 `json:"count"`
+}
+
+// AlarmHistoryCountByAlarm returns the number of histories of each alarm, most first
+func AlarmHistoryCountByAlarm(conds egorm.Conds, limit int) (resp []AlarmHistoryCount, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmHistory{}).Select("alarm_id, count(*) as count").Where(sql, binds...).Group("alarm_id").Order("count desc")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err = db.Scan(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm history count error", zap.Error(err))
+		return
+	}
+	return
+}
+
 func AlarmHistoryCreate(db *gorm.DB, data *AlarmHistory) (err error) {
 	if err = db.Model(AlarmHistory{}).Create(data).Error; err != nil {
 		invoker.Logger.Error("create releaseZone error", zap.Error(err))
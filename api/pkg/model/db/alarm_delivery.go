@@ -0,0 +1,164 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+)
+
+const (
+	DeliveryStatusPending = iota
+	DeliveryStatusSucceeded
+	DeliveryStatusDeadLetter
+	DeliveryStatusSuppressed // over the rate limit of the channel, waiting to be summarized
+	DeliveryStatusSummarized // counted into a summary delivery
+)
+
+func (m *AlarmDelivery) TableName() string {
+	return TableAlarmDelivery
+}
+
+// AlarmDelivery is a notification waiting in the outbox to be sent to one channel.
+// Failed deliveries are retried at NextAt until they move to the dead letter status.
+type AlarmDelivery struct {
+	BaseModel
+
+	AlarmId      int    `gorm:"column:alarm_id;type:int(11);index" json:"alarmId"`        // alarm id
+	HistoryId    int    `gorm:"column:history_id;type:int(11);index" json:"historyId"`    // alarm history id
+	ChannelId    int    `gorm:"column:channel_id;type:int(11)" json:"channelId"`          // alarm channel id
+	Notification string `gorm:"column:notification;type:mediumtext" json:"notification"`  // notification in json
+	Log          string `gorm:"column:log;type:text" json:"log"`                          // sample log
+	Status       int    `gorm:"column:status;type:tinyint(1);index" json:"status"`        // 0 pending 1 succeeded 2 dead letter 3 suppressed 4 summarized
+	Attempts     int    `gorm:"column:attempts;type:int(11)" json:"attempts"`             // number of sending attempts
+	NextAt       int64  `gorm:"column:next_at;type:bigint(20);index" json:"nextAt"`       // unix seconds of the next attempt
+	LastError    string `gorm:"column:last_error;type:text" json:"lastError"`             // error of the last attempt
+	GroupKey     string `gorm:"column:group_key;type:varchar(255);index" json:"groupKey"` // notifications of the same key are merged within the group wait
+	ClaimedAt    int64  `gorm:"column:claimed_at;type:bigint(20)" json:"claimedAt"`       // unix seconds of the last claim by a sender, 0 when it has never been claimed
+}
+
+func AlarmDeliveryInfo(db *gorm.DB, id int) (resp AlarmDelivery, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmDelivery{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm delivery info error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmDeliveryList(conds egorm.Conds) (resp []*AlarmDelivery, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmDelivery{}).Where(sql, binds...).Order("id").Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm delivery list error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmDeliveryDue returns the pending deliveries whose next attempt is due
+func AlarmDeliveryDue(now int64, limit int) (resp []*AlarmDelivery, err error) {
+	conds := egorm.Conds{}
+	conds["status"] = DeliveryStatusPending
+	conds["next_at"] = egorm.Cond{Op: "<=", Val: now}
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmDelivery{}).Where(sql, binds...).Order("next_at").Limit(limit).Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm delivery due error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmDeliveryPage return item list by pagination
+func AlarmDeliveryPage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmDelivery) {
+	respList = make([]*AlarmDelivery, 0)
+	if reqList.PageSize == 0 {
+		reqList.PageSize = 10
+	}
+	if reqList.Current == 0 {
+		reqList.Current = 1
+	}
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmDelivery{}).Where(sql, binds...).Order("id desc")
+	db.Count(&total)
+	db.Offset((reqList.Current - 1) * reqList.PageSize).Limit(reqList.PageSize).Find(&respList)
+	return
+}
+
+func AlarmDeliveryCreate(db *gorm.DB, data *AlarmDelivery) (err error) {
+	if err = db.Model(AlarmDelivery{}).Create(data).Error; err != nil {
+		invoker.Logger.Error("alarm delivery create error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmDeliveryUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmDelivery{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		invoker.Logger.Error("alarm delivery update error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmDeliveryClaim moves the next attempt of a pending delivery from nextAt to leaseAt and marks it claimed at now,
+// it reports false when the delivery has been claimed by others.
+// A claimed delivery that is never finished becomes due again at leaseAt, and no notification is merged into it any more.
+func AlarmDeliveryClaim(db *gorm.DB, id int, nextAt, leaseAt, now int64) (ok bool, err error) {
+	var sql = "`id`=? AND `status`=? AND `next_at`=?"
+	var binds = []interface{}{id, DeliveryStatusPending, nextAt}
+	res := db.Model(AlarmDelivery{}).Where(sql, binds...).Updates(map[string]interface{}{"next_at": leaseAt, "claimed_at": now})
+	if err = res.Error; err != nil {
+		invoker.Logger.Error("alarm delivery claim error", zap.Error(err))
+		return
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// AlarmDeliveryCount returns the number of the deliveries matching the conditions
+func AlarmDeliveryCount(conds egorm.Conds) (total int64, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmDelivery{}).Where(sql, binds...).Count(&total).Error; err != nil {
+		invoker.Logger.Error("alarm delivery count error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmDeliveryGrouping returns the delivery of the group to the channel that is still waiting for the group wait,
+// the claimed ones are being sent and are never returned
+func AlarmDeliveryGrouping(db *gorm.DB, groupKey string, channelId int, now int64) (resp AlarmDelivery, err error) {
+	var sql = "`group_key`=? AND `channel_id`=? AND `status`=? AND `attempts`=0 AND `claimed_at`=0 AND `next_at`>?"
+	var binds = []interface{}{groupKey, channelId, DeliveryStatusPending, now}
+	err = db.Model(AlarmDelivery{}).Where(sql, binds...).Order("id desc").First(&resp).Error
+	return
+}
+
+// AlarmDeliveryMerge replaces the notification of a delivery that is still waiting for the group wait,
+// it reports false when the delivery has become due or has been claimed.
+func AlarmDeliveryMerge(db *gorm.DB, id int, notification string, now int64) (ok bool, err error) {
+	var sql = "`id`=? AND `status`=? AND `attempts`=0 AND `claimed_at`=0 AND `next_at`>?"
+	var binds = []interface{}{id, DeliveryStatusPending, now}
+	res := db.Model(AlarmDelivery{}).Where(sql, binds...).Update("notification", notification)
+	if err = res.Error; err != nil {
+		invoker.Logger.Error("alarm delivery merge error", zap.Error(err))
+		return
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// AlarmDeliverySummarize moves the suppressed deliveries to the summarized status,
+// it returns the number of the ones moved by this call.
+func AlarmDeliverySummarize(db *gorm.DB, ids []int) (n int64, err error) {
+	var sql = "`id` IN (?) AND `status`=?"
+	var binds = []interface{}{ids, DeliveryStatusSuppressed}
+	res := db.Model(AlarmDelivery{}).Where(sql, binds...).Update("status", DeliveryStatusSummarized)
+	if err = res.Error; err != nil {
+		invoker.Logger.Error("alarm delivery summarize error", zap.Error(err))
+		return
+	}
+	return res.RowsAffected, nil
+}
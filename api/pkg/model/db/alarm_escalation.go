@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/ego-component/egorm"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+)
+
+func (m *AlarmEscalation) TableName() string {
+	return TableAlarmEscalation
+}
+
+// AlarmEscalation notifies more channels step by step while a firing alert is not acknowledged
+type AlarmEscalation struct {
+	BaseModel
+
+	Name  string          `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"` // name of the policy
+	Desc  string          `gorm:"column:desc;type:varchar(255)" json:"desc"`          // description
+	Steps EscalationSteps `gorm:"column:steps;type:text" json:"steps"`                // steps in the order they are notified
+	Uid   int             `gorm:"column:uid;type:int(11)" json:"uid"`                 // creator
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
+}
+
+// EscalationStep notifies its channels when the alert has not been acknowledged for After minutes since it started firing
+type EscalationStep struct {
+	After      int   `json:"after"`
+	ChannelIds []int `json:"channelIds"`
+}
+
+type EscalationSteps []EscalationStep
+
+func (t EscalationSteps) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *EscalationSteps) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		in = []byte("[]")
+	}
+	return json.Unmarshal(in, t)
+}
+
+func AlarmEscalationInfo(db *gorm.DB, id int) (resp AlarmEscalation, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmEscalation{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm escalation info error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmEscalationList(conds egorm.Conds) (resp []*AlarmEscalation, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmEscalation{}).Where(sql, binds...).Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm escalation list error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmEscalationPage return item list by pagination
+func AlarmEscalationPage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmEscalation) {
+	respList = make([]*AlarmEscalation, 0)
+	if reqList.PageSize == 0 {
+		reqList.PageSize = 10
+	}
+	if reqList.Current == 0 {
+		reqList.Current = 1
+	}
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmEscalation{}).Preload("User").Where(sql, binds...).Order("id desc")
+	db.Count(&total)
+	db.Offset((reqList.Current - 1) * reqList.PageSize).Limit(reqList.PageSize).Find(&respList)
+	return
+}
+
+func AlarmEscalationCreate(db *gorm.DB, data *AlarmEscalation) (err error) {
+	if err = db.Model(AlarmEscalation{}).Create(data).Error; err != nil {
+		invoker.Logger.Error("alarm escalation create error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmEscalationUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmEscalation{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		invoker.Logger.Error("alarm escalation update error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmEscalationDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmEscalation{}).Unscoped().Delete(&AlarmEscalation{}, id).Error; err != nil {
+		invoker.Logger.Error("alarm escalation delete error", zap.Error(err))
+		return
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+)
+
+const (
+	OncallRotationDaily  = 0
+	OncallRotationWeekly = 1
+)
+
+var OncallRotationPeriod = map[int]time.Duration{
+	OncallRotationDaily:  24 * time.Hour,
+	OncallRotationWeekly: 7 * 24 * time.Hour,
+}
+
+func (m *AlarmOncall) TableName() string {
+	return TableAlarmOncall
+}
+
+// AlarmOncall is a rotation of users, the alarms of the schedule mention the user on call
+type AlarmOncall struct {
+	BaseModel
+
+	Name      string          `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"` // name of the schedule
+	Desc      string          `gorm:"column:desc;type:varchar(255)" json:"desc"`          // description
+	Uids      Ints            `gorm:"column:uids;type:varchar(255)" json:"uids"`          // users in the order they are on call
+	Rotation  int             `gorm:"column:rotation;type:int(11)" json:"rotation"`       // 0 daily 1 weekly
+	HandoffAt int64           `gorm:"column:handoff_at;type:bigint(20)" json:"handoffAt"` // the first user is on call from it, the next user takes over every rotation period
+	Overrides OncallOverrides `gorm:"column:overrides;type:text" json:"overrides"`        // users on call instead of the rotation, the last one wins
+	Uid       int             `gorm:"column:uid;type:int(11)" json:"uid"`                 // creator
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
+}
+
+// OncallOverride puts the user on call in [StartsAt, EndsAt)
+type OncallOverride struct {
+	Uid      int   `json:"uid"`
+	StartsAt int64 `json:"startsAt"`
+	EndsAt   int64 `json:"endsAt"`
+}
+
+type OncallOverrides []OncallOverride
+
+func (t OncallOverrides) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *OncallOverrides) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		in = []byte("[]")
+	}
+	return json.Unmarshal(in, t)
+}
+
+// OncallAt returns the user on call at the time, 0 when the schedule has no user
+func (m *AlarmOncall) OncallAt(t time.Time) int {
+	at := t.Unix()
+	for i := len(m.Overrides) - 1; i >= 0; i-- {
+		if o := m.Overrides[i]; at >= o.StartsAt && at < o.EndsAt {
+			return o.Uid
+		}
+	}
+	if len(m.Uids) == 0 {
+		return 0
+	}
+	period := int64(OncallRotationPeriod[m.Rotation] / time.Second)
+	if period == 0 {
+		period = int64(OncallRotationPeriod[OncallRotationDaily] / time.Second)
+	}
+	// the users before the first handoff are the ones of the previous rotations
+	n := int64(len(m.Uids))
+	shift := (at - m.HandoffAt) / period
+	if at < m.HandoffAt && (at-m.HandoffAt)%period != 0 {
+		shift--
+	}
+	return m.Uids[((shift%n)+n)%n]
+}
+
+func AlarmOncallInfo(db *gorm.DB, id int) (resp AlarmOncall, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmOncall{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm oncall info error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmOncallList(conds egorm.Conds) (resp []*AlarmOncall, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmOncall{}).Where(sql, binds...).Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm oncall list error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmOncallPage return item list by pagination
+func AlarmOncallPage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmOncall) {
+	respList = make([]*AlarmOncall, 0)
+	if reqList.PageSize == 0 {
+		reqList.PageSize = 10
+	}
+	if reqList.Current == 0 {
+		reqList.Current = 1
+	}
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmOncall{}).Preload("User").Where(sql, binds...).Order("id desc")
+	db.Count(&total)
+	db.Offset((reqList.Current - 1) * reqList.PageSize).Limit(reqList.PageSize).Find(&respList)
+	return
+}
+
+func AlarmOncallCreate(db *gorm.DB, data *AlarmOncall) (err error) {
+	if err = db.Model(AlarmOncall{}).Create(data).Error; err != nil {
+		invoker.Logger.Error("alarm oncall create error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmOncallUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmOncall{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		invoker.Logger.Error("alarm oncall update error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmOncallDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmOncall{}).Unscoped().Delete(&AlarmOncall{}, id).Error; err != nil {
+		invoker.Logger.Error("alarm oncall delete error", zap.Error(err))
+		return
+	}
+	return
+}
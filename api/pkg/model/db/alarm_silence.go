@@ -0,0 +1,112 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+)
+
+func (m *AlarmSilence) TableName() string {
+	return TableAlarmSilence
+}
+
+// AlarmSilence suppresses the notifications of the matched alarms in its time window.
+// The zero matchers match everything, and all the non-zero matchers must match.
+type AlarmSilence struct {
+	BaseModel
+
+	AlarmId  int           `gorm:"column:alarm_id;type:int(11)" json:"alarmId"`              // alarm id
+	Tid      int           `gorm:"column:tid;type:int(11)" json:"tid"`                       // table id
+	Iid      int           `gorm:"column:iid;type:int(11)" json:"iid"`                       // instance id
+	Tags     String2String `gorm:"column:tags;type:text" json:"tags"`                        // alarms with all these tags
+	StartsAt int64         `gorm:"column:starts_at;type:bigint(20)" json:"startsAt"`         // unix seconds
+	EndsAt   int64         `gorm:"column:ends_at;type:bigint(20);index" json:"endsAt"`       // unix seconds, the silence expires after it
+	Cron     string        `gorm:"column:cron;type:varchar(64)" json:"cron"`                 // recurring maintenance windows start at the schedule, empty means the whole time window
+	Duration int           `gorm:"column:duration;type:int(11)" json:"duration"`             // seconds of each recurring window
+	Comment  string        `gorm:"column:comment;type:varchar(255);NOT NULL" json:"comment"` // comment
+	Uid      int           `gorm:"column:uid;type:int(11)" json:"uid"`                       // creator
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
+}
+
+// Match reports whether the silence matches the alarm of the table and the instance
+func (m *AlarmSilence) Match(alarm *Alarm, tid, iid int) bool {
+	if m.AlarmId != 0 && m.AlarmId != alarm.ID {
+		return false
+	}
+	if m.Tid != 0 && m.Tid != tid {
+		return false
+	}
+	if m.Iid != 0 && m.Iid != iid {
+		return false
+	}
+	for k, v := range m.Tags {
+		if alarm.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func AlarmSilenceInfo(db *gorm.DB, id int) (resp AlarmSilence, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmSilence{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm silence info error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmSilenceList(conds egorm.Conds) (resp []*AlarmSilence, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmSilence{}).Where(sql, binds...).Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm silence list error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmSilencePage return item list by pagination
+func AlarmSilencePage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmSilence) {
+	respList = make([]*AlarmSilence, 0)
+	if reqList.PageSize == 0 {
+		reqList.PageSize = 10
+	}
+	if reqList.Current == 0 {
+		reqList.Current = 1
+	}
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmSilence{}).Preload("User").Where(sql, binds...).Order("id desc")
+	db.Count(&total)
+	db.Offset((reqList.Current - 1) * reqList.PageSize).Limit(reqList.PageSize).Find(&respList)
+	return
+}
+
+func AlarmSilenceCreate(db *gorm.DB, data *AlarmSilence) (err error) {
+	if err = db.Model(AlarmSilence{}).Create(data).Error; err != nil {
+		invoker.Logger.Error("alarm silence create error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmSilenceUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmSilence{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		invoker.Logger.Error("alarm silence update error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmSilenceDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmSilence{}).Unscoped().Delete(&AlarmSilence{}, id).Error; err != nil {
+		invoker.Logger.Error("alarm silence delete error", zap.Error(err))
+		return
+	}
+	return
+}
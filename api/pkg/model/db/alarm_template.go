@@ -0,0 +1,87 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+)
+
+func (m *AlarmTemplate) TableName() string {
+	return TableAlarmTemplate
+}
+
+// AlarmTemplate is a parameterised alarm, applying it to the tables creates or updates the alarms derived from it
+type AlarmTemplate struct {
+	BaseModel
+
+	Name      string        `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"` // name of the template
+	Desc      string        `gorm:"column:desc;type:varchar(255)" json:"desc"`          // description
+	Content   string        `gorm:"column:content;type:text" json:"content"`            // yaml of the alarm spec, a text/template of the variables
+	Variables String2String `gorm:"column:variables;type:text" json:"variables"`        // default values of the variables
+	Uid       int           `gorm:"column:uid;type:int(11)" json:"uid"`                 // creator
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
+}
+
+func AlarmTemplateInfo(db *gorm.DB, id int) (resp AlarmTemplate, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmTemplate{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm template info error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmTemplateList(conds egorm.Conds) (resp []*AlarmTemplate, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = invoker.Db.Model(AlarmTemplate{}).Where(sql, binds...).Find(&resp).Error; err != nil {
+		invoker.Logger.Error("alarm template list error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// AlarmTemplatePage return item list by pagination
+func AlarmTemplatePage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmTemplate) {
+	respList = make([]*AlarmTemplate, 0)
+	if reqList.PageSize == 0 {
+		reqList.PageSize = 10
+	}
+	if reqList.Current == 0 {
+		reqList.Current = 1
+	}
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmTemplate{}).Preload("User").Where(sql, binds...).Order("id desc")
+	db.Count(&total)
+	db.Offset((reqList.Current - 1) * reqList.PageSize).Limit(reqList.PageSize).Find(&respList)
+	return
+}
+
+func AlarmTemplateCreate(db *gorm.DB, data *AlarmTemplate) (err error) {
+	if err = db.Model(AlarmTemplate{}).Create(data).Error; err != nil {
+		invoker.Logger.Error("alarm template create error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmTemplateUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmTemplate{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		invoker.Logger.Error("alarm template update error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func AlarmTemplateDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmTemplate{}).Unscoped().Delete(&AlarmTemplate{}, id).Error; err != nil {
+		invoker.Logger.Error("alarm template delete error", zap.Error(err))
+		return
+	}
+	return
+}
@@ -20,11 +20,15 @@ const (
 const (
 	DatasourceMySQL      = "mysql"
 	DatasourceClickHouse = "ch"
+	DatasourceStarRocks  = "starrocks"
+	DatasourceDoris      = "doris"
 )
 
 const (
-	RuleStoreTypeFile = 1
-	RuleStoreTypeK8s  = 2
+	RuleStoreTypeFile     = 1
+	RuleStoreTypeK8s      = 2
+	RuleStoreTypeNative   = 3 // evaluated by clickvisual itself, no prometheus is needed
+	RuleStoreTypeOperator = 4 // PrometheusRule resources picked up by prometheus-operator
 )
 
 const TimeFieldSecond = "_time_second_"
@@ -97,19 +101,20 @@ type BaseIndex struct {
 type BaseInstance struct {
 	BaseModel
 
-	Datasource       string  `gorm:"column:datasource;type:varchar(32);NOT NULL;index:idx_datasource_name,unique" json:"datasource"` // datasource type
-	Name             string  `gorm:"column:name;type:varchar(128);NOT NULL;index:idx_datasource_name,unique" json:"name"`            // datasource instance name
-	Dsn              string  `gorm:"column:dsn;type:text" json:"dsn"`                                                                // dsn
-	RuleStoreType    int     `gorm:"column:rule_store_type;type:int(11)" json:"ruleStoreType"`                                       // rule_store_type 0 集群 1 文件
-	FilePath         string  `gorm:"column:file_path;type:varchar(255)" json:"filePath"`                                             // file_path
-	Desc             string  `gorm:"column:desc;type:varchar(255)" json:"desc"`                                                      // file_path
-	ClusterId        int     `gorm:"column:cluster_id;type:int(11)" json:"clusterId"`                                                // cluster_id
-	Namespace        string  `gorm:"column:namespace;type:varchar(128)" json:"namespace"`                                            // namespace
-	Configmap        string  `gorm:"column:configmap;type:varchar(128)" json:"configmap"`                                            // configmap
-	PrometheusTarget string  `gorm:"column:prometheus_target;type:varchar(128)" json:"prometheusTarget"`                             // prometheus ip or domain, eg: https://prometheus:9090
-	Mode             int     `gorm:"column:mode;type:tinyint(1)" json:"mode"`                                                        // 0 standalone 1 cluster
-	ReplicaStatus    int     `gorm:"column:replica_status;type:tinyint(1)" json:"replicaStatus"`                                     // status 0 has replica 1 no replica
-	Clusters         Strings `gorm:"column:clusters;type:text" json:"clusters"`
+	Datasource       string        `gorm:"column:datasource;type:varchar(32);NOT NULL;index:idx_datasource_name,unique" json:"datasource"` // datasource type
+	Name             string        `gorm:"column:name;type:varchar(128);NOT NULL;index:idx_datasource_name,unique" json:"name"`            // datasource instance name
+	Dsn              string        `gorm:"column:dsn;type:text" json:"dsn"`                                                                // dsn
+	RuleStoreType    int           `gorm:"column:rule_store_type;type:int(11)" json:"ruleStoreType"`                                       // rule_store_type 0 集群 1 文件
+	FilePath         string        `gorm:"column:file_path;type:varchar(255)" json:"filePath"`                                             // file_path
+	Desc             string        `gorm:"column:desc;type:varchar(255)" json:"desc"`                                                      // file_path
+	ClusterId        int           `gorm:"column:cluster_id;type:int(11)" json:"clusterId"`                                                // cluster_id
+	Namespace        string        `gorm:"column:namespace;type:varchar(128)" json:"namespace"`                                            // namespace
+	Configmap        string        `gorm:"column:configmap;type:varchar(128)" json:"configmap"`                                            // configmap
+	PrometheusTarget string        `gorm:"column:prometheus_target;type:varchar(128)" json:"prometheusTarget"`                             // prometheus ip or domain, eg: https://prometheus:9090
+	Mode             int           `gorm:"column:mode;type:tinyint(1)" json:"mode"`                                                        // 0 standalone 1 cluster
+	ReplicaStatus    int           `gorm:"column:replica_status;type:tinyint(1)" json:"replicaStatus"`                                     // status 0 has replica 1 no replica
+	Clusters         Strings       `gorm:"column:clusters;type:text" json:"clusters"`
+	RuleLabels       String2String `gorm:"column:rule_labels;type:text" json:"ruleLabels"` // labels of the PrometheusRule resources, matched by the rule selector of prometheus-operator
 }
 
 type BaseTable struct {
@@ -26,11 +26,16 @@ const (
 	TableNameBaseInstance    = "cv_base_instance"
 	TableNameBaseHiddenField = "cv_base_hidden_field"
 
-	TableAlarm          = "cv_alarm"
-	TableAlarmFilter    = "cv_alarm_filter"
-	TableAlarmHistory   = "cv_alarm_history"
-	TableAlarmChannel   = "cv_alarm_channel"
-	TableAlarmCondition = "cv_alarm_condition"
+	TableAlarm           = "cv_alarm"
+	TableAlarmFilter     = "cv_alarm_filter"
+	TableAlarmHistory    = "cv_alarm_history"
+	TableAlarmChannel    = "cv_alarm_channel"
+	TableAlarmCondition  = "cv_alarm_condition"
+	TableAlarmSilence    = "cv_alarm_silence"
+	TableAlarmDelivery   = "cv_alarm_delivery"
+	TableAlarmEscalation = "cv_alarm_escalation"
+	TableAlarmOncall     = "cv_alarm_oncall"
+	TableAlarmTemplate   = "cv_alarm_template"
 
 	TableNameConfiguration        = "cv_configuration"
 	TableNameConfigurationHistory = "cv_configuration_history"
@@ -91,7 +96,11 @@ func (t String2String) Value() (driver.Value, error) {
 }
 
 func (t *String2String) Scan(input interface{}) error {
-	return json.Unmarshal(input.([]byte), t)
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		return nil
+	}
+	return json.Unmarshal(in, t)
 }
 
 type Strings []string
@@ -64,11 +64,29 @@ const (
 	OpnClustersConfigMapUpdate = "opn_clusters_config_map_update"
 
 	OpnAlarmsDelete         = "opn_alarms_delete"
+	OpnAlarmsImport         = "opn_alarms_import"
 	OpnAlarmsCreate         = "opn_alarms_create"
 	OpnAlarmsUpdate         = "opn_alarms_update"
 	OpnAlarmsChannelsDelete = "opn_alarms_channels_delete"
 	OpnAlarmsChannelsCreate = "opn_alarms_channels_create"
 	OpnAlarmsChannelsUpdate = "opn_alarms_channels_update"
+	OpnAlarmsSilencesDelete = "opn_alarms_silences_delete"
+	OpnAlarmsSilencesCreate = "opn_alarms_silences_create"
+	OpnAlarmsSilencesUpdate = "opn_alarms_silences_update"
+	OpnAlarmsDeliveryReplay = "opn_alarms_delivery_replay"
+	OpnAlarmsAck            = "opn_alarms_ack"
+	OpnAlarmsResolve        = "opn_alarms_resolve"
+
+	OpnAlarmsEscalationsDelete = "opn_alarms_escalations_delete"
+	OpnAlarmsEscalationsCreate = "opn_alarms_escalations_create"
+	OpnAlarmsEscalationsUpdate = "opn_alarms_escalations_update"
+	OpnAlarmsOncallsDelete     = "opn_alarms_oncalls_delete"
+	OpnAlarmsOncallsCreate     = "opn_alarms_oncalls_create"
+	OpnAlarmsOncallsUpdate     = "opn_alarms_oncalls_update"
+	OpnAlarmsTemplatesDelete   = "opn_alarms_templates_delete"
+	OpnAlarmsTemplatesCreate   = "opn_alarms_templates_create"
+	OpnAlarmsTemplatesUpdate   = "opn_alarms_templates_update"
+	OpnAlarmsTemplatesApply    = "opn_alarms_templates_apply"
 
 	OpnBigDataNodeCreate        = "opn_big_data_node_create"
 	OpnBigDataNodeUpdate        = "opn_big_data_node_update"
@@ -126,11 +144,29 @@ var OperationMap = map[string]string{
 	OpnClustersConfigMapUpdate: "cluster configmap update",
 
 	OpnAlarmsDelete:         "alarm delete",
+	OpnAlarmsImport:         "alarm import",
 	OpnAlarmsCreate:         "alarm create",
 	OpnAlarmsUpdate:         "alarm update",
 	OpnAlarmsChannelsDelete: "alarm channel delete",
 	OpnAlarmsChannelsCreate: "alarm channel create",
 	OpnAlarmsChannelsUpdate: "alarm channel update",
+	OpnAlarmsSilencesDelete: "alarm silence delete",
+	OpnAlarmsSilencesCreate: "alarm silence create",
+	OpnAlarmsSilencesUpdate: "alarm silence update",
+	OpnAlarmsDeliveryReplay: "alarm delivery replay",
+	OpnAlarmsAck:            "alarm acknowledge",
+	OpnAlarmsResolve:        "alarm resolve",
+
+	OpnAlarmsEscalationsDelete: "alarm escalation delete",
+	OpnAlarmsEscalationsCreate: "alarm escalation create",
+	OpnAlarmsEscalationsUpdate: "alarm escalation update",
+	OpnAlarmsOncallsDelete:     "alarm on-call schedule delete",
+	OpnAlarmsOncallsCreate:     "alarm on-call schedule create",
+	OpnAlarmsOncallsUpdate:     "alarm on-call schedule update",
+	OpnAlarmsTemplatesDelete:   "alarm template delete",
+	OpnAlarmsTemplatesCreate:   "alarm template create",
+	OpnAlarmsTemplatesUpdate:   "alarm template update",
+	OpnAlarmsTemplatesApply:    "alarm template apply",
 
 	OpnMigration: "upgrading the database structure",
 
@@ -201,11 +237,28 @@ var (
 		},
 		SourceAlarmMgtCenter: {
 			OpnAlarmsDelete,
+			OpnAlarmsImport,
 			OpnAlarmsCreate,
 			OpnAlarmsUpdate,
 			OpnAlarmsChannelsDelete,
 			OpnAlarmsChannelsCreate,
 			OpnAlarmsChannelsUpdate,
+			OpnAlarmsSilencesDelete,
+			OpnAlarmsSilencesCreate,
+			OpnAlarmsSilencesUpdate,
+			OpnAlarmsDeliveryReplay,
+			OpnAlarmsAck,
+			OpnAlarmsResolve,
+			OpnAlarmsEscalationsDelete,
+			OpnAlarmsEscalationsCreate,
+			OpnAlarmsEscalationsUpdate,
+			OpnAlarmsOncallsDelete,
+			OpnAlarmsOncallsCreate,
+			OpnAlarmsOncallsUpdate,
+			OpnAlarmsTemplatesDelete,
+			OpnAlarmsTemplatesCreate,
+			OpnAlarmsTemplatesUpdate,
+			OpnAlarmsTemplatesApply,
 		},
 		SourceConfigMgtCenter: {
 			OpnConfigsDelete,
@@ -72,6 +72,9 @@ type User struct {
 	CurrentAuthority string     `gorm:"column:current_authority;type:varchar(256);NOT NULL" json:"currentAuthority"` // currentAuthority
 	Access           string     `gorm:"column:access;type:varchar(256);NOT NULL" json:"access"`                      // access
 	OauthToken       OAuthToken `gorm:"column:oauth_token;type:text" json:"-"`                                       // oauth_token
+	Phone            string     `gorm:"column:phone;type:varchar(32)" json:"phone"`                                  // mentioned by dingding
+	FeishuId         string     `gorm:"column:feishu_id;type:varchar(64)" json:"feishuId"`                           // open_id or user_id mentioned by feishu
+	SlackId          string     `gorm:"column:slack_id;type:varchar(64)" json:"slackId"`                             // member id mentioned by slack
 }
 
 // K8SConfigMapCreate CRUD
@@ -7,20 +7,28 @@ import (
 )
 
 type ReqAlarmCreate struct {
-	Name       string                    `json:"alarmName" form:"alarmName"` // 告警名称
-	Desc       string                    `json:"desc" form:"desc"`           // 描述说明
-	Interval   int                       `json:"interval" form:"interval"`   // 告警频率
-	Unit       int                       `json:"unit" form:"unit"`           // 0 m 1 s 2 h 3 d 4 w 5 y
-	Status     int                       `json:"status" form:"status"`
-	AlertRule  string                    `json:"alertRule" form:"alertRule"` // prometheus alert rule
-	View       string                    `json:"view" form:"view"`           // 数据转换视图
-	NoDataOp   int                       `json:"noDataOp" form:"noDataOp"`
-	Tags       map[string]string         `json:"tags" form:"tags"` //
-	ChannelIds []int                     `json:"channelIds" form:"channelIds"`
-	Filters    []ReqAlarmFilterCreate    `json:"filters" form:"filters"`
-	Conditions []ReqAlarmConditionCreate `json:"conditions" form:"conditions"`
-	Mode       int                       `json:"mode" form:"mode"`
-	Level      int                       `json:"level" form:"level"`
+	Name             string                    `json:"alarmName" form:"alarmName"` // 告警名称
+	Desc             string                    `json:"desc" form:"desc"`           // 描述说明
+	Interval         int                       `json:"interval" form:"interval"`   // 告警频率
+	Unit             int                       `json:"unit" form:"unit"`           // 0 m 1 s 2 h 3 d 4 w 5 y
+	Status           int                       `json:"status" form:"status"`
+	AlertRule        string                    `json:"alertRule" form:"alertRule"` // prometheus alert rule
+	View             string                    `json:"view" form:"view"`           // 数据转换视图
+	NoDataOp         int                       `json:"noDataOp" form:"noDataOp"`
+	Tags             map[string]string         `json:"tags" form:"tags"` //
+	ChannelIds       []int                     `json:"channelIds" form:"channelIds"`
+	Filters          []ReqAlarmFilterCreate    `json:"filters" form:"filters"`
+	Conditions       []ReqAlarmConditionCreate `json:"conditions" form:"conditions"`
+	Mode             int                       `json:"mode" form:"mode"`
+	Level            int                       `json:"level" form:"level"`
+	ForDuration      int                       `json:"forDuration" form:"forDuration"`           // seconds the conditions must hold before firing, native rule store only
+	FiringTemplate   string                    `json:"firingTemplate" form:"firingTemplate"`     // message template of firing alerts, default template when empty
+	ResolvedTemplate string                    `json:"resolvedTemplate" form:"resolvedTemplate"` // message template of resolved alerts
+	GroupBy          []string                  `json:"groupBy" form:"groupBy"`                   // analysis fields of the table, conditions are evaluated per group, default mode only
+	EscalationId     int                       `json:"escalationId" form:"escalationId"`         // escalation policy of the firing alerts that are not acknowledged, 0 for none
+	OncallId         int                       `json:"oncallId" form:"oncallId"`                 // on-call schedule whose current user is mentioned, 0 for none
+	Anomaly          db.AlarmAnomaly           `json:"anomaly" form:"anomaly"`                   // baseline and band of the anomaly mode
+	Freshness        int                       `json:"freshness" form:"freshness"`               // minutes without logs before the table is stale and the alarm is paused, 0 for none
 }
 
 type ReqAlarmFilterCreate struct {
@@ -32,11 +40,11 @@ type ReqAlarmFilterCreate struct {
 }
 
 type ReqAlarmConditionCreate struct {
-	SetOperatorTyp int `json:"typ" form:"typ"`                      // 0 when 1 and  2 or
-	SetOperatorExp int `json:"exp" form:"exp"`                      // 0 avg 1 min 2 max 3 sum 4 count
-	Cond           int `json:"cond" form:"cond"`                    // 0 above 1 below 2 outside range 3 within range
-	Val1           int `json:"val1" form:"val1" binding:"required"` // 基准值/最小值
-	Val2           int `json:"val2" form:"val2"`                    // 最大值
+	SetOperatorTyp int     `json:"typ" form:"typ"`                      // 0 when 1 and  2 or
+	SetOperatorExp int     `json:"exp" form:"exp"`                      // 0 last 1 min 2 max 3 sum 4 count 5 avg
+	Cond           int     `json:"cond" form:"cond"`                    // 0 above 1 below 2 outside range 3 within range 4 rate above 5 rate below 6 increase above 7 decrease above
+	Val1           float64 `json:"val1" form:"val1" binding:"required"` // 基准值/最小值, per second for rates, percent for changes
+	Val2           float64 `json:"val2" form:"val2"`                    // 最大值
 }
 
 type RespAlarmInfo struct {
@@ -63,6 +71,253 @@ type (
 		Succ  int64              `json:"succ"`
 		List  []*db.AlarmHistory `json:"list"`
 	}
+
+	ReqAlarmSilenceCreate struct {
+		AlarmId  int               `json:"alarmId" form:"alarmId"`
+		Tid      int               `json:"tid" form:"tid"`
+		Iid      int               `json:"iid" form:"iid"`
+		Tags     map[string]string `json:"tags" form:"tags"`
+		StartsAt int64             `json:"startsAt" form:"startsAt"`
+		EndsAt   int64             `json:"endsAt" form:"endsAt" binding:"required"`
+		Cron     string            `json:"cron" form:"cron"`         // start of recurring windows, e.g. "0 2 * * 6"
+		Duration int               `json:"duration" form:"duration"` // seconds of each recurring window
+		Comment  string            `json:"comment" form:"comment" binding:"required"`
+	}
+
+	ReqAlarmSilenceList struct {
+		AlarmId int `json:"alarmId" form:"alarmId"`
+		Expired int `json:"expired" form:"expired"` // 0 unexpired 1 expired 2 all
+		db.ReqPage
+	}
+
+	ReqAlarmEscalationCreate struct {
+		Name  string              `json:"name" form:"name" binding:"required"`
+		Desc  string              `json:"desc" form:"desc"`
+		Steps []db.EscalationStep `json:"steps" form:"steps"`
+	}
+
+	ReqAlarmEscalationList struct {
+		Name string `json:"name" form:"name"`
+		db.ReqPage
+	}
+
+	ReqAlarmOncallCreate struct {
+		Name      string              `json:"name" form:"name" binding:"required"`
+		Desc      string              `json:"desc" form:"desc"`
+		Uids      []int               `json:"uids" form:"uids"`
+		Rotation  int                 `json:"rotation" form:"rotation"`   // 0 daily 1 weekly
+		HandoffAt int64               `json:"handoffAt" form:"handoffAt"` // default now
+		Overrides []db.OncallOverride `json:"overrides" form:"overrides"`
+	}
+
+	ReqAlarmOncallList struct {
+		Name string `json:"name" form:"name"`
+		db.ReqPage
+	}
+
+	RespAlarmOncallCurrent struct {
+		Uid  int      `json:"uid"` // 0 when nobody is on call
+		User *db.User `json:"user,omitempty"`
+	}
+
+	ReqAlarmDeliveryList struct {
+		AlarmId   int  `json:"alarmId" form:"alarmId"`
+		HistoryId int  `json:"historyId" form:"historyId"`
+		Status    *int `json:"status" form:"status"` // 0 pending 1 succeeded 2 dead letter, empty means all
+		db.ReqPage
+	}
+
+	ReqAlarmHistoryStats struct {
+		AlarmId   int   `json:"alarmId" form:"alarmId"`
+		StartTime int64 `json:"startTime" form:"startTime"` // default 7 days ago
+		EndTime   int64 `json:"endTime" form:"endTime"`     // default now
+		Limit     int   `json:"limit" form:"limit"`
+	}
+
+	// AlarmIncident is an alert from firing to resolved
+	AlarmIncident struct {
+		StartsAt      int64  `json:"startsAt"`
+		EndsAt        int64  `json:"endsAt"`   // 0 when still firing
+		Duration      int64  `json:"duration"` // seconds, 0 when still firing
+		Notifications int    `json:"notifications"`
+		Value         string `json:"value"`
+	}
+
+	RespAlarmTimeline struct {
+		Events    []*db.AlarmHistory `json:"events"`
+		Incidents []AlarmIncident    `json:"incidents"`
+	}
+
+	RespAlarmMTTR struct {
+		AlarmId   int    `json:"alarmId"`
+		AlarmName string `json:"alarmName"`
+		Incidents int    `json:"incidents"`
+		Resolved  int    `json:"resolved"`
+		MTTR      int64  `json:"mttr"` // seconds, mean duration of the resolved incidents
+	}
+
+	RespAlarmNoisiest struct {
+		AlarmId       int    `json:"alarmId"`
+		AlarmName     string `json:"alarmName"`
+		Notifications int64  `json:"notifications"`
+	}
+
+	ReqAlarmBacktest struct {
+		ReqAlarmCreate
+		StartTime int64 `json:"startTime" form:"startTime"` // unix seconds
+		EndTime   int64 `json:"endTime" form:"endTime"`     // unix seconds, default now
+	}
+
+	RespAlarmBacktest struct {
+		Interval  int64                 `json:"interval"` // seconds of each point
+		Series    []AlarmBacktestPoint  `json:"series"`
+		Crossings []AlarmBacktestPoint  `json:"crossings"` // the points where the conditions start or stop to hold
+		Windows   []AlarmBacktestWindow `json:"windows"`   // the would-be firing windows
+	}
+
+	AlarmBacktestPoint struct {
+		Ts       int64   `json:"ts"` // start of the interval
+		Val      float64 `json:"val"`
+		NoData   bool    `json:"noData"`
+		Breached bool    `json:"breached"` // the conditions hold
+	}
+
+	AlarmBacktestWindow struct {
+		ActiveAt int64 `json:"activeAt"` // the conditions started to hold
+		FiringAt int64 `json:"firingAt"` // the firing notification would be sent
+		EndsAt   int64 `json:"endsAt"`   // the resolved notification would be sent, 0 means still firing
+	}
+
+	RespChannelSendTest struct {
+		Payload string `json:"payload"` // rendered payload of the channels with user-defined templates
+	}
+
+	// WebhookAlarm is the alarm in the payloads of the webhook channels, only the fields safe to send out
+	WebhookAlarm struct {
+		ID    int               `json:"id"`
+		Uuid  string            `json:"uuid"`
+		Name  string            `json:"name"`
+		Desc  string            `json:"desc"`
+		Level int               `json:"level"`
+		Tags  map[string]string `json:"tags,omitempty"`
+	}
+
+	// WebhookTable is the table of the alarm in the payloads of the webhook channels
+	WebhookTable struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Database string `json:"database"`
+		Desc     string `json:"desc"`
+	}
+
+	// WebhookInstance is the instance of the alarm in the payloads of the webhook channels, without the dsn
+	WebhookInstance struct {
+		ID         int    `json:"id"`
+		Name       string `json:"name"`
+		Datasource string `json:"datasource"`
+		Desc       string `json:"desc"`
+	}
+
+	// AlarmDocument is the yaml document of exported alarms, channels and tables are referenced by names
+	AlarmDocument struct {
+		Alarms []AlarmSpec `json:"alarms"`
+	}
+
+	AlarmSpec struct {
+		Uuid             string               `json:"uuid,omitempty"` // matched first when importing, then the name
+		Name             string               `json:"name"`
+		Desc             string               `json:"desc,omitempty"`
+		Table            AlarmTableRef        `json:"table"` // table of the default filter
+		Interval         int                  `json:"interval"`
+		Unit             int                  `json:"unit"`
+		Mode             int                  `json:"mode"`
+		Level            int                  `json:"level"`
+		NoDataOp         int                  `json:"noDataOp"`
+		ForDuration      int                  `json:"forDuration,omitempty"`
+		Freshness        int                  `json:"freshness,omitempty"`
+		Tags             map[string]string    `json:"tags,omitempty"`
+		GroupBy          []string             `json:"groupBy,omitempty"`
+		FiringTemplate   string               `json:"firingTemplate,omitempty"`
+		ResolvedTemplate string               `json:"resolvedTemplate,omitempty"`
+		Escalation       string               `json:"escalation,omitempty"` // name of the escalation policy
+		Oncall           string               `json:"oncall,omitempty"`     // name of the on-call schedule
+		Anomaly          *db.AlarmAnomaly     `json:"anomaly,omitempty"`    // anomaly mode only
+		Channels         []string             `json:"channels"`
+		Filters          []AlarmFilterSpec    `json:"filters"`
+		Conditions       []AlarmConditionSpec `json:"conditions"`
+	}
+
+	AlarmTableRef struct {
+		Instance string `json:"instance"`
+		Database string `json:"database"`
+		Table    string `json:"table"`
+	}
+
+	AlarmFilterSpec struct {
+		Table *AlarmTableRef `json:"table,omitempty"` // empty for the table of the alarm
+		When  string         `json:"when"`
+		Typ   int            `json:"typ,omitempty"` // 0 default 1 INNER 2 LEFT OUTER 3 RIGHT OUTER 4 FULL OUTER 5 CROSS
+		Exp   string         `json:"exp,omitempty"`
+	}
+
+	AlarmConditionSpec struct {
+		Typ  int     `json:"typ"`  // 0 when 1 and 2 or
+		Exp  int     `json:"exp"`  // 0 last 1 min 2 max 3 sum 4 count 5 avg
+		Cond int     `json:"cond"` // 0 above 1 below 2 outside range 3 within range 4 rate above 5 rate below 6 increase above 7 decrease above
+		Val1 float64 `json:"val1"`
+		Val2 float64 `json:"val2,omitempty"`
+	}
+
+	ReqAlarmImport struct {
+		Content string `json:"content" form:"content" binding:"required"` // yaml document of AlarmDocument
+		DryRun  bool   `json:"dryRun" form:"dryRun"`                      // only returns the changes
+		Prune   bool   `json:"prune" form:"prune"`                        // deletes the alarms of the tables in the document that are not in the document
+	}
+
+	RespAlarmImport struct {
+		DryRun bool              `json:"dryRun"`
+		Items  []AlarmImportItem `json:"items"`
+	}
+
+	AlarmImportItem struct {
+		Name    string             `json:"name"`
+		Uuid    string             `json:"uuid"`
+		Action  string             `json:"action"` // create, update, delete or unchanged
+		Changes []AlarmFieldChange `json:"changes,omitempty"`
+	}
+
+	AlarmFieldChange struct {
+		Field string `json:"field"`
+		Old   string `json:"old"` // json of the current value, empty for created alarms
+		New   string `json:"new"`
+	}
+
+	ReqAlarmTemplateCreate struct {
+		Name      string            `json:"name" form:"name" binding:"required"`
+		Desc      string            `json:"desc" form:"desc"`
+		Content   string            `json:"content" form:"content" binding:"required"` // yaml of AlarmSpec, variables are referenced as ${threshold}
+		Variables map[string]string `json:"variables" form:"variables"`                // default values of the variables
+	}
+
+	ReqAlarmTemplateUpdate struct {
+		ReqAlarmTemplateCreate
+		DryRun bool `json:"dryRun" form:"dryRun"` // only returns the changes of the derived alarms
+	}
+
+	ReqAlarmTemplateList struct {
+		Name string `json:"name" form:"name"`
+		db.ReqPage
+	}
+
+	// ReqAlarmTemplateApply selects the tables by ids, or by the glob patterns of their database and table names
+	ReqAlarmTemplateApply struct {
+		Tids     []int             `json:"tids" form:"tids"`
+		Iid      int               `json:"iid" form:"iid"`           // instance of the patterns, 0 for all the instances
+		Database string            `json:"database" form:"database"` // pattern of the database names, such as app_*
+		Table    string            `json:"table" form:"table"`       // pattern of the table names
+		Vars     map[string]string `json:"vars" form:"vars"`         // values of the variables, override the defaults of the template
+		DryRun   bool              `json:"dryRun" form:"dryRun"`
+	}
 )
 
 type (
@@ -58,6 +58,7 @@ type (
 		Page          uint32 `form:"page"`
 		PageSize      uint32 `form:"pageSize"`
 		AlarmMode     int    `form:"alarmMode"`
+		GroupByLimit  uint32 `form:"-"` // rows of GroupBy, 10 when it is 0
 	}
 
 	RespQuery struct {
@@ -159,6 +160,8 @@ type (
 		Id           int                  `json:"id"`
 		InstanceName string               `json:"instanceName"`
 		Desc         string               `json:"desc"`
+		Datasource   string               `json:"datasource"`
+		Capability   Capability           `json:"capability"`
 		Databases    []RespDatabaseSimple `json:"databases"`
 	}
 	RespDatabaseSimple struct {
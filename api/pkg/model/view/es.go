@@ -0,0 +1,86 @@
+package view
+
+// Elasticsearch compatible api, see https://www.elastic.co/guide/en/elasticsearch/reference/current/search-search.html
+type (
+	ReqESSearch struct {
+		Query        map[string]interface{} `json:"query"`
+		Size         *int                   `json:"size"`
+		From         int                    `json:"from"`
+		Aggs         map[string]ESAgg       `json:"aggs"`
+		Aggregations map[string]ESAgg       `json:"aggregations"`
+	}
+
+	ESAgg struct {
+		Terms *ESAggTerms `json:"terms"`
+	}
+
+	ESAggTerms struct {
+		Field string `json:"field"`
+		Size  int    `json:"size"`
+	}
+
+	RespESSearch struct {
+		Took         int64                  `json:"took"`
+		TimedOut     bool                   `json:"timed_out"`
+		Shards       ESShards               `json:"_shards"`
+		Hits         ESHits                 `json:"hits"`
+		Aggregations map[string]ESAggResult `json:"aggregations,omitempty"`
+	}
+
+	RespESCount struct {
+		Count  uint64   `json:"count"`
+		Shards ESShards `json:"_shards"`
+	}
+
+	ESShards struct {
+		Total      int `json:"total"`
+		Successful int `json:"successful"`
+		Skipped    int `json:"skipped"`
+		Failed     int `json:"failed"`
+	}
+
+	ESHits struct {
+		Total    ESTotal  `json:"total"`
+		MaxScore *float64 `json:"max_score"`
+		Hits     []ESHit  `json:"hits"`
+	}
+
+	ESTotal struct {
+		Value    uint64 `json:"value"`
+		Relation string `json:"relation"`
+	}
+
+	ESHit struct {
+		Index  string                 `json:"_index"`
+		ID     string                 `json:"_id"`
+		Score  *float64               `json:"_score"`
+		Source map[string]interface{} `json:"_source"`
+	}
+
+	ESAggResult struct {
+		DocCountErrorUpperBound int        `json:"doc_count_error_upper_bound"`
+		SumOtherDocCount        uint64     `json:"sum_other_doc_count"`
+		Buckets                 []ESBucket `json:"buckets"`
+	}
+
+	ESBucket struct {
+		Key      string `json:"key"`
+		DocCount uint64 `json:"doc_count"`
+	}
+
+	RespESError struct {
+		Error  ESError `json:"error"`
+		Status int     `json:"status"`
+	}
+
+	ESError struct {
+		RootCause []ESErrorCause `json:"root_cause"`
+		Type      string         `json:"type"`
+		Reason    string         `json:"reason"`
+	}
+
+	ESErrorCause struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+)
@@ -0,0 +1,49 @@
+package view
+
+// Loki compatible api, see https://grafana.com/docs/loki/latest/api/
+type (
+	ReqLokiQueryRange struct {
+		Query     string `form:"query" json:"query"`
+		Start     string `form:"start" json:"start"`
+		End       string `form:"end" json:"end"`
+		Limit     int    `form:"limit" json:"limit"`
+		Step      string `form:"step" json:"step"`
+		Direction string `form:"direction" json:"direction"` // forward or backward
+	}
+
+	ReqLokiLabels struct {
+		Start string `form:"start" json:"start"`
+		End   string `form:"end" json:"end"`
+	}
+
+	ReqLokiSeries struct {
+		Match []string `form:"match[]" json:"match[]"`
+		Start string   `form:"start" json:"start"`
+		End   string   `form:"end" json:"end"`
+	}
+
+	ReqLokiPush struct {
+		Streams []LokiStream `json:"streams"`
+	}
+
+	RespLoki struct {
+		Status string      `json:"status"`
+		Data   interface{} `json:"data"`
+	}
+
+	RespLokiQuery struct {
+		ResultType string                 `json:"resultType"` // streams or matrix
+		Result     interface{}            `json:"result"`
+		Stats      map[string]interface{} `json:"stats"`
+	}
+
+	LokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"` // [unix nanoseconds, log line]
+	}
+
+	LokiSeries struct {
+		Metric map[string]string `json:"metric"`
+		Values [][2]interface{}  `json:"values"` // [unix seconds, value]
+	}
+)
@@ -13,24 +13,42 @@ type RespConfigmap struct {
 	Name string `json:"configmapName"`
 }
 
+// Capability is the features supported by a datasource, the ui hides the unsupported ones
+type Capability struct {
+	Alarm bool `json:"alarm"` // alarms and their prometheus rules
+	DDL   bool `json:"ddl"`   // create and drop databases, tables and views
+}
+
+type RespDatasource struct {
+	Datasource string     `json:"datasource"`
+	Capability Capability `json:"capability"`
+}
+
+type RespInstance struct {
+	*db.BaseInstance
+	Capability Capability `json:"capability"`
+}
+
 type ReqTestInstance struct {
-	Dsn string `json:"dsn" binding:"required"`
+	Datasource string `json:"datasource"` // defaults to clickhouse
+	Dsn        string `json:"dsn" binding:"required"`
 }
 
 type ReqCreateInstance struct {
-	Datasource       string     `json:"datasource" binding:"required"`
-	Name             string     `json:"name" binding:"required"`
-	Dsn              string     `json:"dsn" binding:"required"`
-	RuleStoreType    int        `json:"ruleStoreType"`
-	FilePath         string     `json:"filePath"`
-	Desc             string     `json:"desc"`
-	ClusterId        int        `json:"clusterId"`
-	Namespace        string     `json:"namespace"`
-	Configmap        string     `json:"configmap"`
-	PrometheusTarget string     `json:"prometheusTarget"`
-	Mode             int        `json:"mode"`
-	ReplicaStatus    int        `json:"replicaStatus"`
-	Clusters         db.Strings `json:"clusters"`
+	Datasource       string           `json:"datasource" binding:"required"`
+	Name             string           `json:"name" binding:"required"`
+	Dsn              string           `json:"dsn" binding:"required"`
+	RuleStoreType    int              `json:"ruleStoreType"`
+	FilePath         string           `json:"filePath"`
+	Desc             string           `json:"desc"`
+	ClusterId        int              `json:"clusterId"`
+	Namespace        string           `json:"namespace"`
+	Configmap        string           `json:"configmap"`
+	PrometheusTarget string           `json:"prometheusTarget"`
+	Mode             int              `json:"mode"`
+	ReplicaStatus    int              `json:"replicaStatus"`
+	Clusters         db.Strings       `json:"clusters"`
+	RuleLabels       db.String2String `json:"ruleLabels"`
 }
 
 type ReqCreateCluster struct {
@@ -0,0 +1,91 @@
+package push
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// actions of the buttons in interactive messages
+const (
+	CallbackAcknowledge = "ack"
+	CallbackResolve     = "resolve"
+)
+
+// AnnotationIncident is the common annotation of a firing notification, the id of the history that opens the incident,
+// the buttons are bound to it so that they do not act on the incidents opened after the message
+const AnnotationIncident = "incident"
+
+// CallbackValue is carried by the buttons of interactive messages and posted back by the channels,
+// it is signed with alarm.callback.secret since the callbacks are not authenticated by users.
+type CallbackValue struct {
+	Action    string `json:"action"`
+	AlarmId   int    `json:"alarmId"`
+	HistoryId int    `json:"historyId"` // history that opens the incident
+	ExpiresAt int64  `json:"expiresAt"` // unix seconds after which the value is rejected
+	Sig       string `json:"sig"`
+}
+
+// CallbackEnabled reports whether the firing messages carry acknowledge and resolve buttons
+func CallbackEnabled() bool {
+	return econf.GetString("alarm.callback.secret") != ""
+}
+
+// NewCallbackValue returns the signed value of the incident opened by the history, it expires after alarm.callback.ttl
+func NewCallbackValue(alarmId, historyId int, action string) CallbackValue {
+	ttl := econf.GetDuration("alarm.callback.ttl")
+	if ttl <= 0 {
+		ttl = time.Hour * 24
+	}
+	v := CallbackValue{Action: action, AlarmId: alarmId, HistoryId: historyId, ExpiresAt: time.Now().Add(ttl).Unix()}
+	v.Sig = callbackSign(econf.GetString("alarm.callback.secret"), v)
+	return v
+}
+
+// Verify checks the signature and the expiry of the value
+func (v CallbackValue) Verify() bool {
+	secret := econf.GetString("alarm.callback.secret")
+	if secret == "" || v.AlarmId == 0 || v.HistoryId == 0 {
+		return false
+	}
+	if v.Action != CallbackAcknowledge && v.Action != CallbackResolve {
+		return false
+	}
+	if v.ExpiresAt < time.Now().Unix() {
+		return false
+	}
+	return hmac.Equal([]byte(v.Sig), []byte(callbackSign(secret, v)))
+}
+
+func callbackSign(secret string, v CallbackValue) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.Itoa(v.AlarmId) + ":" + strconv.Itoa(v.HistoryId) + ":" + strconv.FormatInt(v.ExpiresAt, 10) + ":" + v.Action))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// callbackActions returns the buttons of the message, firing messages of the incidents of saved alarms only
+func callbackActions(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel) (values []CallbackValue, labels []string) {
+	if !CallbackEnabled() || alarm == nil || alarm.ID == 0 || notification.Status != StatusFiring {
+		return nil, nil
+	}
+	historyId, _ := strconv.Atoi(notification.CommonAnnotations[AnnotationIncident])
+	if historyId == 0 {
+		return nil, nil
+	}
+	tpl, ok := messageTemplates[Locale(channel)]
+	if !ok {
+		tpl = messageTemplates[LocaleZhCN]
+	}
+	for _, action := range []string{CallbackAcknowledge, CallbackResolve} {
+		values = append(values, NewCallbackValue(alarm.ID, historyId, action))
+		labels = append(labels, tpl.Actions[action])
+	}
+	return values, labels
+}
@@ -0,0 +1,82 @@
+package push
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TestCallbackValue(t *testing.T) {
+	Convey("buttons are signed and verified with the callback secret", t, func() {
+		econf.Set("alarm.callback.secret", "s3cret")
+		defer econf.Set("alarm.callback.secret", "")
+
+		v := NewCallbackValue(7, 3, CallbackAcknowledge)
+		So(v.Verify(), ShouldBeTrue)
+
+		forged := v
+		forged.AlarmId = 8
+		So(forged.Verify(), ShouldBeFalse)
+		forged = v
+		forged.Action = CallbackResolve
+		So(forged.Verify(), ShouldBeFalse)
+		forged = v
+		forged.HistoryId = 4
+		So(forged.Verify(), ShouldBeFalse)
+		forged = v
+		forged.ExpiresAt += 3600
+		So(forged.Verify(), ShouldBeFalse)
+
+		expired := v
+		expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		expired.Sig = callbackSign("s3cret", expired)
+		So(expired.Verify(), ShouldBeFalse)
+
+		econf.Set("alarm.callback.secret", "another")
+		So(v.Verify(), ShouldBeFalse)
+	})
+	Convey("firing messages of saved alarms carry the buttons", t, func() {
+		channel := &db.AlarmChannel{Locale: LocaleZhCN}
+		alarm := &db.Alarm{BaseModel: db.BaseModel{ID: 7}}
+		firing := view.Notification{Status: StatusFiring, CommonAnnotations: map[string]string{AnnotationIncident: "3"}}
+		values, _ := callbackActions(firing, alarm, channel)
+		So(values, ShouldBeEmpty)
+
+		econf.Set("alarm.callback.secret", "s3cret")
+		defer econf.Set("alarm.callback.secret", "")
+		values, labels := callbackActions(firing, alarm, channel)
+		So(len(values), ShouldEqual, 2)
+		So(values[0].HistoryId, ShouldEqual, 3)
+		So(labels, ShouldResemble, []string{"认领", "解决"})
+		values, _ = callbackActions(view.Notification{Status: StatusResolved, CommonAnnotations: firing.CommonAnnotations}, alarm, channel)
+		So(values, ShouldBeEmpty)
+		values, _ = callbackActions(view.Notification{Status: StatusFiring}, alarm, channel)
+		So(values, ShouldBeEmpty)
+		values, _ = callbackActions(firing, &db.Alarm{}, channel)
+		So(values, ShouldBeEmpty)
+	})
+	Convey("the values of the slack buttons are posted back as they are", t, func() {
+		econf.Set("alarm.callback.secret", "s3cret")
+		defer econf.Set("alarm.callback.secret", "")
+		v := NewCallbackValue(7, 3, CallbackResolve)
+		blocks, err := slackButtons([]CallbackValue{v}, []string{"Resolve"})
+		So(err, ShouldBeNil)
+		out, err := json.Marshal(blocks)
+		So(err, ShouldBeNil)
+		var parsed []struct {
+			Elements []struct {
+				Value string `json:"value"`
+			} `json:"elements"`
+		}
+		So(json.Unmarshal(out, &parsed), ShouldBeNil)
+		var back CallbackValue
+		So(json.Unmarshal([]byte(parsed[0].Elements[0].Value), &back), ShouldBeNil)
+		So(back.Verify(), ShouldBeTrue)
+	})
+}
@@ -3,14 +3,9 @@ package push
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/ego-component/egorm"
-	"github.com/gotomicro/ego/core/econf"
-
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/pkg/model/view"
 )
@@ -18,7 +13,7 @@ import (
 type DingDing struct{}
 
 func (d *DingDing) Send(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (err error) {
-	markdown, err := d.transformToMarkdown(notification, alarm, oneTheLogs)
+	markdown, err := d.transformToMarkdown(notification, alarm, channel, oneTheLogs)
 	if err != nil {
 		return
 	}
@@ -44,64 +39,30 @@ func (d *DingDing) Send(notification view.Notification, alarm *db.Alarm, channel
 	return
 }
 
-// TransformToMarkdown transform alertmanager notification to dingtalk markdow message
-func (d *DingDing) transformToMarkdown(notification view.Notification, alarm *db.Alarm, oneTheLogs string) (markdown *view.DingTalkMarkdown, err error) {
-
-	// groupKey := notification.GroupKey
-	status := notification.Status
-	annotations := notification.CommonAnnotations
-
-	var buffer bytes.Buffer
-	buffer.WriteString("### ClickVisual 告警\n")
-	buffer.WriteString(fmt.Sprintf("##### 告警名称: %s\n", alarm.Name))
-	if alarm.Desc != "" {
-		buffer.WriteString(fmt.Sprintf("##### 告警描述: %s\n", alarm.Desc))
-	}
-	status = "告警中"
-	if status == "resolved" {
-		status = "已恢复"
-	}
-
-	condsFilter := egorm.Conds{}
-	condsFilter["alarm_id"] = alarm.ID
-	filters, err := db.AlarmFilterList(condsFilter)
+// transformToMarkdown transform alertmanager notification to dingtalk markdown message
+func (d *DingDing) transformToMarkdown(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (markdown *view.DingTalkMarkdown, err error) {
+	title, text, err := transformToMarkdown(notification, alarm, channel, oneTheLogs)
 	if err != nil {
 		return
 	}
-	exp := db.WhereConditionFromFilter(alarm, filters)
-	user, _ := db.UserInfo(alarm.Uid)
-	ins, table, _, _ := db.GetAlarmTableInstanceInfo(alarm.ID)
-	for _, alert := range notification.Alerts {
-		end := alert.StartsAt.Add(time.Minute).Unix()
-		start := alert.StartsAt.Add(-db.UnitMap[alarm.Unit].Duration - time.Minute).Unix()
-		annotations = alert.Annotations
-		buffer.WriteString(fmt.Sprintf("##### 表达式: %s\n\n", exp))
-
-		buffer.WriteString(fmt.Sprintf("##### 首次触发时间：%s\n", alert.StartsAt.Add(time.Hour*8).Format("2006-01-02 15:04:05")))
-		buffer.WriteString(fmt.Sprintf("##### 相关实例：%s %s\n", ins.Name, ins.Desc))
-		buffer.WriteString(fmt.Sprintf("##### 相关日志库：%s %s\n", table.Name, table.Desc))
-		buffer.WriteString(fmt.Sprintf("##### 状态：%s\n", status))
-		buffer.WriteString(fmt.Sprintf("##### 创建人 ：%s(%s)\n", user.Username, user.Nickname))
-
-		buffer.WriteString(fmt.Sprintf("##### %s\n\n", annotations["description"]))
-
-		buffer.WriteString(fmt.Sprintf("##### 详情: %s/alarm/rules/history?id=%d&start=%d&end=%d\n\n",
-			strings.TrimRight(econf.GetString("app.rootURL"), "/"), alarm.ID, start, end,
-		))
-		if oneTheLogs != "" {
-			buffer.WriteString(fmt.Sprintf("##### 详情: %s", oneTheLogs))
-		}
-	}
+	return dingdingMarkdown(title, text, oncallOf(alarm, time.Now())), nil
+}
 
-	markdown = &view.DingTalkMarkdown{
+// dingdingMarkdown mentions the user on call by the phone, which should be in the text as well
+func dingdingMarkdown(title, text string, oncall *Oncall) *view.DingTalkMarkdown {
+	markdown := &view.DingTalkMarkdown{
 		MsgType: "markdown",
 		Markdown: &view.Markdown{
-			Title: fmt.Sprintf("【%s】%s", status, alarm.Name),
-			Text:  buffer.String(),
+			Title: title,
+			Text:  text,
 		},
 		At: &view.At{
 			IsAtAll: false,
 		},
 	}
-	return
+	if oncall != nil && oncall.Phone != "" {
+		markdown.At.AtMobiles = []string{oncall.Phone}
+		markdown.Markdown.Text += "\n\n@" + oncall.Phone
+	}
+	return markdown
 }
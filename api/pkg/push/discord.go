@@ -0,0 +1,70 @@
+package push
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+)
+
+// Discord posts an embed to the webhook of the Discord channel, the key is the webhook url
+type Discord struct{}
+
+type discordMessage struct {
+	Username  string         `json:"username"`
+	AvatarURL string         `json:"avatar_url"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Color       int           `json:"color"`
+	Footer      discordFooter `json:"footer"`
+	Timestamp   string        `json:"timestamp"`
+}
+
+type discordFooter struct {
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url"`
+}
+
+func (d *Discord) Send(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (err error) {
+	title, text, err := transformToMarkdown(notification, alarm, channel, oneTheLogs)
+	if err != nil {
+		return err
+	}
+	return postWebhook(channel.Key, discordEmbedMessage(notification.Status, title, text, time.Now()))
+}
+
+func discordEmbedMessage(status, title, text string, now time.Time) *discordMessage {
+	// the colors of embeds are decimal rgb values
+	color, _ := strconv.ParseInt(strings.TrimPrefix(statusColor(status), "#"), 16, 32)
+	return &discordMessage{
+		Username:  FOOTER,
+		AvatarURL: ICON,
+		Embeds: []discordEmbed{{
+			Title:       truncateRunes(title, discordTitleLimit),
+			Description: truncateRunes(text, discordDescriptionLimit),
+			Color:       int(color),
+			Footer:      discordFooter{Text: FOOTER, IconURL: ICON},
+			Timestamp:   now.UTC().Format(time.RFC3339),
+		}},
+	}
+}
+
+// truncateRunes keeps the first n characters, the embeds over the limits are rejected by discord
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,41 @@
+package push
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TestDiscord_Send(t *testing.T) {
+	Convey("send an embed to discord", t, func() {
+		var body []byte
+		// discord answers the webhooks without wait with 204
+		srv := testChatServer(http.StatusNoContent, &body)
+		defer srv.Close()
+		channel := &db.AlarmChannel{Key: srv.URL, Locale: LocaleEnUS}
+		err := (&Discord{}).Send(view.Notification{Status: StatusFiring}, &db.Alarm{Name: "test"}, channel, "")
+		So(err, ShouldBeNil)
+
+		var msg discordMessage
+		So(json.Unmarshal(body, &msg), ShouldBeNil)
+		So(msg.Username, ShouldEqual, FOOTER)
+		So(msg.Embeds, ShouldHaveLength, 1)
+		So(msg.Embeds[0].Color, ShouldEqual, 0x8b0000)
+		So(msg.Embeds[0].Description, ShouldContainSubstring, "test")
+	})
+	Convey("embeds are truncated to the limits of discord", t, func() {
+		now := time.Unix(1700000000, 0)
+		msg := discordEmbedMessage(StatusResolved, strings.Repeat("告", 300), strings.Repeat("a", 5000), now)
+		So([]rune(msg.Embeds[0].Title), ShouldHaveLength, discordTitleLimit)
+		So(msg.Embeds[0].Description, ShouldHaveLength, discordDescriptionLimit-1+len("…"))
+		So(msg.Embeds[0].Color, ShouldEqual, 0x2e8b57)
+		So(msg.Embeds[0].Timestamp, ShouldEqual, "2023-11-14T22:13:20Z")
+	})
+}
@@ -1,16 +1,350 @@
 package push
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/pkg/model/view"
 )
 
-type Email struct {
+const (
+	EmailSecurityAuto     = ""         // use STARTTLS when the server supports it
+	EmailSecuritySTARTTLS = "starttls" // STARTTLS is required
+	EmailSecurityTLS      = "tls"      // implicit tls, usually port 465
+	EmailSecurityNone     = "none"     // plain text
+)
+
+// EmailPasswordMask replaces the smtp password in the keys returned by the apis,
+// an update with the mask keeps the stored password.
+const EmailPasswordMask = "******"
+
+// EmailConfig is stored as json in AlarmChannel.Key,
+// the empty fields fall back to the global config in [alarm.email]
+type EmailConfig struct {
+	Host               string   `json:"host" mapstructure:"host"`
+	Port               int      `json:"port" mapstructure:"port"`
+	Username           string   `json:"username" mapstructure:"username"`
+	Password           string   `json:"password" mapstructure:"password"`
+	From               string   `json:"from" mapstructure:"from"`
+	To                 []string `json:"to" mapstructure:"to"`
+	Security           string   `json:"security" mapstructure:"security"`
+	InsecureSkipVerify bool     `json:"insecureSkipVerify" mapstructure:"insecureSkipVerify"`
+	Timeout            int      `json:"timeout" mapstructure:"timeout"` // seconds
 }
 
-//TODO 需要增加读取邮件的配置，后续更新
-//Need to increase the configuration of reading mail, follow-up update
+type Email struct {
+}
 
 func (e *Email) Send(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (err error) {
+	cfg, err := ParseEmailConfig(channel.Key)
+	if err != nil {
+		return
+	}
+	data, err := messageDataFrom(notification, alarm, oneTheLogs)
+	if err != nil {
+		return
+	}
+	subject, body, err := renderEmail(Locale(channel), data)
+	if err != nil {
+		return
+	}
+	return sendMail(cfg, subject, body)
+}
+
+// MaskEmailKey hides the smtp password of the json key of an email channel
+func MaskEmailKey(key string) string {
+	fields, ok := emailKeyFields(key)
+	if !ok || fields["password"] == nil || fields["password"] == "" {
+		return key
+	}
+	fields["password"] = EmailPasswordMask
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// UnmaskEmailKey puts the stored smtp password back into the key when it carries the mask
+func UnmaskEmailKey(key, stored string) string {
+	fields, ok := emailKeyFields(key)
+	if !ok || fields["password"] != EmailPasswordMask {
+		return key
+	}
+	fields["password"] = ""
+	if storedFields, storedOk := emailKeyFields(stored); storedOk {
+		fields["password"] = storedFields["password"]
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return key
+	}
+	return string(b)
+}
+
+func emailKeyFields(key string) (map[string]interface{}, bool) {
+	key = strings.TrimSpace(key)
+	if !strings.HasPrefix(key, "{") {
+		return nil, false
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(key), &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+// ParseEmailConfig parses the key of an email channel, which is either the json of EmailConfig
+// or comma separated recipients when the smtp server is configured globally.
+func ParseEmailConfig(key string) (*EmailConfig, error) {
+	var cfg EmailConfig
+	if econf.Get("alarm.email") != nil {
+		if err := econf.UnmarshalKey("alarm.email", &cfg); err != nil {
+			return nil, err
+		}
+	}
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "{") {
+		var channelCfg EmailConfig
+		if err := json.Unmarshal([]byte(key), &channelCfg); err != nil {
+			return nil, fmt.Errorf("invalid email config: %w", err)
+		}
+		if channelCfg.Host != "" {
+			// the smtp server of the channel is used as a whole
+			cfg = EmailConfig{To: cfg.To}
+			cfg.Host = channelCfg.Host
+			cfg.Port = channelCfg.Port
+			cfg.Username = channelCfg.Username
+			cfg.Password = channelCfg.Password
+			cfg.Security = channelCfg.Security
+			cfg.InsecureSkipVerify = channelCfg.InsecureSkipVerify
+		}
+		if channelCfg.From != "" {
+			cfg.From = channelCfg.From
+		}
+		if len(channelCfg.To) > 0 {
+			cfg.To = channelCfg.To
+		}
+		if channelCfg.Timeout > 0 {
+			cfg.Timeout = channelCfg.Timeout
+		}
+	} else if key != "" {
+		cfg.To = strings.FieldsFunc(key, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' '
+		})
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.Security == EmailSecurityTLS {
+			cfg.Port = 465
+		}
+	}
+	return &cfg, cfg.validate()
+}
+
+func (c *EmailConfig) validate() error {
+	if c.Host == "" {
+		return errors.New("smtp host is required")
+	}
+	switch c.Security {
+	case EmailSecurityAuto, EmailSecuritySTARTTLS, EmailSecurityTLS, EmailSecurityNone:
+	default:
+		return fmt.Errorf("invalid smtp security: %s", c.Security)
+	}
+	if c.From == "" {
+		return errors.New("sender address is required")
+	}
+	if len(c.To) == 0 {
+		return errors.New("at least one recipient is required")
+	}
 	return nil
 }
+
+var emailLabels = map[string]map[string]string{
+	LocaleZhCN: {
+		"name": "告警名称", "desc": "告警描述", "status": "状态", "exp": "表达式", "startsAt": "首次触发时间", "endsAt": "恢复时间",
+		"instance": "相关实例", "table": "相关日志库", "detail": "详情", "link": "链接", "log": "日志样例",
+	},
+	LocaleEnUS: {
+		"name": "Alert", "desc": "Description", "status": "Status", "exp": "Expression", "startsAt": "Started at", "endsAt": "Resolved at",
+		"instance": "Instance", "table": "Table", "detail": "Detail", "link": "Link", "log": "Sample log",
+	},
+}
+
+type emailContent struct {
+	MessageData
+	Title string
+	Text  string // rendered template of the alarm, replaces the default table
+	L     map[string]string
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, Helvetica, sans-serif; font-size: 14px; color: #333;">
+<h3 style="color: {{if eq .Status "resolved"}}#389e0d{{else}}#cf1322{{end}};">{{.Title}}</h3>
+{{- if .Text}}
+<pre style="white-space: pre-wrap; word-break: break-all;">{{.Text}}</pre>
+{{- else}}
+<table cellpadding="6" style="border-collapse: collapse;">
+<tr><td><b>{{.L.name}}</b></td><td>{{.Name}}</td></tr>
+{{- if .Desc}}
+<tr><td><b>{{.L.desc}}</b></td><td>{{.Desc}}</td></tr>
+{{- end}}
+<tr><td><b>{{.L.status}}</b></td><td>{{.StatusText}}</td></tr>
+{{- if .Exp}}
+<tr><td><b>{{.L.exp}}</b></td><td><code>{{.Exp}}</code></td></tr>
+{{- end}}
+{{- if .Instance}}
+<tr><td><b>{{.L.instance}}</b></td><td>{{.Instance}}</td></tr>
+{{- end}}
+{{- if .Table}}
+<tr><td><b>{{.L.table}}</b></td><td>{{.Table}}</td></tr>
+{{- end}}
+{{- range .Alerts}}
+<tr><td><b>{{$.L.startsAt}}</b></td><td>{{.StartsAt}}</td></tr>
+{{- if and .EndsAt (eq $.Status "resolved")}}
+<tr><td><b>{{$.L.endsAt}}</b></td><td>{{.EndsAt}}</td></tr>
+{{- end}}
+{{- if .Description}}
+<tr><td><b>{{$.L.detail}}</b></td><td>{{.Description}}</td></tr>
+{{- end}}
+{{- if .Link}}
+<tr><td><b>{{$.L.link}}</b></td><td><a href="{{.Link}}">{{.Link}}</a></td></tr>
+{{- end}}
+{{- end}}
+</table>
+{{- if .Log}}
+<p><b>{{.L.log}}</b></p>
+<pre style="background: #f5f5f5; padding: 8px; white-space: pre-wrap; word-break: break-all;">{{.Log}}</pre>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// renderEmail returns the subject and the html body of the alert,
+// the body is the default table unless the alarm has its own template for the status
+func renderEmail(locale string, data MessageData) (subject, body string, err error) {
+	title, text, err := renderMessage(locale, data)
+	if err != nil {
+		return
+	}
+	labels, ok := emailLabels[locale]
+	if !ok {
+		labels = emailLabels[LocaleZhCN]
+	}
+	content := emailContent{MessageData: data, Title: title, L: labels}
+	content.StatusText = messageTemplates[locale].Status[data.Status]
+	if data.Status == StatusStale || data.Alarm != nil && ((data.Status == StatusResolved && data.Alarm.ResolvedTemplate != "") ||
+		(data.Status == StatusFiring && data.Alarm.FiringTemplate != "")) {
+		content.Text = text
+	}
+	var buffer bytes.Buffer
+	if err = emailTemplate.Execute(&buffer, content); err != nil {
+		return
+	}
+	return "[ClickVisual] " + title, buffer.String(), nil
+}
+
+// buildMessage builds the mime message with base64 encoded html body
+func buildMessage(cfg *EmailConfig, subject, body string, now time.Time) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("From: " + cfg.From + "\r\n")
+	buffer.WriteString("To: " + strings.Join(cfg.To, ", ") + "\r\n")
+	buffer.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	buffer.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		buffer.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buffer.WriteString(encoded + "\r\n")
+	return buffer.Bytes()
+}
+
+func sendMail(cfg *EmailConfig, subject, body string) (err error) {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Second * 10
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if cfg.Security == EmailSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("smtp connect: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+	if cfg.Security == EmailSecurityAuto || cfg.Security == EmailSecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("smtp starttls: %w", err)
+			}
+		} else if cfg.Security == EmailSecuritySTARTTLS {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+	}
+	if cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp server does not support AUTH")
+		}
+		if err = client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %s: %w", cfg.From, err)
+	}
+	if err = client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp mail from %s: %w", from.Address, err)
+	}
+	for _, to := range cfg.To {
+		if err = client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt to %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err = w.Write(buildMessage(cfg, subject, body, time.Now())); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,207 @@
+package push
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+// fakeSMTP is a minimal smtp server, the recipients starting with "reject" are refused
+type fakeSMTP struct {
+	ln       net.Listener
+	auth     bool
+	from     string
+	to       []string
+	data     string
+	authLine string
+}
+
+func newFakeSMTP(t *testing.T, auth bool) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, auth: auth}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	write("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			if s.auth {
+				write("250-localhost")
+				write("250 AUTH PLAIN")
+			} else {
+				write("250 localhost")
+			}
+		case "AUTH":
+			s.authLine = line
+			write("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = line
+			write("250 OK")
+		case "RCPT":
+			if strings.Contains(line, "<reject") {
+				write("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			s.to = append(s.to, line)
+			write("250 OK")
+		case "DATA":
+			write("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			write("250 OK")
+		case "QUIT":
+			write("221 Bye")
+			return
+		default:
+			write("502 Command not implemented")
+		}
+	}
+}
+
+func TestParseEmailConfig(t *testing.T) {
+	Convey("parse email channel key", t, func() {
+		cfg, err := ParseEmailConfig(`{"host":"smtp.example.com","security":"tls","username":"bot@example.com","to":["a@example.com","b@example.com"]}`)
+		So(err, ShouldBeNil)
+		So(cfg.Port, ShouldEqual, 465)
+		So(cfg.From, ShouldEqual, "bot@example.com")
+		So(cfg.To, ShouldResemble, []string{"a@example.com", "b@example.com"})
+
+		_, err = ParseEmailConfig(`{"host":"smtp.example.com","from":"bot@example.com"}`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseEmailConfig(`{"host":"smtp.example.com","from":"bot@example.com","to":["a@example.com"],"security":"ssl"}`)
+		So(err, ShouldNotBeNil)
+		// no smtp server configured globally
+		_, err = ParseEmailConfig("a@example.com,b@example.com")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestMaskEmailKey(t *testing.T) {
+	Convey("smtp passwords are masked on read and kept on update", t, func() {
+		stored := `{"host":"smtp.example.com","port":465,"username":"alert@example.com","password":"secret","to":["ops@example.com"]}`
+		masked := MaskEmailKey(stored)
+		So(masked, ShouldNotContainSubstring, "secret")
+		So(masked, ShouldContainSubstring, EmailPasswordMask)
+		So(MaskEmailKey("ops@example.com"), ShouldEqual, "ops@example.com")
+
+		cfg, err := ParseEmailConfig(UnmaskEmailKey(masked, stored))
+		So(err, ShouldBeNil)
+		So(cfg.Password, ShouldEqual, "secret")
+
+		cfg, err = ParseEmailConfig(UnmaskEmailKey(`{"host":"smtp.example.com","username":"alert@example.com","password":"new","to":["ops@example.com"]}`, stored))
+		So(err, ShouldBeNil)
+		So(cfg.Password, ShouldEqual, "new")
+	})
+}
+
+func TestRenderEmail(t *testing.T) {
+	Convey("render email", t, func() {
+		data := MessageData{
+			Status:   StatusFiring,
+			Name:     "error logs",
+			Exp:      "level='error'",
+			Instance: "ch",
+			Table:    "app",
+			Alerts:   []MessageAlert{{StartsAt: "2022-01-01 00:00:00", Link: "http://localhost:19001/alarm/rules/history?id=1"}},
+			Log:      `{"msg":"<script>"}`,
+			Alarm:    &db.Alarm{Name: "error logs"},
+		}
+		subject, body, err := renderEmail(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(subject, ShouldEqual, "[ClickVisual] 【告警中】error logs")
+		So(body, ShouldContainSubstring, "level=&#39;error&#39;")
+		So(body, ShouldContainSubstring, `<a href="http://localhost:19001/alarm/rules/history?id=1">`)
+		So(body, ShouldContainSubstring, "&lt;script&gt;")
+		So(body, ShouldNotContainSubstring, "告警描述")
+
+		data.Status = StatusResolved
+		subject, body, err = renderEmail(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(subject, ShouldEqual, "[ClickVisual] [Resolved] error logs")
+		So(body, ShouldContainSubstring, "<b>Expression</b>")
+
+		data.Alarm.ResolvedTemplate = "{{.Name}} is back to normal"
+		_, body, err = renderEmail(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(body, ShouldContainSubstring, "<pre style=\"white-space: pre-wrap; word-break: break-all;\">error logs is back to normal</pre>")
+	})
+}
+
+func TestSendMail(t *testing.T) {
+	Convey("send mail to a fake smtp server", t, func() {
+		s := newFakeSMTP(t, true)
+		defer s.ln.Close()
+		cfg, err := ParseEmailConfig(`{"host":"127.0.0.1","port":` + strconv.Itoa(s.port()) +
+			`,"username":"bot@example.com","password":"secret","to":["a@example.com","b@example.com"]}`)
+		So(err, ShouldBeNil)
+		err = sendMail(cfg, "告警", "<b>hello</b>")
+		So(err, ShouldBeNil)
+		So(s.authLine, ShouldStartWith, "AUTH PLAIN")
+		So(s.from, ShouldContainSubstring, "<bot@example.com>")
+		So(s.to, ShouldHaveLength, 2)
+		So(s.data, ShouldContainSubstring, "To: a@example.com, b@example.com\r\n")
+		So(s.data, ShouldContainSubstring, "Subject: =?utf-8?q?")
+		So(s.data, ShouldContainSubstring, "Content-Type: text/html; charset=UTF-8")
+		So(s.data, ShouldContainSubstring, base64.StdEncoding.EncodeToString([]byte("<b>hello</b>")))
+	})
+	Convey("smtp errors are returned", t, func() {
+		s := newFakeSMTP(t, false)
+		defer s.ln.Close()
+		cfg, err := ParseEmailConfig(`{"host":"127.0.0.1","port":` + strconv.Itoa(s.port()) +
+			`,"from":"ClickVisual <bot@example.com>","to":["a@example.com","reject@example.com"]}`)
+		So(err, ShouldBeNil)
+		err = sendMail(cfg, "告警", "hello")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "smtp rcpt to reject@example.com")
+	})
+	Convey("starttls is required", t, func() {
+		s := newFakeSMTP(t, false)
+		defer s.ln.Close()
+		cfg, err := ParseEmailConfig(`{"host":"127.0.0.1","port":` + strconv.Itoa(s.port()) +
+			`,"security":"starttls","from":"bot@example.com","to":["a@example.com"]}`)
+		So(err, ShouldBeNil)
+		err = sendMail(cfg, "告警", "hello")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "STARTTLS")
+	})
+}
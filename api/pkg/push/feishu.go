@@ -2,6 +2,8 @@ package push
 
 import (
 	"errors"
+	"time"
+
 	"github.com/clickvisual/clickvisual/api/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/pkg/model/view"
 	"github.com/clickvisual/clickvisual/api/pkg/push/feishu"
@@ -15,11 +17,31 @@ func (s *FeiShu) Send(notification view.Notification, alarm *db.Alarm,
 	if err != nil {
 		return err
 	}
-	err = s.sendMessage(channel.Key, title, text)
-	if err != nil {
-		return err
+	msg := feishu.NewCardMsg(title, feishu.WARNING)
+	msg.AddElement(text)
+	if oncall := oncallOf(alarm, time.Now()); oncall != nil && oncall.FeishuId != "" {
+		msg.AddAt(oncall.FeishuId)
+	}
+	if values, labels := callbackActions(notification, alarm, channel); len(values) > 0 {
+		msg.AddButtons(feishuButtons(values, labels)...)
 	}
-	return nil
+	return s.sendCard(channel.Key, msg)
+}
+
+func feishuButtons(values []CallbackValue, labels []string) []feishu.ActionsItem {
+	buttons := make([]feishu.ActionsItem, 0, len(values))
+	for i, v := range values {
+		typ := "default"
+		if v.Action == CallbackAcknowledge {
+			typ = "primary"
+		}
+		buttons = append(buttons, feishu.NewButton(labels[i], typ, map[string]interface{}{
+			"action":  v.Action,
+			"alarmId": v.AlarmId,
+			"sig":     v.Sig,
+		}))
+	}
+	return buttons
 }
 
 //sendMessage
@@ -33,6 +55,10 @@ func (s *FeiShu) Send(notification view.Notification, alarm *db.Alarm,
 func (s FeiShu) sendMessage(url string, title, text string) (err error) {
 	msg := feishu.NewCardMsg(title, feishu.WARNING)
 	msg.AddElement(text)
+	return s.sendCard(url, msg)
+}
+
+func (s FeiShu) sendCard(url string, msg *feishu.CardMsg) (err error) {
 	sendMsg, errflag, err := feishu.SendMsg(url, msg)
 	//err 不为空基本为本地问题
 	//err is not empty is basically a local problem
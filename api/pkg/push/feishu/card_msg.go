@@ -37,10 +37,9 @@ type ActionsItem struct {
 		Content string `json:"content"`
 		Tag     string `json:"tag"`
 	} `json:"text"`
-	URL   string `json:"url"`
-	Type  string `json:"type"`
-	Value struct {
-	} `json:"value"`
+	URL   string                 `json:"url,omitempty"`
+	Type  string                 `json:"type"`
+	Value map[string]interface{} `json:"value"` // posted to the request url of the app when the button is clicked
 }
 type Header struct {
 	Title    Body   `json:"title,omitempty"`
@@ -122,7 +121,7 @@ func (c *CardMsg) AddUrl(url string) {
 				},
 				URL:   url,
 				Type:  "primary",
-				Value: struct{}{},
+				Value: map[string]interface{}{},
 			}},
 		},
 	}
@@ -130,6 +129,23 @@ func (c *CardMsg) AddUrl(url string) {
 
 }
 
+// NewButton returns a callback button, the value is posted to the request url of the app
+func NewButton(content, typ string, value map[string]interface{}) ActionsItem {
+	item := ActionsItem{Tag: "button", Type: typ, Value: value}
+	item.Text.Content = content
+	item.Text.Tag = "plain_text"
+	return item
+}
+
+// AddButtons adds a row of buttons
+func (c *CardMsg) AddButtons(buttons ...ActionsItem) {
+	element := Element{
+		Tag:     "action",
+		Actions: &Actions{Actions: buttons},
+	}
+	c.Card.Elements = append(c.Card.Elements, element)
+}
+
 // AddAtAll 增加一个@全体的功能
 //Add an @All function
 func (c *CardMsg) AddAtAll() {
@@ -142,3 +158,15 @@ func (c *CardMsg) AddAtAll() {
 	}
 	c.Card.Elements = append(c.Card.Elements, element)
 }
+
+// AddAt mentions the user of the open_id or the user_id
+func (c *CardMsg) AddAt(id string) {
+	element := Element{
+		Tag: "div",
+		Body: &Body{
+			Content: "<at id=" + id + "></at> \n",
+			Tag:     "lark_md",
+		},
+	}
+	c.Card.Elements = append(c.Card.Elements, element)
+}
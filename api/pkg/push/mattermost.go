@@ -0,0 +1,51 @@
+package push
+
+import (
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// Mattermost posts an attachment to the incoming webhook of Mattermost, the key is the webhook url
+type Mattermost struct{}
+
+type mattermostMessage struct {
+	Username    string                 `json:"username"`
+	IconURL     string                 `json:"icon_url"`
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+type mattermostAttachment struct {
+	Fallback   string `json:"fallback"`
+	Color      string `json:"color"`
+	AuthorName string `json:"author_name"`
+	AuthorIcon string `json:"author_icon"`
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	Footer     string `json:"footer"`
+	FooterIcon string `json:"footer_icon"`
+}
+
+func (m *Mattermost) Send(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (err error) {
+	title, text, err := transformToMarkdown(notification, alarm, channel, oneTheLogs)
+	if err != nil {
+		return err
+	}
+	return postWebhook(channel.Key, mattermostAttachmentMessage(notification.Status, title, text))
+}
+
+func mattermostAttachmentMessage(status, title, text string) *mattermostMessage {
+	return &mattermostMessage{
+		Username: FOOTER,
+		IconURL:  ICON,
+		Attachments: []mattermostAttachment{{
+			Fallback:   title,
+			Color:      statusColor(status),
+			AuthorName: SUBNAME,
+			AuthorIcon: ICON,
+			Title:      title,
+			Text:       text,
+			Footer:     FOOTER,
+			FooterIcon: ICON,
+		}},
+	}
+}
@@ -0,0 +1,30 @@
+package push
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TestMattermost_Send(t *testing.T) {
+	Convey("send an attachment to mattermost", t, func() {
+		var body []byte
+		srv := testChatServer(http.StatusOK, &body)
+		defer srv.Close()
+		channel := &db.AlarmChannel{Key: srv.URL, Locale: LocaleZhCN}
+		err := (&Mattermost{}).Send(view.Notification{Status: StatusFiring}, &db.Alarm{Name: "test"}, channel, "")
+		So(err, ShouldBeNil)
+
+		var msg mattermostMessage
+		So(json.Unmarshal(body, &msg), ShouldBeNil)
+		So(msg.Attachments, ShouldHaveLength, 1)
+		So(msg.Attachments[0].Color, ShouldEqual, COLOR)
+		So(msg.Attachments[0].Fallback, ShouldEqual, msg.Attachments[0].Title)
+		So(msg.Attachments[0].Text, ShouldContainSubstring, "test")
+	})
+}
@@ -0,0 +1,381 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEnUS = "en-US"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusStale    = "stale" // the table of the alarm stopped receiving logs
+)
+
+// AnnotationSuppressed is the common annotation of a summary notification, the number of the other notifications
+// suppressed by the rate limit of the channel
+const AnnotationSuppressed = "suppressed"
+
+// messageZone is the time zone of the times in messages
+var messageZone = time.FixedZone("UTC+8", 8*3600)
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+// MessageData is the data of alert message templates
+type MessageData struct {
+	Status     string // firing or resolved
+	StatusText string // localized status
+	Name       string
+	Desc       string
+	GroupKey   string
+	Exp        string
+	Instance   string
+	Table      string
+	Creator    string
+	Log        string
+	Oncall     *Oncall // user on call of the schedule of the alarm, nil when there is none
+	Suppressed int     // notifications suppressed by the rate limit of the channel since the last one
+	Alerts     []MessageAlert
+
+	Notification view.Notification
+	Alarm        *db.Alarm
+}
+
+type MessageAlert struct {
+	StartsAt    string
+	EndsAt      string
+	Description string
+	Expected    string // expected value of the anomaly alarms, with the normal range from Lower to Upper
+	Lower       string
+	Upper       string
+	Link        string
+	Group       string // values of the group-by fields of the alarm
+	Labels      map[string]string
+}
+
+type messageTemplate struct {
+	Status      map[string]string // localized status
+	Actions     map[string]string // localized buttons of interactive messages
+	Title       string
+	Firing      string
+	Resolved    string
+	Stale       string
+	Summary     string // summary annotation of the alerts of the native evaluator
+	Description string // description annotation of the alerts of the native evaluator
+}
+
+// AnnotationData is the data of the annotation templates
+type AnnotationData struct {
+	Name  string
+	Desc  string
+	Value string
+}
+
+var messageTemplates = map[string]messageTemplate{
+	LocaleZhCN: {
+		Status:      map[string]string{StatusFiring: "告警中", StatusResolved: "已恢复", StatusStale: "数据源中断"},
+		Actions:     map[string]string{CallbackAcknowledge: "认领", CallbackResolve: "解决"},
+		Title:       `【{{.StatusText}}】{{.Name}}`,
+		Summary:     `告警 {{.Name}}`,
+		Description: `{{.Desc}}  (当前值: {{.Value}})`,
+		Firing: `### ClickVisual 告警
+##### 告警名称: {{.Name}}
+{{if .Desc}}##### 告警描述: {{.Desc}}
+{{end}}{{range .Alerts}}##### 表达式: {{$.Exp}}
+
+{{if .Group}}##### 分组：{{.Group}}
+{{end}}##### 首次触发时间：{{.StartsAt}}
+##### 相关实例：{{$.Instance}}
+##### 相关日志库：{{$.Table}}
+##### 状态：{{$.StatusText}}
+##### 创建人 ：{{$.Creator}}
+{{with $.Oncall}}##### 值班人：{{.Nickname}}
+{{end}}##### {{.Description}}
+{{if .Expected}}##### 预期值: {{.Expected}}, 正常范围: {{.Lower}} ~ {{.Upper}}
+{{end}}
+##### 详情: {{.Link}}
+
+{{if $.Log}}##### 日志: {{$.Log}}
+{{end}}{{end}}{{if .Suppressed}}##### 另有 {{.Suppressed}} 条告警因频率限制未发送
+{{end}}`,
+		Resolved: `### ClickVisual 告警恢复
+##### 告警名称: {{.Name}}
+{{if .Desc}}##### 告警描述: {{.Desc}}
+{{end}}{{range .Alerts}}##### 表达式: {{$.Exp}}
+
+{{if .Group}}##### 分组：{{.Group}}
+{{end}}##### 首次触发时间：{{.StartsAt}}
+{{if .EndsAt}}##### 恢复时间：{{.EndsAt}}
+{{end}}##### 相关实例：{{$.Instance}}
+##### 相关日志库：{{$.Table}}
+##### 状态：{{$.StatusText}}
+##### 创建人 ：{{$.Creator}}
+
+##### 详情: {{.Link}}
+
+{{end}}{{if .Suppressed}}##### 另有 {{.Suppressed}} 条告警因频率限制未发送
+{{end}}`,
+		Stale: `### ClickVisual 数据源中断
+##### 告警名称: {{.Name}}
+##### 相关实例：{{.Instance}}
+##### 相关日志库：{{.Table}}
+##### 状态：{{.StatusText}}
+##### 日志库超过 {{index .Notification.CommonAnnotations "freshness"}} 分钟没有新日志，告警暂停评估和通知，恢复写入后自动继续
+{{range .Alerts}}{{if .StartsAt}}##### 最新日志时间：{{.StartsAt}}
+{{end}}{{end}}`,
+	},
+	LocaleEnUS: {
+		Status:      map[string]string{StatusFiring: "Firing", StatusResolved: "Resolved", StatusStale: "Data source stale"},
+		Actions:     map[string]string{CallbackAcknowledge: "Acknowledge", CallbackResolve: "Resolve"},
+		Title:       `[{{.StatusText}}] {{.Name}}`,
+		Summary:     `Alert {{.Name}}`,
+		Description: `{{.Desc}}  (current value: {{.Value}})`,
+		Firing: `### ClickVisual Alert
+##### Alert: {{.Name}}
+{{if .Desc}}##### Description: {{.Desc}}
+{{end}}{{range .Alerts}}##### Expression: {{$.Exp}}
+
+{{if .Group}}##### Group: {{.Group}}
+{{end}}##### Started at: {{.StartsAt}}
+##### Instance: {{$.Instance}}
+##### Table: {{$.Table}}
+##### Status: {{$.StatusText}}
+##### Creator: {{$.Creator}}
+{{with $.Oncall}}##### On call: {{.Nickname}}
+{{end}}##### {{.Description}}
+{{if .Expected}}##### Expected: {{.Expected}}, normal range: {{.Lower}} ~ {{.Upper}}
+{{end}}
+##### Detail: {{.Link}}
+
+{{if $.Log}}##### Log: {{$.Log}}
+{{end}}{{end}}{{if .Suppressed}}##### {{.Suppressed}} more alerts suppressed
+{{end}}`,
+		Resolved: `### ClickVisual Alert Resolved
+##### Alert: {{.Name}}
+{{if .Desc}}##### Description: {{.Desc}}
+{{end}}{{range .Alerts}}##### Expression: {{$.Exp}}
+
+{{if .Group}}##### Group: {{.Group}}
+{{end}}##### Started at: {{.StartsAt}}
+{{if .EndsAt}}##### Resolved at: {{.EndsAt}}
+{{end}}##### Instance: {{$.Instance}}
+##### Table: {{$.Table}}
+##### Status: {{$.StatusText}}
+##### Creator: {{$.Creator}}
+
+##### Detail: {{.Link}}
+
+{{end}}{{if .Suppressed}}##### {{.Suppressed}} more alerts suppressed
+{{end}}`,
+		Stale: `### ClickVisual Data Source Stale
+##### Alert: {{.Name}}
+##### Instance: {{.Instance}}
+##### Table: {{.Table}}
+##### Status: {{.StatusText}}
+##### No logs in the table for {{index .Notification.CommonAnnotations "freshness"}} minutes, the alarm is not evaluated nor notified until the logs come back
+{{range .Alerts}}{{if .StartsAt}}##### Latest log at: {{.StartsAt}}
+{{end}}{{end}}`,
+	},
+}
+
+// Locale returns the locale of the channel, falls back to alarm.locale in config and zh-CN
+func Locale(channel *db.AlarmChannel) string {
+	if channel != nil {
+		if _, ok := messageTemplates[channel.Locale]; ok {
+			return channel.Locale
+		}
+	}
+	if locale := econf.GetString("alarm.locale"); locale != "" {
+		if _, ok := messageTemplates[locale]; ok {
+			return locale
+		}
+	}
+	return LocaleZhCN
+}
+
+// IsLocaleSupported reports whether there are default templates of the locale, empty means the default locale
+func IsLocaleSupported(locale string) bool {
+	if locale == "" {
+		return true
+	}
+	_, ok := messageTemplates[locale]
+	return ok
+}
+
+// ValidateMessageTemplate checks the user-defined template of an alarm by rendering it with a sample alert
+func ValidateMessageTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := renderMessageTemplate(text, sampleMessageData())
+	return err
+}
+
+// transformToMarkdown
+// Description: 提供一个通用的md模式的获取内容的方法, the templates of the alarm take precedence over the defaults of the locale
+func transformToMarkdown(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (title, text string, err error) {
+	data, err := messageDataFrom(notification, alarm, oneTheLogs)
+	if err != nil {
+		return
+	}
+	return renderMessage(Locale(channel), data)
+}
+
+func renderMessage(locale string, data MessageData) (title, text string, err error) {
+	tpl, ok := messageTemplates[locale]
+	if !ok {
+		tpl = messageTemplates[LocaleZhCN]
+	}
+	data.StatusText = tpl.Status[data.Status]
+	body := tpl.Firing
+	if data.Status == StatusStale {
+		body = tpl.Stale
+	} else if data.Status == StatusResolved {
+		body = tpl.Resolved
+		if data.Alarm != nil && data.Alarm.ResolvedTemplate != "" {
+			body = data.Alarm.ResolvedTemplate
+		}
+	} else if data.Alarm != nil && data.Alarm.FiringTemplate != "" {
+		body = data.Alarm.FiringTemplate
+	}
+	if title, err = renderMessageTemplate(tpl.Title, data); err != nil {
+		return
+	}
+	text, err = renderMessageTemplate(body, data)
+	return
+}
+
+// RenderAnnotations renders the summary and the description annotations of an alert with the templates of the default locale,
+// the annotations are built before the channels, and their locales, are known
+func RenderAnnotations(data AnnotationData) (summary, description string) {
+	tpl := messageTemplates[Locale(nil)]
+	summary, err := renderMessageTemplate(tpl.Summary, data)
+	if err != nil {
+		summary = data.Name
+	}
+	description, err = renderMessageTemplate(tpl.Description, data)
+	if err != nil {
+		description = data.Desc
+	}
+	return
+}
+
+func renderMessageTemplate(text string, data interface{}) (string, error) {
+	tpl, err := template.New("message").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid message template: %w", err)
+	}
+	var buffer bytes.Buffer
+	if err = tpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("message template render failed: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+// messageDataFrom collects the information of the alert, alarms without id are the ones of channel tests
+func messageDataFrom(notification view.Notification, alarm *db.Alarm, oneTheLogs string) (data MessageData, err error) {
+	data = MessageData{
+		Status:       StatusFiring,
+		Name:         alarm.Name,
+		Desc:         alarm.Desc,
+		GroupKey:     notification.GroupKey,
+		Log:          oneTheLogs,
+		Notification: notification,
+		Alarm:        alarm,
+	}
+	if notification.Status == StatusResolved || notification.Status == StatusStale {
+		data.Status = notification.Status
+	}
+	data.Suppressed, _ = strconv.Atoi(notification.CommonAnnotations[AnnotationSuppressed])
+	if alarm.ID == 0 {
+		return
+	}
+	condsFilter := egorm.Conds{}
+	condsFilter["alarm_id"] = alarm.ID
+	filters, err := db.AlarmFilterList(condsFilter)
+	if err != nil {
+		return
+	}
+	data.Exp = db.WhereConditionFromFilter(alarm, filters)
+	user, _ := db.UserInfo(alarm.Uid)
+	data.Creator = fmt.Sprintf("%s(%s)", user.Username, user.Nickname)
+	data.Oncall = oncallOf(alarm, time.Now())
+	ins, table, _, _ := db.GetAlarmTableInstanceInfo(alarm.ID)
+	data.Instance = strings.TrimSpace(ins.Name + " " + ins.Desc)
+	data.Table = strings.TrimSpace(table.Name + " " + table.Desc)
+	rootURL := strings.TrimRight(econf.GetString("app.rootURL"), "/")
+	for _, alert := range notification.Alerts {
+		end := alert.StartsAt.Add(time.Minute).Unix()
+		start := alert.StartsAt.Add(-db.UnitMap[alarm.Unit].Duration - time.Minute).Unix()
+		a := MessageAlert{
+			Description: alert.Annotations["description"],
+			Expected:    alert.Annotations["expected"],
+			Lower:       alert.Annotations["lower"],
+			Upper:       alert.Annotations["upper"],
+			Link:        fmt.Sprintf("%s/alarm/rules/history?id=%d&start=%d&end=%d", rootURL, alarm.ID, start, end),
+			Group:       strings.Join(alarm.GroupLabels(alert.Labels), ", "),
+			Labels:      alert.Labels,
+		}
+		if !alert.StartsAt.IsZero() {
+			a.StartsAt = alert.StartsAt.In(messageZone).Format("2006-01-02 15:04:05")
+		}
+		if !alert.EndsAt.IsZero() && alert.EndsAt.After(alert.StartsAt) {
+			a.EndsAt = alert.EndsAt.In(messageZone).Format("2006-01-02 15:04:05")
+		}
+		data.Alerts = append(data.Alerts, a)
+	}
+	return
+}
+
+func sampleMessageData() MessageData {
+	now := time.Now().In(messageZone).Format("2006-01-02 15:04:05")
+	alarm := &db.Alarm{Name: "sample", Desc: "Test the availability of the alarm channel"}
+	return MessageData{
+		Status:     StatusFiring,
+		StatusText: messageTemplates[LocaleZhCN].Status[StatusFiring],
+		Name:       alarm.Name,
+		Desc:       alarm.Desc,
+		GroupKey:   alarm.Name,
+		Exp:        "`level`='error'",
+		Instance:   "sample_instance",
+		Table:      "sample_table",
+		Creator:    "admin(admin)",
+		Log:        `{"level":"error","msg":"sample log"}`,
+		Alerts:     []MessageAlert{{StartsAt: now, EndsAt: now, Description: alarm.Desc, Labels: map[string]string{"alertname": alarm.Name}}},
+		Alarm:      alarm,
+	}
+}
+
+// statusColor is the color of the cards and attachments, the resolved alerts are green
+func statusColor(status string) string {
+	if status == StatusResolved {
+		return "#2e8b57"
+	}
+	return COLOR
+}
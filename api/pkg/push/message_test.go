@@ -0,0 +1,153 @@
+package push
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+func TestRenderMessage(t *testing.T) {
+	Convey("firing and resolved messages are distinguished", t, func() {
+		data := sampleMessageData()
+		title, text, err := renderMessage(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(title, ShouldEqual, "【告警中】sample")
+		So(text, ShouldContainSubstring, "##### 状态：告警中")
+		So(text, ShouldContainSubstring, "##### 日志: ")
+
+		data.Status = StatusResolved
+		title, text, err = renderMessage(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(title, ShouldEqual, "【已恢复】sample")
+		So(text, ShouldContainSubstring, "### ClickVisual 告警恢复")
+		So(text, ShouldContainSubstring, "##### 状态：已恢复")
+		So(text, ShouldContainSubstring, "##### 恢复时间：")
+
+		title, text, err = renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(title, ShouldEqual, "[Resolved] sample")
+		So(text, ShouldContainSubstring, "##### Status: Resolved")
+	})
+	Convey("group values of group-by alarms are shown", t, func() {
+		data := sampleMessageData()
+		_, text, err := renderMessage(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldNotContainSubstring, "##### 分组")
+
+		data.Alerts[0].Group = "service=api"
+		_, text, err = renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "##### Group: service=api")
+	})
+	Convey("summaries show the number of the suppressed notifications", t, func() {
+		data := sampleMessageData()
+		_, text, err := renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldNotContainSubstring, "suppressed")
+
+		data.Suppressed = 12
+		_, text, err = renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "##### 12 more alerts suppressed")
+
+		data.Status = StatusResolved
+		_, text, err = renderMessage(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "##### 另有 12 条告警因频率限制未发送")
+	})
+	Convey("stale messages use the default template", t, func() {
+		data := sampleMessageData()
+		data.Status = StatusStale
+		data.Alarm = &db.Alarm{FiringTemplate: "{{.Name}} {{.StatusText}}"}
+		data.Notification.CommonAnnotations = map[string]string{"freshness": "15"}
+		title, text, err := renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(title, ShouldEqual, "[Data source stale] sample")
+		So(text, ShouldContainSubstring, "No logs in the table for 15 minutes")
+		So(text, ShouldContainSubstring, "##### Latest log at: ")
+
+		data.Alerts[0].StartsAt = ""
+		_, text, err = renderMessage(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "日志库超过 15 分钟没有新日志")
+		So(text, ShouldNotContainSubstring, "最新日志时间")
+	})
+	Convey("templates of the alarm take precedence", t, func() {
+		data := sampleMessageData()
+		data.Alarm = &db.Alarm{FiringTemplate: "{{.Name}} {{.StatusText}}", ResolvedTemplate: "{{.Name}} ok"}
+		_, text, err := renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldEqual, "sample Firing")
+
+		data.Status = StatusResolved
+		_, text, err = renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldEqual, "sample ok")
+	})
+}
+
+func TestLocale(t *testing.T) {
+	Convey("locale of channels", t, func() {
+		So(Locale(nil), ShouldEqual, LocaleZhCN)
+		So(Locale(&db.AlarmChannel{Locale: LocaleEnUS}), ShouldEqual, LocaleEnUS)
+		So(Locale(&db.AlarmChannel{Locale: "fr-FR"}), ShouldEqual, LocaleZhCN)
+		So(IsLocaleSupported(""), ShouldBeTrue)
+		So(IsLocaleSupported("fr-FR"), ShouldBeFalse)
+	})
+}
+
+func TestValidateMessageTemplate(t *testing.T) {
+	Convey("validate templates of alarms", t, func() {
+		So(ValidateMessageTemplate(""), ShouldBeNil)
+		So(ValidateMessageTemplate("{{range .Alerts}}{{.StartsAt}} {{.Link}}{{end}}"), ShouldBeNil)
+		So(ValidateMessageTemplate("{{.Name"), ShouldNotBeNil)
+		So(ValidateMessageTemplate("{{.Unknown}}"), ShouldNotBeNil)
+	})
+}
+
+func TestOncallMention(t *testing.T) {
+	Convey("the user on call is shown and mentioned", t, func() {
+		data := sampleMessageData()
+		_, text, err := renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldNotContainSubstring, "On call")
+
+		data.Oncall = &Oncall{Nickname: "alice", Phone: "13800000000"}
+		_, text, err = renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "##### On call: alice")
+
+		markdown := dingdingMarkdown("title", text, data.Oncall)
+		So(markdown.At.AtMobiles, ShouldResemble, []string{"13800000000"})
+		So(markdown.Markdown.Text, ShouldEndWith, "@13800000000")
+		markdown = dingdingMarkdown("title", text, nil)
+		So(markdown.At.AtMobiles, ShouldBeEmpty)
+	})
+}
+
+func TestRenderAnnotations(t *testing.T) {
+	Convey("annotations of the native alerts are rendered with the default locale", t, func() {
+		summary, description := RenderAnnotations(AnnotationData{Name: "nginx 5xx", Desc: "errors", Value: "60"})
+		So(summary, ShouldEqual, "告警 nginx 5xx")
+		So(description, ShouldEqual, "errors  (当前值: 60)")
+	})
+}
+
+func TestAnomalyMessage(t *testing.T) {
+	Convey("the expected value and the normal range of anomaly alerts are shown", t, func() {
+		data := sampleMessageData()
+		_, text, err := renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldNotContainSubstring, "Expected")
+
+		data.Alerts[0].Expected, data.Alerts[0].Lower, data.Alerts[0].Upper = "100", "50", "150"
+		_, text, err = renderMessage(LocaleEnUS, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "##### Expected: 100, normal range: 50 ~ 150")
+		_, text, err = renderMessage(LocaleZhCN, data)
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, "##### 预期值: 100, 正常范围: 50 ~ 150")
+	})
+}
@@ -0,0 +1,43 @@
+package push
+
+import (
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+)
+
+// Oncall is the user on call shown and mentioned in alert messages, it keeps the credentials of the user out of the templates
+type Oncall struct {
+	Nickname string
+	Email    string
+	Phone    string // mentioned by dingding
+	FeishuId string // mentioned by feishu
+	SlackId  string // mentioned by slack
+}
+
+// oncallOf returns the user on call of the schedule of the alarm, nil when the alarm has no schedule or the schedule has no user
+func oncallOf(alarm *db.Alarm, at time.Time) *Oncall {
+	if alarm == nil || alarm.OncallId == 0 {
+		return nil
+	}
+	schedule, err := db.AlarmOncallInfo(invoker.Db, alarm.OncallId)
+	if err != nil {
+		return nil
+	}
+	uid := schedule.OncallAt(at)
+	if uid == 0 {
+		return nil
+	}
+	user, err := db.UserInfo(uid)
+	if err != nil {
+		return nil
+	}
+	return &Oncall{
+		Nickname: user.Nickname,
+		Email:    user.Email,
+		Phone:    user.Phone,
+		FeishuId: user.FeishuId,
+		SlackId:  user.SlackId,
+	}
+}
@@ -25,11 +25,34 @@ func (s *Slack) Send(notification view.Notification, alarm *db.Alarm,
 	if err != nil {
 		return err
 	}
-	err = s.sendMessage(channel.Key, title, text)
-	if err != nil {
-		return err
+	msg := slackMessage(title, text)
+	if oncall := oncallOf(alarm, time.Now()); oncall != nil && oncall.SlackId != "" {
+		// mentions in the attachments do not notify
+		msg.Text = "<@" + oncall.SlackId + ">"
 	}
-	return nil
+	if values, labels := callbackActions(notification, alarm, channel); len(values) > 0 {
+		if msg.Blocks, err = slackButtons(values, labels); err != nil {
+			return err
+		}
+	}
+	return slack.PostWebhook(channel.Key, msg)
+}
+
+// slackButtons returns the buttons handled by the interactivity request url of the slack app
+func slackButtons(values []CallbackValue, labels []string) (*slack.Blocks, error) {
+	elements := make([]slack.BlockElement, 0, len(values))
+	for i, v := range values {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		button := slack.NewButtonBlockElement(v.Action, string(value), slack.NewTextBlockObject(slack.PlainTextType, labels[i], false, false))
+		if v.Action == CallbackAcknowledge {
+			button.Style = slack.StylePrimary
+		}
+		elements = append(elements, button)
+	}
+	return &slack.Blocks{BlockSet: []slack.Block{slack.NewActionBlock("clickvisual_alarm", elements...)}}, nil
 }
 
 //sendMessage
@@ -41,6 +64,14 @@ func (s *Slack) Send(notification view.Notification, alarm *db.Alarm,
 //  return err
 //
 func (s *Slack) sendMessage(url string, title, text string) (err error) {
+	err = slack.PostWebhook(url, slackMessage(title, text))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func slackMessage(title, text string) *slack.WebhookMessage {
 	attachment := slack.Attachment{
 		Color:         COLOR,
 		AuthorName:    title,
@@ -52,12 +83,7 @@ func (s *Slack) sendMessage(url string, title, text string) (err error) {
 		FooterIcon:    ICON,
 		Ts:            json.Number(strconv.FormatInt(time.Now().Unix(), 10)),
 	}
-	msg := slack.WebhookMessage{
+	return &slack.WebhookMessage{
 		Attachments: []slack.Attachment{attachment},
 	}
-	err = slack.PostWebhook(url, &msg)
-	if err != nil {
-		return err
-	}
-	return nil
 }
@@ -0,0 +1,68 @@
+package push
+
+import (
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// Teams posts an Adaptive Card to the incoming webhook of Microsoft Teams, the key is the webhook url
+type Teams struct{}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []teamsTextBlock  `json:"body"`
+	MSTeams map[string]string `json:"msteams"`
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap"`
+}
+
+func (t *Teams) Send(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (err error) {
+	title, text, err := transformToMarkdown(notification, alarm, channel, oneTheLogs)
+	if err != nil {
+		return err
+	}
+	return postWebhook(channel.Key, teamsCardMessage(notification.Status, title, text))
+}
+
+func teamsCardMessage(status, title, text string) *teamsMessage {
+	// the colors of adaptive cards are named
+	color := "Attention"
+	if status == StatusResolved {
+		color = "Good"
+	}
+	return &teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body: []teamsTextBlock{
+					{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Medium", Color: color, Wrap: true},
+					{Type: "TextBlock", Text: text, Wrap: true},
+				},
+				MSTeams: map[string]string{"width": "Full"},
+			},
+		}},
+	}
+}
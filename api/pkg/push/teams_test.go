@@ -0,0 +1,51 @@
+package push
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+// testChatServer records the body posted to the incoming webhook
+func testChatServer(status int, body *[]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+}
+
+func TestTeams_Send(t *testing.T) {
+	Convey("send an adaptive card to teams", t, func() {
+		var body []byte
+		srv := testChatServer(http.StatusOK, &body)
+		defer srv.Close()
+		channel := &db.AlarmChannel{Key: srv.URL, Locale: LocaleEnUS}
+		err := (&Teams{}).Send(view.Notification{Status: StatusResolved}, &db.Alarm{Name: "test"}, channel, "")
+		So(err, ShouldBeNil)
+
+		var msg teamsMessage
+		So(json.Unmarshal(body, &msg), ShouldBeNil)
+		So(msg.Type, ShouldEqual, "message")
+		So(msg.Attachments, ShouldHaveLength, 1)
+		So(msg.Attachments[0].ContentType, ShouldEqual, "application/vnd.microsoft.card.adaptive")
+		card := msg.Attachments[0].Content
+		So(card.Type, ShouldEqual, "AdaptiveCard")
+		So(card.Body, ShouldHaveLength, 2)
+		So(card.Body[0].Color, ShouldEqual, "Good")
+		So(card.Body[1].Text, ShouldContainSubstring, "test")
+	})
+	Convey("error responses are returned", t, func() {
+		var body []byte
+		srv := testChatServer(http.StatusBadRequest, &body)
+		defer srv.Close()
+		err := (&Teams{}).Send(view.Notification{}, &db.Alarm{Name: "test"}, &db.AlarmChannel{Key: srv.URL}, "")
+		So(err, ShouldNotBeNil)
+	})
+}
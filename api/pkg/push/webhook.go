@@ -0,0 +1,248 @@
+package push
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+const (
+	WebhookSignatureHeader = "X-ClickVisual-Signature"
+	webhookDefaultTemplate = `{{ json . }}`
+)
+
+// WebhookConfig is stored as json in AlarmChannel.Key
+type WebhookConfig struct {
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`
+	Headers         map[string]string `json:"headers"`
+	Template        string            `json:"template"`        // text/template of the body, WebhookData is the data
+	Secret          string            `json:"secret"`          // the body is signed with hmac-sha256 when not empty
+	SignatureHeader string            `json:"signatureHeader"` // default X-ClickVisual-Signature, the value is sha256=<hex>
+	Timeout         int               `json:"timeout"`         // seconds
+}
+
+// WebhookData is the data of webhook templates
+type WebhookData struct {
+	Notification view.Notification    `json:"notification"`
+	Alarm        view.WebhookAlarm    `json:"alarm"`
+	Table        view.WebhookTable    `json:"table"`
+	Instance     view.WebhookInstance `json:"instance"`
+	Status       string               `json:"status"`
+	Exp          string               `json:"exp"`
+	Link         string               `json:"link"`
+	Log          string               `json:"log"`
+	Title        string               `json:"title"` // message rendered with the templates of the channel locale
+	Text         string               `json:"text"`
+}
+
+type Webhook struct{}
+
+func (w *Webhook) Send(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (err error) {
+	cfg, payload, err := w.Render(notification, alarm, channel, oneTheLogs)
+	if err != nil {
+		return
+	}
+	return w.sendMessage(cfg, payload)
+}
+
+func (w *Webhook) Preview(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (payload string, err error) {
+	_, payload, err = w.Render(notification, alarm, channel, oneTheLogs)
+	return
+}
+
+// Render returns the config of the channel and the payload rendered from the alert
+func (w *Webhook) Render(notification view.Notification, alarm *db.Alarm, channel *db.AlarmChannel, oneTheLogs string) (cfg *WebhookConfig, payload string, err error) {
+	cfg, err = ParseWebhookConfig(channel.Key)
+	if err != nil {
+		return
+	}
+	data, err := webhookDataFrom(notification, alarm, Locale(channel), oneTheLogs)
+	if err != nil {
+		return
+	}
+	payload, err = renderWebhook(cfg, data)
+	return
+}
+
+// ParseWebhookConfig parses the channel key and checks the template by rendering it with a sample alert
+func ParseWebhookConfig(key string) (*WebhookConfig, error) {
+	var cfg WebhookConfig
+	if err := json.Unmarshal([]byte(key), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+	err := ValidateWebhookURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Method = strings.ToUpper(cfg.Method)
+	switch cfg.Method {
+	case "":
+		cfg.Method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodGet:
+	default:
+		return nil, fmt.Errorf("invalid webhook method: %s", cfg.Method)
+	}
+	if cfg.Template == "" {
+		cfg.Template = webhookDefaultTemplate
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = WebhookSignatureHeader
+	}
+	if _, err = renderWebhook(&cfg, webhookSampleData()); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ValidateWebhookURL checks the url of the incoming webhooks is absolute http or https
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: %s", raw)
+	}
+	return nil
+}
+
+func renderWebhook(cfg *WebhookConfig, data WebhookData) (string, error) {
+	tpl, err := template.New("webhook").Funcs(templateFuncs).Option("missingkey=zero").Parse(cfg.Template)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook template: %w", err)
+	}
+	var buffer bytes.Buffer
+	if err = tpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("webhook template render failed: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+func webhookDataFrom(notification view.Notification, alarm *db.Alarm, locale, oneTheLogs string) (data WebhookData, err error) {
+	if alarm.ID == 0 {
+		// channel test, the payload is rendered with a sample alert
+		data = webhookSampleData()
+		data.Alarm.Name = alarm.Name
+		data.Alarm.Desc = alarm.Desc
+		return
+	}
+	md, err := messageDataFrom(notification, alarm, oneTheLogs)
+	if err != nil {
+		return
+	}
+	data = WebhookData{
+		Notification: notification,
+		Alarm:        webhookAlarm(alarm),
+		Status:       notification.Status,
+		Exp:          md.Exp,
+		Log:          oneTheLogs,
+	}
+	if data.Title, data.Text, err = renderMessage(locale, md); err != nil {
+		return
+	}
+	ins, table, _, _ := db.GetAlarmTableInstanceInfo(alarm.ID)
+	data.Instance = view.WebhookInstance{ID: ins.ID, Name: ins.Name, Datasource: ins.Datasource, Desc: ins.Desc}
+	data.Table = view.WebhookTable{ID: table.ID, Name: table.Name, Desc: table.Desc}
+	if table.Database != nil {
+		data.Table.Database = table.Database.Name
+	}
+	data.Link = fmt.Sprintf("%s/alarm/rules/history?id=%d", strings.TrimRight(econf.GetString("app.rootURL"), "/"), alarm.ID)
+	if len(notification.Alerts) > 0 {
+		alert := notification.Alerts[0]
+		data.Link += fmt.Sprintf("&start=%d&end=%d",
+			alert.StartsAt.Add(-db.UnitMap[alarm.Unit].Duration-time.Minute).Unix(), alert.StartsAt.Add(time.Minute).Unix())
+	}
+	return
+}
+
+// webhookAlarm keeps the fields of the alarm that are safe to send to the external urls
+func webhookAlarm(alarm *db.Alarm) view.WebhookAlarm {
+	return view.WebhookAlarm{ID: alarm.ID, Uuid: alarm.Uuid, Name: alarm.Name, Desc: alarm.Desc, Level: alarm.Level, Tags: alarm.Tags}
+}
+
+func webhookSampleData() WebhookData {
+	now := time.Now()
+	title, text, _ := renderMessage(LocaleZhCN, sampleMessageData())
+	alarm := &db.Alarm{Name: "sample", Desc: "Test the availability of the alarm channel", Uuid: "00000000-0000-0000-0000-000000000000"}
+	labels := map[string]string{"alertname": alarm.Name, "severity": alarm.Severity(), "uuid": alarm.Uuid}
+	summary, description := RenderAnnotations(AnnotationData{Name: alarm.Name, Desc: alarm.Desc, Value: "1"})
+	annotations := map[string]string{"summary": summary, "description": description, "value": "1"}
+	return WebhookData{
+		Notification: view.Notification{
+			Version:           "4",
+			GroupKey:          alarm.Name,
+			Status:            "firing",
+			Receiver:          "clickvisual",
+			GroupLabels:       map[string]string{"alertname": alarm.Name},
+			CommonLabels:      labels,
+			CommonAnnotations: annotations,
+			Alerts:            []view.Alert{{Labels: labels, Annotations: annotations, StartsAt: now}},
+		},
+		Alarm:    webhookAlarm(alarm),
+		Table:    view.WebhookTable{Name: "sample_table", Database: "sample_database"},
+		Instance: view.WebhookInstance{Name: "sample_instance", Datasource: "ch"},
+		Status:   "firing",
+		Exp:      "`level`='error'",
+		Link:     strings.TrimRight(econf.GetString("app.rootURL"), "/") + "/alarm/rules",
+		Log:      `{"level":"error","msg":"sample log"}`,
+		Title:    title,
+		Text:     text,
+	}
+}
+
+// sign returns the hex encoded hmac-sha256 of the body
+func (c *WebhookConfig) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postWebhook posts the json of the payload to the incoming webhook of the chat apps
+func postWebhook(url string, payload interface{}) (err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	return (&Webhook{}).sendMessage(&WebhookConfig{URL: url, Method: http.MethodPost}, string(data))
+}
+
+func (w *Webhook) sendMessage(cfg *WebhookConfig, payload string) (err error) {
+	req, err := http.NewRequest(cfg.Method, cfg.URL, strings.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if cfg.Secret != "" {
+		req.Header.Set(cfg.SignatureHeader, cfg.sign([]byte(payload)))
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Second * 10
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook response %s: %s", resp.Status, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package push
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/pkg/model/view"
+)
+
+func TestParseWebhookConfig(t *testing.T) {
+	Convey("parse webhook channel key", t, func() {
+		cfg, err := ParseWebhookConfig(`{"url":"https://example.com/hook"}`)
+		So(err, ShouldBeNil)
+		So(cfg.Method, ShouldEqual, http.MethodPost)
+		So(cfg.SignatureHeader, ShouldEqual, WebhookSignatureHeader)
+
+		_, err = ParseWebhookConfig(`{"url":"example.com/hook"}`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseWebhookConfig(`{"url":"https://example.com/hook","method":"DELETE"}`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseWebhookConfig(`{"url":"https://example.com/hook","template":"{{ .Alarm.Name "}`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseWebhookConfig(`{"url":"https://example.com/hook","template":"{{ .Unknown }}"}`)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	Convey("the default payload has no credentials of the instance", t, func() {
+		cfg, err := ParseWebhookConfig(`{"url":"https://example.com/hook"}`)
+		So(err, ShouldBeNil)
+		payload, err := renderWebhook(cfg, webhookSampleData())
+		So(err, ShouldBeNil)
+		So(payload, ShouldContainSubstring, `"instance":{"id":0,"name":"sample_instance"`)
+		So(payload, ShouldNotContainSubstring, "dsn")
+		So(payload, ShouldNotContainSubstring, "sqlData")
+	})
+}
+
+func TestWebhook_Send(t *testing.T) {
+	Convey("send rendered payload with signature", t, func() {
+		var (
+			body   []byte
+			header http.Header
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer srv.Close()
+		key, _ := json.Marshal(WebhookConfig{
+			URL:      srv.URL,
+			Headers:  map[string]string{"X-Token": "abc"},
+			Template: `{"title":"{{ .Alarm.Name }}","status":"{{ .Status }}","table":"{{ .Table.Name }}","alerts":{{ len .Notification.Alerts }}}`,
+			Secret:   "secret",
+		})
+		w := &Webhook{}
+		channel := &db.AlarmChannel{Key: string(key)}
+		payload, err := w.Preview(view.Notification{}, &db.Alarm{Name: "test"}, channel, "")
+		So(err, ShouldBeNil)
+		So(payload, ShouldEqual, `{"title":"test","status":"firing","table":"sample_table","alerts":1}`)
+
+		err = w.Send(view.Notification{}, &db.Alarm{Name: "test"}, channel, "")
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, payload)
+		So(header.Get("X-Token"), ShouldEqual, "abc")
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		So(header.Get(WebhookSignatureHeader), ShouldEqual, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	})
+	Convey("error responses are returned", t, func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad payload"))
+		}))
+		defer srv.Close()
+		err := (&Webhook{}).Send(view.Notification{}, &db.Alarm{Name: "test"}, &db.AlarmChannel{Key: `{"url":"` + srv.URL + `"}`}, "")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "bad payload")
+	})
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/cityhash102"
+)
+
+// SipHash64 returns the same value as sipHash64(s) of clickhouse, which is SipHash-2-4 with a zero key
+func SipHash64(s string) uint64 {
+	v0, v1, v2, v3 := uint64(0x736f6d6570736575), uint64(0x646f72616e646f6d), uint64(0x6c7967656e657261), uint64(0x7465646279746573)
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	compress := func(m uint64) {
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	b := []byte(s)
+	for ; len(b) >= 8; b = b[8:] {
+		compress(binary.LittleEndian.Uint64(b))
+	}
+	var last [8]byte
+	copy(last[:], b)
+	last[7] = byte(len(s))
+	compress(binary.LittleEndian.Uint64(last[:]))
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+// URLHash returns the same value as URLHash(s) of clickhouse, the trailing '/', '?' or '#' is ignored
+func URLHash(s string) uint64 {
+	if n := len(s); n > 0 && (s[n-1] == '/' || s[n-1] == '?' || s[n-1] == '#') {
+		s = s[:n-1]
+	}
+	return cityhash102.CityHash64([]byte(s), uint32(len(s)))
+}
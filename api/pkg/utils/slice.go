@@ -134,6 +134,16 @@ func StringSliceWithoutRepeat(source []string, notContainEmpty bool) []string {
 	return res
 }
 
+// IntSliceContains 判断 []int 中是否包含 item
+func IntSliceContains(source []int, item int) bool {
+	for _, v := range source {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
 func IsSliceEqual(a, b interface{}) bool {
 	if (a == nil) && (b == nil) {
 		return true
@@ -489,6 +489,10 @@ export default {
   "alarm.rules.form.level.notice": "Notice",
   "alarm.rules.form.level.serious": "Serious",
   "alarm.rules.table.logLibrary": "Associated log library",
+  "alarm.rules.table.sync": "Rule sync",
+  "alarm.rules.table.sync.pending": "Pending",
+  "alarm.rules.table.sync.synced": "Synced",
+  "alarm.rules.table.sync.failed": "Failed",
   "alarm.rules.form.title": "Alarm Monitoring Rule",
   "alarm.rules.form.alarmName": "Alarm Name",
   "alarm.rules.form.description": "Alarm Description",
@@ -557,6 +561,7 @@ export default {
   "alarm.rules.state.alerting": "alerting",
   "alarm.rules.state.ok": "ok",
   "alarm.rules.state.paused": "paused",
+  "alarm.rules.state.stale": "data source stale",
 
   "alarm.rules.historyBorad.theLog": "The log",
   "alarm.rules.historyBorad.toView": "Viewing Log Details",
@@ -457,6 +457,10 @@ export default {
   "alarm.rules.button.created": "新增报警",
   "alarm.rules.table.alarmName": "报警名称",
   "alarm.rules.table.logLibrary": "关联日志库",
+  "alarm.rules.table.sync": "规则同步",
+  "alarm.rules.table.sync.pending": "同步中",
+  "alarm.rules.table.sync.synced": "已同步",
+  "alarm.rules.table.sync.failed": "同步失败",
   "alarm.rules.form.title": "报警监控规则",
   "alarm.rules.form.alarmName": "报警名称",
   "alarm.rules.form.level": "报警级别",
@@ -524,6 +528,7 @@ export default {
   "alarm.rules.state.alerting": "正在报警",
   "alarm.rules.state.ok": "正常",
   "alarm.rules.state.paused": "暂停",
+  "alarm.rules.state.stale": "数据源中断",
 
   "alarm.rules.historyBorad.theLog": "日志",
   "alarm.rules.historyBorad.toView": "查看日志详情",
@@ -51,6 +51,10 @@ const useAlarmOperations = () => {
     onSuccess: (res) => setDatabaseList(res.data || []),
   });
 
+  // instances missing from the list are treated as supported, the api checks again
+  const isAlarmSupported = (iid: number) =>
+    instanceList.find((item) => item.id === iid)?.capability?.alarm !== false;
+
   const onChangeInputName = (name: string | undefined) => {
     setInputName(name);
   };
@@ -82,6 +86,7 @@ const useAlarmOperations = () => {
     getDatabases,
     instanceList,
     getInstanceList,
+    isAlarmSupported,
     searchQuery,
     onChangeInputName,
     onChangeSelectIid,
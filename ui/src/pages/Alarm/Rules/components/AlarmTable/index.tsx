@@ -264,6 +264,25 @@ const AlarmTable = () => {
         );
       },
     },
+    {
+      title: i18n.formatMessage({ id: "alarm.rules.table.sync" }),
+      dataIndex: "syncStatus",
+      width: 100,
+      align: "center",
+      render: (value: number, record: AlarmType) => {
+        const syncStatus = ["", "pending", "synced", "failed"][value];
+        if (!syncStatus) return <>-</>;
+        return (
+          <Tooltip title={record.syncMsg}>
+            <span>
+              {i18n.formatMessage({
+                id: `alarm.rules.table.sync.${syncStatus}`,
+              })}
+            </span>
+          </Tooltip>
+        );
+      },
+    },
     {
       title: i18n.formatMessage({ id: "operation" }),
       dataIndex: "operations",
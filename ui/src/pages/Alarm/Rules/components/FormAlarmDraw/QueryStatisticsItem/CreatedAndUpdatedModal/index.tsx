@@ -423,23 +423,25 @@ const CreatedAndUpdatedModal = ({
                 modalForm.current?.resetFields(["tableId"]);
               }}
             >
-              {databaseList.map((database) => (
-                <Option key={database.id} value={database.id}>
-                  {i18n.formatMessage(
-                    { id: "alarm.rules.inspectionFrequency.database.Option" },
-                    {
-                      instance:
-                        database.instanceName +
-                        (database.instanceDesc
-                          ? ` | ${database.instanceDesc}`
-                          : ""),
-                      database:
-                        database.name +
-                        (database.desc ? ` | ${database.desc}` : ""),
-                    }
-                  )}
-                </Option>
-              ))}
+              {databaseList
+                .filter((database) => operations.isAlarmSupported(database.iid))
+                .map((database) => (
+                  <Option key={database.id} value={database.id}>
+                    {i18n.formatMessage(
+                      { id: "alarm.rules.inspectionFrequency.database.Option" },
+                      {
+                        instance:
+                          database.instanceName +
+                          (database.instanceDesc
+                            ? ` | ${database.instanceDesc}`
+                            : ""),
+                        database:
+                          database.name +
+                          (database.desc ? ` | ${database.desc}` : ""),
+                      }
+                    )}
+                  </Option>
+                ))}
             </Select>
           </Form.Item>
           <Form.Item
@@ -113,9 +113,9 @@ const TriggerConditionItem = ({
                         }
                       >
                         {({ getFieldValue }) => {
-                          const condFlag =
-                            getFieldValue(["conditions", field.name, "cond"]) >
-                            1;
+                          const condFlag = [2, 3].includes(
+                            getFieldValue(["conditions", field.name, "cond"])
+                          );
 
                           return (
                             <Space>
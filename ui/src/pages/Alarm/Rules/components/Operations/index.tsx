@@ -210,9 +210,16 @@ const Operations = () => {
             </Option>
           ))}
         </Select>
-        <Button icon={<PlusOutlined />} type="primary" onClick={handleOpenDraw}>
-          {i18n.formatMessage({ id: "alarm.rules.button.created" })}
-        </Button>
+        {(!operations.selectIid ||
+          operations.isAlarmSupported(operations.selectIid)) && (
+          <Button
+            icon={<PlusOutlined />}
+            type="primary"
+            onClick={handleOpenDraw}
+          >
+            {i18n.formatMessage({ id: "alarm.rules.button.created" })}
+          </Button>
+        )}
       </Space>
       <Space>
         <Input
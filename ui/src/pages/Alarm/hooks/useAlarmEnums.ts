@@ -26,6 +26,12 @@ const useAlarmEnums = () => {
       color: "#b22e33",
       icon: "icon-love-failure",
     },
+    {
+      status: 4,
+      label: i18n.formatMessage({ id: "alarm.rules.state.stale" }),
+      color: "#e69a24",
+      icon: "icon-suspended",
+    },
   ];
   return { ChannelTypes, AlarmStatus };
 };
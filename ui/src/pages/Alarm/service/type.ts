@@ -16,8 +16,10 @@ export const typList = [
   { key: 2, label: "OR" },
 ];
 
+// 0 is the latest sample, the value the alarms created before the selectors are evaluated with
 export const expList = [
-  { key: 0, label: "avg()" },
+  { key: 0, label: "last()" },
+  { key: 5, label: "avg()" },
   { key: 1, label: "min()" },
   { key: 2, label: "max()" },
   { key: 3, label: "sum()" },
@@ -29,4 +31,8 @@ export const condList = [
   { key: 1, label: "below" },
   { key: 2, label: "outside range" },
   { key: 3, label: "within range" },
+  { key: 4, label: "rate above" },
+  { key: 5, label: "rate below" },
+  { key: 6, label: "increase % above" },
+  { key: 7, label: "decrease % above" },
 ];
@@ -5,9 +5,14 @@ import { PlusSquareOutlined } from "@ant-design/icons";
 import { Dropdown, Menu, message, Tooltip } from "antd";
 import MenuItem from "antd/es/menu/MenuItem";
 import { useIntl, useModel } from "umi";
+import { CapabilityType } from "@/services/systemSetting";
 
-const DatabaseItem = (props: { databasesItem: any; onGetList: any }) => {
-  const { databasesItem, onGetList } = props;
+const DatabaseItem = (props: {
+  databasesItem: any;
+  onGetList: any;
+  capability?: CapabilityType;
+}) => {
+  const { databasesItem, onGetList, capability } = props;
   const i18n = useIntl();
   const {
     // currentDatabase,
@@ -78,27 +83,32 @@ const DatabaseItem = (props: { databasesItem: any; onGetList: any }) => {
       >
         {i18n.formatMessage({ id: "datasource.draw.table.edit.tip" })}
       </MenuItem>
-      <MenuItem
-        icon={<PlusSquareOutlined style={{ color: "#000" }} />}
-        onClick={() => {
-          onChangeAddLogToDatabase(databasesItem);
-          onChangeLogLibraryCreatedModalVisible(true);
-        }}
-      >
-        {i18n.formatMessage({
-          id: "datasource.draw.table.operation.tip",
-        })}
-      </MenuItem>
-      <MenuItem
-        icon={<IconFont type={"icon-delete"} />}
-        onClick={() => {
-          doDeletedDatabase(databasesItem);
-        }}
-      >
-        <span style={{ color: "#de6464" }}>
-          {i18n.formatMessage({ id: "datasource.draw.table.delete.tip" })}
-        </span>
-      </MenuItem>
+      {capability?.ddl !== false && (
+        <MenuItem
+          icon={<PlusSquareOutlined style={{ color: "#000" }} />}
+          onClick={() => {
+            onChangeAddLogToDatabase(databasesItem);
+            onChangeLogLibraryCreatedModalVisible(true);
+          }}
+        >
+          {i18n.formatMessage({
+            id: "datasource.draw.table.operation.tip",
+          })}
+        </MenuItem>
+      )}
+      {/* databases not created by clickvisual are only unregistered, the datasource drops nothing */}
+      {(capability?.ddl !== false || !databasesItem.isCreateByCV) && (
+        <MenuItem
+          icon={<IconFont type={"icon-delete"} />}
+          onClick={() => {
+            doDeletedDatabase(databasesItem);
+          }}
+        >
+          <span style={{ color: "#de6464" }}>
+            {i18n.formatMessage({ id: "datasource.draw.table.delete.tip" })}
+          </span>
+        </MenuItem>
+      )}
     </Menu>
   );
 
@@ -30,14 +30,17 @@ import useUrlState from "@ahooksjs/use-url-state";
 import { PaneType } from "@/models/datalogs/types";
 import MenuItem from "antd/es/menu/MenuItem";
 import { ALARMRULES_PATH } from "@/config/config";
+import { CapabilityType } from "@/services/systemSetting";
 
 type LogLibraryItemProps = {
   logLibrary: TablesResponse;
   onGetList: any;
+  // features of the datasource of the instance, the unsupported ones are hidden
+  capability?: CapabilityType;
 };
 
 const LogLibraryItem = (props: LogLibraryItemProps) => {
-  const { logLibrary, onGetList } = props;
+  const { logLibrary, onGetList, capability } = props;
   const [, setUrlState] = useUrlState();
   const { resizeMenuWidth } = useModel("dataLogs");
   const {
@@ -201,51 +204,60 @@ const LogLibraryItem = (props: LogLibraryItemProps) => {
           {i18n.formatMessage({ id: "datasource.tooltip.icon.edit" })}
         </span>
       </MenuItem>
-      <MenuItem
-        icon={<CalendarOutlined />}
-        onClick={async () => {
-          window.open(await getGoToAlarmRulesPagePathByid(), "_blank");
-        }}
-      >
-        <span>
-          {i18n.formatMessage({ id: "datasource.tooltip.icon.alarmRuleList" })}
-        </span>
-      </MenuItem>
-      <MenuItem
-        icon={<FundViewOutlined />}
-        disabled={logLibrary.createType !== 0}
-        onClick={() => {
-          onChangeViewsVisibleDraw(true);
-        }}
-      >
-        <span>
-          {i18n.formatMessage({
-            id: "datasource.tooltip.icon.view",
-          })}
-        </span>
-      </MenuItem>
-      <MenuItem
-        icon={<IconFont type={"icon-delete"} />}
-        onClick={() => {
-          deletedModal({
-            onOk: () => {
-              doDeleted();
-            },
-            content: i18n.formatMessage(
-              {
-                id: "datasource.logLibrary.deleted.content",
+      {capability?.alarm !== false && (
+        <MenuItem
+          icon={<CalendarOutlined />}
+          onClick={async () => {
+            window.open(await getGoToAlarmRulesPagePathByid(), "_blank");
+          }}
+        >
+          <span>
+            {i18n.formatMessage({
+              id: "datasource.tooltip.icon.alarmRuleList",
+            })}
+          </span>
+        </MenuItem>
+      )}
+      {capability?.ddl !== false && (
+        <MenuItem
+          icon={<FundViewOutlined />}
+          disabled={logLibrary.createType !== 0}
+          onClick={() => {
+            onChangeViewsVisibleDraw(true);
+          }}
+        >
+          <span>
+            {i18n.formatMessage({
+              id: "datasource.tooltip.icon.view",
+            })}
+          </span>
+        </MenuItem>
+      )}
+      {/* tables not created by clickvisual are only unregistered, the datasource drops nothing */}
+      {(capability?.ddl !== false || logLibrary.createType !== 0) && (
+        <MenuItem
+          icon={<IconFont type={"icon-delete"} />}
+          onClick={() => {
+            deletedModal({
+              onOk: () => {
+                doDeleted();
               },
-              { logLibrary: logLibrary.tableName }
-            ),
-          });
-        }}
-      >
-        <span className={logLibraryListStyles.deletedSpan}>
-          {i18n.formatMessage({
-            id: "datasource.tooltip.icon.deleted",
-          })}
-        </span>
-      </MenuItem>
+              content: i18n.formatMessage(
+                {
+                  id: "datasource.logLibrary.deleted.content",
+                },
+                { logLibrary: logLibrary.tableName }
+              ),
+            });
+          }}
+        >
+          <span className={logLibraryListStyles.deletedSpan}>
+            {i18n.formatMessage({
+              id: "datasource.tooltip.icon.deleted",
+            })}
+          </span>
+        </MenuItem>
+      )}
     </Menu>
   );
 
@@ -63,6 +63,7 @@ const DataSourceMenu = () => {
                       logLibrary={tablesItem}
                       key={`table-${tablesItem.id}`}
                       onGetList={getList}
+                      capability={item.capability}
                     />
                   ),
                   key: `table-${tablesItem.id}`,
@@ -78,6 +79,7 @@ const DataSourceMenu = () => {
                 <DatabaseItem
                   databasesItem={databasesItem}
                   onGetList={getList}
+                  capability={item.capability}
                 />
               ),
               key: `databases-${databasesItem.id}`,
@@ -31,6 +31,8 @@ export interface AlarmType extends TimeBaseType {
   uid: number;
   channelIds: number[];
   status: number;
+  syncStatus: number;
+  syncMsg: string;
 }
 
 export interface AlarmFilterType extends TimeBaseType {
@@ -4,6 +4,12 @@ export interface TimeBaseType {
   ctime?: number;
   dtime?: number;
 }
+// features supported by the datasource of an instance, the unsupported ones are hidden
+export interface CapabilityType {
+  alarm: boolean;
+  ddl: boolean;
+}
+
 export interface InstanceType extends TimeBaseType {
   mode: number;
   id?: number;
@@ -16,6 +22,7 @@ export interface InstanceType extends TimeBaseType {
   prometheusTarget?: string;
   clusters?: string[];
   desc?: string;
+  capability?: CapabilityType;
 }
 
 export interface TestInstanceRequest {